@@ -1,16 +1,31 @@
 package main
 
 import (
+	"context"
+	"gin-simple-app/internal/address"
+	"gin-simple-app/internal/cache"
 	"gin-simple-app/internal/config"
 	"gin-simple-app/internal/database"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/graph"
+	"gin-simple-app/internal/grpcapi"
 	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/idempotency"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/internal/ratelimit"
 	"gin-simple-app/internal/repository"
 	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/server"
 	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/webhooks"
+	"gin-simple-app/pkg/response"
 	"log"
+	"net"
 	"os"
 
 	"github.com/gin-gonic/gin"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
 )
 
 func main() {
@@ -23,6 +38,19 @@ func main() {
 	// Set Gin mode
 	gin.SetMode(cfg.Server.GinMode)
 
+	// Set the region used to parse phone numbers without a country code
+	if err := phone.SetDefaultRegion(cfg.Phone.DefaultRegion); err != nil {
+		log.Fatal("Invalid phone configuration:", err)
+	}
+
+	// Set the country assumed when parsing free-text addresses
+	if err := address.SetDefaultCountry(cfg.Address.DefaultCountry); err != nil {
+		log.Fatal("Invalid address configuration:", err)
+	}
+
+	// Choose between the standard error format and RFC 7807 problem details
+	response.SetProblemDetails(cfg.Response.ErrorFormat == "problem", cfg.Response.ProblemTypeBaseURL)
+
 	// Initialize database connection
 	if err := database.Connect(&cfg.Database); err != nil {
 		log.Printf("Failed to connect to database: %v", err)
@@ -41,25 +69,57 @@ func runWithDatabaseRepository(cfg *config.Config) {
 	log.Println("Using database repository (GORM + PostgreSQL)")
 	
 	// Initialize repository with database
-	userRepo := repository.NewGormUserRepository(database.GetDB())
+	rowLevelSecurity := repository.WithRowLevelSecurity(cfg.Database.RowLevelSecurity)
+	userRepo := repository.NewGormUserRepository(database.GetDB(), rowLevelSecurity)
+	auditRepo := repository.NewGormAuditRepository(database.GetDB())
+	outboxRepo := repository.NewGormOutboxRepository(database.GetDB())
+	webhookRepo := repository.NewGormWebhookRepository(database.GetDB())
+	uow := repository.NewGormUnitOfWork(database.GetDB(), rowLevelSecurity)
+	tenantRepo := repository.NewGormTenantRepository(database.GetDB())
+
+	// Relay user events from the outbox to the configured sinks and webhooks
+	startRelay(cfg, outboxRepo, webhookRepo)
+	broadcaster := startBroadcaster(cfg, outboxRepo)
 
-	// Initialize service
-	userService := services.NewUserService(userRepo)
+	// Initialize services
+	cachedUserRepo, uow, healthOpts := cacheUsers(cfg, userRepo, uow)
+	userService := services.NewUserService(cachedUserRepo, services.WithAuditLog(auditRepo, uow))
+	webhookService := services.NewWebhookService(webhookRepo, services.WithTargetPolicy(cfg.Webhooks.Targets))
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
-	healthHandler := handlers.NewHealthHandler()
+	healthHandler := handlers.NewHealthHandler(healthOpts...)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	eventStreamHandler := handlers.NewEventStreamHandler(broadcaster, cfg.EventStream.Heartbeat)
+	graphQLHandler := newGraphQLHandler(userService)
 
 	// Initialize router
-	appRouter := router.NewRouter(userHandler, healthHandler)
+	appRouter := router.NewRouter(userHandler, healthHandler,
+		router.WithIdempotency(idempotency.NewGormStore(database.GetDB()), cfg.Idempotency.TTL),
+		router.WithRateLimit(ratelimit.NewMemoryStore(), cfg.RateLimit),
+		router.WithCORS(cfg.CORS),
+		router.WithSecurityHeaders(cfg.Security),
+		router.WithCompression(cfg.Compression),
+		router.WithTrustedProxies(cfg.Server.TrustedProxies),
+		router.WithTenants(tenantRepo, cfg.Tenants),
+		router.WithWebhooks(webhookHandler),
+		router.WithEventStream(eventStreamHandler),
+		router.WithGraphQL(graphQLHandler),
+		router.WithOpenAPIValidation(cfg.Server.OpenAPIValidation),
+		router.WithDeprecations(cfg.Deprecations),
+	)
 
 	// Setup routes
 	engine := appRouter.SetupRoutes()
 
 	// Start server
-	port := ":" + cfg.Server.Port
-	log.Printf("Starting server on %s (Database mode)", port)
-	if err := engine.Run(port); err != nil {
+	srv, err := server.New(engine, cfg.Server)
+	if err != nil {
+		log.Fatal("Invalid server configuration:", err)
+	}
+	startGRPC(cfg, srv, userService, broadcaster, tenantRepo)
+	log.Printf("Starting %s server on :%s (Database mode)", serverScheme(srv), cfg.Server.Port)
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 
@@ -76,28 +136,148 @@ func runWithInMemoryRepository(cfg *config.Config) {
 	
 	// Initialize repository with in-memory storage
 	userRepo := repository.NewInMemoryUserRepository()
+	auditRepo := repository.NewInMemoryAuditRepository()
+	outboxRepo := repository.NewInMemoryOutboxRepository()
+	webhookRepo := repository.NewInMemoryWebhookRepository()
+	uow := repository.NewInMemoryUnitOfWork(userRepo, auditRepo, outboxRepo)
+	tenantRepo := repository.NewInMemoryTenantRepository()
+
+	// Relay user events from the outbox to the configured sinks and webhooks
+	startRelay(cfg, outboxRepo, webhookRepo)
+	broadcaster := startBroadcaster(cfg, outboxRepo)
 
-	// Initialize service
-	userService := services.NewUserService(userRepo)
+	// Initialize services
+	cachedUserRepo, uow, healthOpts := cacheUsers(cfg, userRepo, uow)
+	userService := services.NewUserService(cachedUserRepo, services.WithAuditLog(auditRepo, uow))
+	webhookService := services.NewWebhookService(webhookRepo, services.WithTargetPolicy(cfg.Webhooks.Targets))
 
 	// Initialize handlers
 	userHandler := handlers.NewUserHandler(userService)
-	healthHandler := handlers.NewHealthHandler()
+	healthHandler := handlers.NewHealthHandler(healthOpts...)
+	webhookHandler := handlers.NewWebhookHandler(webhookService)
+	eventStreamHandler := handlers.NewEventStreamHandler(broadcaster, cfg.EventStream.Heartbeat)
+	graphQLHandler := newGraphQLHandler(userService)
 
 	// Initialize router
-	appRouter := router.NewRouter(userHandler, healthHandler)
+	appRouter := router.NewRouter(userHandler, healthHandler,
+		router.WithIdempotency(idempotency.NewMemoryStore(), cfg.Idempotency.TTL),
+		router.WithRateLimit(ratelimit.NewMemoryStore(), cfg.RateLimit),
+		router.WithCORS(cfg.CORS),
+		router.WithSecurityHeaders(cfg.Security),
+		router.WithCompression(cfg.Compression),
+		router.WithTrustedProxies(cfg.Server.TrustedProxies),
+		router.WithTenants(tenantRepo, cfg.Tenants),
+		router.WithWebhooks(webhookHandler),
+		router.WithEventStream(eventStreamHandler),
+		router.WithGraphQL(graphQLHandler),
+		router.WithOpenAPIValidation(cfg.Server.OpenAPIValidation),
+		router.WithDeprecations(cfg.Deprecations),
+	)
 
 	// Setup routes
 	engine := appRouter.SetupRoutes()
 
 	// Start server
-	port := ":" + cfg.Server.Port
-	log.Printf("Starting server on %s (In-memory mode)", port)
-	if err := engine.Run(port); err != nil {
+	srv, err := server.New(engine, cfg.Server)
+	if err != nil {
+		log.Fatal("Invalid server configuration:", err)
+	}
+	startGRPC(cfg, srv, userService, broadcaster, tenantRepo)
+	log.Printf("Starting %s server on :%s (In-memory mode)", serverScheme(srv), cfg.Server.Port)
+	if err := srv.ListenAndServe(); err != nil {
 		log.Fatal("Failed to start server:", err)
 	}
 }
 
+// cacheUsers puts the user cache in front of userRepo, unless it is
+// disabled. Writes through the returned unit of work evict the users they
+// touch from the cache.
+func cacheUsers(cfg *config.Config, userRepo repository.UserRepository, uow repository.UnitOfWork) (repository.UserRepository, repository.UnitOfWork, []handlers.HealthOption) {
+	if cfg.UserCache.TTL <= 0 {
+		return userRepo, uow, nil
+	}
+	log.Printf("Caching up to %d users for %s", cfg.UserCache.MaxEntries, cfg.UserCache.TTL)
+	cached := repository.NewCachedUserRepository(userRepo, cache.NewMemoryStore(cfg.UserCache.MaxEntries), cfg.UserCache.TTL)
+	return cached, repository.NewCachedUnitOfWork(uow, cached), []handlers.HealthOption{handlers.WithCacheStats(cached.Stats)}
+}
+
+// startRelay starts delivering outbox events in the background: to the
+// configured sinks, and to webhook subscriptions through a queue worked by
+// a webhook worker.
+func startRelay(cfg *config.Config, outbox repository.OutboxRepository, webhookRepo repository.WebhookRepository) {
+	sinks := []events.Sink{webhooks.NewDispatcher(webhookRepo)}
+	for _, url := range cfg.Outbox.WebhookURLs {
+		sinks = append(sinks, events.NewWebhookSink(url))
+	}
+	if cfg.Outbox.File != "" {
+		sink, err := events.NewFileSink(cfg.Outbox.File)
+		if err != nil {
+			log.Fatal("Invalid outbox configuration:", err)
+		}
+		sinks = append(sinks, sink)
+	}
+	for _, sink := range sinks {
+		log.Printf("Relaying user events to %s", sink.Name())
+	}
+
+	relay := events.NewRelay(outbox, sinks, cfg.Outbox.Relay)
+	go relay.Run(context.Background())
+
+	worker := webhooks.NewWorker(webhookRepo, cfg.Webhooks)
+	go worker.Run(context.Background())
+}
+
+// startBroadcaster starts tailing the outbox for the live event stream
+func startBroadcaster(cfg *config.Config, outbox repository.OutboxRepository) *events.Broadcaster {
+	broadcaster := events.NewBroadcaster(outbox, cfg.EventStream.BufferSize, cfg.Outbox.Relay.PollInterval)
+	go broadcaster.Run(context.Background())
+	return broadcaster
+}
+
+// newGraphQLHandler serves userService over GraphQL
+func newGraphQLHandler(userService services.UserService) *handlers.GraphQLHandler {
+	schema, err := graph.NewSchema(userService)
+	if err != nil {
+		log.Fatal("Invalid GraphQL schema:", err)
+	}
+	return handlers.NewGraphQLHandler(schema)
+}
+
+// startGRPC serves userService over gRPC in the background, on its own port,
+// with the same TLS configuration as srv and resolving tenants like the
+// REST API
+func startGRPC(cfg *config.Config, srv *server.Server, userService services.UserService, broadcaster *events.Broadcaster, tenants repository.TenantRepository) {
+	if cfg.Server.GRPCPort == "" {
+		return
+	}
+	opts := grpcapi.WithTenants(tenants, cfg.Tenants)
+	if tlsConfig := srv.TLSConfig(); tlsConfig != nil {
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsConfig)))
+	}
+	grpcServer, err := grpcapi.NewServer(grpcapi.NewUserServer(userService, broadcaster), opts...)
+	if err != nil {
+		log.Fatal("Invalid gRPC configuration:", err)
+	}
+	listener, err := net.Listen("tcp", ":"+cfg.Server.GRPCPort)
+	if err != nil {
+		log.Fatal("Failed to start gRPC server:", err)
+	}
+	log.Printf("Starting gRPC server on :%s", cfg.Server.GRPCPort)
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatal("gRPC server stopped:", err)
+		}
+	}()
+}
+
+// serverScheme describes how srv serves requests, for the startup log
+func serverScheme(srv *server.Server) string {
+	if srv.TLS() {
+		return "HTTPS"
+	}
+	return "HTTP"
+}
+
 func init() {
 	// Set up logging
 	log.SetFlags(log.LstdFlags | log.Lshortfile)
@@ -113,8 +293,51 @@ func init() {
 		log.Println("  DB_PASSWORD - Database password (default: password)")
 		log.Println("  DB_NAME     - Database name (default: gin_app)")
 		log.Println("  DB_SSLMODE  - SSL mode (default: disable)")
+		log.Println("  DB_ROW_LEVEL_SECURITY - Also enforce tenants with Postgres row-level security; DB_USER must not be a superuser (default: false)")
 		log.Println("  PORT        - Server port (default: 8080)")
 		log.Println("  GIN_MODE    - Gin mode (default: debug)")
+		log.Println("  PHONE_DEFAULT_REGION - Region for phone numbers without country code (default: US)")
+		log.Println("  ADDRESS_DEFAULT_COUNTRY - Country for free-text addresses without one (default: US)")
+		log.Println("  ERROR_FORMAT - Error response format, json or problem (default: json)")
+		log.Println("  PROBLEM_TYPE_BASE_URL - Base URL for RFC 7807 problem types (default: about:blank)")
+		log.Println("  IDEMPOTENCY_TTL - How long Idempotency-Key responses are kept (default: 24h)")
+		log.Println("  USER_CACHE_TTL - How long users read by ID are cached, 0 disables the cache (default: 1m)")
+		log.Println("  USER_CACHE_SIZE - Most users held in the cache (default: 10000)")
+		log.Println("  RATE_LIMIT - Default per-client rate limit, e.g. 100/1m, or off (default: 100/1m)")
+		log.Println("  RATE_LIMIT_<GROUP> - Rate limit for one route group, e.g. RATE_LIMIT_USERS_BULK (default: 10/1m)")
+		log.Println("  TLS_CERT_FILE / TLS_KEY_FILE - Serve HTTPS with this certificate, reloaded on change or SIGHUP (default: HTTP)")
+		log.Println("  TLS_CLIENT_CA_FILE - Require client certificates signed by these CAs (mTLS) (default: none)")
+		log.Println("  HTTP2 - Enable HTTP/2 over TLS and h2c over HTTP (default: true)")
+		log.Println("  GRPC_PORT - Port of the gRPC API, or off (default: 9090)")
+		log.Println("  API_DEPRECATIONS - Deprecated API versions as version:deprecated[:sunset] dates, e.g. v1:2026-09-01:2027-03-01 (default: none)")
+		log.Println("  OPENAPI_VALIDATION - Check requests and responses against the OpenAPI document (default: true in test mode)")
+		log.Println("  TRUSTED_PROXIES - Comma-separated load balancer IPs or CIDRs trusted for X-Forwarded-For and X-Tenant (default: none)")
+		log.Println("  TENANT_CLAIM - Bearer token claim naming the tenant, or off (default: tenant)")
+		log.Println("  TENANT_TOKEN_SECRET - Key bearer tokens are signed with using HS256, at least 32 characters (default: none, tokens are ignored)")
+		log.Println("  TENANT_BASE_DOMAIN - Domain whose subdomains name tenants, requires TENANT_TOKEN_SECRET (default: none)")
+		log.Println("  TENANT_REQUIRED - Reject requests that name no tenant instead of using the default tenant (default: false)")
+		log.Println("  CORS_ALLOWED_ORIGINS - Comma-separated browser origins allowed to call the API, or * (default: none)")
+		log.Println("  CORS_ALLOWED_METHODS / CORS_ALLOWED_HEADERS / CORS_EXPOSED_HEADERS - Comma-separated CORS lists")
+		log.Println("  CORS_ALLOW_CREDENTIALS - Allow cookies and auth headers on CORS requests (default: false)")
+		log.Println("  CORS_MAX_AGE - How long browsers cache preflight responses (default: 12h)")
+		log.Println("  HSTS_MAX_AGE - Strict-Transport-Security max-age, 0 disables (default: 8760h)")
+		log.Println("  HSTS_INCLUDE_SUBDOMAINS - Add includeSubDomains to HSTS (default: false)")
+		log.Println("  CONTENT_SECURITY_POLICY - Content-Security-Policy header (default: default-src 'none'; frame-ancestors 'none')")
+		log.Println("  COMPRESSION_ENCODINGS - Codings responses are compressed with, in order of preference, or off (default: zstd,br,gzip)")
+		log.Println("  COMPRESSION_MIN_SIZE - Smallest response worth compressing, in bytes (default: 1024)")
+		log.Println("  COMPRESSION_CONTENT_TYPES - Comma-separated media types that are compressed (default: JSON, XML, CSV, NDJSON, MessagePack and text)")
+		log.Println("  MAX_DECOMPRESSED_BODY_SIZE - Largest gzip request body accepted by the bulk endpoints once decompressed, in bytes (default: 10485760)")
+		log.Println("  OUTBOX_WEBHOOK_URLS - Comma-separated URLs that receive user events as JSON POSTs (default: none)")
+		log.Println("  OUTBOX_FILE - File that receives user events as JSON lines, - for stdout (default: none)")
+		log.Println("  OUTBOX_POLL_INTERVAL - How often the outbox is checked for new events (default: 1s)")
+		log.Println("  OUTBOX_RETENTION - How long published events are kept, 0 keeps them forever (default: 168h)")
+		log.Println("  WEBHOOK_MAX_ATTEMPTS - Attempts before a webhook delivery is dead-lettered (default: 10)")
+		log.Println("  WEBHOOK_TIMEOUT - Timeout for each webhook request (default: 10s)")
+		log.Println("  WEBHOOK_MIN_BACKOFF / WEBHOOK_MAX_BACKOFF - Bounds of the delay between webhook attempts (default: 30s / 1h)")
+		log.Println("  WEBHOOK_ALLOW_HTTP - Accept plain http:// webhook URLs (default: false)")
+		log.Println("  WEBHOOK_ALLOW_PRIVATE_NETWORKS - Deliver webhooks to loopback, private and link-local addresses (default: false)")
+		log.Println("  EVENT_STREAM_BUFFER - Recent events kept in memory for clients resuming the event stream (default: 1000)")
+		log.Println("  EVENT_STREAM_HEARTBEAT - How often an idle event stream sends a heartbeat (default: 15s)")
 		os.Exit(0)
 	}
 }
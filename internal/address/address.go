@@ -0,0 +1,86 @@
+package address
+
+import (
+	"errors"
+	"gin-simple-app/internal/models"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+var (
+	countryMu      sync.RWMutex
+	defaultCountry = "US"
+
+	countryCode = regexp.MustCompile(`^[A-Za-z]{2}$`)
+	// "NY 10001", "CA 90210-1234"
+	regionPostal = regexp.MustCompile(`^([A-Za-z]{2,3})\s+(\d{5}(?:-\d{4})?)$`)
+	postalOnly   = regexp.MustCompile(`^\d{5}(?:-\d{4})?$`)
+)
+
+// SetDefaultCountry sets the ISO 3166-1 alpha-2 country assumed when parsing
+// a free-text address that does not name one
+func SetDefaultCountry(country string) error {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if !countryCode.MatchString(country) {
+		return errors.New("invalid default country: " + country)
+	}
+
+	countryMu.Lock()
+	defer countryMu.Unlock()
+	defaultCountry = country
+	return nil
+}
+
+// DefaultCountry returns the country assumed for addresses that do not name one
+func DefaultCountry() string {
+	countryMu.RLock()
+	defer countryMu.RUnlock()
+	return defaultCountry
+}
+
+// Parse makes a best-effort attempt at splitting a free-text address such as
+// "123 Main St, Apt 4, New York, NY 10001" into its parts. It understands
+// comma-separated US-style addresses; anything it cannot place ends up in
+// Line1, so no input is lost.
+func Parse(text string) models.Address {
+	var parts []string
+	for _, p := range strings.Split(text, ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			parts = append(parts, p)
+		}
+	}
+
+	addr := models.Address{Country: DefaultCountry()}
+	if len(parts) == 0 {
+		return addr
+	}
+
+	// Trailing two-letter country code, e.g. "..., NY 10001, US"
+	if len(parts) >= 3 && countryCode.MatchString(parts[len(parts)-1]) {
+		addr.Country = strings.ToUpper(parts[len(parts)-1])
+		parts = parts[:len(parts)-1]
+	}
+
+	// Region and/or postal code, e.g. "NY 10001"
+	if len(parts) >= 2 {
+		last := parts[len(parts)-1]
+		if m := regionPostal.FindStringSubmatch(last); m != nil {
+			addr.Region, addr.PostalCode = strings.ToUpper(m[1]), m[2]
+			parts = parts[:len(parts)-1]
+		} else if postalOnly.MatchString(last) {
+			addr.PostalCode = last
+			parts = parts[:len(parts)-1]
+		} else if len(parts) >= 3 {
+			addr.Region = last
+			parts = parts[:len(parts)-1]
+		}
+	}
+
+	addr.Line1 = parts[0]
+	if len(parts) >= 2 {
+		addr.City = parts[len(parts)-1]
+		addr.Line2 = strings.Join(parts[1:len(parts)-1], ", ")
+	}
+	return addr
+}
@@ -0,0 +1,118 @@
+// Package audit carries the actor and request ID of a change through the
+// request context and computes field-level diffs of users for the audit log.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/tenant"
+	"reflect"
+)
+
+// SystemActor is recorded for changes made outside an API request
+const SystemActor = "system"
+
+type contextKey int
+
+const (
+	actorKey contextKey = iota
+	claimedActorKey
+	requestIDKey
+)
+
+// WithActor returns a context that records actor as the author of changes
+func WithActor(ctx context.Context, actor string) context.Context {
+	return context.WithValue(ctx, actorKey, actor)
+}
+
+// ActorFromContext returns the actor recorded in ctx, or SystemActor
+func ActorFromContext(ctx context.Context) string {
+	if actor, ok := ctx.Value(actorKey).(string); ok && actor != "" {
+		return actor
+	}
+	return SystemActor
+}
+
+// WithClaimedActor returns a context that records the identity the client
+// claimed, which nothing has verified, next to the actor
+func WithClaimedActor(ctx context.Context, identity string) context.Context {
+	return context.WithValue(ctx, claimedActorKey, identity)
+}
+
+// ClaimedActorFromContext returns the claimed identity recorded in ctx, if any
+func ClaimedActorFromContext(ctx context.Context) string {
+	identity, _ := ctx.Value(claimedActorKey).(string)
+	return identity
+}
+
+// WithRequestID returns a context that carries the ID of the current request
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey, id)
+}
+
+// RequestIDFromContext returns the request ID carried by ctx, if any
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey).(string)
+	return id
+}
+
+// untrackedFields are user fields that change on every write or are not data
+var untrackedFields = map[string]bool{
+	"id":         true,
+	"created_at": true,
+	"updated_at": true,
+}
+
+// NewEntry builds the audit entry for a change to a user. before is nil for
+// a create and after is nil for a delete.
+func NewEntry(ctx context.Context, action string, userID uint, before, after *models.User) models.AuditEntry {
+	return models.AuditEntry{
+		TenantID:     tenant.IDFromContext(ctx),
+		Actor:        ActorFromContext(ctx),
+		ClaimedActor: ClaimedActorFromContext(ctx),
+		Action:       action,
+		UserID:       userID,
+		RequestID:    RequestIDFromContext(ctx),
+		Changes:      Diff(before, after),
+	}
+}
+
+// Diff returns the fields that differ between two versions of a user, by
+// their JSON names. Either version may be nil.
+func Diff(before, after *models.User) models.AuditChanges {
+	beforeFields := userFields(before)
+	afterFields := userFields(after)
+
+	changes := models.AuditChanges{}
+	for name, value := range beforeFields {
+		if !reflect.DeepEqual(value, afterFields[name]) {
+			changes[name] = models.FieldChange{Before: value, After: afterFields[name]}
+		}
+	}
+	for name, value := range afterFields {
+		if _, ok := beforeFields[name]; !ok && value != nil {
+			changes[name] = models.FieldChange{After: value}
+		}
+	}
+	return changes
+}
+
+// userFields returns the tracked fields of user as they appear in the API
+func userFields(user *models.User) map[string]interface{} {
+	fields := map[string]interface{}{}
+	if user == nil {
+		return fields
+	}
+	data, err := json.Marshal(user)
+	if err != nil {
+		return fields
+	}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return fields
+	}
+	for name := range untrackedFields {
+		delete(fields, name)
+	}
+	return fields
+}
@@ -0,0 +1,100 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// entry is a cached value and when it expires
+type entry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore implements Store in memory, for single-node deployments and
+// tests. It holds at most maxEntries values and evicts the least recently
+// used one to make room.
+type MemoryStore struct {
+	maxEntries int
+	// order lists entries from most to least recently used
+	order   *list.List
+	entries map[string]*list.Element
+	mutex   sync.Mutex
+}
+
+// NewMemoryStore creates an in-memory store holding up to maxEntries values
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		maxEntries: maxEntries,
+		order:      list.New(),
+		entries:    make(map[string]*list.Element),
+	}
+}
+
+// Get returns the value for key
+func (s *MemoryStore) Get(key string) ([]byte, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	element, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	e := element.Value.(*entry)
+	if time.Now().After(e.expires) {
+		s.remove(element)
+		return nil, false, nil
+	}
+	s.order.MoveToFront(element)
+	return e.value, true, nil
+}
+
+// Set stores value for key, evicting the least recently used value if the
+// store is full
+func (s *MemoryStore) Set(key string, value []byte, ttl time.Duration) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	expires := time.Now().Add(ttl)
+	if element, ok := s.entries[key]; ok {
+		e := element.Value.(*entry)
+		e.value = value
+		e.expires = expires
+		s.order.MoveToFront(element)
+		return nil
+	}
+
+	s.entries[key] = s.order.PushFront(&entry{key: key, value: value, expires: expires})
+	for s.order.Len() > s.maxEntries {
+		s.remove(s.order.Back())
+	}
+	return nil
+}
+
+// Delete removes keys
+func (s *MemoryStore) Delete(keys ...string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for _, key := range keys {
+		if element, ok := s.entries[key]; ok {
+			s.remove(element)
+		}
+	}
+	return nil
+}
+
+// Len returns how many values are held, including expired ones not yet
+// evicted
+func (s *MemoryStore) Len() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.order.Len()
+}
+
+func (s *MemoryStore) remove(element *list.Element) {
+	s.order.Remove(element)
+	delete(s.entries, element.Value.(*entry).key)
+}
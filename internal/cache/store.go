@@ -0,0 +1,30 @@
+package cache
+
+import "time"
+
+// Store keeps cached values by key. MemoryStore serves a single node; a store
+// backed by a shared cache lets several nodes share entries, so a change made
+// through one node is not served stale by another.
+type Store interface {
+	// Get returns the value for key, and false if it is missing or expired
+	Get(key string) ([]byte, bool, error)
+	// Set stores value for key until ttl has passed
+	Set(key string, value []byte, ttl time.Duration) error
+	// Delete removes keys; missing keys are ignored
+	Delete(keys ...string) error
+}
+
+// Stats counts how often lookups were served from the cache
+type Stats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+// HitRatio returns the share of lookups served from the cache, or 0 before
+// any lookup
+func (s Stats) HitRatio() float64 {
+	if total := s.Hits + s.Misses; total > 0 {
+		return float64(s.Hits) / float64(total)
+	}
+	return 0
+}
@@ -0,0 +1,198 @@
+package compression
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings, as named in Accept-Encoding and Content-Encoding
+const (
+	Gzip     = "gzip"
+	Brotli   = "br"
+	Zstd     = "zstd"
+	Identity = "identity"
+)
+
+// DefaultEncodings are the codings responses are compressed with, in order
+// of preference when a client accepts several equally
+var DefaultEncodings = []string{Zstd, Brotli, Gzip}
+
+// brotliLevel trades a little size for much faster compression than the
+// default level 6, which matters for responses compressed on every request
+const brotliLevel = 4
+
+// Writer compresses what is written to it into the writer it was reset to
+type Writer interface {
+	io.WriteCloser
+	// Flush writes out what has been compressed so far
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// writerPools keep writers for reuse: setting up a compressor allocates
+// tables and windows far larger than a typical response
+var writerPools = map[string]*sync.Pool{
+	Gzip: {New: func() interface{} {
+		return gzip.NewWriter(io.Discard)
+	}},
+	Brotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, brotliLevel)
+	}},
+	Zstd: {New: func() interface{} {
+		// One goroutine per encoder; concurrency comes from serving
+		// many requests at once
+		encoder, err := zstd.NewWriter(io.Discard, zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			panic("failed to create zstd encoder: " + err.Error())
+		}
+		return encoder
+	}},
+}
+
+// Supported reports whether responses can be compressed with encoding
+func Supported(encoding string) bool {
+	_, ok := writerPools[encoding]
+	return ok
+}
+
+// Validate checks that every encoding is supported
+func Validate(encodings []string) error {
+	for _, encoding := range encodings {
+		if !Supported(encoding) {
+			return fmt.Errorf("unsupported encoding %q: expected %s, %s or %s", encoding, Zstd, Brotli, Gzip)
+		}
+	}
+	return nil
+}
+
+// GetWriter returns a pooled writer for encoding that compresses into w.
+// It panics if encoding is not supported. Close the writer, then hand it
+// back with PutWriter.
+func GetWriter(encoding string, w io.Writer) Writer {
+	writer := writerPools[encoding].Get().(Writer)
+	writer.Reset(w)
+	return writer
+}
+
+// PutWriter returns a closed writer to its pool
+func PutWriter(encoding string, writer Writer) {
+	// Drop the reference to the response so it can be collected
+	writer.Reset(io.Discard)
+	writerPools[encoding].Put(writer)
+}
+
+// gzipReaders keep gzip readers for reuse; they have no usable zero value,
+// so the pool starts empty and readers are created on first use
+var gzipReaders sync.Pool
+
+// GzipReader is a pooled reader decompressing a gzip stream. Close returns
+// it to the pool; it must not be used afterwards.
+type GzipReader struct {
+	*gzip.Reader
+	closed bool
+}
+
+// NewGzipReader returns a pooled reader decompressing r, or an error if r
+// does not start with a gzip header
+func NewGzipReader(r io.Reader) (*GzipReader, error) {
+	if reader, ok := gzipReaders.Get().(*GzipReader); ok {
+		if err := reader.Reset(r); err != nil {
+			gzipReaders.Put(reader)
+			return nil, err
+		}
+		reader.closed = false
+		return reader, nil
+	}
+	reader, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return &GzipReader{Reader: reader}, nil
+}
+
+// Close returns the reader to the pool. It is safe to call more than once.
+func (r *GzipReader) Close() error {
+	if r.closed {
+		return nil
+	}
+	r.closed = true
+	err := r.Reader.Close()
+	gzipReaders.Put(r)
+	return err
+}
+
+// Negotiate picks the coding for a response from an Accept-Encoding header,
+// among offered in order of preference. It returns Identity when the
+// response should not be compressed. A client that accepts none of offered
+// gets an uncompressed response even if it refuses identity, which is
+// friendlier than 406 Not Acceptable.
+func Negotiate(acceptEncoding string, offered []string) string {
+	if strings.TrimSpace(acceptEncoding) == "" {
+		return Identity
+	}
+
+	qualities := map[string]float64{}
+	wildcard, hasWildcard := 0.0, false
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		if coding == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, _ := strings.Cut(param, "=")
+			if strings.TrimSpace(strings.ToLower(name)) == "q" {
+				if q, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					quality = q
+				}
+			}
+		}
+		// Older clients send x-gzip
+		if coding == "x-gzip" {
+			coding = Gzip
+		}
+		if coding == "*" {
+			wildcard, hasWildcard = quality, true
+			continue
+		}
+		qualities[coding] = quality
+	}
+
+	qualityOf := func(coding string) float64 {
+		if q, ok := qualities[coding]; ok {
+			return q
+		}
+		if hasWildcard {
+			return wildcard
+		}
+		return 0
+	}
+
+	candidates := make([]string, 0, len(offered))
+	for _, coding := range offered {
+		if qualityOf(coding) > 0 {
+			candidates = append(candidates, coding)
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return qualityOf(candidates[i]) > qualityOf(candidates[j])
+	})
+
+	// A client preferring identity to every coding offered gets it
+	if len(candidates) == 0 {
+		return Identity
+	}
+	if identity, ok := qualities[Identity]; ok && identity > qualityOf(candidates[0]) {
+		return Identity
+	}
+	return candidates[0]
+}
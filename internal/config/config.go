@@ -2,16 +2,44 @@ package config
 
 import (
 	"fmt"
+	"gin-simple-app/internal/compression"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/ratelimit"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/versioning"
+	"gin-simple-app/internal/webhooks"
 	"log"
+	"net"
+	"net/netip"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/joho/godotenv"
 )
 
 // Config holds all configuration for the application
 type Config struct {
-	Database DatabaseConfig
-	Server   ServerConfig
+	Database    DatabaseConfig
+	Server      ServerConfig
+	Phone       PhoneConfig
+	Address     AddressConfig
+	Response    ResponseConfig
+	Idempotency IdempotencyConfig
+	RateLimit   ratelimit.Limits
+	CORS        middleware.CORSConfig
+	Security    middleware.SecurityHeadersConfig
+	Compression middleware.CompressionConfig
+	Tenants     middleware.TenantConfig
+	Outbox      OutboxConfig
+	Webhooks    webhooks.WorkerConfig
+	EventStream EventStreamConfig
+	UserCache   UserCacheConfig
+	// Deprecations announces the API versions going away
+	Deprecations versioning.Deprecations
 }
 
 // DatabaseConfig holds database configuration
@@ -22,12 +50,79 @@ type DatabaseConfig struct {
 	Password string
 	Name     string
 	SSLMode  string
+	// RowLevelSecurity enforces the tenant of every user query in Postgres
+	// as well, with row-level security policies on the users table
+	RowLevelSecurity bool
 }
 
 // ServerConfig holds server configuration
 type ServerConfig struct {
 	Port    string
 	GinMode string
+	// TrustedProxies lists the load balancer addresses or CIDR ranges whose
+	// X-Forwarded-For header is believed
+	TrustedProxies []string
+	// TLSCertFile and TLSKeyFile enable HTTPS; both are reloaded on change
+	TLSCertFile string
+	TLSKeyFile  string
+	// TLSClientCAFile enables mTLS: clients must present a certificate
+	// signed by one of the CAs in this PEM bundle
+	TLSClientCAFile string
+	// HTTP2 enables HTTP/2 over TLS and h2c over plain HTTP
+	HTTP2 bool
+	// GRPCPort serves the gRPC API on a port of its own; empty disables it
+	GRPCPort string
+	// OpenAPIValidation checks every request and response against the
+	// OpenAPI document
+	OpenAPIValidation bool
+}
+
+// PhoneConfig holds phone number parsing configuration
+type PhoneConfig struct {
+	DefaultRegion string
+}
+
+// AddressConfig holds postal address parsing configuration
+type AddressConfig struct {
+	DefaultCountry string
+}
+
+// ResponseConfig holds API response format configuration
+type ResponseConfig struct {
+	// ErrorFormat is "json" for the standard APIResponse or "problem" for RFC 7807
+	ErrorFormat        string
+	ProblemTypeBaseURL string
+}
+
+// IdempotencyConfig holds Idempotency-Key handling configuration
+type IdempotencyConfig struct {
+	TTL time.Duration
+}
+
+// OutboxConfig holds the event relay configuration
+type OutboxConfig struct {
+	// WebhookURLs receive every user event as a JSON POST
+	WebhookURLs []string
+	// File receives every user event as a line of JSON; "-" means stdout
+	File  string
+	Relay events.RelayConfig
+}
+
+// EventStreamConfig holds the live event stream configuration
+type EventStreamConfig struct {
+	// BufferSize is how many recent events are kept for clients resuming
+	// with Last-Event-ID; older ones are replayed from the outbox
+	BufferSize int
+	// Heartbeat is how often an idle stream sends a keep-alive comment
+	Heartbeat time.Duration
+}
+
+// UserCacheConfig holds the cache of users read by ID
+type UserCacheConfig struct {
+	// TTL is how long a user is cached; zero disables the cache
+	TTL time.Duration
+	// MaxEntries is how many users are cached at most
+	MaxEntries int
 }
 
 // Load loads configuration from environment variables
@@ -49,12 +144,397 @@ func Load() (*Config, error) {
 		Server: ServerConfig{
 			Port:    getEnv("SERVER_PORT", getEnv("PORT", "8080")), // Check SERVER_PORT first, then PORT, then default
 			GinMode: getEnv("GIN_MODE", "debug"),
+
+			TLSCertFile:     getEnv("TLS_CERT_FILE", ""),
+			TLSKeyFile:      getEnv("TLS_KEY_FILE", ""),
+			TLSClientCAFile: getEnv("TLS_CLIENT_CA_FILE", ""),
+		},
+		Phone: PhoneConfig{
+			DefaultRegion: getEnv("PHONE_DEFAULT_REGION", "US"),
+		},
+		Address: AddressConfig{
+			DefaultCountry: getEnv("ADDRESS_DEFAULT_COUNTRY", "US"),
 		},
+		Response: ResponseConfig{
+			ErrorFormat:        getEnv("ERROR_FORMAT", "json"),
+			ProblemTypeBaseURL: getEnv("PROBLEM_TYPE_BASE_URL", ""),
+		},
+	}
+
+	rowLevelSecurity, err := strconv.ParseBool(getEnv("DB_ROW_LEVEL_SECURITY", "false"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid DB_ROW_LEVEL_SECURITY: %w", err)
+	}
+	config.Database.RowLevelSecurity = rowLevelSecurity
+
+	ttl, err := time.ParseDuration(getEnv("IDEMPOTENCY_TTL", "24h"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid IDEMPOTENCY_TTL: %w", err)
+	}
+	config.Idempotency.TTL = ttl
+
+	rateLimits, err := loadRateLimits()
+	if err != nil {
+		return nil, err
+	}
+	config.RateLimit = rateLimits
+
+	http2, err := strconv.ParseBool(getEnv("HTTP2", "true"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid HTTP2: %w", err)
+	}
+	config.Server.HTTP2 = http2
+
+	// The gRPC API is served unless GRPC_PORT is off
+	config.Server.GRPCPort = getEnv("GRPC_PORT", "9090")
+	if strings.EqualFold(config.Server.GRPCPort, "off") {
+		config.Server.GRPCPort = ""
+	} else if _, err := strconv.ParseUint(config.Server.GRPCPort, 10, 16); err != nil {
+		return nil, fmt.Errorf("invalid GRPC_PORT: %q is not a port number", config.Server.GRPCPort)
+	}
+
+	deprecations, err := versioning.ParseDeprecations(getEnv("API_DEPRECATIONS", ""))
+	if err != nil {
+		return nil, fmt.Errorf("invalid API_DEPRECATIONS: %w", err)
+	}
+	config.Deprecations = deprecations
+
+	// Validating against the OpenAPI document is meant for tests, so it is
+	// only on by default in test mode
+	openAPIValidation, err := strconv.ParseBool(getEnv("OPENAPI_VALIDATION", strconv.FormatBool(config.Server.GinMode == "test")))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OPENAPI_VALIDATION: %w", err)
+	}
+	config.Server.OpenAPIValidation = openAPIValidation
+
+	config.Server.TrustedProxies = getEnvList("TRUSTED_PROXIES", nil)
+	for _, proxy := range config.Server.TrustedProxies {
+		if net.ParseIP(proxy) == nil {
+			if _, _, err := net.ParseCIDR(proxy); err != nil {
+				return nil, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: expected an IP address or CIDR range", proxy)
+			}
+		}
 	}
 
+	cors, err := loadCORS()
+	if err != nil {
+		return nil, err
+	}
+	config.CORS = cors
+
+	security, err := loadSecurityHeaders()
+	if err != nil {
+		return nil, err
+	}
+	config.Security = security
+
+	compressionConfig, err := loadCompression()
+	if err != nil {
+		return nil, err
+	}
+	config.Compression = compressionConfig
+
+	tenants, err := loadTenants(config.Server.TrustedProxies)
+	if err != nil {
+		return nil, err
+	}
+	config.Tenants = tenants
+
+	outbox, err := loadOutbox()
+	if err != nil {
+		return nil, err
+	}
+	config.Outbox = outbox
+
+	webhookConfig, err := loadWebhooks()
+	if err != nil {
+		return nil, err
+	}
+	config.Webhooks = webhookConfig
+
+	bufferSize, err := strconv.Atoi(getEnv("EVENT_STREAM_BUFFER", "1000"))
+	if err != nil || bufferSize < 1 {
+		return nil, fmt.Errorf("invalid EVENT_STREAM_BUFFER: expected a positive number")
+	}
+	config.EventStream.BufferSize = bufferSize
+
+	heartbeat, err := time.ParseDuration(getEnv("EVENT_STREAM_HEARTBEAT", "15s"))
+	if err != nil || heartbeat <= 0 {
+		return nil, fmt.Errorf("invalid EVENT_STREAM_HEARTBEAT: expected a positive duration")
+	}
+	config.EventStream.Heartbeat = heartbeat
+
+	cacheTTL, err := time.ParseDuration(getEnv("USER_CACHE_TTL", "1m"))
+	if err != nil || cacheTTL < 0 {
+		return nil, fmt.Errorf("invalid USER_CACHE_TTL: expected a duration, 0 to disable the cache")
+	}
+	config.UserCache.TTL = cacheTTL
+
+	cacheSize, err := strconv.Atoi(getEnv("USER_CACHE_SIZE", "10000"))
+	if err != nil || cacheSize < 1 {
+		return nil, fmt.Errorf("invalid USER_CACHE_SIZE: expected a positive number")
+	}
+	config.UserCache.MaxEntries = cacheSize
+
 	return config, nil
 }
 
+// loadWebhooks reads the WEBHOOK_* settings for webhook delivery
+func loadWebhooks() (webhooks.WorkerConfig, error) {
+	worker := webhooks.DefaultWorkerConfig()
+
+	maxAttempts, err := strconv.Atoi(getEnv("WEBHOOK_MAX_ATTEMPTS", strconv.Itoa(worker.MaxAttempts)))
+	if err != nil || maxAttempts < 1 {
+		return worker, fmt.Errorf("invalid WEBHOOK_MAX_ATTEMPTS: expected a positive number")
+	}
+	worker.MaxAttempts = maxAttempts
+
+	timeout, err := time.ParseDuration(getEnv("WEBHOOK_TIMEOUT", worker.Timeout.String()))
+	if err != nil || timeout <= 0 {
+		return worker, fmt.Errorf("invalid WEBHOOK_TIMEOUT: expected a positive duration")
+	}
+	worker.Timeout = timeout
+	// A longer timeout makes batches slower, so claims are held for longer
+	if worker.Lease < worker.MinLease() {
+		worker.Lease = worker.MinLease()
+	}
+
+	minBackoff, err := time.ParseDuration(getEnv("WEBHOOK_MIN_BACKOFF", worker.MinBackoff.String()))
+	if err != nil {
+		return worker, fmt.Errorf("invalid WEBHOOK_MIN_BACKOFF: %w", err)
+	}
+	maxBackoff, err := time.ParseDuration(getEnv("WEBHOOK_MAX_BACKOFF", worker.MaxBackoff.String()))
+	if err != nil {
+		return worker, fmt.Errorf("invalid WEBHOOK_MAX_BACKOFF: %w", err)
+	}
+	if minBackoff > maxBackoff {
+		return worker, fmt.Errorf("WEBHOOK_MIN_BACKOFF cannot be longer than WEBHOOK_MAX_BACKOFF")
+	}
+	worker.MinBackoff = minBackoff
+	worker.MaxBackoff = maxBackoff
+
+	allowHTTP, err := strconv.ParseBool(getEnv("WEBHOOK_ALLOW_HTTP", "false"))
+	if err != nil {
+		return worker, fmt.Errorf("invalid WEBHOOK_ALLOW_HTTP: %w", err)
+	}
+	worker.Targets.AllowHTTP = allowHTTP
+	allowPrivate, err := strconv.ParseBool(getEnv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "false"))
+	if err != nil {
+		return worker, fmt.Errorf("invalid WEBHOOK_ALLOW_PRIVATE_NETWORKS: %w", err)
+	}
+	worker.Targets.AllowPrivateNetworks = allowPrivate
+
+	if err := worker.Validate(); err != nil {
+		return worker, fmt.Errorf("invalid webhook settings: %w", err)
+	}
+	return worker, nil
+}
+
+// loadOutbox reads the OUTBOX_* settings for the event relay
+func loadOutbox() (OutboxConfig, error) {
+	outbox := OutboxConfig{
+		WebhookURLs: getEnvList("OUTBOX_WEBHOOK_URLS", nil),
+		File:        getEnv("OUTBOX_FILE", ""),
+		Relay:       events.DefaultRelayConfig(),
+	}
+
+	for _, url := range outbox.WebhookURLs {
+		if !strings.HasPrefix(url, "http://") && !strings.HasPrefix(url, "https://") {
+			return outbox, fmt.Errorf("invalid OUTBOX_WEBHOOK_URLS entry %q: expected an http(s):// URL", url)
+		}
+	}
+
+	pollInterval, err := time.ParseDuration(getEnv("OUTBOX_POLL_INTERVAL", outbox.Relay.PollInterval.String()))
+	if err != nil || pollInterval <= 0 {
+		return outbox, fmt.Errorf("invalid OUTBOX_POLL_INTERVAL: expected a positive duration")
+	}
+	outbox.Relay.PollInterval = pollInterval
+
+	retention, err := time.ParseDuration(getEnv("OUTBOX_RETENTION", "168h"))
+	if err != nil {
+		return outbox, fmt.Errorf("invalid OUTBOX_RETENTION: %w", err)
+	}
+	outbox.Relay.Retention = retention
+
+	if err := outbox.Relay.Validate(); err != nil {
+		return outbox, fmt.Errorf("invalid outbox settings: %w", err)
+	}
+	return outbox, nil
+}
+
+// loadCORS reads the CORS_* settings. CORS stays disabled unless
+// CORS_ALLOWED_ORIGINS is set.
+func loadCORS() (middleware.CORSConfig, error) {
+	cors := middleware.DefaultCORSConfig()
+	cors.AllowedOrigins = getEnvList("CORS_ALLOWED_ORIGINS", nil)
+	cors.AllowedMethods = getEnvList("CORS_ALLOWED_METHODS", cors.AllowedMethods)
+	cors.AllowedHeaders = getEnvList("CORS_ALLOWED_HEADERS", cors.AllowedHeaders)
+	cors.ExposedHeaders = getEnvList("CORS_EXPOSED_HEADERS", cors.ExposedHeaders)
+
+	for _, origin := range cors.AllowedOrigins {
+		if origin != "*" && !strings.HasPrefix(origin, "http://") && !strings.HasPrefix(origin, "https://") {
+			return cors, fmt.Errorf("invalid CORS_ALLOWED_ORIGINS entry %q: expected * or an http(s):// origin", origin)
+		}
+	}
+
+	credentials, err := strconv.ParseBool(getEnv("CORS_ALLOW_CREDENTIALS", "false"))
+	if err != nil {
+		return cors, fmt.Errorf("invalid CORS_ALLOW_CREDENTIALS: %w", err)
+	}
+	cors.AllowCredentials = credentials
+	for _, origin := range cors.AllowedOrigins {
+		if origin == "*" && credentials {
+			// Browsers refuse credentialed responses allowed for any origin
+			return cors, fmt.Errorf("CORS_ALLOW_CREDENTIALS cannot be used with CORS_ALLOWED_ORIGINS=*")
+		}
+	}
+
+	maxAge, err := time.ParseDuration(getEnv("CORS_MAX_AGE", cors.MaxAge.String()))
+	if err != nil {
+		return cors, fmt.Errorf("invalid CORS_MAX_AGE: %w", err)
+	}
+	cors.MaxAge = maxAge
+
+	return cors, nil
+}
+
+// loadSecurityHeaders reads the HSTS and Content-Security-Policy settings
+func loadSecurityHeaders() (middleware.SecurityHeadersConfig, error) {
+	security := middleware.DefaultSecurityHeadersConfig()
+
+	maxAge, err := time.ParseDuration(getEnv("HSTS_MAX_AGE", "8760h"))
+	if err != nil {
+		return security, fmt.Errorf("invalid HSTS_MAX_AGE: %w", err)
+	}
+	security.HSTSMaxAge = maxAge
+
+	includeSubdomains, err := strconv.ParseBool(getEnv("HSTS_INCLUDE_SUBDOMAINS", "false"))
+	if err != nil {
+		return security, fmt.Errorf("invalid HSTS_INCLUDE_SUBDOMAINS: %w", err)
+	}
+	security.HSTSIncludeSubdomains = includeSubdomains
+
+	security.ContentSecurityPolicy = getEnv("CONTENT_SECURITY_POLICY", security.ContentSecurityPolicy)
+
+	return security, nil
+}
+
+// loadCompression reads the COMPRESSION_* settings. COMPRESSION_ENCODINGS=off
+// disables response compression.
+func loadCompression() (middleware.CompressionConfig, error) {
+	cfg := middleware.DefaultCompressionConfig()
+
+	var encodings []string
+	for _, encoding := range getEnvList("COMPRESSION_ENCODINGS", cfg.Encodings) {
+		encodings = append(encodings, strings.ToLower(encoding))
+	}
+	if len(encodings) == 1 && encodings[0] == "off" {
+		encodings = nil
+	}
+	if err := compression.Validate(encodings); err != nil {
+		return cfg, fmt.Errorf("invalid COMPRESSION_ENCODINGS: %w", err)
+	}
+	cfg.Encodings = encodings
+
+	minSize, err := strconv.Atoi(getEnv("COMPRESSION_MIN_SIZE", strconv.Itoa(cfg.MinSize)))
+	if err != nil || minSize < 0 {
+		return cfg, fmt.Errorf("invalid COMPRESSION_MIN_SIZE: expected a non-negative number of bytes")
+	}
+	cfg.MinSize = minSize
+
+	cfg.ContentTypes = getEnvList("COMPRESSION_CONTENT_TYPES", cfg.ContentTypes)
+
+	maxDecompressedSize, err := strconv.ParseInt(getEnv("MAX_DECOMPRESSED_BODY_SIZE", strconv.FormatInt(cfg.MaxDecompressedSize, 10)), 10, 64)
+	if err != nil || maxDecompressedSize < 1 {
+		return cfg, fmt.Errorf("invalid MAX_DECOMPRESSED_BODY_SIZE: expected a positive number of bytes")
+	}
+	cfg.MaxDecompressedSize = maxDecompressedSize
+
+	return cfg, nil
+}
+
+// loadTenants reads how the tenant of a request is resolved. TENANT_CLAIM=off
+// ignores bearer tokens, as does leaving TENANT_TOKEN_SECRET unset. Only
+// trustedProxies may name the tenant with the X-Tenant header.
+func loadTenants(trustedProxies []string) (middleware.TenantConfig, error) {
+	cfg := middleware.DefaultTenantConfig()
+	cfg.Claim = getEnv("TENANT_CLAIM", cfg.Claim)
+	if strings.EqualFold(cfg.Claim, "off") {
+		cfg.Claim = ""
+	}
+	if secret := getEnv("TENANT_TOKEN_SECRET", ""); secret != "" {
+		if len(secret) < 32 {
+			return cfg, fmt.Errorf("invalid TENANT_TOKEN_SECRET: expected at least 32 characters")
+		}
+		cfg.TokenSecret = []byte(secret)
+	}
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, err := netip.ParseAddr(proxy)
+			if err != nil {
+				return cfg, fmt.Errorf("invalid TRUSTED_PROXIES entry %q: expected an IP address or CIDR range", proxy)
+			}
+			prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+		}
+		cfg.TrustedProxies = append(cfg.TrustedProxies, prefix.Masked())
+	}
+	cfg.BaseDomain = getEnv("TENANT_BASE_DOMAIN", "")
+	// The host is chosen by the client, so it needs a token to check it against
+	if cfg.BaseDomain != "" && (cfg.Claim == "" || len(cfg.TokenSecret) == 0) {
+		return cfg, fmt.Errorf("invalid TENANT_BASE_DOMAIN: requires TENANT_TOKEN_SECRET and TENANT_CLAIM")
+	}
+
+	required, err := strconv.ParseBool(getEnv("TENANT_REQUIRED", "false"))
+	if err != nil {
+		return cfg, fmt.Errorf("invalid TENANT_REQUIRED: %w", err)
+	}
+	cfg.Required = required
+
+	return cfg, nil
+}
+
+// defaultGroupRateLimits are the built-in limits for route groups that need
+// a tighter budget than RATE_LIMIT
+var defaultGroupRateLimits = map[string]string{
+	"users_bulk": "10/1m",
+}
+
+// loadRateLimits reads RATE_LIMIT as the default limit and RATE_LIMIT_<GROUP>
+// for each route group, e.g. RATE_LIMIT_USERS_BULK for the users_bulk group.
+// Groups the router does not have are rejected, so a typo is not ignored.
+func loadRateLimits() (ratelimit.Limits, error) {
+	limits := ratelimit.Limits{Groups: make(map[string]ratelimit.Limit)}
+
+	defaultLimit, err := ratelimit.ParseLimit(getEnv("RATE_LIMIT", "100/1m"))
+	if err != nil {
+		return limits, fmt.Errorf("invalid RATE_LIMIT: %w", err)
+	}
+	limits.Default = defaultLimit
+
+	groups := make(map[string]string)
+	for group, value := range defaultGroupRateLimits {
+		groups[group] = value
+	}
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if group, ok := strings.CutPrefix(name, "RATE_LIMIT_"); ok && group != "" && value != "" {
+			if !slices.Contains(router.RateLimitGroups, strings.ToLower(group)) {
+				return limits, fmt.Errorf("invalid %s: unknown route group, expected one of: %s", name, strings.ToUpper(strings.Join(router.RateLimitGroups, ", ")))
+			}
+			groups[strings.ToLower(group)] = value
+		}
+	}
+	for group, value := range groups {
+		limit, err := ratelimit.ParseLimit(value)
+		if err != nil {
+			return limits, fmt.Errorf("invalid RATE_LIMIT_%s: %w", strings.ToUpper(group), err)
+		}
+		limits.Groups[group] = limit
+	}
+	return limits, nil
+}
+
 // GetDSN returns the database connection string
 func (db *DatabaseConfig) GetDSN() string {
 	return fmt.Sprintf(
@@ -70,3 +550,18 @@ func getEnv(key, defaultValue string) string {
 	}
 	return defaultValue
 }
+
+// getEnvList gets a comma-separated environment variable as a list
+func getEnvList(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
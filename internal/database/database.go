@@ -2,8 +2,10 @@ package database
 
 import (
 	"gin-simple-app/internal/config"
+	"gin-simple-app/internal/idempotency"
 	"gin-simple-app/internal/models"
 	"log"
+	"strconv"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -41,7 +43,7 @@ func Connect(cfg *config.DatabaseConfig) error {
 		return err
 	}
 
-	return nil
+	return SetRowLevelSecurity(cfg.RowLevelSecurity)
 }
 
 // AutoMigrate runs auto migration for all models
@@ -49,24 +51,78 @@ func AutoMigrate() error {
 	log.Println("Running database migrations...")
 	
 	err := DB.AutoMigrate(
+		&models.Tenant{},
 		&models.User{},
+		&models.AuditEntry{},
+		&models.Event{},
+		&models.WebhookSubscription{},
+		&models.WebhookDelivery{},
+		&idempotency.Record{},
 	)
 	
 	if err != nil {
 		return err
 	}
+
+	if err := RunMigrations(DB); err != nil {
+		return err
+	}
 	
 	log.Println("Database migrations completed")
 	return nil
 }
 
-// SeedData seeds initial data into the database
+// SetRowLevelSecurity turns enforcement of the row-level security
+// policies on the users table on or off. They are forced on the table
+// owner too, so they apply unless the application connects as a superuser.
+func SetRowLevelSecurity(enabled bool) error {
+	var current bool
+	if err := DB.Raw("SELECT relrowsecurity FROM pg_class WHERE oid = 'users'::regclass").Scan(&current).Error; err != nil {
+		return err
+	}
+	if current == enabled {
+		return nil
+	}
+
+	statements := []string{
+		`ALTER TABLE users DISABLE ROW LEVEL SECURITY`,
+		`ALTER TABLE users NO FORCE ROW LEVEL SECURITY`,
+	}
+	if enabled {
+		statements = []string{
+			`ALTER TABLE users ENABLE ROW LEVEL SECURITY`,
+			`ALTER TABLE users FORCE ROW LEVEL SECURITY`,
+		}
+	}
+	return DB.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range statements {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		log.Printf("Row-level security on users enabled: %t", enabled)
+		return nil
+	})
+}
+
+// SeedData seeds initial data into the default tenant
 func SeedData() error {
+	return DB.Transaction(func(tx *gorm.DB) error {
+		// Row-level security only shows the users of the tenant in app.tenant_id
+		if err := tx.Exec("SELECT set_config('app.tenant_id', ?, true)", strconv.FormatUint(uint64(models.DefaultTenantID), 10)).Error; err != nil {
+			return err
+		}
+		return seedUsers(tx)
+	})
+}
+
+// seedUsers creates the sample users unless there are users already
+func seedUsers(tx *gorm.DB) error {
 	log.Println("Checking for initial data...")
 
 	// Check if users already exist
 	var count int64
-	DB.Model(&models.User{}).Count(&count)
+	tx.Model(&models.User{}).Count(&count)
 	
 	if count > 0 {
 		log.Println("Data already exists, skipping seed")
@@ -76,17 +132,20 @@ func SeedData() error {
 	// Seed initial users
 	address1 := "123 Main St, New York, NY 10001"
 	address2 := "456 Oak Ave, Los Angeles, CA 90210"
-	phone1 := "+1-555-0101"
-	phone2 := "+1-555-0102"
-	phone3 := "+1-555-0103"
+	phone1, original1 := "+12125550101", "+1 212-555-0101"
+	phone2, original2 := "+13105550102", "+1 310-555-0102"
+	phone3, original3 := "+13125550103", "+1 312-555-0103"
+	
+	postal1 := models.Address{Line1: "123 Main St", City: "New York", Region: "NY", PostalCode: "10001", Country: "US"}
+	postal2 := models.Address{Line1: "456 Oak Ave", City: "Los Angeles", Region: "CA", PostalCode: "90210", Country: "US"}
 	
 	users := []models.User{
-		{Name: "John Doe", Email: "john@example.com", Phone: &phone1, Address: &address1},
-		{Name: "Jane Smith", Email: "jane@example.com", Phone: &phone2, Address: &address2},
-		{Name: "Bob Johnson", Email: "bob@example.com", Phone: &phone3, Address: nil}, // No address
+		{Name: "John Doe", Email: "john@example.com", Phone: &phone1, PhoneOriginal: &original1, Address: &address1, PostalAddress: &postal1},
+		{Name: "Jane Smith", Email: "jane@example.com", Phone: &phone2, PhoneOriginal: &original2, Address: &address2, PostalAddress: &postal2},
+		{Name: "Bob Johnson", Email: "bob@example.com", Phone: &phone3, PhoneOriginal: &original3, Address: nil}, // No address
 	}
 
-	result := DB.Create(&users)
+	result := tx.Create(&users)
 	if result.Error != nil {
 		return result.Error
 	}
@@ -0,0 +1,166 @@
+package database
+
+import (
+	"gin-simple-app/internal/address"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/phone"
+	"log"
+
+	"gorm.io/gorm"
+)
+
+// migration is a schema or data change that GORM's AutoMigrate cannot express.
+// Statements run first, then Run if set.
+type migration struct {
+	ID         string
+	Statements []string
+	Run        func(tx *gorm.DB) error
+}
+
+// migrations run in order after AutoMigrate. Each one is applied once and
+// recorded in the schema_migrations table. Never edit a migration that has
+// shipped; add a new one instead.
+var migrations = []migration{
+	{
+		ID: "0001_user_search",
+		Statements: []string{
+			`CREATE EXTENSION IF NOT EXISTS pg_trgm`,
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS search_vector tsvector GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(name, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(email, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(phone, '')), 'B') ||
+				setweight(to_tsvector('simple', coalesce(address, '')), 'C')
+			) STORED`,
+			`ALTER TABLE users ADD COLUMN IF NOT EXISTS search_text text GENERATED ALWAYS AS (
+				lower(coalesce(name, '') || ' ' || coalesce(email, '') || ' ' || coalesce(phone, '') || ' ' || coalesce(address, ''))
+			) STORED`,
+			`CREATE INDEX IF NOT EXISTS idx_users_search_vector ON users USING GIN (search_vector)`,
+			`CREATE INDEX IF NOT EXISTS idx_users_search_text_trgm ON users USING GIN (search_text gin_trgm_ops)`,
+		},
+	},
+	{
+		ID:  "0002_normalize_phones",
+		Run: normalizePhones,
+	},
+	{
+		ID: "0003_structured_addresses",
+		Statements: []string{
+			`CREATE INDEX IF NOT EXISTS idx_users_postal_city ON users ((lower(postal_address->>'city')))`,
+			`CREATE INDEX IF NOT EXISTS idx_users_postal_country ON users ((postal_address->>'country'))`,
+		},
+		Run: parseAddresses,
+	},
+	{
+		ID: "0004_append_only_audit",
+		Statements: []string{
+			`CREATE OR REPLACE FUNCTION reject_audit_change() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION 'audit_entries is append-only';
+			END;
+			$$ LANGUAGE plpgsql`,
+			`CREATE TRIGGER audit_entries_append_only BEFORE UPDATE OR DELETE ON audit_entries
+				FOR EACH ROW EXECUTE FUNCTION reject_audit_change()`,
+			`CREATE TRIGGER audit_entries_no_truncate BEFORE TRUNCATE ON audit_entries
+				FOR EACH STATEMENT EXECUTE FUNCTION reject_audit_change()`,
+		},
+	},
+	{
+		// Existing users, audit entries and events belong to the default
+		// tenant, which their tenant_id column defaults to. Emails are
+		// unique per tenant from now on.
+		ID: "0005_tenants",
+		Statements: []string{
+			`INSERT INTO tenants (id, created_at, slug, name) VALUES (1, now(), 'default', 'Default') ON CONFLICT DO NOTHING`,
+			`SELECT setval(pg_get_serial_sequence('tenants', 'id'), (SELECT max(id) FROM tenants))`,
+			`ALTER TABLE users ADD CONSTRAINT fk_users_tenant FOREIGN KEY (tenant_id) REFERENCES tenants (id)`,
+			`DROP INDEX IF EXISTS idx_users_email`,
+		},
+	},
+	{
+		// Only lets a session see and write the users of the tenant in
+		// app.tenant_id, and none while it is unset. The policy is enforced
+		// once SetRowLevelSecurity enables row-level security on the table,
+		// after which migrations touching users must set app.tenant_id.
+		ID: "0006_users_row_level_security",
+		Statements: []string{
+			`CREATE POLICY users_tenant_isolation ON users
+				USING (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::bigint)
+				WITH CHECK (tenant_id = NULLIF(current_setting('app.tenant_id', true), '')::bigint)`,
+		},
+	},
+}
+
+// schemaMigration records an applied migration
+type schemaMigration struct {
+	ID string `gorm:"primarykey"`
+}
+
+// RunMigrations applies all pending SQL migrations, each in its own transaction
+func RunMigrations(db *gorm.DB) error {
+	if err := db.AutoMigrate(&schemaMigration{}); err != nil {
+		return err
+	}
+
+	for _, m := range migrations {
+		var count int64
+		if err := db.Model(&schemaMigration{}).Where("id = ?", m.ID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			continue
+		}
+
+		log.Printf("Applying migration %s", m.ID)
+		err := db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range m.Statements {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			if m.Run != nil {
+				if err := m.Run(tx); err != nil {
+					return err
+				}
+			}
+			return tx.Create(&schemaMigration{ID: m.ID}).Error
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizePhones keeps the existing free-text phone numbers as the original
+// input and rewrites the ones that parse to E.164. Numbers that cannot be
+// parsed are left untouched.
+func normalizePhones(tx *gorm.DB) error {
+	var users []models.User
+	return tx.Where("phone IS NOT NULL AND phone_original IS NULL").FindInBatches(&users, 500, func(_ *gorm.DB, _ int) error {
+		for _, user := range users {
+			updates := map[string]interface{}{"phone_original": *user.Phone}
+			if normalized, err := phone.Normalize(*user.Phone); err == nil {
+				updates["phone"] = normalized
+			}
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumns(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// parseAddresses fills in the structured postal address of existing users
+// from their free-text address, on a best-effort basis
+func parseAddresses(tx *gorm.DB) error {
+	var users []models.User
+	return tx.Where("address IS NOT NULL AND address <> '' AND postal_address IS NULL").FindInBatches(&users, 500, func(_ *gorm.DB, _ int) error {
+		for _, user := range users {
+			parsed := address.Parse(*user.Address)
+			if err := tx.Model(&models.User{}).Where("id = ?", user.ID).UpdateColumn("postal_address", parsed).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
@@ -0,0 +1,320 @@
+package events
+
+import (
+	"context"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"log"
+	"sync"
+	"time"
+)
+
+const (
+	// subscriberBuffer is how many events a subscriber can fall behind
+	// before it is dropped and has to reconnect
+	subscriberBuffer = 256
+	// replayPageSize is how many events are read from the outbox at a time
+	// when replaying for a subscriber
+	replayPageSize = 500
+	// gapTimeout is how long a skipped sequence number is watched for. A
+	// transaction that commits after a later one leaves a temporary gap.
+	gapTimeout = 10 * time.Second
+	// maxGaps bounds the skipped sequence numbers tracked at once
+	maxGaps = 1000
+)
+
+// Broadcaster tails the outbox and fans new events out to live
+// subscribers, such as the SSE stream. Every instance tails the outbox on
+// its own, so subscribers see every event whichever relay publishes it.
+// The most recent events are kept in a ring buffer so that reconnecting
+// subscribers can resume where they left off.
+type Broadcaster struct {
+	outbox       repository.OutboxRepository
+	pollInterval time.Duration
+	size         int
+
+	mutex       sync.Mutex
+	buffer      []models.Event
+	next        int
+	last        uint
+	gaps        map[uint]time.Time
+	subscribers map[*subscriber]struct{}
+}
+
+type subscriber struct {
+	events chan models.Event
+}
+
+// NewBroadcaster creates a broadcaster that polls outbox every
+// pollInterval and keeps the last bufferSize events
+func NewBroadcaster(outbox repository.OutboxRepository, bufferSize int, pollInterval time.Duration) *Broadcaster {
+	return &Broadcaster{
+		outbox:       outbox,
+		pollInterval: pollInterval,
+		size:         bufferSize,
+		gaps:         make(map[uint]time.Time),
+		subscribers:  make(map[*subscriber]struct{}),
+	}
+}
+
+// Run fills the buffer with the latest events and then polls for new ones
+// until ctx is cancelled
+func (b *Broadcaster) Run(ctx context.Context) {
+	if err := b.Load(); err != nil {
+		log.Printf("Event stream load error: %v", err)
+	}
+
+	ticker := time.NewTicker(b.pollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		if err := b.Poll(); err != nil {
+			log.Printf("Event stream poll error: %v", err)
+		}
+	}
+}
+
+// Load fills the buffer with the latest events without publishing them, so
+// that only changes made from now on are streamed live
+func (b *Broadcaster) Load() error {
+	events, err := b.outbox.Latest(b.size)
+	if err != nil {
+		return err
+	}
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for _, event := range events {
+		b.store(event)
+	}
+	return nil
+}
+
+// Poll reads events added to the outbox since the last poll and publishes
+// them to subscribers
+func (b *Broadcaster) Poll() error {
+	b.mutex.Lock()
+	from := b.last
+	for sequence := range b.gaps {
+		if sequence <= from {
+			from = sequence - 1
+		}
+	}
+	b.mutex.Unlock()
+
+	for {
+		events, err := b.outbox.ListAfter(from, replayPageSize)
+		if err != nil {
+			return err
+		}
+
+		b.mutex.Lock()
+		for _, event := range events {
+			b.publish(event)
+		}
+		b.expireGaps()
+		b.mutex.Unlock()
+
+		if len(events) < replayPageSize {
+			return nil
+		}
+		from = events[len(events)-1].Sequence
+	}
+}
+
+// publish buffers event and sends it to every subscriber, unless it has
+// been seen before. The caller must hold the mutex.
+func (b *Broadcaster) publish(event models.Event) {
+	if _, gap := b.gaps[event.Sequence]; gap {
+		delete(b.gaps, event.Sequence)
+	} else if event.Sequence <= b.last {
+		return
+	}
+	b.store(event)
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			// Too far behind; the client resumes from its last event ID
+			// when it reconnects
+			delete(b.subscribers, sub)
+			close(sub.events)
+		}
+	}
+}
+
+// store adds event to the ring buffer, noting any sequence numbers it
+// skips over. The caller must hold the mutex.
+func (b *Broadcaster) store(event models.Event) {
+	if event.Sequence > b.last {
+		if b.last > 0 {
+			now := time.Now()
+			for sequence := b.last + 1; sequence < event.Sequence && len(b.gaps) < maxGaps; sequence++ {
+				b.gaps[sequence] = now
+			}
+		}
+		b.last = event.Sequence
+	}
+
+	if len(b.buffer) < b.size {
+		b.buffer = append(b.buffer, event)
+		return
+	}
+	b.buffer[b.next] = event
+	b.next = (b.next + 1) % b.size
+}
+
+// expireGaps stops watching for sequence numbers that never showed up,
+// e.g. from rolled back transactions. The caller must hold the mutex.
+func (b *Broadcaster) expireGaps() {
+	for sequence, since := range b.gaps {
+		if time.Since(since) > gapTimeout {
+			delete(b.gaps, sequence)
+		}
+	}
+}
+
+// Subscription is a live feed of events for one subscriber
+type Subscription struct {
+	// C receives the replayed events and then live ones. It is closed when
+	// the subscriber falls too far behind or the subscription is closed.
+	C <-chan models.Event
+
+	broadcaster *Broadcaster
+	sub         *subscriber
+	done        chan struct{}
+	closeOnce   sync.Once
+}
+
+// Subscribe starts a feed of new events. If resume is set, events after
+// the sequence lastEventID are replayed first: from the buffer if it still
+// holds them, otherwise from the outbox.
+func (b *Broadcaster) Subscribe(resume bool, lastEventID uint) *Subscription {
+	sub := &subscriber{events: make(chan models.Event, subscriberBuffer)}
+
+	b.mutex.Lock()
+	b.subscribers[sub] = struct{}{}
+	var backlog []models.Event
+	fromOutbox := false
+	if resume {
+		backlog, fromOutbox = b.backlog(lastEventID)
+	}
+	b.mutex.Unlock()
+
+	out := make(chan models.Event)
+	s := &Subscription{
+		C:           out,
+		broadcaster: b,
+		sub:         sub,
+		done:        make(chan struct{}),
+	}
+	go s.forward(out, backlog, fromOutbox, lastEventID)
+	return s
+}
+
+// backlog returns the buffered events after lastEventID in the order they
+// were published, or reports that the buffer no longer reaches back that
+// far. The caller must hold the mutex.
+func (b *Broadcaster) backlog(lastEventID uint) ([]models.Event, bool) {
+	if len(b.buffer) == 0 {
+		return nil, lastEventID < b.last
+	}
+
+	oldest := b.buffer[0].Sequence
+	for _, event := range b.buffer {
+		if event.Sequence < oldest {
+			oldest = event.Sequence
+		}
+	}
+	if lastEventID+1 < oldest {
+		return nil, true
+	}
+
+	var backlog []models.Event
+	for i := range b.buffer {
+		event := b.buffer[(b.next+i)%len(b.buffer)]
+		if event.Sequence > lastEventID {
+			backlog = append(backlog, event)
+		}
+	}
+	return backlog, false
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	s.closeOnce.Do(func() {
+		close(s.done)
+		b := s.broadcaster
+		b.mutex.Lock()
+		if _, ok := b.subscribers[s.sub]; ok {
+			delete(b.subscribers, s.sub)
+			close(s.sub.events)
+		}
+		b.mutex.Unlock()
+	})
+}
+
+// forward sends the backlog, or the events replayed from the outbox, and
+// then the live events to out
+func (s *Subscription) forward(out chan<- models.Event, backlog []models.Event, fromOutbox bool, lastEventID uint) {
+	defer close(out)
+
+	send := func(event models.Event) bool {
+		select {
+		case out <- event:
+			return true
+		case <-s.done:
+			return false
+		}
+	}
+
+	for _, event := range backlog {
+		if !send(event) {
+			return
+		}
+	}
+
+	// Live events that were also replayed from the outbox are skipped
+	replayed := uint(0)
+	if fromOutbox {
+		for cursor := lastEventID; ; {
+			events, err := s.broadcaster.outbox.ListAfter(cursor, replayPageSize)
+			if err != nil {
+				log.Printf("Event stream replay error: %v", err)
+				return
+			}
+			for _, event := range events {
+				if !send(event) {
+					return
+				}
+				replayed = event.Sequence
+			}
+			if len(events) < replayPageSize {
+				break
+			}
+			cursor = replayed
+		}
+	}
+
+	for {
+		select {
+		case event, ok := <-s.sub.events:
+			if !ok {
+				return
+			}
+			if event.Sequence <= replayed {
+				continue
+			}
+			if !send(event) {
+				return
+			}
+		case <-s.done:
+			return
+		}
+	}
+}
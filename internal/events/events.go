@@ -0,0 +1,48 @@
+// Package events builds domain events for user changes and relays them from
+// the outbox to sinks such as webhooks and log files.
+package events
+
+import (
+	"crypto/rand"
+	"fmt"
+	"gin-simple-app/internal/models"
+	"time"
+)
+
+// eventTypes maps audit actions to the event type they publish
+var eventTypes = map[string]string{
+	models.AuditActionCreate: models.EventUserCreated,
+	models.AuditActionUpdate: models.EventUserUpdated,
+	models.AuditActionDelete: models.EventUserDeleted,
+}
+
+// NewUserEvent builds the event for the change recorded by entry. user is
+// the user after the change, or before it for deletes.
+func NewUserEvent(entry models.AuditEntry, user *models.User) models.Event {
+	now := time.Now().UTC()
+	var snapshot *models.User
+	if user != nil {
+		userCopy := *user
+		snapshot = &userCopy
+	}
+	return models.Event{
+		EventID:       newEventID(),
+		Type:          eventTypes[entry.Action],
+		TenantID:      entry.TenantID,
+		UserID:        entry.UserID,
+		Actor:         entry.Actor,
+		RequestID:     entry.RequestID,
+		OccurredAt:    now,
+		Data:          models.EventData{User: snapshot, Changes: entry.Changes},
+		NextAttemptAt: now,
+	}
+}
+
+// newEventID returns a random UUID (version 4)
+func newEventID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16])
+}
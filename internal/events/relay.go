@@ -0,0 +1,157 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"gin-simple-app/internal/repository"
+	"log"
+	"strings"
+	"time"
+)
+
+// RelayConfig tunes the outbox relay
+type RelayConfig struct {
+	// PollInterval is how often the outbox is checked for due events
+	PollInterval time.Duration
+	// BatchSize is how many events are claimed at once
+	BatchSize int
+	// Lease is how long claimed events are hidden from other relays; it
+	// must be longer than delivering a batch takes, see Validate
+	Lease time.Duration
+	// EventTimeout bounds delivering one event to every sink
+	EventTimeout time.Duration
+	// MinBackoff and MaxBackoff bound the exponential delay between attempts
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Retention is how long published events are kept; zero keeps them forever
+	Retention time.Duration
+}
+
+// DefaultRelayConfig returns the settings used when none are configured
+func DefaultRelayConfig() RelayConfig {
+	return RelayConfig{
+		PollInterval: time.Second,
+		BatchSize:    20,
+		Lease:        5 * time.Minute,
+		EventTimeout: webhookTimeout,
+		MinBackoff:   time.Second,
+		MaxBackoff:   10 * time.Minute,
+		Retention:    7 * 24 * time.Hour,
+	}
+}
+
+// leaseMargin is how much longer than its slowest batch a lease lasts, to
+// cover the outbox writes around the deliveries
+const leaseMargin = time.Minute
+
+// Validate returns an error if a batch could outlast its lease, which would
+// let another relay claim the events still being delivered and deliver them
+// again
+func (c RelayConfig) Validate() error {
+	if c.EventTimeout <= 0 {
+		return fmt.Errorf("event timeout must be positive")
+	}
+	if batch := time.Duration(c.BatchSize)*c.EventTimeout + leaseMargin; c.Lease < batch {
+		return fmt.Errorf("lease of %s is shorter than the %s a batch of %d events can take", c.Lease, batch, c.BatchSize)
+	}
+	return nil
+}
+
+// Relay delivers outbox events to every sink. An event is marked published
+// only once all sinks have accepted it; otherwise it is retried with
+// exponential backoff, forever, so no event is lost.
+type Relay struct {
+	outbox repository.OutboxRepository
+	sinks  []Sink
+	config RelayConfig
+}
+
+// NewRelay creates a relay from outbox to sinks
+func NewRelay(outbox repository.OutboxRepository, sinks []Sink, config RelayConfig) *Relay {
+	return &Relay{
+		outbox: outbox,
+		sinks:  sinks,
+		config: config,
+	}
+}
+
+// Run relays events until ctx is cancelled
+func (r *Relay) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.config.PollInterval)
+	defer ticker.Stop()
+	lastPurge := time.Time{}
+
+	for {
+		// Drain everything that is due before waiting for the next tick
+		for {
+			n, err := r.RelayOnce(ctx)
+			if err != nil {
+				log.Printf("Outbox relay error: %v", err)
+			}
+			if err != nil || n < r.config.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		if r.config.Retention > 0 && time.Since(lastPurge) > time.Hour {
+			if _, err := r.outbox.DeletePublishedBefore(time.Now().Add(-r.config.Retention)); err != nil {
+				log.Printf("Outbox purge error: %v", err)
+			}
+			lastPurge = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RelayOnce claims one batch of due events and delivers it, returning how
+// many events were claimed
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.outbox.Claim(time.Now(), r.config.Lease, r.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		var failures []string
+		eventCtx, cancel := context.WithTimeout(ctx, r.config.EventTimeout)
+		for _, sink := range r.sinks {
+			if err := sink.Deliver(eventCtx, event); err != nil {
+				failures = append(failures, fmt.Sprintf("%s: %v", sink.Name(), err))
+			}
+		}
+		cancel()
+
+		if len(failures) == 0 {
+			if err := r.outbox.MarkPublished(event.Sequence, time.Now()); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+
+		lastError := strings.Join(failures, "; ")
+		delay := Backoff(event.Attempts, r.config.MinBackoff, r.config.MaxBackoff)
+		log.Printf("Event %s (%s) failed on attempt %d, retrying in %s: %s", event.EventID, event.Type, event.Attempts, delay, lastError)
+		if err := r.outbox.MarkFailed(event.Sequence, time.Now().Add(delay), lastError); err != nil {
+			return len(events), err
+		}
+	}
+	return len(events), nil
+}
+
+// Backoff returns the delay after the given number of failed attempts:
+// min after the first, doubling after each one after that, up to max
+func Backoff(attempts int, min, max time.Duration) time.Duration {
+	delay := min
+	for i := 1; i < attempts && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
@@ -0,0 +1,123 @@
+package events
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/models"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// Sink receives relayed events. Delivery is at least once: an event can
+// arrive more than once, e.g. when another sink failed and the event is
+// retried, so receivers should skip event IDs they have already seen.
+type Sink interface {
+	Name() string
+	Deliver(ctx context.Context, event models.Event) error
+}
+
+// Event headers sent by WebhookSink
+const (
+	EventIDHeader   = "X-Event-ID"
+	EventTypeHeader = "X-Event-Type"
+)
+
+// webhookTimeout bounds a single webhook delivery
+const webhookTimeout = 10 * time.Second
+
+// WebhookSink POSTs each event as JSON to a URL. Any 2xx response counts
+// as delivered.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhookSink creates a sink that posts events to url
+func NewWebhookSink(url string) *WebhookSink {
+	return &WebhookSink{
+		url:    url,
+		client: &http.Client{Timeout: webhookTimeout},
+	}
+}
+
+// Name identifies the sink in logs
+func (s *WebhookSink) Name() string {
+	return "webhook " + s.url
+}
+
+// Deliver posts event to the webhook URL
+func (s *WebhookSink) Deliver(ctx context.Context, event models.Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(EventIDHeader, event.EventID)
+	req.Header.Set(EventTypeHeader, event.Type)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with %s", resp.Status)
+	}
+	return nil
+}
+
+// WriterSink writes each event as a line of JSON, for stdout or a log file
+// picked up by a shipper
+type WriterSink struct {
+	name   string
+	writer io.Writer
+	mutex  sync.Mutex
+}
+
+// NewWriterSink creates a sink that writes events to w
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{
+		name:   name,
+		writer: w,
+	}
+}
+
+// NewFileSink creates a sink that appends events to the file at path, or
+// writes them to stdout if path is "-"
+func NewFileSink(path string) (*WriterSink, error) {
+	if path == "-" {
+		return NewWriterSink("stdout", os.Stdout), nil
+	}
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event file: %w", err)
+	}
+	return NewWriterSink("file "+path, file), nil
+}
+
+// Name identifies the sink in logs
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+// Deliver writes event as one line
+func (s *WriterSink) Deliver(_ context.Context, event models.Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	_, err = s.writer.Write(append(line, '\n'))
+	return err
+}
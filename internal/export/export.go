@@ -0,0 +1,135 @@
+package export
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/pkg/response"
+	"io"
+	"strconv"
+	"time"
+)
+
+// Format identifies a supported export file format
+type Format string
+
+const (
+	FormatCSV    Format = "csv"
+	FormatNDJSON Format = "ndjson"
+	FormatXLSX   Format = "xlsx"
+)
+
+// ErrUnsupportedFormat is returned when an unknown export format is requested
+var ErrUnsupportedFormat = errors.New("unsupported export format")
+
+// columns are the exported user fields, in output order
+var columns = []string{"id", "name", "email", "phone", "address", "created_at", "updated_at"}
+
+// Writer writes users to an output stream one at a time
+type Writer interface {
+	Write(user *models.User) error
+	Close() error
+}
+
+// ParseFormat validates a format name
+func ParseFormat(name string) (Format, error) {
+	switch f := Format(name); f {
+	case FormatCSV, FormatNDJSON, FormatXLSX:
+		return f, nil
+	}
+	return "", ErrUnsupportedFormat
+}
+
+// ContentType returns the MIME type for the format
+func (f Format) ContentType() string {
+	switch f {
+	case FormatNDJSON:
+		return "application/x-ndjson"
+	case FormatXLSX:
+		return "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"
+	default:
+		return "text/csv; charset=utf-8"
+	}
+}
+
+// Filename returns a download file name for an export generated at t
+func (f Format) Filename(t time.Time) string {
+	return "users-" + t.UTC().Format("20060102-150405") + "." + string(f)
+}
+
+// NewWriter creates a Writer for the given format
+func NewWriter(format Format, w io.Writer) (Writer, error) {
+	switch format {
+	case FormatCSV:
+		return newCSVWriter(w), nil
+	case FormatNDJSON:
+		return &ndjsonWriter{enc: json.NewEncoder(w)}, nil
+	case FormatXLSX:
+		return newXLSXWriter(w)
+	}
+	return nil, ErrUnsupportedFormat
+}
+
+// record converts a user into string values matching columns
+func record(user *models.User) []string {
+	return []string{
+		strconv.FormatUint(uint64(user.ID), 10),
+		user.Name,
+		user.Email,
+		deref(user.Phone),
+		deref(user.Address),
+		user.CreatedAt.UTC().Format(time.RFC3339),
+		user.UpdatedAt.UTC().Format(time.RFC3339),
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// csvWriter writes users as comma-separated values with a header row
+type csvWriter struct {
+	w *csv.Writer
+}
+
+func newCSVWriter(w io.Writer) *csvWriter {
+	cw := csv.NewWriter(w)
+	// Header errors surface on the first Write or Close via cw.Error
+	_ = cw.Write(columns)
+	return &csvWriter{w: cw}
+}
+
+func (c *csvWriter) Write(user *models.User) error {
+	values := record(user)
+	// XLSX cells are written as text, but CSV cells are evaluated when
+	// opened in a spreadsheet
+	for i, value := range values {
+		values[i] = response.EscapeFormula(value)
+	}
+	if err := c.w.Write(values); err != nil {
+		return err
+	}
+	return c.w.Error()
+}
+
+func (c *csvWriter) Close() error {
+	c.w.Flush()
+	return c.w.Error()
+}
+
+// ndjsonWriter writes one JSON object per line
+type ndjsonWriter struct {
+	enc *json.Encoder
+}
+
+func (n *ndjsonWriter) Write(user *models.User) error {
+	return n.enc.Encode(user)
+}
+
+func (n *ndjsonWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,96 @@
+package export
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"gin-simple-app/internal/models"
+	"io"
+	"strconv"
+)
+
+// Static parts of a minimal single-sheet SpreadsheetML package
+var xlsxParts = []struct {
+	name    string
+	content string
+}{
+	{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+		`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+		`<Default Extension="xml" ContentType="application/xml"/>` +
+		`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+		`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+		`</Types>`},
+	{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+		`</Relationships>`},
+	{"xl/workbook.xml", xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+		`<sheets><sheet name="Users" sheetId="1" r:id="rId1"/></sheets>` +
+		`</workbook>`},
+	{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+		`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+		`</Relationships>`},
+}
+
+// xlsxWriter streams users into a single worksheet. The sheet is written
+// row by row directly into the zip stream, so memory use stays constant
+// regardless of the number of users.
+type xlsxWriter struct {
+	zw    *zip.Writer
+	sheet *bufio.Writer
+}
+
+func newXLSXWriter(w io.Writer) (*xlsxWriter, error) {
+	zw := zip.NewWriter(w)
+	for _, part := range xlsxParts {
+		f, err := zw.Create(part.name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, part.content); err != nil {
+			return nil, err
+		}
+	}
+
+	f, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	x := &xlsxWriter{zw: zw, sheet: bufio.NewWriter(f)}
+	x.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err := x.writeRow(columns, false); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+func (x *xlsxWriter) Write(user *models.User) error {
+	return x.writeRow(record(user), true)
+}
+
+func (x *xlsxWriter) Close() error {
+	x.sheet.WriteString(`</sheetData></worksheet>`)
+	if err := x.sheet.Flush(); err != nil {
+		return err
+	}
+	return x.zw.Close()
+}
+
+// writeRow writes one sheet row; when numericID is set the first cell is
+// written as a number so spreadsheets sort it correctly. bufio.Writer errors
+// are sticky, so only the final write needs checking.
+func (x *xlsxWriter) writeRow(values []string, numericID bool) error {
+	x.sheet.WriteString("<row>")
+	for i, v := range values {
+		if i == 0 && numericID {
+			if _, err := strconv.ParseUint(v, 10, 64); err == nil {
+				x.sheet.WriteString("<c><v>" + v + "</v></c>")
+				continue
+			}
+		}
+		x.sheet.WriteString(`<c t="inlineStr"><is><t xml:space="preserve">`)
+		xml.EscapeText(x.sheet, []byte(v))
+		x.sheet.WriteString("</t></is></c>")
+	}
+	_, err := x.sheet.WriteString("</row>")
+	return err
+}
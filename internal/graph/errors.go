@@ -0,0 +1,93 @@
+package graph
+
+import (
+	"errors"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/pkg/response"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+)
+
+// Error codes set in the "code" extension of GraphQL errors
+const (
+	CodeValidationFailed = response.CodeValidationFailed
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeInternal         = "internal"
+)
+
+// errUserNotFound matches the error the user service returns for unknown IDs
+var errUserNotFound = errors.New("user not found")
+
+// Error is a resolver error carrying GraphQL error extensions
+type Error struct {
+	Message string
+	Code    string
+	Fields  []response.FieldError
+}
+
+// Error returns the message shown in the GraphQL response
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Extensions returns the "extensions" of the GraphQL error
+func (e *Error) Extensions() map[string]interface{} {
+	extensions := map[string]interface{}{"code": e.Code}
+	if len(e.Fields) > 0 {
+		extensions["fields"] = e.Fields
+	}
+	return extensions
+}
+
+// validationError converts the errors of validating an input into an Error,
+// in the language of acceptLanguage. Field paths use the GraphQL names.
+func validationError(err error, acceptLanguage string) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return &Error{Message: err.Error(), Code: CodeValidationFailed}
+	}
+	fields := response.TranslateValidationErrors(verrs, acceptLanguage)
+	for i := range fields {
+		fields[i].Field = camelPath(fields[i].Field)
+	}
+	return &Error{
+		Message: response.JoinMessages(fields),
+		Code:    CodeValidationFailed,
+		Fields:  fields,
+	}
+}
+
+// serviceError maps an error returned by the user service to an Error
+func serviceError(err error) error {
+	switch {
+	case err.Error() == "user not found":
+		return &Error{Message: "User not found", Code: CodeNotFound}
+	case err.Error() == "user with this email already exists":
+		return &Error{Message: "User with this email already exists", Code: CodeConflict}
+	case errors.Is(err, phone.ErrInvalidPhone):
+		return &Error{
+			Message: "Invalid phone number",
+			Code:    CodeValidationFailed,
+			Fields:  []response.FieldError{{Field: "input.phone", Code: "phone", Message: "Invalid phone number"}},
+		}
+	}
+	return &Error{Message: "Internal server error", Code: CodeInternal}
+}
+
+// camelPath converts a snake_case field path such as
+// "postal_address.postal_code" to "input.postalAddress.postalCode"
+func camelPath(path string) string {
+	parts := strings.Split(path, ".")
+	for i, part := range parts {
+		words := strings.Split(part, "_")
+		for j := 1; j < len(words); j++ {
+			if words[j] != "" {
+				words[j] = strings.ToUpper(words[j][:1]) + words[j][1:]
+			}
+		}
+		parts[i] = strings.Join(words, "")
+	}
+	return "input." + strings.Join(parts, ".")
+}
@@ -0,0 +1,417 @@
+// Package graph serves the user service over GraphQL.
+package graph
+
+import (
+	"context"
+	_ "embed"
+	"encoding/base64"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/pkg/response"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	graphql "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+const (
+	// defaultPageSize is the page size when first is not given
+	defaultPageSize = 20
+	// maxPageSize bounds the first argument of connections
+	maxPageSize = 100
+	// maxDepth bounds how deeply queries can nest
+	maxDepth = 10
+	// cursorPrefix marks user cursors, which are opaque to clients
+	cursorPrefix = "user:"
+)
+
+// NewSchema parses the schema with resolvers backed by userService
+func NewSchema(userService services.UserService) (*graphql.Schema, error) {
+	return graphql.ParseSchema(schemaSDL, &Resolver{userService: userService},
+		graphql.UseStringDescriptions(),
+		graphql.MaxDepth(maxDepth),
+	)
+}
+
+// Schema returns the schema definition
+func Schema() string {
+	return schemaSDL
+}
+
+type acceptLanguageKey struct{}
+
+// WithAcceptLanguage returns a copy of ctx in which validation messages are
+// translated according to acceptLanguage
+func WithAcceptLanguage(ctx context.Context, acceptLanguage string) context.Context {
+	return context.WithValue(ctx, acceptLanguageKey{}, acceptLanguage)
+}
+
+func acceptLanguage(ctx context.Context) string {
+	lang, _ := ctx.Value(acceptLanguageKey{}).(string)
+	return lang
+}
+
+// Resolver is the root resolver for queries and mutations
+type Resolver struct {
+	userService services.UserService
+}
+
+// User resolves Query.user
+func (r *Resolver) User(ctx context.Context, args struct{ ID graphql.ID }) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, nil
+	}
+	user, err := r.userService.GetUserByID(ctx, id)
+	if err != nil {
+		if err.Error() == "user not found" {
+			return nil, nil
+		}
+		return nil, serviceError(err)
+	}
+	return &userResolver{user: user}, nil
+}
+
+// usersArgs are the arguments of Query.users
+type usersArgs struct {
+	Filter *userFilterInput
+	First  *int32
+	After  *string
+}
+
+// userFilterInput is the UserFilter input
+type userFilterInput struct {
+	Name    *string
+	Email   *string
+	City    *string
+	Country *string
+}
+
+// Users resolves Query.users. Users are paged by ID so cursors stay valid
+// as users are added and removed.
+func (r *Resolver) Users(ctx context.Context, args usersArgs) (*connectionResolver, error) {
+	var filter models.UserFilter
+	if args.Filter != nil {
+		filter = models.UserFilter{
+			Name:    deref(args.Filter.Name),
+			Email:   deref(args.Filter.Email),
+			City:    deref(args.Filter.City),
+			Country: deref(args.Filter.Country),
+		}
+	}
+	if err := binding.Validator.ValidateStruct(filter); err != nil {
+		return nil, validationError(err, acceptLanguage(ctx))
+	}
+
+	first := defaultPageSize
+	if args.First != nil {
+		first = int(*args.First)
+	}
+	if first < 0 || first > maxPageSize {
+		return nil, argumentError("first", "first must be between 0 and "+strconv.Itoa(maxPageSize))
+	}
+	var after uint
+	if args.After != nil {
+		id, ok := decodeCursor(*args.After)
+		if !ok {
+			return nil, argumentError("after", "after is not a valid cursor")
+		}
+		after = id
+	}
+
+	page, err := r.userService.ListUsersPage(ctx, filter, after, first)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	// Whether users precede the cursor would take another query; a cursor
+	// is only ever handed out after some user, so it stands in for one
+	return &connectionResolver{
+		users:           page.Users,
+		total:           int(page.Total),
+		hasNextPage:     page.HasNextPage,
+		hasPreviousPage: after != 0,
+	}, nil
+}
+
+// userInput is the CreateUserInput and UpdateUserInput input
+type userInput struct {
+	Name          string
+	Email         string
+	Phone         string
+	Address       *string
+	PostalAddress *addressInput
+}
+
+// addressInput is the AddressInput input
+type addressInput struct {
+	Line1      string
+	Line2      *string
+	City       string
+	Region     *string
+	PostalCode *string
+	Country    string
+}
+
+func (in *addressInput) toModel() *models.Address {
+	if in == nil {
+		return nil
+	}
+	return &models.Address{
+		Line1:      in.Line1,
+		Line2:      deref(in.Line2),
+		City:       in.City,
+		Region:     deref(in.Region),
+		PostalCode: deref(in.PostalCode),
+		Country:    strings.ToUpper(in.Country),
+	}
+}
+
+// CreateUser resolves Mutation.createUser
+func (r *Resolver) CreateUser(ctx context.Context, args struct{ Input userInput }) (*userResolver, error) {
+	req := models.CreateUserRequest{
+		Name:          args.Input.Name,
+		Email:         args.Input.Email,
+		Phone:         args.Input.Phone,
+		Address:       args.Input.Address,
+		PostalAddress: args.Input.PostalAddress.toModel(),
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, validationError(err, acceptLanguage(ctx))
+	}
+
+	user, err := r.userService.CreateUser(ctx, req)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &userResolver{user: user}, nil
+}
+
+// UpdateUser resolves Mutation.updateUser
+func (r *Resolver) UpdateUser(ctx context.Context, args struct {
+	ID    graphql.ID
+	Input userInput
+}) (*userResolver, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	req := models.UpdateUserRequest{
+		Name:          args.Input.Name,
+		Email:         args.Input.Email,
+		Phone:         args.Input.Phone,
+		Address:       args.Input.Address,
+		PostalAddress: args.Input.PostalAddress.toModel(),
+	}
+	if err := binding.Validator.ValidateStruct(req); err != nil {
+		return nil, validationError(err, acceptLanguage(ctx))
+	}
+
+	user, err := r.userService.UpdateUser(ctx, id, req)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return &userResolver{user: user}, nil
+}
+
+// DeleteUser resolves Mutation.deleteUser
+func (r *Resolver) DeleteUser(ctx context.Context, args struct{ ID graphql.ID }) (graphql.ID, error) {
+	id, err := parseID(args.ID)
+	if err != nil {
+		return "", serviceError(err)
+	}
+	if err := r.userService.DeleteUser(ctx, id); err != nil {
+		return "", serviceError(err)
+	}
+	return args.ID, nil
+}
+
+// userResolver resolves the User type
+type userResolver struct {
+	user *models.User
+}
+
+func (r *userResolver) ID() graphql.ID {
+	return graphql.ID(strconv.FormatUint(uint64(r.user.ID), 10))
+}
+
+func (r *userResolver) Name() string {
+	return r.user.Name
+}
+
+func (r *userResolver) Email() string {
+	return r.user.Email
+}
+
+func (r *userResolver) Phone() *string {
+	return r.user.Phone
+}
+
+func (r *userResolver) PhoneOriginal() *string {
+	return r.user.PhoneOriginal
+}
+
+func (r *userResolver) Address() *string {
+	return r.user.Address
+}
+
+func (r *userResolver) PostalAddress() *addressResolver {
+	if r.user.PostalAddress == nil {
+		return nil
+	}
+	return &addressResolver{address: r.user.PostalAddress}
+}
+
+func (r *userResolver) CreatedAt() graphql.Time {
+	return graphql.Time{Time: r.user.CreatedAt}
+}
+
+func (r *userResolver) UpdatedAt() graphql.Time {
+	return graphql.Time{Time: r.user.UpdatedAt}
+}
+
+// addressResolver resolves the Address type
+type addressResolver struct {
+	address *models.Address
+}
+
+func (r *addressResolver) Line1() string {
+	return r.address.Line1
+}
+
+func (r *addressResolver) Line2() *string {
+	return optional(r.address.Line2)
+}
+
+func (r *addressResolver) City() string {
+	return r.address.City
+}
+
+func (r *addressResolver) Region() *string {
+	return optional(r.address.Region)
+}
+
+func (r *addressResolver) PostalCode() *string {
+	return optional(r.address.PostalCode)
+}
+
+func (r *addressResolver) Country() string {
+	return r.address.Country
+}
+
+func (r *addressResolver) Formatted() string {
+	return r.address.String()
+}
+
+// connectionResolver resolves the UserConnection and PageInfo types
+type connectionResolver struct {
+	users           []models.User
+	total           int
+	hasNextPage     bool
+	hasPreviousPage bool
+}
+
+func (r *connectionResolver) Edges() []*edgeResolver {
+	edges := make([]*edgeResolver, len(r.users))
+	for i := range r.users {
+		edges[i] = &edgeResolver{user: &r.users[i]}
+	}
+	return edges
+}
+
+func (r *connectionResolver) PageInfo() *connectionResolver {
+	return r
+}
+
+func (r *connectionResolver) TotalCount() int32 {
+	return int32(r.total)
+}
+
+func (r *connectionResolver) HasNextPage() bool {
+	return r.hasNextPage
+}
+
+func (r *connectionResolver) HasPreviousPage() bool {
+	return r.hasPreviousPage
+}
+
+func (r *connectionResolver) StartCursor() *string {
+	if len(r.users) == 0 {
+		return nil
+	}
+	cursor := encodeCursor(r.users[0].ID)
+	return &cursor
+}
+
+func (r *connectionResolver) EndCursor() *string {
+	if len(r.users) == 0 {
+		return nil
+	}
+	cursor := encodeCursor(r.users[len(r.users)-1].ID)
+	return &cursor
+}
+
+// edgeResolver resolves the UserEdge type
+type edgeResolver struct {
+	user *models.User
+}
+
+func (r *edgeResolver) Cursor() string {
+	return encodeCursor(r.user.ID)
+}
+
+func (r *edgeResolver) Node() *userResolver {
+	return &userResolver{user: r.user}
+}
+
+func encodeCursor(id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(cursorPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodeCursor(cursor string) (uint, bool) {
+	b, err := base64.StdEncoding.DecodeString(cursor)
+	if err != nil {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(b), cursorPrefix), 10, 32)
+	if err != nil || !strings.HasPrefix(string(b), cursorPrefix) {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// parseID converts a GraphQL ID to a user ID. IDs that cannot belong to a
+// user are reported as not found.
+func parseID(id graphql.ID) (uint, error) {
+	n, err := strconv.ParseUint(string(id), 10, 32)
+	if err != nil {
+		return 0, errUserNotFound
+	}
+	return uint(n), nil
+}
+
+// argumentError reports an invalid field argument
+func argumentError(argument, message string) error {
+	return &Error{
+		Message: message,
+		Code:    CodeValidationFailed,
+		Fields:  []response.FieldError{{Field: argument, Code: "invalid", Message: message}},
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func optional(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
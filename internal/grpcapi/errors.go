@@ -0,0 +1,70 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/pkg/response"
+
+	"github.com/go-playground/validator/v10"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// validationError converts the errors of validating a request into an
+// INVALID_ARGUMENT status with a BadRequest detail, in the language of the
+// accept-language metadata. Field paths are prefixed with prefix.
+func validationError(ctx context.Context, err error, prefix string) error {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	fields := response.TranslateValidationErrors(verrs, acceptLanguage(ctx))
+	violations := make([]*errdetails.BadRequest_FieldViolation, len(fields))
+	for i, field := range fields {
+		violations[i] = &errdetails.BadRequest_FieldViolation{
+			Field:       prefix + field.Field,
+			Description: field.Message,
+		}
+	}
+	return badRequest(response.JoinMessages(fields), violations...)
+}
+
+// argumentError reports an invalid request field
+func argumentError(field, message string) error {
+	return badRequest(message, &errdetails.BadRequest_FieldViolation{Field: field, Description: message})
+}
+
+// serviceError maps an error returned by the user service to a status
+func serviceError(err error) error {
+	switch {
+	case err.Error() == "user not found":
+		return status.Error(codes.NotFound, "User not found")
+	case err.Error() == "user with this email already exists":
+		return status.Error(codes.AlreadyExists, "User with this email already exists")
+	case errors.Is(err, phone.ErrInvalidPhone):
+		return argumentError("phone", "Invalid phone number")
+	}
+	return status.Error(codes.Internal, "Internal server error")
+}
+
+// badRequest builds an INVALID_ARGUMENT status listing violations
+func badRequest(message string, violations ...*errdetails.BadRequest_FieldViolation) error {
+	st := status.New(codes.InvalidArgument, message)
+	withDetails, err := st.WithDetails(&errdetails.BadRequest{FieldViolations: violations})
+	if err != nil {
+		return st.Err()
+	}
+	return withDetails.Err()
+}
+
+// acceptLanguage returns the accept-language metadata of the call
+func acceptLanguage(ctx context.Context) string {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get("accept-language"); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
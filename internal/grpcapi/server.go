@@ -0,0 +1,109 @@
+package grpcapi
+
+import (
+	"context"
+	"gin-simple-app/internal/audit"
+	"gin-simple-app/internal/grpcapi/userspb"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/pkg/response"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+)
+
+// RequestIDMetadata carries the request ID in both directions, like the
+// X-Request-ID header of the REST API
+const RequestIDMetadata = "x-request-id"
+
+// NewServer creates a gRPC server serving users, the standard health
+// service and server reflection. Calls are attributed to their client in
+// the audit log the same way REST requests are, from the x-api-key and
+// authorization metadata or the peer address.
+func NewServer(users *UserServer, opts ...grpc.ServerOption) (*grpc.Server, error) {
+	// Requests are validated with the same rules and messages as REST
+	if err := phone.RegisterValidator(); err != nil {
+		return nil, err
+	}
+	if err := response.SetupValidator(); err != nil {
+		return nil, err
+	}
+
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(unaryCallContext),
+		grpc.ChainStreamInterceptor(streamCallContext),
+	)
+	srv := grpc.NewServer(opts...)
+	userspb.RegisterUserServiceServer(srv, users)
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("", healthpb.HealthCheckResponse_SERVING)
+	healthServer.SetServingStatus(userspb.UserService_ServiceDesc.ServiceName, healthpb.HealthCheckResponse_SERVING)
+	healthpb.RegisterHealthServer(srv, healthServer)
+
+	reflection.Register(srv)
+	return srv, nil
+}
+
+// unaryCallContext records the actor and request ID of unary calls
+func unaryCallContext(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, requestID := callContext(ctx)
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDMetadata, requestID))
+	return handler(ctx, req)
+}
+
+// streamCallContext records the actor and request ID of streaming calls
+func streamCallContext(srv interface{}, ss grpc.ServerStream, _ *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, requestID := callContext(ss.Context())
+	_ = ss.SetHeader(metadata.Pairs(RequestIDMetadata, requestID))
+	return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// callContext returns ctx carrying the actor and request ID of the call
+func callContext(ctx context.Context) (context.Context, string) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ip := peerIP(ctx)
+	claimed := middleware.ClaimedIdentity(
+		firstValue(md, strings.ToLower(middleware.APIKeyHeader)),
+		firstValue(md, "authorization"),
+	)
+	requestID := middleware.RequestIDOrNew(firstValue(md, RequestIDMetadata))
+
+	ctx = audit.WithActor(ctx, middleware.ClientAddress(ip))
+	ctx = audit.WithClaimedActor(ctx, claimed)
+	ctx = audit.WithRequestID(ctx, requestID)
+	return ctx, requestID
+}
+
+// peerIP returns the IP address of the peer of a call, or "" if it is unknown
+func peerIP(ctx context.Context) string {
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		if host, _, err := net.SplitHostPort(p.Addr.String()); err == nil {
+			return host
+		}
+	}
+	return ""
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+// contextStream is a ServerStream with a replaced context
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
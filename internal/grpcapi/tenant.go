@@ -0,0 +1,85 @@
+package grpcapi
+
+import (
+	"context"
+	"errors"
+	"gin-simple-app/internal/grpcapi/userspb"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"log"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// TenantMetadata names the tenant of a call by slug, like the X-Tenant
+// header of the REST API
+const TenantMetadata = "x-tenant"
+
+// WithTenants returns the server options that serve every call for the
+// tenant it names in its x-tenant metadata, if a trusted proxy sent it, the
+// claim of its signed bearer token or its authority, looked up in tenants
+// as cfg describes. The authority only counts alongside one of the others.
+// Without them, every call is for the default tenant. Health checks and
+// reflection name no tenant.
+func WithTenants(tenants repository.TenantRepository, cfg middleware.TenantConfig) []grpc.ServerOption {
+	resolve := func(ctx context.Context) (context.Context, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		var header string
+		if cfg.TrustsProxy(peerIP(ctx)) {
+			header = firstValue(md, TenantMetadata)
+		}
+		slug, err := middleware.TenantSlug(header, firstValue(md, "authorization"), firstValue(md, ":authority"), cfg)
+		var tenantID uint
+		if err == nil {
+			tenantID, err = middleware.ResolveTenant(tenants, slug, cfg)
+		}
+		switch {
+		case errors.Is(err, middleware.ErrInvalidToken):
+			return nil, status.Error(codes.Unauthenticated, "Invalid bearer token")
+		case errors.Is(err, middleware.ErrUnverifiedTenant):
+			return nil, status.Error(codes.Unauthenticated, "A bearer token naming the tenant is required")
+		case errors.Is(err, middleware.ErrTenantMismatch):
+			return nil, status.Error(codes.PermissionDenied, "The call names more than one tenant")
+		case errors.Is(err, middleware.ErrTenantRequired):
+			return nil, status.Error(codes.InvalidArgument, "A tenant is required: send the "+TenantMetadata+" metadata")
+		case errors.Is(err, middleware.ErrUnknownTenant):
+			return nil, status.Error(codes.InvalidArgument, "Unknown tenant "+slug)
+		case err != nil:
+			log.Printf("Failed to resolve tenant: %v", err)
+			return nil, status.Error(codes.Internal, "Failed to resolve tenant")
+		}
+		return tenant.WithID(ctx, tenantID), nil
+	}
+
+	scoped := func(method string) bool {
+		return strings.HasPrefix(method, "/"+userspb.UserService_ServiceDesc.ServiceName+"/")
+	}
+
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if !scoped(info.FullMethod) {
+				return handler(ctx, req)
+			}
+			ctx, err := resolve(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return handler(ctx, req)
+		}),
+		grpc.ChainStreamInterceptor(func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+			if !scoped(info.FullMethod) {
+				return handler(srv, ss)
+			}
+			ctx, err := resolve(ss.Context())
+			if err != nil {
+				return err
+			}
+			return handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+		}),
+	}
+}
@@ -0,0 +1,307 @@
+// Package grpcapi serves the user service over gRPC.
+package grpcapi
+
+import (
+	"context"
+	"encoding/base64"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/grpcapi/userspb"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/tenant"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	// defaultPageSize is the page size when page_size is not given
+	defaultPageSize = 20
+	// maxPageSize bounds page_size
+	maxPageSize = 100
+	// pageTokenPrefix marks page tokens, which are opaque to clients
+	pageTokenPrefix = "user:"
+)
+
+// watchEventTypes are the event types WatchUsers can be filtered by
+var watchEventTypes = map[string]bool{
+	models.EventUserCreated: true,
+	models.EventUserUpdated: true,
+	models.EventUserDeleted: true,
+}
+
+// UserServer implements userspb.UserServiceServer on top of the user service
+type UserServer struct {
+	userspb.UnimplementedUserServiceServer
+
+	userService services.UserService
+	broadcaster *events.Broadcaster
+}
+
+// NewUserServer creates a UserServer. WatchUsers streams the events of
+// broadcaster, and is unimplemented if broadcaster is nil.
+func NewUserServer(userService services.UserService, broadcaster *events.Broadcaster) *UserServer {
+	return &UserServer{
+		userService: userService,
+		broadcaster: broadcaster,
+	}
+}
+
+// GetUser implements UserService.GetUser
+func (s *UserServer) GetUser(ctx context.Context, req *userspb.GetUserRequest) (*userspb.User, error) {
+	id, err := userID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.userService.GetUserByID(ctx, id)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// ListUsers implements UserService.ListUsers. Users are paged by ID so page
+// tokens stay valid as users are added and removed.
+func (s *UserServer) ListUsers(ctx context.Context, req *userspb.ListUsersRequest) (*userspb.ListUsersResponse, error) {
+	filter := models.UserFilter{
+		Name:    req.GetFilter().GetName(),
+		Email:   req.GetFilter().GetEmail(),
+		City:    req.GetFilter().GetCity(),
+		Country: req.GetFilter().GetCountry(),
+	}
+	if err := binding.Validator.ValidateStruct(filter); err != nil {
+		return nil, validationError(ctx, err, "filter.")
+	}
+
+	pageSize := int(req.GetPageSize())
+	switch {
+	case pageSize == 0:
+		pageSize = defaultPageSize
+	case pageSize < 0 || pageSize > maxPageSize:
+		return nil, argumentError("page_size", "page_size must be between 0 and "+strconv.Itoa(maxPageSize))
+	}
+	var after uint
+	if req.GetPageToken() != "" {
+		id, ok := decodePageToken(req.GetPageToken())
+		if !ok {
+			return nil, argumentError("page_token", "page_token is not a valid page token")
+		}
+		after = id
+	}
+
+	page, err := s.userService.ListUsersPage(ctx, filter, after, pageSize)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	resp := &userspb.ListUsersResponse{
+		Users:     make([]*userspb.User, 0, len(page.Users)),
+		TotalSize: int32(page.Total),
+	}
+	for i := range page.Users {
+		resp.Users = append(resp.Users, toProtoUser(&page.Users[i]))
+	}
+	if page.HasNextPage {
+		resp.NextPageToken = encodePageToken(page.Users[len(page.Users)-1].ID)
+	}
+	return resp, nil
+}
+
+// CreateUser implements UserService.CreateUser
+func (s *UserServer) CreateUser(ctx context.Context, req *userspb.CreateUserRequest) (*userspb.User, error) {
+	createReq := models.CreateUserRequest{
+		Name:          req.GetName(),
+		Email:         req.GetEmail(),
+		Phone:         req.GetPhone(),
+		Address:       req.Address,
+		PostalAddress: toModelAddress(req.GetPostalAddress()),
+	}
+	if err := binding.Validator.ValidateStruct(createReq); err != nil {
+		return nil, validationError(ctx, err, "")
+	}
+
+	user, err := s.userService.CreateUser(ctx, createReq)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// UpdateUser implements UserService.UpdateUser
+func (s *UserServer) UpdateUser(ctx context.Context, req *userspb.UpdateUserRequest) (*userspb.User, error) {
+	id, err := userID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	updateReq := models.UpdateUserRequest{
+		Name:          req.GetName(),
+		Email:         req.GetEmail(),
+		Phone:         req.GetPhone(),
+		Address:       req.Address,
+		PostalAddress: toModelAddress(req.GetPostalAddress()),
+	}
+	if err := binding.Validator.ValidateStruct(updateReq); err != nil {
+		return nil, validationError(ctx, err, "")
+	}
+
+	user, err := s.userService.UpdateUser(ctx, id, updateReq)
+	if err != nil {
+		return nil, serviceError(err)
+	}
+	return toProtoUser(user), nil
+}
+
+// DeleteUser implements UserService.DeleteUser
+func (s *UserServer) DeleteUser(ctx context.Context, req *userspb.DeleteUserRequest) (*emptypb.Empty, error) {
+	id, err := userID(req.GetId())
+	if err != nil {
+		return nil, err
+	}
+	if err := s.userService.DeleteUser(ctx, id); err != nil {
+		return nil, serviceError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+// WatchUsers implements UserService.WatchUsers. Headers are sent as soon as
+// the stream is subscribed. A client that falls too far behind gets
+// UNAVAILABLE and should resume after the last sequence it saw.
+func (s *UserServer) WatchUsers(req *userspb.WatchUsersRequest, stream userspb.UserService_WatchUsersServer) error {
+	if s.broadcaster == nil {
+		return status.Error(codes.Unimplemented, "user events are not available")
+	}
+	types := make(map[string]bool)
+	for _, t := range req.GetTypes() {
+		if !watchEventTypes[t] {
+			return argumentError("types", "types must be user.created, user.updated or user.deleted")
+		}
+		types[t] = true
+	}
+
+	// Clients only see the events of their own tenant
+	tenantID := tenant.IDFromContext(stream.Context())
+	sub := s.broadcaster.Subscribe(req.AfterSequence != nil, uint(req.GetAfterSequence()))
+	defer sub.Close()
+	// Sending the headers right away tells clients they are subscribed
+	if err := stream.SendHeader(nil); err != nil {
+		return err
+	}
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "stream fell behind; resume with after_sequence")
+			}
+			if event.TenantID != tenantID || len(types) > 0 && !types[event.Type] {
+				continue
+			}
+			if err := stream.Send(toProtoEvent(event)); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// userID converts a request ID to a user ID. IDs that cannot belong to a
+// user are reported as not found.
+func userID(id uint64) (uint, error) {
+	if id == 0 || id > uint64(^uint32(0)) {
+		return 0, status.Error(codes.NotFound, "User not found")
+	}
+	return uint(id), nil
+}
+
+func encodePageToken(id uint) string {
+	return base64.StdEncoding.EncodeToString([]byte(pageTokenPrefix + strconv.FormatUint(uint64(id), 10)))
+}
+
+func decodePageToken(token string) (uint, bool) {
+	b, err := base64.StdEncoding.DecodeString(token)
+	if err != nil || !strings.HasPrefix(string(b), pageTokenPrefix) {
+		return 0, false
+	}
+	id, err := strconv.ParseUint(strings.TrimPrefix(string(b), pageTokenPrefix), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint(id), true
+}
+
+func toProtoUser(user *models.User) *userspb.User {
+	if user == nil {
+		return nil
+	}
+	return &userspb.User{
+		Id:            uint64(user.ID),
+		Name:          user.Name,
+		Email:         user.Email,
+		Phone:         deref(user.Phone),
+		PhoneOriginal: deref(user.PhoneOriginal),
+		Address:       deref(user.Address),
+		PostalAddress: toProtoAddress(user.PostalAddress),
+		CreatedAt:     timestamppb.New(user.CreatedAt),
+		UpdatedAt:     timestamppb.New(user.UpdatedAt),
+	}
+}
+
+func toProtoAddress(address *models.Address) *userspb.Address {
+	if address == nil {
+		return nil
+	}
+	return &userspb.Address{
+		Line1:      address.Line1,
+		Line2:      address.Line2,
+		City:       address.City,
+		Region:     address.Region,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+	}
+}
+
+func toModelAddress(address *userspb.Address) *models.Address {
+	if address == nil {
+		return nil
+	}
+	return &models.Address{
+		Line1:      address.GetLine1(),
+		Line2:      address.GetLine2(),
+		City:       address.GetCity(),
+		Region:     address.GetRegion(),
+		PostalCode: address.GetPostalCode(),
+		Country:    strings.ToUpper(address.GetCountry()),
+	}
+}
+
+func toProtoEvent(event models.Event) *userspb.UserEvent {
+	changed := make([]string, 0, len(event.Data.Changes))
+	for field := range event.Data.Changes {
+		changed = append(changed, field)
+	}
+	sort.Strings(changed)
+	return &userspb.UserEvent{
+		Sequence:      uint64(event.Sequence),
+		Id:            event.EventID,
+		Type:          event.Type,
+		UserId:        uint64(event.UserID),
+		Actor:         event.Actor,
+		RequestId:     event.RequestID,
+		OccurredAt:    timestamppb.New(event.OccurredAt),
+		User:          toProtoUser(event.Data.User),
+		ChangedFields: changed,
+	}
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
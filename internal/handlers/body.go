@@ -0,0 +1,20 @@
+package handlers
+
+import (
+	"gin-simple-app/pkg/response"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+)
+
+// bindBody binds and validates a request body in the format of its
+// Content-Type, JSON if it has none
+func bindBody(c *gin.Context, obj interface{}) error {
+	switch format, _ := response.FormatOf(c.ContentType()); format {
+	case response.FormatXML:
+		return c.ShouldBindWith(obj, binding.XML)
+	case response.FormatMsgPack:
+		return c.ShouldBindWith(obj, binding.MsgPack)
+	}
+	return c.ShouldBindJSON(obj)
+}
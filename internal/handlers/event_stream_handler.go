@@ -0,0 +1,117 @@
+package handlers
+
+import (
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/tenant"
+	"gin-simple-app/pkg/response"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// streamRetry tells clients how long to wait before reconnecting, in milliseconds
+const streamRetry = 3000
+
+// streamEventTypes are the event types a stream can be filtered by
+var streamEventTypes = map[string]bool{
+	models.EventUserCreated: true,
+	models.EventUserUpdated: true,
+	models.EventUserDeleted: true,
+}
+
+// EventStreamHandler streams user events to clients over Server-Sent Events
+type EventStreamHandler struct {
+	broadcaster *events.Broadcaster
+	heartbeat   time.Duration
+}
+
+// NewEventStreamHandler creates a handler that streams the events of
+// broadcaster, sending a heartbeat comment whenever the stream has been
+// quiet for heartbeat
+func NewEventStreamHandler(broadcaster *events.Broadcaster, heartbeat time.Duration) *EventStreamHandler {
+	return &EventStreamHandler{
+		broadcaster: broadcaster,
+		heartbeat:   heartbeat,
+	}
+}
+
+// StreamEvents handles GET /api/v1/users/events
+func (h *EventStreamHandler) StreamEvents(c *gin.Context) {
+	types := make(map[string]bool)
+	for _, t := range strings.Split(c.Query("types"), ",") {
+		if t = strings.TrimSpace(t); t == "" {
+			continue
+		}
+		if !streamEventTypes[t] {
+			response.BadRequest(c, "Invalid event type, must be one of: user.created, user.updated, user.deleted")
+			return
+		}
+		types[t] = true
+	}
+
+	// Browsers send Last-Event-ID when reconnecting; the query parameter
+	// lets a new EventSource pick up from a known event
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var after uint64
+	if lastEventID != "" {
+		var err error
+		if after, err = strconv.ParseUint(lastEventID, 10, 32); err != nil {
+			response.BadRequest(c, "Invalid Last-Event-ID")
+			return
+		}
+	}
+
+	// Clients only see the events of their own tenant
+	tenantID := tenant.IDFromContext(c.Request.Context())
+	sub := h.broadcaster.Subscribe(lastEventID != "", uint(after))
+	defer sub.Close()
+
+	c.Header("Content-Type", sse.ContentType)
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	// Stop reverse proxies such as nginx from buffering the stream
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+	_, _ = io.WriteString(c.Writer, "retry: "+strconv.Itoa(streamRetry)+"\n\n")
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				// Fell too far behind; the client reconnects and resumes
+				return
+			}
+			if event.TenantID != tenantID || len(types) > 0 && !types[event.Type] {
+				continue
+			}
+			err := sse.Encode(c.Writer, sse.Event{
+				Id:    strconv.FormatUint(uint64(event.Sequence), 10),
+				Event: event.Type,
+				Data:  event,
+			})
+			if err != nil {
+				return
+			}
+			heartbeat.Reset(h.heartbeat)
+		case <-heartbeat.C:
+			if _, err := io.WriteString(c.Writer, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		c.Writer.Flush()
+	}
+}
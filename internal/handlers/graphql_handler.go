@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"gin-simple-app/internal/graph"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	graphql "github.com/graph-gophers/graphql-go"
+	gqlerrors "github.com/graph-gophers/graphql-go/errors"
+)
+
+const (
+	// maxGraphQLBody bounds the size of a GraphQL request body
+	maxGraphQLBody = 1 << 20
+	// maxGraphQLBatch bounds the number of operations in a batch
+	maxGraphQLBatch = 20
+)
+
+// GraphQLHandler handles GraphQL requests
+type GraphQLHandler struct {
+	schema *graphql.Schema
+}
+
+// NewGraphQLHandler creates a new GraphQL handler
+func NewGraphQLHandler(schema *graphql.Schema) *GraphQLHandler {
+	return &GraphQLHandler{
+		schema: schema,
+	}
+}
+
+// graphQLRequest is a single GraphQL operation
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Query handles POST /graphql. The body is either one operation or, to
+// batch them, a JSON array of operations answered with an array of results.
+func (h *GraphQLHandler) Query(c *gin.Context) {
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGraphQLBody+1))
+	if err != nil || len(body) > maxGraphQLBody {
+		graphQLError(c, http.StatusRequestEntityTooLarge, "Request body too large")
+		return
+	}
+
+	var reqs []graphQLRequest
+	batch := len(bytes.TrimSpace(body)) > 0 && bytes.TrimSpace(body)[0] == '['
+	if batch {
+		err = json.Unmarshal(body, &reqs)
+	} else {
+		reqs = make([]graphQLRequest, 1)
+		err = json.Unmarshal(body, &reqs[0])
+	}
+	if err != nil {
+		graphQLError(c, http.StatusBadRequest, "Malformed request: "+err.Error())
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxGraphQLBatch {
+		graphQLError(c, http.StatusBadRequest, "A batch must hold between 1 and 20 operations")
+		return
+	}
+	for _, req := range reqs {
+		if req.Query == "" {
+			graphQLError(c, http.StatusBadRequest, "Missing query")
+			return
+		}
+	}
+
+	ctx := graph.WithAcceptLanguage(c.Request.Context(), c.GetHeader("Accept-Language"))
+	results := make([]*graphql.Response, len(reqs))
+	for i, req := range reqs {
+		results[i] = h.schema.Exec(ctx, req.Query, req.OperationName, req.Variables)
+	}
+
+	if batch {
+		c.JSON(http.StatusOK, results)
+		return
+	}
+	c.JSON(http.StatusOK, results[0])
+}
+
+// Schema handles GET /graphql/schema
+func (h *GraphQLHandler) Schema(c *gin.Context) {
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(graph.Schema()))
+}
+
+// graphQLError sends a request-level error in the GraphQL response format
+func graphQLError(c *gin.Context, statusCode int, message string) {
+	c.JSON(statusCode, graphql.Response{
+		Errors: []*gqlerrors.QueryError{{Message: message}},
+	})
+}
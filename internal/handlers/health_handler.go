@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"gin-simple-app/internal/cache"
 	"gin-simple-app/pkg/response"
 	"net/http"
 
@@ -8,11 +9,29 @@ import (
 )
 
 // HealthHandler handles health-related HTTP requests
-type HealthHandler struct{}
+type HealthHandler struct {
+	// cacheStats reports the user cache, if users are cached
+	cacheStats func() cache.Stats
+}
+
+// HealthOption customizes a HealthHandler
+type HealthOption func(h *HealthHandler)
+
+// WithCacheStats reports the hits and misses of the user cache in the
+// health check
+func WithCacheStats(stats func() cache.Stats) HealthOption {
+	return func(h *HealthHandler) {
+		h.cacheStats = stats
+	}
+}
 
 // NewHealthHandler creates a new health handler
-func NewHealthHandler() *HealthHandler {
-	return &HealthHandler{}
+func NewHealthHandler(opts ...HealthOption) *HealthHandler {
+	h := &HealthHandler{}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 // HealthCheck handles GET /health
@@ -21,6 +40,14 @@ func (h *HealthHandler) HealthCheck(c *gin.Context) {
 		"status":  "ok",
 		"message": "Gin REST API is running",
 	}
+	if h.cacheStats != nil {
+		stats := h.cacheStats()
+		healthData["cache"] = gin.H{
+			"hits":      stats.Hits,
+			"misses":    stats.Misses,
+			"hit_ratio": stats.HitRatio(),
+		}
+	}
 	response.Success(c, http.StatusOK, "Health check successful", healthData)
 }
 
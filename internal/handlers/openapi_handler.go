@@ -0,0 +1,183 @@
+package handlers
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/compression"
+	"gin-simple-app/internal/openapi"
+	"gin-simple-app/pkg/response"
+	"io"
+	"net/http"
+	"regexp"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	swaggerui "github.com/swaggest/swgui/v5/static"
+)
+
+// swaggerUIVersion is the Swagger UI release the docs page serves. Its
+// files are embedded from swgui v1.8.2, which bundles this release, so the
+// page loads no code from third parties.
+const swaggerUIVersion = "5.17.14"
+
+// swaggerUIScript starts Swagger UI on the generated document
+const swaggerUIScript = `window.onload = function () {
+  window.ui = SwaggerUIBundle({ url: "/openapi.json", dom_id: "#swagger-ui" });
+};`
+
+// swaggerUIAsset is a Swagger UI file served under /docs
+type swaggerUIAsset struct {
+	name        string
+	contentType string
+	gzipped     []byte
+	plain       []byte
+	// integrity is the Subresource Integrity hash of plain
+	integrity string
+}
+
+// path returns the URL path the asset is served at
+func (a *swaggerUIAsset) path() string {
+	return "/docs/" + a.name
+}
+
+// swaggerUIAssets are the embedded Swagger UI files by name
+var swaggerUIAssets = map[string]*swaggerUIAsset{
+	"swagger-ui-bundle.js": loadSwaggerUIAsset("swagger-ui-bundle.js", "text/javascript; charset=utf-8"),
+	"swagger-ui.css":       loadSwaggerUIAsset("swagger-ui.css", "text/css; charset=utf-8"),
+}
+
+// loadSwaggerUIAsset reads an embedded Swagger UI file, which swgui stores
+// gzipped
+func loadSwaggerUIAsset(name, contentType string) *swaggerUIAsset {
+	gzipped, err := swaggerui.FS.ReadFile(name + ".gz")
+	if err != nil {
+		panic(fmt.Sprintf("swagger ui: %s is not embedded: %v", name, err))
+	}
+	reader, err := gzip.NewReader(bytes.NewReader(gzipped))
+	if err != nil {
+		panic(fmt.Sprintf("swagger ui: %s: %v", name, err))
+	}
+	plain, err := io.ReadAll(reader)
+	if err != nil {
+		panic(fmt.Sprintf("swagger ui: %s: %v", name, err))
+	}
+	sum := sha512.Sum384(plain)
+	return &swaggerUIAsset{
+		name:        name,
+		contentType: contentType,
+		gzipped:     gzipped,
+		plain:       plain,
+		integrity:   "sha384-" + base64.StdEncoding.EncodeToString(sum[:]),
+	}
+}
+
+// swaggerUIPage is the docs page. Both files carry integrity hashes, so a
+// browser refuses them if they were tampered with on the way.
+var swaggerUIPage = func() string {
+	css := swaggerUIAssets["swagger-ui.css"]
+	bundle := swaggerUIAssets["swagger-ui-bundle.js"]
+	return `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Gin Simple REST API</title>
+  <link rel="stylesheet" href="` + css.path() + `" integrity="` + css.integrity + `">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="` + bundle.path() + `" integrity="` + bundle.integrity + `"></script>
+  <script>` + swaggerUIScript + `</script>
+</body>
+</html>
+`
+}()
+
+// swaggerUIScriptHash is the CSP hash of the inline script
+var swaggerUIScriptHash = func() string {
+	sum := sha256.Sum256([]byte(swaggerUIScript))
+	return "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+}()
+
+// plainHost matches a Host header that can be used in a CSP source as is
+var plainHost = regexp.MustCompile(`^[A-Za-z0-9.-]+(:[0-9]+)?$`)
+
+// swaggerUIPolicy replaces the API's Content-Security-Policy on the docs
+// page: it may load the Swagger UI bundle and stylesheet, run its own
+// inline script and fetch the document, and nothing else. Sources are the
+// exact file URLs on host; a host that cannot be written in a CSP source
+// falls back to 'self'.
+func swaggerUIPolicy(host string) string {
+	script, style := "'self'", "'self'"
+	if plainHost.MatchString(host) {
+		script = host + swaggerUIAssets["swagger-ui-bundle.js"].path()
+		style = host + swaggerUIAssets["swagger-ui.css"].path()
+	}
+	return "default-src 'none'; " +
+		"script-src " + script + " " + swaggerUIScriptHash + "; " +
+		"style-src " + style + "; " +
+		"img-src 'self' data:; " +
+		"connect-src 'self'; " +
+		"frame-ancestors 'none'"
+}
+
+// OpenAPIHandler serves the OpenAPI document and a page to browse it
+type OpenAPIHandler struct {
+	doc *openapi.Document
+
+	once sync.Once
+	spec []byte
+	err  error
+}
+
+// NewOpenAPIHandler creates a handler serving doc. The document is encoded
+// on first request, so routes may still be added to it until the server
+// starts.
+func NewOpenAPIHandler(doc *openapi.Document) *OpenAPIHandler {
+	return &OpenAPIHandler{doc: doc}
+}
+
+// Spec handles GET /openapi.json
+func (h *OpenAPIHandler) Spec(c *gin.Context) {
+	h.once.Do(func() {
+		h.spec, h.err = json.Marshal(h.doc)
+	})
+	if h.err != nil {
+		response.InternalServerError(c, "Failed to encode OpenAPI document")
+		return
+	}
+	c.Data(http.StatusOK, "application/json; charset=utf-8", h.spec)
+}
+
+// SwaggerUI handles GET /docs
+func (h *OpenAPIHandler) SwaggerUI(c *gin.Context) {
+	c.Header("Content-Security-Policy", swaggerUIPolicy(c.Request.Host))
+	c.Data(http.StatusOK, "text/html; charset=utf-8", []byte(swaggerUIPage))
+}
+
+// SwaggerUIScript handles GET /docs/swagger-ui-bundle.js
+func (h *OpenAPIHandler) SwaggerUIScript(c *gin.Context) {
+	serveSwaggerUIAsset(c, swaggerUIAssets["swagger-ui-bundle.js"])
+}
+
+// SwaggerUIStylesheet handles GET /docs/swagger-ui.css
+func (h *OpenAPIHandler) SwaggerUIStylesheet(c *gin.Context) {
+	serveSwaggerUIAsset(c, swaggerUIAssets["swagger-ui.css"])
+}
+
+// serveSwaggerUIAsset sends a Swagger UI file, gzipped to clients that
+// accept it
+func serveSwaggerUIAsset(c *gin.Context, asset *swaggerUIAsset) {
+	c.Header("Cache-Control", "public, max-age=86400")
+	c.Header("Vary", "Accept-Encoding")
+	if compression.Negotiate(c.GetHeader("Accept-Encoding"), []string{compression.Gzip}) == compression.Gzip {
+		c.Header("Content-Encoding", compression.Gzip)
+		c.Data(http.StatusOK, asset.contentType, asset.gzipped)
+		return
+	}
+	c.Data(http.StatusOK, asset.contentType, asset.plain)
+}
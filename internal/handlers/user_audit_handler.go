@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"gin-simple-app/internal/models"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetUserHistory handles GET /api/v1/users/:id/history
+func (h *UserHandler) GetUserHistory(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid user ID")
+		return
+	}
+
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	entries, err := h.userService.GetUserHistory(c.Request.Context(), uint(id), filter)
+	if err != nil {
+		if err.Error() == "user not found" {
+			response.NotFound(c, "User not found")
+			return
+		}
+		response.InternalServerError(c, "Failed to retrieve user history")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "User history retrieved successfully", entries, len(entries))
+}
+
+// GetAuditLog handles GET /api/v1/audit
+func (h *UserHandler) GetAuditLog(c *gin.Context) {
+	var filter models.AuditFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	entries, err := h.userService.ListAuditEntries(c.Request.Context(), filter)
+	if err != nil {
+		response.InternalServerError(c, "Failed to retrieve audit log")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "Audit log retrieved successfully", entries, len(entries))
+}
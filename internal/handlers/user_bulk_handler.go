@@ -0,0 +1,157 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// maxBulkDeleteIDs limits the number of IDs accepted by DELETE /api/v1/users
+const maxBulkDeleteIDs = 1000
+
+// BulkUsers handles POST /api/v1/users/bulk
+func (h *UserHandler) BulkUsers(c *gin.Context) {
+	var req models.BulkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	ops, results, ok := decodeBulkOperations(req.Operations, apiVersion(c), c.GetHeader("Accept-Language"))
+	if !ok {
+		response.ErrorWithData(c, http.StatusBadRequest, "Bulk operations failed, no changes were applied", presentBulkResults(c, results))
+		return
+	}
+
+	results, err := h.userService.BulkUsers(c.Request.Context(), ops)
+	if err != nil {
+		if errors.Is(err, services.ErrBulkFailed) {
+			response.ErrorWithData(c, http.StatusBadRequest, "Bulk operations failed, no changes were applied", presentBulkResults(c, results))
+			return
+		}
+		response.InternalServerError(c, "Failed to apply bulk operations")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "Bulk operations applied successfully", presentBulkResults(c, results), len(results))
+}
+
+// decodeBulkOperations decodes and validates the payload of every operation
+// in the shape of the API version. When any operation is invalid, ok is
+// false and results explain each failure.
+func decodeBulkOperations(reqs []models.BulkOperationRequest, version, acceptLanguage string) ([]models.BulkOperation, []models.BulkResult, bool) {
+	ops := make([]models.BulkOperation, len(reqs))
+	results := make([]models.BulkResult, len(reqs))
+	ok := true
+
+	for i, req := range reqs {
+		ops[i] = models.BulkOperation{Op: req.Op, ID: req.ID}
+		results[i] = models.BulkResult{Index: i, Op: req.Op, ID: req.ID}
+
+		var err error
+		switch req.Op {
+		case models.BulkOpCreate:
+			ops[i].Create, err = decodeBulkCreate(req.Data, version, acceptLanguage)
+		case models.BulkOpUpdate:
+			if req.ID == 0 {
+				err = errors.New("id is required")
+				break
+			}
+			ops[i].Update, err = decodeBulkUpdate(req.Data, version, acceptLanguage)
+		case models.BulkOpDelete:
+			if req.ID == 0 {
+				err = errors.New("id is required")
+			}
+		}
+
+		if err != nil {
+			results[i].Status = models.BulkStatusFailed
+			results[i].Error = err.Error()
+			ok = false
+		}
+	}
+
+	if !ok {
+		for i := range results {
+			if results[i].Status != models.BulkStatusFailed {
+				results[i].Status = models.BulkStatusSkipped
+			}
+		}
+	}
+	return ops, results, ok
+}
+
+// decodeBulkData decodes an operation's data into obj and validates its
+// binding tags, translating validation messages for the client
+func decodeBulkData(data json.RawMessage, obj interface{}, acceptLanguage string) error {
+	if len(data) == 0 {
+		return errors.New("data is required")
+	}
+	if err := json.Unmarshal(data, obj); err != nil {
+		return err
+	}
+
+	err := binding.Validator.ValidateStruct(obj)
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		return err
+	}
+	return errors.New(response.JoinMessages(response.TranslateValidationErrors(verrs, acceptLanguage)))
+}
+
+// DeleteUsers handles DELETE /api/v1/users?ids=1,2,3
+func (h *UserHandler) DeleteUsers(c *gin.Context) {
+	ids, err := parseIDList(c.Query("ids"))
+	if err != nil {
+		response.BadRequest(c, err.Error())
+		return
+	}
+
+	deleted, err := h.userService.DeleteUsers(c.Request.Context(), ids)
+	if err != nil {
+		var missing *services.MissingUsersError
+		if errors.As(err, &missing) {
+			response.ErrorWithData(c, http.StatusNotFound, "Users not found", gin.H{"missing_ids": missing.IDs})
+			return
+		}
+		response.InternalServerError(c, "Failed to delete users")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Users deleted successfully", gin.H{"deleted": deleted})
+}
+
+// parseIDList parses a comma-separated list of user IDs, dropping duplicates
+func parseIDList(raw string) ([]uint, error) {
+	if raw == "" {
+		return nil, errors.New("Query parameter 'ids' is required")
+	}
+
+	parts := strings.Split(raw, ",")
+	if len(parts) > maxBulkDeleteIDs {
+		return nil, errors.New("Too many user IDs, maximum is " + strconv.Itoa(maxBulkDeleteIDs))
+	}
+
+	seen := make(map[uint]bool, len(parts))
+	ids := make([]uint, 0, len(parts))
+	for _, part := range parts {
+		id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 32)
+		if err != nil || id == 0 {
+			return nil, errors.New("Invalid user ID: " + part)
+		}
+		if !seen[uint(id)] {
+			seen[uint(id)] = true
+			ids = append(ids, uint(id))
+		}
+	}
+	return ids, nil
+}
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/models"
+)
+
+// sparseUsers trims the users in list, as written by the request's API
+// version, to the fields of a projection
+func sparseUsers(list interface{}, fields models.UserFields) (interface{}, error) {
+	if len(fields) == 0 {
+		return list, nil
+	}
+	data, err := json.Marshal(list)
+	if err != nil {
+		return nil, err
+	}
+	var users []map[string]json.RawMessage
+	if err := json.Unmarshal(data, &users); err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		for name := range user {
+			if !fields.Has(name) {
+				delete(user, name)
+			}
+		}
+	}
+	return users, nil
+}
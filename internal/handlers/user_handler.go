@@ -1,15 +1,21 @@
 package handlers
 
 import (
+	"gin-simple-app/internal/export"
 	"gin-simple-app/internal/models"
 	"gin-simple-app/internal/services"
 	"gin-simple-app/pkg/response"
+	"log"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
+// defaultSearchLimit is the number of search results returned when no limit is given
+const defaultSearchLimit = 20
+
 // UserHandler handles user-related HTTP requests
 type UserHandler struct {
 	userService services.UserService
@@ -24,13 +30,84 @@ func NewUserHandler(userService services.UserService) *UserHandler {
 
 // GetUsers handles GET /api/v1/users
 func (h *UserHandler) GetUsers(c *gin.Context) {
-	users, err := h.userService.GetAllUsers()
+	var filter models.UserFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	// ?fields=id,name trims both the query and the response
+	fields, err := models.ParseUserFields(c.Query("fields"))
+	if err != nil {
+		response.InvalidFields(c, []response.FieldError{{Field: "fields", Code: "invalid", Message: err.Error()}})
+		return
+	}
+
+	users, err := h.userService.ListUsers(c.Request.Context(), filter, fields)
+	if err != nil {
+		response.InternalServerError(c, "Failed to retrieve users")
+		return
+	}
+
+	data, err := sparseUsers(presentUsers(c, users), fields)
 	if err != nil {
 		response.InternalServerError(c, "Failed to retrieve users")
 		return
 	}
 	
-	response.SuccessWithCount(c, http.StatusOK, "Users retrieved successfully", users, len(users))
+	response.SuccessWithCount(c, http.StatusOK, "Users retrieved successfully", data, len(users))
+}
+
+// ExportUsers handles GET /api/v1/users/export
+func (h *UserHandler) ExportUsers(c *gin.Context) {
+	var filter models.UserFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	format, err := export.ParseFormat(c.DefaultQuery("format", string(export.FormatCSV)))
+	if err != nil {
+		response.BadRequest(c, "Invalid export format, must be one of: csv, ndjson, xlsx")
+		return
+	}
+
+	c.Header("Content-Type", format.ContentType())
+	c.Header("Content-Disposition", `attachment; filename="`+format.Filename(time.Now())+`"`)
+	c.Status(http.StatusOK)
+
+	writer, err := export.NewWriter(format, c.Writer)
+	if err == nil {
+		err = h.userService.ExportUsers(c.Request.Context(), filter, writer.Write)
+		if closeErr := writer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		// Headers are already sent, so the best we can do is log and cut the stream short
+		log.Printf("User export failed: %v", err)
+		c.Abort()
+	}
+}
+
+// SearchUsers handles GET /api/v1/users/search
+func (h *UserHandler) SearchUsers(c *gin.Context) {
+	var req models.UserSearchRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+	if req.Limit == 0 {
+		req.Limit = defaultSearchLimit
+	}
+
+	results, err := h.userService.SearchUsers(c.Request.Context(), req.Query, req.Limit)
+	if err != nil {
+		response.InternalServerError(c, "Failed to search users")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "Users retrieved successfully", presentSearchResults(c, results), len(results))
 }
 
 // GetUserByID handles GET /api/v1/users/:id
@@ -42,7 +119,7 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	user, err := h.userService.GetUserByID(uint(id))
+	user, err := h.userService.GetUserByID(c.Request.Context(), uint(id))
 	if err != nil {
 		if err.Error() == "user not found" {
 			response.NotFound(c, "User not found")
@@ -52,18 +129,18 @@ func (h *UserHandler) GetUserByID(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusOK, "User retrieved successfully", user)
+	response.Success(c, http.StatusOK, "User retrieved successfully", presentUser(c, user))
 }
 
 // CreateUser handles POST /api/v1/users
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	var req models.CreateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req, err := bindCreateUserRequest(c)
+	if err != nil {
 		response.ValidationError(c, err)
 		return
 	}
 
-	user, err := h.userService.CreateUser(req)
+	user, err := h.userService.CreateUser(c.Request.Context(), req)
 	if err != nil {
 		if err.Error() == "user with this email already exists" {
 			response.BadRequest(c, "User with this email already exists")
@@ -73,7 +150,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusCreated, "User created successfully", user)
+	response.Success(c, http.StatusCreated, "User created successfully", presentUser(c, user))
 }
 
 // UpdateUser handles PUT /api/v1/users/:id
@@ -85,13 +162,13 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	var req models.UpdateUserRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
+	req, err := bindUpdateUserRequest(c)
+	if err != nil {
 		response.ValidationError(c, err)
 		return
 	}
 
-	user, err := h.userService.UpdateUser(uint(id), req)
+	user, err := h.userService.UpdateUser(c.Request.Context(), uint(id), req)
 	if err != nil {
 		if err.Error() == "user not found" {
 			response.NotFound(c, "User not found")
@@ -105,7 +182,7 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 		return
 	}
 
-	response.Success(c, http.StatusOK, "User updated successfully", user)
+	response.Success(c, http.StatusOK, "User updated successfully", presentUser(c, user))
 }
 
 // DeleteUser handles DELETE /api/v1/users/:id
@@ -117,7 +194,7 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	err = h.userService.DeleteUser(uint(id))
+	err = h.userService.DeleteUser(c.Request.Context(), uint(id))
 	if err != nil {
 		if err.Error() == "user not found" {
 			response.NotFound(c, "User not found")
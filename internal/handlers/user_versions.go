@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/versioning"
+
+	"github.com/gin-gonic/gin"
+)
+
+// apiVersion returns the API version a request is served at. Routes outside
+// a versioned group are served at v1.
+func apiVersion(c *gin.Context) string {
+	if version := c.GetString(versioning.ContextKey); version != "" {
+		return version
+	}
+	return versioning.V1
+}
+
+// presentUser maps a user to the representation of the request's version
+func presentUser(c *gin.Context, user *models.User) interface{} {
+	if apiVersion(c) == versioning.V2 {
+		return models.NewUserV2(user)
+	}
+	return user
+}
+
+// presentUsers maps a list of users to the representation of the request's
+// version
+func presentUsers(c *gin.Context, users []models.User) interface{} {
+	if apiVersion(c) != versioning.V2 {
+		return users
+	}
+	result := make([]*models.UserV2, len(users))
+	for i := range users {
+		result[i] = models.NewUserV2(&users[i])
+	}
+	return result
+}
+
+// presentSearchResults maps search results to the representation of the
+// request's version
+func presentSearchResults(c *gin.Context, results []models.UserSearchResult) interface{} {
+	if apiVersion(c) != versioning.V2 {
+		return results
+	}
+	mapped := make([]models.UserSearchResultV2, len(results))
+	for i, result := range results {
+		mapped[i] = models.UserSearchResultV2{
+			User:       *models.NewUserV2(&result.User),
+			Rank:       result.Rank,
+			Highlights: result.Highlights,
+		}
+	}
+	return mapped
+}
+
+// presentBulkResults maps bulk results to the representation of the
+// request's version
+func presentBulkResults(c *gin.Context, results []models.BulkResult) interface{} {
+	if apiVersion(c) != versioning.V2 {
+		return results
+	}
+	mapped := make([]models.BulkResultV2, len(results))
+	for i, result := range results {
+		mapped[i] = models.BulkResultV2{
+			Index:  result.Index,
+			Op:     result.Op,
+			ID:     result.ID,
+			Status: result.Status,
+			Error:  result.Error,
+			User:   models.NewUserV2(result.User),
+		}
+	}
+	return mapped
+}
+
+// bindCreateUserRequest binds a create request in the shape of the
+// request's version
+func bindCreateUserRequest(c *gin.Context) (models.CreateUserRequest, error) {
+	if apiVersion(c) == versioning.V2 {
+		var req models.CreateUserRequestV2
+		err := bindBody(c, &req)
+		return req.V1(), err
+	}
+	var req models.CreateUserRequest
+	err := bindBody(c, &req)
+	return req, err
+}
+
+// bindUpdateUserRequest binds an update request in the shape of the
+// request's version
+func bindUpdateUserRequest(c *gin.Context) (models.UpdateUserRequest, error) {
+	if apiVersion(c) == versioning.V2 {
+		var req models.UpdateUserRequestV2
+		err := bindBody(c, &req)
+		return req.V1(), err
+	}
+	var req models.UpdateUserRequest
+	err := bindBody(c, &req)
+	return req, err
+}
+
+// decodeBulkCreate decodes the data of a bulk create operation in the shape
+// of version
+func decodeBulkCreate(data json.RawMessage, version, acceptLanguage string) (*models.CreateUserRequest, error) {
+	if version == versioning.V2 {
+		var req models.CreateUserRequestV2
+		if err := decodeBulkData(data, &req, acceptLanguage); err != nil {
+			return nil, err
+		}
+		v1 := req.V1()
+		return &v1, nil
+	}
+	var req models.CreateUserRequest
+	if err := decodeBulkData(data, &req, acceptLanguage); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
+
+// decodeBulkUpdate decodes the data of a bulk update operation in the shape
+// of version
+func decodeBulkUpdate(data json.RawMessage, version, acceptLanguage string) (*models.UpdateUserRequest, error) {
+	if version == versioning.V2 {
+		var req models.UpdateUserRequestV2
+		if err := decodeBulkData(data, &req, acceptLanguage); err != nil {
+			return nil, err
+		}
+		v1 := req.V1()
+		return &v1, nil
+	}
+	var req models.UpdateUserRequest
+	if err := decodeBulkData(data, &req, acceptLanguage); err != nil {
+		return nil, err
+	}
+	return &req, nil
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/webhooks"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WebhookHandler handles webhook subscription HTTP requests
+type WebhookHandler struct {
+	webhookService services.WebhookService
+}
+
+// NewWebhookHandler creates a new webhook handler
+func NewWebhookHandler(webhookService services.WebhookService) *WebhookHandler {
+	return &WebhookHandler{
+		webhookService: webhookService,
+	}
+}
+
+// GetWebhooks handles GET /api/v1/webhooks
+func (h *WebhookHandler) GetWebhooks(c *gin.Context) {
+	subs, err := h.webhookService.ListWebhooks(c.Request.Context())
+	if err != nil {
+		response.InternalServerError(c, "Failed to retrieve webhooks")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "Webhooks retrieved successfully", subs, len(subs))
+}
+
+// GetWebhookByID handles GET /api/v1/webhooks/:id
+func (h *WebhookHandler) GetWebhookByID(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	sub, err := h.webhookService.GetWebhook(c.Request.Context(), id)
+	if err != nil {
+		h.handleError(c, err, "Failed to retrieve webhook")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook retrieved successfully", sub)
+}
+
+// CreateWebhook handles POST /api/v1/webhooks
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	sub, err := h.webhookService.CreateWebhook(c.Request.Context(), req)
+	if err != nil {
+		h.handleError(c, err, "Failed to create webhook")
+		return
+	}
+
+	response.Success(c, http.StatusCreated, "Webhook created successfully", sub)
+}
+
+// UpdateWebhook handles PUT /api/v1/webhooks/:id
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	var req models.WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	sub, err := h.webhookService.UpdateWebhook(c.Request.Context(), id, req)
+	if err != nil {
+		h.handleError(c, err, "Failed to update webhook")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook updated successfully", sub)
+}
+
+// DeleteWebhook handles DELETE /api/v1/webhooks/:id
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.webhookService.DeleteWebhook(c.Request.Context(), id); err != nil {
+		h.handleError(c, err, "Failed to delete webhook")
+		return
+	}
+
+	response.Success(c, http.StatusOK, "Webhook deleted successfully", nil)
+}
+
+// GetDeliveries handles GET /api/v1/webhooks/:id/deliveries
+func (h *WebhookHandler) GetDeliveries(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+
+	var filter models.WebhookDeliveryFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		response.ValidationError(c, err)
+		return
+	}
+
+	deliveries, err := h.webhookService.ListDeliveries(c.Request.Context(), id, filter)
+	if err != nil {
+		h.handleError(c, err, "Failed to retrieve deliveries")
+		return
+	}
+
+	response.SuccessWithCount(c, http.StatusOK, "Deliveries retrieved successfully", deliveries, len(deliveries))
+}
+
+// Redeliver handles POST /api/v1/webhooks/:id/deliveries/:delivery_id/redeliver
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, ok := webhookID(c)
+	if !ok {
+		return
+	}
+	deliveryID, err := strconv.ParseUint(c.Param("delivery_id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid delivery ID")
+		return
+	}
+
+	delivery, err := h.webhookService.Redeliver(c.Request.Context(), id, uint(deliveryID))
+	if err != nil {
+		h.handleError(c, err, "Failed to redeliver")
+		return
+	}
+
+	response.Success(c, http.StatusAccepted, "Delivery queued for redelivery", delivery)
+}
+
+// handleError maps webhook service errors to responses
+func (h *WebhookHandler) handleError(c *gin.Context, err error, message string) {
+	var targetErr *webhooks.TargetError
+	if errors.As(err, &targetErr) {
+		response.BadRequest(c, "Webhook URL not allowed: "+targetErr.Reason)
+		return
+	}
+	switch err.Error() {
+	case "webhook not found":
+		response.NotFound(c, "Webhook not found")
+	case "delivery not found":
+		response.NotFound(c, "Delivery not found")
+	default:
+		response.InternalServerError(c, message)
+	}
+}
+
+// webhookID parses the :id parameter, responding with 400 if it is invalid
+func webhookID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		response.BadRequest(c, "Invalid webhook ID")
+		return 0, false
+	}
+	return uint(id), true
+}
@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"sync/atomic"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// GormStore implements Store on the idempotency_keys table, so that retries
+// are recognized across server instances
+type GormStore struct {
+	db    *gorm.DB
+	calls atomic.Int64
+}
+
+// NewGormStore creates a new GORM idempotency store
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{
+		db: db,
+	}
+}
+
+// Begin claims key for a new request. The INSERT ... ON CONFLICT DO NOTHING
+// makes the claim atomic, so concurrent duplicates see each other's record.
+func (s *GormStore) Begin(key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	now := time.Now()
+	if s.calls.Add(1)%sweepInterval == 0 {
+		if err := s.db.Where("expires_at < ?", now).Delete(&Record{}).Error; err != nil {
+			return nil, false, err
+		}
+	} else if err := s.db.Where("key = ? AND expires_at < ?", key, now).Delete(&Record{}).Error; err != nil {
+		return nil, false, err
+	}
+
+	record := &Record{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	result := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(record)
+	if result.Error != nil {
+		return nil, false, result.Error
+	}
+	if result.RowsAffected == 1 {
+		return record, true, nil
+	}
+
+	var existing Record
+	if err := s.db.Where("key = ?", key).First(&existing).Error; err != nil {
+		return nil, false, err
+	}
+	return &existing, false, nil
+}
+
+// Complete stores the response for key
+func (s *GormStore) Complete(key string, statusCode int, contentType string, body []byte) error {
+	result := s.db.Model(&Record{}).Where("key = ?", key).Updates(map[string]interface{}{
+		"completed":    true,
+		"status_code":  statusCode,
+		"content_type": contentType,
+		"body":         body,
+	})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// Release drops an in-flight claim on key
+func (s *GormStore) Release(key string) error {
+	return s.db.Where("key = ? AND completed = ?", key, false).Delete(&Record{}).Error
+}
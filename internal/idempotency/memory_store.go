@@ -0,0 +1,78 @@
+package idempotency
+
+import (
+	"sync"
+	"time"
+)
+
+// MemoryStore implements Store in memory, for single-node deployments and tests
+type MemoryStore struct {
+	records map[string]*Record
+	calls   int
+	mutex   sync.Mutex
+}
+
+// NewMemoryStore creates a new in-memory idempotency store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]*Record),
+	}
+}
+
+// Begin claims key for a new request
+func (s *MemoryStore) Begin(key, fingerprint string, ttl time.Duration) (*Record, bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.calls++
+	if s.calls%sweepInterval == 0 {
+		for k, r := range s.records {
+			if now.After(r.ExpiresAt) {
+				delete(s.records, k)
+			}
+		}
+	}
+
+	if existing, ok := s.records[key]; ok && now.Before(existing.ExpiresAt) {
+		recordCopy := *existing
+		return &recordCopy, false, nil
+	}
+
+	record := &Record{
+		Key:         key,
+		Fingerprint: fingerprint,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+	s.records[key] = record
+	recordCopy := *record
+	return &recordCopy, true, nil
+}
+
+// Complete stores the response for key
+func (s *MemoryStore) Complete(key string, statusCode int, contentType string, body []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	record, ok := s.records[key]
+	if !ok {
+		return ErrNotFound
+	}
+	record.Completed = true
+	record.StatusCode = statusCode
+	record.ContentType = contentType
+	record.Body = append([]byte(nil), body...)
+	return nil
+}
+
+// Release drops an in-flight claim on key
+func (s *MemoryStore) Release(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if record, ok := s.records[key]; ok && !record.Completed {
+		delete(s.records, key)
+	}
+	return nil
+}
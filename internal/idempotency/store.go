@@ -0,0 +1,42 @@
+package idempotency
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrNotFound is returned when a key has no record
+var ErrNotFound = errors.New("idempotency key not found")
+
+// Record is the stored state of an idempotency key. A record without a
+// response yet (Completed false) marks a request that is still in flight.
+type Record struct {
+	// Key is the client's key, prefixed with its tenant ID and a colon
+	Key         string    `gorm:"primarykey;size:266"`
+	Fingerprint string    `gorm:"not null;size:64"`
+	Completed   bool      `gorm:"not null;default:false"`
+	StatusCode  int       `gorm:"not null;default:0"`
+	ContentType string    `gorm:"type:text"`
+	Body        []byte    `gorm:"type:bytea"`
+	CreatedAt   time.Time `gorm:"not null"`
+	ExpiresAt   time.Time `gorm:"not null;index"`
+}
+
+// TableName sets the table used by GormStore
+func (Record) TableName() string {
+	return "idempotency_keys"
+}
+
+// Store persists idempotency records
+type Store interface {
+	// Begin claims key for a new request. If the key is already claimed and
+	// not expired, the existing record is returned with created set to false.
+	Begin(key, fingerprint string, ttl time.Duration) (record *Record, created bool, err error)
+	// Complete stores the response of the request that claimed key
+	Complete(key string, statusCode int, contentType string, body []byte) error
+	// Release drops an in-flight claim so the request can be retried
+	Release(key string) error
+}
+
+// sweepInterval is how many Begin calls pass between purges of expired records
+const sweepInterval = 100
@@ -0,0 +1,20 @@
+package middleware
+
+import (
+	"gin-simple-app/internal/audit"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Actor records the client behind each request in the request context, so
+// changes it makes are attributed to it in the audit log. The actor is the
+// client's address; the identity its API key or bearer token claims is
+// recorded next to it, marked as unverified.
+func Actor() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx := audit.WithActor(c.Request.Context(), clientAddress(c))
+		ctx = audit.WithClaimedActor(ctx, claimedIdentity(c))
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+	}
+}
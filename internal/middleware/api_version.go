@@ -0,0 +1,68 @@
+package middleware
+
+import (
+	"gin-simple-app/internal/versioning"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Headers describing the API version of a response
+const (
+	// APIVersionHeader names the version that served the request
+	APIVersionHeader = "API-Version"
+	// DeprecationHeader carries the date a version was deprecated (RFC 9745)
+	DeprecationHeader = "Deprecation"
+	// SunsetHeader carries the date a version stops being served (RFC 8594)
+	SunsetHeader = "Sunset"
+)
+
+// APIVersion serves a route group at version. With an empty version the
+// group is unversioned and the version is negotiated from the vendor media
+// type in the Accept header, e.g. application/vnd.app.v2+json, falling back
+// to the latest. Accept naming an unsupported version, or a different one
+// than the path, is answered with 406. Responses from deprecated versions
+// carry Deprecation and Sunset headers.
+func APIVersion(version string, deprecations versioning.Deprecations) gin.HandlerFunc {
+	supported := strings.Join(versioning.Supported, ", ")
+
+	return func(c *gin.Context) {
+		requested, ok, err := versioning.FromAccept(c.GetHeader("Accept"))
+		if err != nil {
+			response.Error(c, http.StatusNotAcceptable, "Unsupported API version "+requested+", must be one of: "+supported)
+			c.Abort()
+			return
+		}
+
+		served := version
+		if version == "" {
+			served = versioning.Latest
+			if ok {
+				served = requested
+			}
+			addVary(c, "Accept")
+		} else if ok && requested != version {
+			response.Error(c, http.StatusNotAcceptable, "Accept asks for API version "+requested+" but the path is for "+version)
+			c.Abort()
+			return
+		}
+
+		c.Set(versioning.ContextKey, served)
+		c.Header(APIVersionHeader, served)
+		if deprecation, ok := deprecations[served]; ok {
+			c.Header(DeprecationHeader, "@"+strconv.FormatInt(deprecation.At.Unix(), 10))
+			if !deprecation.Sunset.IsZero() {
+				c.Header(SunsetHeader, deprecation.Sunset.UTC().Format(http.TimeFormat))
+			}
+			if version != "" {
+				// Point clients at the same resource in the latest version
+				successor := strings.Replace(c.Request.URL.Path, "/"+version+"/", "/"+versioning.Latest+"/", 1)
+				c.Header("Link", "<"+successor+`>; rel="successor-version"`)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,144 @@
+package middleware
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// APIKeyHeader is the request header carrying the client's API key
+const APIKeyHeader = "X-API-Key"
+
+// claimedIdentity returns the identity the client behind a request
+// claims, see ClaimedIdentity
+func claimedIdentity(c *gin.Context) string {
+	return ClaimedIdentity(c.GetHeader(APIKeyHeader), c.GetHeader("Authorization"))
+}
+
+// clientAddress identifies the client behind a request by its IP address,
+// see ClientAddress
+func clientAddress(c *gin.Context) string {
+	return ClientAddress(c.ClientIP())
+}
+
+// ClaimedIdentity returns the identity a client claims with its API key or
+// Authorization header: "key:" and a hash of its API key, "sub:" and its
+// JWT subject, or "" if it claims none. API keys are hashed so they are
+// never stored.
+// Neither the key nor the token is verified, so a client can claim any
+// identity, or a new one with every request. Until authentication verifies
+// them, use ClientAddress wherever a client could gain from claiming
+// another identity.
+func ClaimedIdentity(apiKey, authorization string) string {
+	if apiKey != "" {
+		sum := sha256.Sum256([]byte(apiKey))
+		return "key:" + hex.EncodeToString(sum[:16])
+	}
+	if subject := bearerSubject(authorization); subject != "" {
+		return "sub:" + subject
+	}
+	return ""
+}
+
+// ClientAddress identifies a client by its IP address, "ip:" and the
+// address, or "anonymous" when it is unknown. Behind trusted proxies the
+// address is taken from X-Forwarded-For, so clients cannot choose it.
+func ClientAddress(ip string) string {
+	if ip != "" {
+		return "ip:" + ip
+	}
+	return "anonymous"
+}
+
+// bearerSubject returns the sub claim of a JWT bearer token, or "" if the
+// header does not carry one
+func bearerSubject(header string) string {
+	return bearerClaim(header, "sub")
+}
+
+// bearerClaim returns the string claim called name of a JWT bearer token,
+// or "" if the header does not carry one
+func bearerClaim(header, name string) string {
+	parts := bearerToken(header)
+	if parts == nil {
+		return ""
+	}
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return ""
+	}
+	value, _ := claims[name].(string)
+	return value
+}
+
+// ErrInvalidToken is returned for bearer tokens whose signature does not
+// verify or that have expired
+var ErrInvalidToken = errors.New("invalid bearer token")
+
+// verifiedBearerClaim returns the string claim called name of a JWT bearer
+// token signed with secret using HS256, or "" if the header carries no JWT.
+// A JWT that is not signed with secret, or that has expired, returns
+// ErrInvalidToken.
+func verifiedBearerClaim(header, name string, secret []byte) (string, error) {
+	parts := bearerToken(header)
+	if parts == nil {
+		return "", nil
+	}
+	joseHeader, err := decodeSegment(parts[0])
+	if err != nil || joseHeader["alg"] != "HS256" {
+		return "", ErrInvalidToken
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return "", ErrInvalidToken
+	}
+
+	claims, err := decodeSegment(parts[1])
+	if err != nil {
+		return "", ErrInvalidToken
+	}
+	if exp, ok := claims["exp"].(float64); ok && time.Now().Unix() >= int64(exp) {
+		return "", ErrInvalidToken
+	}
+	value, _ := claims[name].(string)
+	return value, nil
+}
+
+// bearerToken returns the three segments of a JWT bearer token, or nil if
+// the header does not carry one
+func bearerToken(header string) []string {
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil
+	}
+	parts := strings.Split(strings.TrimSpace(token), ".")
+	if len(parts) != 3 {
+		return nil
+	}
+	return parts
+}
+
+// decodeSegment decodes the JSON object of a JWT header or payload
+func decodeSegment(segment string) (map[string]interface{}, error) {
+	b, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return nil, err
+	}
+	var object map[string]interface{}
+	if err := json.Unmarshal(b, &object); err != nil {
+		return nil, err
+	}
+	return object, nil
+}
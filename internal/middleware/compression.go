@@ -0,0 +1,272 @@
+package middleware
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"gin-simple-app/internal/compression"
+	"gin-simple-app/pkg/response"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CompressionConfig controls response compression
+type CompressionConfig struct {
+	// Encodings are the codings responses may be compressed with, in order
+	// of preference; empty disables compression
+	Encodings []string
+	// MinSize is the smallest response worth compressing, in bytes
+	MinSize int
+	// ContentTypes lists the media types that are compressed. Formats that
+	// are compressed already, such as XLSX, gain nothing from it.
+	ContentTypes []string
+	// MaxDecompressedSize caps request bodies sent compressed, in bytes
+	// once decompressed
+	MaxDecompressedSize int64
+}
+
+// DefaultCompressionConfig compresses the text formats the API writes once
+// they reach 1 KiB, and accepts compressed request bodies of up to 10 MiB
+func DefaultCompressionConfig() CompressionConfig {
+	return CompressionConfig{
+		Encodings:           compression.DefaultEncodings,
+		MinSize:             1024,
+		MaxDecompressedSize: 10 << 20,
+		ContentTypes: []string{
+			"application/json",
+			"application/problem+json",
+			"application/xml",
+			"text/xml",
+			"text/csv",
+			"application/x-ndjson",
+			"application/msgpack",
+			"text/html",
+			"text/plain",
+		},
+	}
+}
+
+// Compression compresses responses with the coding the client prefers in
+// Accept-Encoding. A response is compressed once it is known to reach
+// cfg.MinSize, or when a streaming handler flushes it, as long as its
+// Content-Type is in cfg.ContentTypes and it is not encoded already. Event
+// streams are left alone, so every event reaches the client as it is sent.
+func Compression(cfg CompressionConfig) gin.HandlerFunc {
+	contentTypes := make(map[string]bool, len(cfg.ContentTypes))
+	for _, contentType := range cfg.ContentTypes {
+		contentTypes[strings.ToLower(contentType)] = true
+	}
+
+	return func(c *gin.Context) {
+		if len(cfg.Encodings) == 0 {
+			c.Next()
+			return
+		}
+		addVary(c, "Accept-Encoding")
+		encoding := compression.Negotiate(c.GetHeader("Accept-Encoding"), cfg.Encodings)
+		if encoding == compression.Identity || c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		writer := &compressWriter{
+			ResponseWriter: c.Writer,
+			encoding:       encoding,
+			minSize:        cfg.MinSize,
+			contentTypes:   contentTypes,
+		}
+		c.Writer = writer
+		defer func() {
+			if err := writer.finish(); err != nil {
+				log.Printf("Failed to compress response: %v", err)
+			}
+			c.Writer = writer.ResponseWriter
+		}()
+		c.Next()
+	}
+}
+
+// compressWriter holds back the start of a response until it knows whether
+// to compress it, then either compresses or passes writes through.
+// Status codes need no holding back: gin only sends them on the first write.
+type compressWriter struct {
+	gin.ResponseWriter
+	encoding     string
+	minSize      int
+	contentTypes map[string]bool
+
+	decided    bool
+	buffer     bytes.Buffer
+	compressor compression.Writer
+}
+
+// compressible reports whether the response can be compressed, judging by
+// its status and headers
+func (w *compressWriter) compressible() bool {
+	status := w.ResponseWriter.Status()
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		return false
+	}
+	header := w.Header()
+	if header.Get("Content-Encoding") != "" {
+		return false
+	}
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	return w.contentTypes[mediaType]
+}
+
+// decide settles whether to compress and sends what was held back.
+// Compression is skipped when the response is known to be too small.
+func (w *compressWriter) decide(complete bool) error {
+	w.decided = true
+	if w.compressible() && (!complete || w.buffer.Len() >= w.minSize) {
+		header := w.Header()
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		// A strong ETag names the uncompressed bytes, so the compressed
+		// ones only get a weak one
+		if etag := header.Get("ETag"); strings.HasPrefix(etag, `"`) {
+			header.Set("ETag", "W/"+etag)
+		}
+		w.compressor = compression.GetWriter(w.encoding, w.ResponseWriter)
+	}
+	if w.buffer.Len() == 0 {
+		return nil
+	}
+	_, err := w.write(w.buffer.Bytes())
+	w.buffer.Reset()
+	return err
+}
+
+// write sends b on, compressed if the response is being compressed
+func (w *compressWriter) write(b []byte) (int, error) {
+	if w.compressor != nil {
+		return w.compressor.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// finish sends anything still held back and completes the compressed stream
+func (w *compressWriter) finish() error {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return err
+		}
+	}
+	if w.compressor == nil {
+		return nil
+	}
+	err := w.compressor.Close()
+	compression.PutWriter(w.encoding, w.compressor)
+	w.compressor = nil
+	return err
+}
+
+func (w *compressWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		return w.write(b)
+	}
+	// Decide as soon as the response is known to be too large to hold
+	// back, or not to be compressible at all
+	w.buffer.Write(b)
+	if w.buffer.Len() >= w.minSize || !w.compressible() {
+		if err := w.decide(false); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+func (w *compressWriter) WriteHeaderNow() {
+	if w.decided {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(false); err != nil {
+			log.Printf("Failed to compress response: %v", err)
+		}
+	}
+	if w.compressor != nil {
+		if err := w.compressor.Flush(); err != nil {
+			log.Printf("Failed to compress response: %v", err)
+		}
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *compressWriter) Written() bool {
+	return w.buffer.Len() > 0 || w.ResponseWriter.Written()
+}
+
+// DecompressRequest decompresses request bodies sent with
+// Content-Encoding: gzip, for clients uploading large payloads. Bodies
+// larger than maxSize bytes once decompressed get 413, so a small upload
+// cannot expand into an unbounded one, and other codings get 415
+// Unsupported Media Type.
+func DecompressRequest(maxSize int64) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		encoding := strings.ToLower(strings.TrimSpace(c.GetHeader("Content-Encoding")))
+		if encoding == "" || encoding == compression.Identity || c.Request.Body == nil {
+			c.Next()
+			return
+		}
+		if encoding != compression.Gzip && encoding != "x-gzip" {
+			c.Header("Accept-Encoding", compression.Gzip)
+			response.Error(c, http.StatusUnsupportedMediaType, "Unsupported Content-Encoding "+encoding+", must be gzip")
+			c.Abort()
+			return
+		}
+
+		body, err := decompressBody(c.Request.Body, maxSize)
+		if errors.Is(err, errBodyTooLarge) {
+			response.Error(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("Request body is larger than %d bytes once decompressed", maxSize))
+			c.Abort()
+			return
+		}
+		if err != nil {
+			response.BadRequest(c, "Request body is not valid gzip")
+			c.Abort()
+			return
+		}
+
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		c.Request.ContentLength = int64(len(body))
+		c.Request.Header.Del("Content-Encoding")
+		c.Request.Header.Set("Content-Length", strconv.Itoa(len(body)))
+		c.Next()
+	}
+}
+
+var errBodyTooLarge = errors.New("request body too large")
+
+// decompressBody reads a gzip body, failing once more than maxSize bytes
+// come out of it
+func decompressBody(body io.Reader, maxSize int64) ([]byte, error) {
+	reader, err := compression.NewGzipReader(body)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	data, err := io.ReadAll(io.LimitReader(reader, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > maxSize {
+		return nil, errBodyTooLarge
+	}
+	return data, nil
+}
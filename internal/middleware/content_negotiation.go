@@ -0,0 +1,69 @@
+package middleware
+
+import (
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ContentNegotiation picks the format of the response from the Accept
+// header: JSON, XML or MessagePack, and also CSV for GET requests, which is
+// how lists are read. Requests accepting none of them get 406. Request
+// bodies may be sent in bodyFormats, JSON if none are given; a body with
+// another Content-Type gets 415. A body without a Content-Type is taken to
+// be JSON.
+func ContentNegotiation(bodyFormats ...response.Format) gin.HandlerFunc {
+	if len(bodyFormats) == 0 {
+		bodyFormats = []response.Format{response.FormatJSON}
+	}
+	bodyTypes := strings.Join(response.ContentTypes(bodyFormats), ", ")
+
+	return func(c *gin.Context) {
+		addVary(c, "Accept")
+
+		if contentType := c.ContentType(); c.Request.ContentLength != 0 && contentType != "" {
+			format, ok := response.FormatOf(contentType)
+			if !ok || !hasFormat(bodyFormats, format) {
+				response.Error(c, http.StatusUnsupportedMediaType, "Unsupported Content-Type "+contentType+", must be one of: "+bodyTypes)
+				c.Abort()
+				return
+			}
+		}
+
+		offered := response.Formats
+		if c.Request.Method != http.MethodGet {
+			offered = []response.Format{response.FormatJSON, response.FormatXML, response.FormatMsgPack}
+		}
+		accepted := response.AcceptedFormats(c.GetHeader("Accept"), offered)
+		if len(accepted) == 0 {
+			response.Error(c, http.StatusNotAcceptable, "Not acceptable, responses are available as: "+strings.Join(response.ContentTypes(offered), ", "))
+			c.Abort()
+			return
+		}
+		c.Set(response.FormatsKey, accepted)
+		c.Next()
+	}
+}
+
+func hasFormat(formats []response.Format, format response.Format) bool {
+	for _, f := range formats {
+		if f == format {
+			return true
+		}
+	}
+	return false
+}
+
+// addVary adds field to the Vary header unless it is already listed
+func addVary(c *gin.Context, field string) {
+	for _, value := range c.Writer.Header().Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), field) {
+				return
+			}
+		}
+	}
+	c.Writer.Header().Add("Vary", field)
+}
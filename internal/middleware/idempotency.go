@@ -0,0 +1,146 @@
+package middleware
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"gin-simple-app/internal/idempotency"
+	"gin-simple-app/internal/tenant"
+	"gin-simple-app/pkg/response"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// IdempotencyKeyHeader is the request header carrying the client's key
+	IdempotencyKeyHeader = "Idempotency-Key"
+	// IdempotentReplayedHeader marks a response replayed from the store
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// Idempotency makes POST requests that carry an Idempotency-Key header safe
+// to retry. The first request with a key runs normally and its response is
+// stored for ttl; a retry with the same key, body and formats gets the
+// stored response back. Reusing a key for a different request, or while the
+// first request is still running, is answered with 409 Conflict. Server
+// errors are not stored, so the client can retry them. It runs after
+// ContentNegotiation, so requests rejected for their format are not stored.
+func Idempotency(store idempotency.Store, ttl time.Duration) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(IdempotencyKeyHeader)
+		if c.Request.Method != http.MethodPost || key == "" {
+			c.Next()
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			response.BadRequest(c, "Idempotency-Key must be at most 255 characters")
+			c.Abort()
+			return
+		}
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			response.BadRequest(c, "Failed to read request body")
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := requestFingerprint(c, body)
+		// Each tenant has keys of its own, so a key another tenant already
+		// used neither replays its response nor blocks the request
+		key = strconv.FormatUint(uint64(tenant.IDFromContext(c.Request.Context())), 10) + ":" + key
+
+		record, created, err := store.Begin(key, fingerprint, ttl)
+		if err != nil {
+			log.Printf("Idempotency store error: %v", err)
+			response.InternalServerError(c, "Failed to process Idempotency-Key")
+			c.Abort()
+			return
+		}
+
+		if !created {
+			replay(c, record, fingerprint)
+			return
+		}
+
+		writer := &captureWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		completed := false
+		defer func() {
+			// Release the claim if the handler failed or panicked so a retry can run
+			if !completed {
+				if err := store.Release(key); err != nil {
+					log.Printf("Failed to release idempotency key: %v", err)
+				}
+			}
+		}()
+
+		c.Next()
+
+		if status := writer.Status(); status < http.StatusInternalServerError {
+			if err := store.Complete(key, status, writer.Header().Get("Content-Type"), writer.body.Bytes()); err != nil {
+				log.Printf("Failed to store idempotent response: %v", err)
+				return
+			}
+			completed = true
+		}
+	}
+}
+
+// replay answers a request whose key was already used
+func replay(c *gin.Context, record *idempotency.Record, fingerprint string) {
+	defer c.Abort()
+
+	if record.Fingerprint != fingerprint {
+		response.Error(c, http.StatusConflict, "Idempotency-Key was already used for a different request")
+		return
+	}
+	if !record.Completed {
+		c.Header("Retry-After", "1")
+		response.Error(c, http.StatusConflict, "A request with this Idempotency-Key is still being processed")
+		return
+	}
+
+	c.Header(IdempotentReplayedHeader, "true")
+	c.Data(record.StatusCode, record.ContentType, record.Body)
+}
+
+// requestFingerprint identifies a request by method, path, body and its
+// format: the Content-Type of the body and the formats the response may be
+// written in, as negotiated by ContentNegotiation if it ran first
+func requestFingerprint(c *gin.Context, body []byte) string {
+	accept := c.GetHeader("Accept")
+	if formats, ok := c.Get(response.FormatsKey); ok {
+		accept = fmt.Sprint(formats)
+	}
+	h := sha256.New()
+	io.WriteString(h, c.Request.Method+" "+c.Request.URL.Path+"\n")
+	io.WriteString(h, "Content-Type: "+c.ContentType()+"\n")
+	io.WriteString(h, "Accept: "+accept+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// captureWriter records the response body while passing it through
+type captureWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *captureWriter) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
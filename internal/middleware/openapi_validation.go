@@ -0,0 +1,175 @@
+package middleware
+
+import (
+	"bytes"
+	"gin-simple-app/internal/openapi"
+	"gin-simple-app/pkg/response"
+	"io"
+	"log"
+	"mime"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIValidation checks requests and responses against the OpenAPI
+// document, so the document cannot drift from what the API really does. It
+// is meant for tests and development: requests that do not match get 400
+// validation_failed, and JSON responses that do not match are replaced by
+// a 500. Other responses, such as exports and event streams, are passed
+// through as they are written and mismatches are only logged.
+func OpenAPIValidation(doc *openapi.Document) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			var err error
+			if body, err = io.ReadAll(c.Request.Body); err != nil {
+				response.BadRequest(c, "Failed to read request body")
+				c.Abort()
+				return
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+		if violations := doc.ValidateRequest(c.Request, route, c.Params, body); len(violations) > 0 {
+			fields := make([]response.FieldError, len(violations))
+			for i, v := range violations {
+				fields[i] = response.FieldError{Field: v.Field, Code: "openapi", Message: v.Field + " " + v.Message}
+			}
+			response.InvalidFields(c, fields)
+			c.Abort()
+			return
+		}
+
+		writer := &validationWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+		c.Writer = writer.ResponseWriter
+
+		violations := doc.ValidateResponse(c.Request.Method, route, writer.Status(), writer.Header(), writer.body.Bytes())
+		if writer.streaming {
+			for _, v := range violations {
+				log.Printf("OpenAPI: %s %s response %s %s", c.Request.Method, route, v.Field, v.Message)
+			}
+			return
+		}
+		if len(violations) == 0 {
+			writer.commit()
+			return
+		}
+
+		messages := make([]string, len(violations))
+		for i, v := range violations {
+			messages[i] = v.Field + " " + v.Message
+			log.Printf("OpenAPI: %s %s response %s %s", c.Request.Method, route, v.Field, v.Message)
+		}
+		writer.Header().Del("Content-Type")
+		response.InternalServerError(c, "Response does not match the OpenAPI document: "+strings.Join(messages, "; "))
+	}
+}
+
+// validationWriter holds back JSON responses until they have been checked.
+// Anything else, and anything flushed, is streamed straight through.
+type validationWriter struct {
+	gin.ResponseWriter
+	status    int
+	body      bytes.Buffer
+	streaming bool
+	decided   bool
+}
+
+// buffering reports whether writes are being held back, deciding on the
+// first write by the response's content type
+func (w *validationWriter) buffering() bool {
+	if !w.decided {
+		w.decided = true
+		mediaType, _, _ := mime.ParseMediaType(w.Header().Get("Content-Type"))
+		if mediaType != "" && mediaType != "application/json" && !strings.HasSuffix(mediaType, "+json") {
+			w.stream()
+		}
+	}
+	return !w.streaming
+}
+
+// stream switches to passing writes through, sending what was held back
+func (w *validationWriter) stream() {
+	w.streaming = true
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	if w.body.Len() > 0 {
+		_, _ = w.ResponseWriter.Write(w.body.Bytes())
+	}
+}
+
+// commit sends a response that was held back
+func (w *validationWriter) commit() {
+	if w.status != 0 {
+		w.ResponseWriter.WriteHeader(w.status)
+	}
+	w.ResponseWriter.WriteHeaderNow()
+	_, _ = w.ResponseWriter.Write(w.body.Bytes())
+}
+
+func (w *validationWriter) WriteHeader(code int) {
+	if w.streaming {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	w.status = code
+}
+
+func (w *validationWriter) WriteHeaderNow() {
+	if w.buffering() {
+		return
+	}
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *validationWriter) Write(b []byte) (int, error) {
+	if w.buffering() {
+		return w.body.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *validationWriter) WriteString(s string) (int, error) {
+	if w.buffering() {
+		return w.body.WriteString(s)
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *validationWriter) Flush() {
+	if !w.streaming {
+		w.decided = true
+		w.stream()
+	}
+	w.ResponseWriter.Flush()
+}
+
+func (w *validationWriter) Status() int {
+	if !w.streaming && w.status != 0 {
+		return w.status
+	}
+	return w.ResponseWriter.Status()
+}
+
+func (w *validationWriter) Size() int {
+	if !w.streaming {
+		return w.body.Len()
+	}
+	return w.ResponseWriter.Size()
+}
+
+func (w *validationWriter) Written() bool {
+	if !w.streaming {
+		return w.status != 0 || w.body.Len() > 0
+	}
+	return w.ResponseWriter.Written()
+}
@@ -0,0 +1,58 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"gin-simple-app/internal/audit"
+	"gin-simple-app/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in both directions
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds client-supplied request IDs
+const maxRequestIDLength = 128
+
+// RequestID assigns every request an ID, reusing the client's X-Request-ID
+// when it looks sane. The ID is echoed in the response header and stored in
+// the context for error responses, logs and the audit log.
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := RequestIDOrNew(c.GetHeader(RequestIDHeader))
+
+		c.Set(response.RequestIDKey, id)
+		c.Request = c.Request.WithContext(audit.WithRequestID(c.Request.Context(), id))
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestIDOrNew returns id if it is usable as a request ID, or a new random one
+func RequestIDOrNew(id string) string {
+	if !validRequestID(id) {
+		return newRequestID()
+	}
+	return id
+}
+
+// validRequestID accepts non-empty IDs of printable ASCII characters
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+// newRequestID returns a random 128-bit hex ID
+func newRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
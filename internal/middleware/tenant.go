@@ -0,0 +1,189 @@
+package middleware
+
+import (
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"gin-simple-app/pkg/response"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TenantHeader is the request header naming the tenant by slug
+const TenantHeader = "X-Tenant"
+
+// TenantConfig controls how the tenant of a request is resolved
+type TenantConfig struct {
+	// Claim is the bearer token claim naming the tenant; empty ignores
+	// bearer tokens
+	Claim string
+	// TokenSecret is the key bearer tokens are signed with using HS256.
+	// Tokens are only read when it is set, and only once they verify.
+	TokenSecret []byte
+	// TrustedProxies are the networks of the proxies that set the X-Tenant
+	// header. Requests from anywhere else cannot name their tenant with it.
+	TrustedProxies []netip.Prefix
+	// BaseDomain is the domain tenants are subdomains of, such as
+	// api.example.com for acme.api.example.com; empty ignores the host.
+	// Clients choose the host they send, so it only counts alongside a
+	// verified token or a trusted header naming the same tenant.
+	BaseDomain string
+	// Required rejects requests that name no tenant instead of serving
+	// them for the default tenant
+	Required bool
+}
+
+// DefaultTenantConfig reads the tenant from the tenant claim of bearer
+// tokens once a TokenSecret is set, and serves requests that name none for
+// the default tenant
+func DefaultTenantConfig() TenantConfig {
+	return TenantConfig{Claim: "tenant"}
+}
+
+// Errors returned by TenantSlug and ResolveTenant
+var (
+	ErrTenantMismatch   = errors.New("tenant mismatch")
+	ErrTenantRequired   = errors.New("tenant required")
+	ErrUnknownTenant    = errors.New("unknown tenant")
+	ErrUnverifiedTenant = errors.New("tenant named only by the host")
+)
+
+// TrustsProxy reports whether ip, the address of the peer that sent a
+// request, is in one of cfg.TrustedProxies
+func (cfg TenantConfig) TrustsProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range cfg.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// TenantSlug returns the slug of the tenant a request names in its
+// X-Tenant header, the claim of its bearer token or its host, or "" if it
+// names none. The header must only be passed on when a trusted proxy sent
+// the request, see TrustsProxy, and the token is only read once it
+// verifies with cfg.TokenSecret; a token that does not returns
+// ErrInvalidToken. Sources that name different tenants return
+// ErrTenantMismatch, so neither the header nor the host can override the
+// tenant of a token. The host is up to the client, so a host naming a
+// tenant neither the header nor a token names returns ErrUnverifiedTenant.
+func TenantSlug(header, authorization, host string, cfg TenantConfig) (string, error) {
+	var slugs []string
+	if slug := strings.TrimSpace(header); slug != "" {
+		slugs = append(slugs, strings.ToLower(slug))
+	}
+	if cfg.Claim != "" && len(cfg.TokenSecret) > 0 {
+		slug, err := verifiedBearerClaim(authorization, cfg.Claim, cfg.TokenSecret)
+		if err != nil {
+			return "", err
+		}
+		if slug != "" {
+			slugs = append(slugs, strings.ToLower(slug))
+		}
+	}
+	if cfg.BaseDomain != "" {
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		suffix := "." + strings.ToLower(strings.Trim(cfg.BaseDomain, "."))
+		if host = strings.ToLower(host); strings.HasSuffix(host, suffix) {
+			if len(slugs) == 0 {
+				return "", ErrUnverifiedTenant
+			}
+			slugs = append(slugs, strings.TrimSuffix(host, suffix))
+		}
+	}
+
+	if len(slugs) == 0 {
+		return "", nil
+	}
+	for _, slug := range slugs[1:] {
+		if slug != slugs[0] {
+			return "", ErrTenantMismatch
+		}
+	}
+	return slugs[0], nil
+}
+
+// ResolveTenant returns the ID of the tenant with slug. A request naming no
+// tenant is for the default tenant, unless cfg.Required.
+func ResolveTenant(tenants repository.TenantRepository, slug string, cfg TenantConfig) (uint, error) {
+	if slug == "" {
+		if cfg.Required {
+			return 0, ErrTenantRequired
+		}
+		return models.DefaultTenantID, nil
+	}
+	t, err := tenants.GetBySlug(slug)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, ErrUnknownTenant
+	}
+	if err != nil {
+		return 0, err
+	}
+	return t.ID, nil
+}
+
+// Tenant resolves the tenant of each request, see TenantSlug, and records
+// it in the request context so every user read and written is scoped to
+// it. Requests naming a tenant that does not exist get 400, as do requests
+// naming none when cfg.Required. Requests with an invalid token, or naming
+// their tenant only by host, get 401, and requests naming two different
+// tenants get 403.
+func Tenant(tenants repository.TenantRepository, cfg TenantConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		// Responses differ from one tenant to the next
+		addVary(c, TenantHeader)
+		var header string
+		if cfg.TrustsProxy(c.RemoteIP()) {
+			header = c.GetHeader(TenantHeader)
+		}
+		slug, err := TenantSlug(header, c.GetHeader("Authorization"), c.Request.Host, cfg)
+		var tenantID uint
+		if err == nil {
+			tenantID, err = ResolveTenant(tenants, slug, cfg)
+		}
+		switch {
+		case errors.Is(err, ErrInvalidToken):
+			response.Error(c, http.StatusUnauthorized, "Invalid bearer token")
+			c.Abort()
+			return
+		case errors.Is(err, ErrUnverifiedTenant):
+			response.Error(c, http.StatusUnauthorized, "A bearer token naming the tenant is required")
+			c.Abort()
+			return
+		case errors.Is(err, ErrTenantMismatch):
+			response.Error(c, http.StatusForbidden, "The request names more than one tenant")
+			c.Abort()
+			return
+		case errors.Is(err, ErrTenantRequired):
+			response.BadRequest(c, "A tenant is required: send the "+TenantHeader+" header")
+			c.Abort()
+			return
+		case errors.Is(err, ErrUnknownTenant):
+			response.BadRequest(c, "Unknown tenant "+slug)
+			c.Abort()
+			return
+		case err != nil:
+			log.Printf("Failed to resolve tenant: %v", err)
+			response.InternalServerError(c, "Failed to resolve tenant")
+			c.Abort()
+			return
+		}
+		c.Request = c.Request.WithContext(tenant.WithID(c.Request.Context(), tenantID))
+		c.Next()
+	}
+}
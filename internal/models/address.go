@@ -0,0 +1,53 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+// Address is a structured postal address, stored as a JSONB column
+type Address struct {
+	Line1      string `json:"line1" xml:"line1" binding:"required,max=200"`
+	Line2      string `json:"line2,omitempty" xml:"line2,omitempty" binding:"max=200"`
+	City       string `json:"city" xml:"city" binding:"required,max=100"`
+	Region     string `json:"region,omitempty" xml:"region,omitempty" binding:"max=100"`
+	PostalCode string `json:"postal_code,omitempty" xml:"postal_code,omitempty" binding:"max=20"`
+	Country    string `json:"country" xml:"country" binding:"required,iso3166_1_alpha2"`
+}
+
+// String formats the address on a single line, e.g. "123 Main St, New York, NY 10001, US"
+func (a Address) String() string {
+	parts := []string{a.Line1, a.Line2, a.City, strings.TrimSpace(a.Region + " " + a.PostalCode), a.Country}
+	nonEmpty := parts[:0]
+	for _, p := range parts {
+		if p != "" {
+			nonEmpty = append(nonEmpty, p)
+		}
+	}
+	return strings.Join(nonEmpty, ", ")
+}
+
+// Value implements driver.Valuer so GORM stores the address as JSON
+func (a Address) Value() (driver.Value, error) {
+	b, err := json.Marshal(a)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so GORM can read the address from JSON
+func (a *Address) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, a)
+	case string:
+		return json.Unmarshal([]byte(v), a)
+	case nil:
+		*a = Address{}
+		return nil
+	}
+	return errors.New("unsupported address value")
+}
@@ -0,0 +1,90 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Audit actions
+const (
+	AuditActionCreate = "create"
+	AuditActionUpdate = "update"
+	AuditActionDelete = "delete"
+)
+
+// AuditEntry records one change to a user: who made it, in which request,
+// and the value of every changed field before and after
+type AuditEntry struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"timestamp" gorm:"not null;index"`
+	TenantID  uint      `json:"-" gorm:"not null;default:1;index"`
+	// Actor is the address of the client, the part of its identity the
+	// server can vouch for until requests are authenticated
+	Actor string `json:"actor" gorm:"size:255;not null;index"`
+	// ClaimedActor is the identity the client claimed with an API key or
+	// bearer token. Nothing verifies it, so it is a hint, not a record.
+	ClaimedActor string       `json:"claimed_actor,omitempty" gorm:"size:255;index"`
+	Action       string       `json:"action" gorm:"size:16;not null"`
+	UserID       uint         `json:"user_id" gorm:"not null;index"`
+	RequestID    string       `json:"request_id,omitempty" gorm:"size:128;index"`
+	Changes      AuditChanges `json:"changes" gorm:"type:jsonb;not null"`
+}
+
+// TableName sets the table used for audit entries
+func (AuditEntry) TableName() string {
+	return "audit_entries"
+}
+
+// FieldChange is the value of a field before and after a change. Before is
+// null for created users and After is null for deleted ones.
+type FieldChange struct {
+	Before interface{} `json:"before"`
+	After  interface{} `json:"after"`
+}
+
+// AuditChanges maps the JSON names of changed user fields to their change
+type AuditChanges map[string]FieldChange
+
+// Value implements driver.Valuer so GORM stores the changes as JSON
+func (c AuditChanges) Value() (driver.Value, error) {
+	if c == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so GORM can read the changes from JSON
+func (c *AuditChanges) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, c)
+	case string:
+		return json.Unmarshal([]byte(v), c)
+	case nil:
+		*c = AuditChanges{}
+		return nil
+	}
+	return errors.New("unsupported audit changes value")
+}
+
+// AuditFilter narrows down audit entries. Entries are returned newest first;
+// BeforeID pages back through older ones.
+type AuditFilter struct {
+	// TenantID is set from the request, never by the client
+	TenantID     uint       `form:"-"`
+	UserID       uint       `form:"user_id"`
+	Actor        string     `form:"actor" binding:"max=255"`
+	ClaimedActor string     `form:"claimed_actor" binding:"max=255"`
+	Action       string     `form:"action" binding:"omitempty,oneof=create update delete"`
+	RequestID    string     `form:"request_id" binding:"max=128"`
+	Since        *time.Time `form:"since" time_format:"2006-01-02T15:04:05Z07:00"`
+	Until        *time.Time `form:"until" time_format:"2006-01-02T15:04:05Z07:00"`
+	BeforeID     uint       `form:"before_id"`
+	Limit        int        `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
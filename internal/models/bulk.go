@@ -0,0 +1,50 @@
+package models
+
+import "encoding/json"
+
+// Bulk operation types
+const (
+	BulkOpCreate = "create"
+	BulkOpUpdate = "update"
+	BulkOpDelete = "delete"
+)
+
+// Bulk operation result statuses
+const (
+	BulkStatusCreated = "created"
+	BulkStatusUpdated = "updated"
+	BulkStatusDeleted = "deleted"
+	BulkStatusFailed  = "failed"
+	BulkStatusSkipped = "skipped"
+)
+
+// BulkRequest represents the request payload for POST /api/v1/users/bulk
+type BulkRequest struct {
+	Operations []BulkOperationRequest `json:"operations" binding:"required,min=1,max=1000,dive"`
+}
+
+// BulkOperationRequest is a single operation as sent by the client. Data is
+// decoded into a CreateUserRequest or UpdateUserRequest depending on Op.
+type BulkOperationRequest struct {
+	Op   string          `json:"op" binding:"required,oneof=create update delete"`
+	ID   uint            `json:"id,omitempty"`
+	Data json.RawMessage `json:"data,omitempty"`
+}
+
+// BulkOperation is a decoded and validated bulk operation
+type BulkOperation struct {
+	Op     string
+	ID     uint
+	Create *CreateUserRequest
+	Update *UpdateUserRequest
+}
+
+// BulkResult reports the outcome of a single bulk operation
+type BulkResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	ID     uint   `json:"id,omitempty"`
+	Status string `json:"status"`
+	Error  string `json:"error,omitempty"`
+	User   *User  `json:"user,omitempty"`
+}
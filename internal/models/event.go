@@ -0,0 +1,70 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Domain event types for user changes
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Event is a domain event. Events are written to the outbox in the same
+// transaction as the change they describe and relayed to sinks afterwards;
+// the delivery fields track that and are not part of the event itself.
+type Event struct {
+	Sequence   uint      `json:"sequence" gorm:"primarykey"`
+	EventID    string    `json:"id" gorm:"size:36;uniqueIndex;not null"`
+	Type       string    `json:"type" gorm:"size:64;not null;index"`
+	TenantID   uint      `json:"tenant_id" gorm:"not null;default:1;index"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	Actor      string    `json:"actor" gorm:"size:255;not null"`
+	RequestID  string    `json:"request_id,omitempty" gorm:"size:128"`
+	OccurredAt time.Time `json:"occurred_at" gorm:"not null"`
+	Data       EventData `json:"data" gorm:"type:jsonb;not null"`
+
+	PublishedAt   *time.Time `json:"-" gorm:"index"`
+	Attempts      int        `json:"-" gorm:"not null;default:0"`
+	NextAttemptAt time.Time  `json:"-" gorm:"not null;index"`
+	LastError     string     `json:"-" gorm:"type:text"`
+}
+
+// TableName sets the outbox table
+func (Event) TableName() string {
+	return "outbox_events"
+}
+
+// EventData is the body of a user event: the user after the change, or
+// before it for deletes, and the fields that changed
+type EventData struct {
+	User    *User        `json:"user"`
+	Changes AuditChanges `json:"changes"`
+}
+
+// Value implements driver.Valuer so GORM stores the data as JSON
+func (d EventData) Value() (driver.Value, error) {
+	b, err := json.Marshal(d)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so GORM can read the data from JSON
+func (d *EventData) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, d)
+	case string:
+		return json.Unmarshal([]byte(v), d)
+	case nil:
+		*d = EventData{}
+		return nil
+	}
+	return errors.New("unsupported event data value")
+}
@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// The default tenant owns the users of single-tenant deployments and of
+// requests that do not name a tenant
+const (
+	DefaultTenantID   uint = 1
+	DefaultTenantSlug      = "default"
+)
+
+// Tenant is a customer organization. Every user belongs to exactly one
+// tenant and is only visible to requests made for it.
+type Tenant struct {
+	ID        uint      `json:"id" gorm:"primarykey"`
+	CreatedAt time.Time `json:"created_at"`
+	// Slug names the tenant in the X-Tenant header, the tenant claim of
+	// bearer tokens and subdomains, so it must be a valid DNS label
+	Slug string `json:"slug" gorm:"size:63;uniqueIndex;not null"`
+	Name string `json:"name" gorm:"size:255;not null"`
+}
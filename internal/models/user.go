@@ -8,28 +8,65 @@ import (
 
 // User represents a user in the system
 type User struct {
-	ID        uint           `json:"id" gorm:"primarykey"`
-	CreatedAt time.Time      `json:"created_at"`
-	UpdatedAt time.Time      `json:"updated_at"`
-	DeletedAt gorm.DeletedAt `json:"-" gorm:"index"`
-	Name      string         `json:"name" gorm:"not null" binding:"required"`
-	Email     string         `json:"email" gorm:"uniqueIndex;not null" binding:"required,email"`
-	Phone     *string        `json:"phone" gorm:"type:text;default:null" binding:"required"`
-	Address   *string        `json:"address,omitempty" gorm:"type:text"`
+	ID            uint           `json:"id" gorm:"primarykey"`
+	CreatedAt     time.Time      `json:"created_at"`
+	UpdatedAt     time.Time      `json:"updated_at"`
+	DeletedAt     gorm.DeletedAt `json:"-" gorm:"index"`
+	TenantID      uint           `json:"-" gorm:"not null;default:1;uniqueIndex:idx_users_tenant_email,priority:1"`
+	Name          string         `json:"name" gorm:"not null" binding:"required"`
+	Email         string         `json:"email" gorm:"uniqueIndex:idx_users_tenant_email,priority:2;not null" binding:"required,email"`
+	Phone         *string        `json:"phone" gorm:"type:text;default:null" binding:"required"`
+	PhoneOriginal *string        `json:"phone_original,omitempty" gorm:"type:text"`
+	Address       *string        `json:"address,omitempty" gorm:"type:text"`
+	PostalAddress *Address       `json:"postal_address,omitempty" gorm:"type:jsonb"`
 }
 
 // CreateUserRequest represents the request payload for creating a user
 type CreateUserRequest struct {
-	Name    string  `json:"name" binding:"required"`
-	Email   string  `json:"email" binding:"required,email"`
-	Phone   string  `json:"phone" binding:"required"`
-	Address *string `json:"address,omitempty"`
+	Name    string  `json:"name" xml:"name" binding:"required"`
+	Email   string  `json:"email" xml:"email" binding:"required,email"`
+	Phone   string  `json:"phone" xml:"phone" binding:"required,phone"`
+	Address *string `json:"address,omitempty" xml:"address,omitempty"`
+	// PostalAddress takes precedence over the deprecated free-text Address
+	PostalAddress *Address `json:"postal_address,omitempty" xml:"postal_address,omitempty"`
 }
 
 // UpdateUserRequest represents the request payload for updating a user
 type UpdateUserRequest struct {
-	Name    string  `json:"name" binding:"required"`
-	Email   string  `json:"email" binding:"required,email"`
-	Phone   string  `json:"phone" binding:"required"`
-	Address *string `json:"address,omitempty"`
+	Name    string  `json:"name" xml:"name" binding:"required"`
+	Email   string  `json:"email" xml:"email" binding:"required,email"`
+	Phone   string  `json:"phone" xml:"phone" binding:"required,phone"`
+	Address *string `json:"address,omitempty" xml:"address,omitempty"`
+	// PostalAddress takes precedence over the deprecated free-text Address
+	PostalAddress *Address `json:"postal_address,omitempty" xml:"postal_address,omitempty"`
+}
+
+// UserFilter holds the optional filters accepted by user listing endpoints
+type UserFilter struct {
+	Name    string `form:"name"`
+	Email   string `form:"email"`
+	City    string `form:"city"`
+	Country string `form:"country" binding:"omitempty,iso3166_1_alpha2"`
+}
+
+// UserPage is one page of the users matching a UserFilter, in ID order
+type UserPage struct {
+	Users []User
+	// Total is the number of users matching the filter across every page
+	Total int64
+	// HasNextPage reports whether more users follow the last one
+	HasNextPage bool
+}
+
+// UserSearchRequest holds the query parameters for user search
+type UserSearchRequest struct {
+	Query string `form:"q" binding:"required,max=200"`
+	Limit int    `form:"limit" binding:"omitempty,min=1,max=100"`
+}
+
+// UserSearchResult is a single ranked match returned by user search
+type UserSearchResult struct {
+	User       User              `json:"user"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
 }
@@ -0,0 +1,80 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+)
+
+// userFieldColumns maps the JSON fields of a user to the columns they are
+// read from. The names are the same in every API version.
+var userFieldColumns = map[string]string{
+	"id":             "id",
+	"name":           "name",
+	"email":          "email",
+	"phone":          "phone",
+	"phone_original": "phone_original",
+	"address":        "address",
+	"postal_address": "postal_address",
+	"created_at":     "created_at",
+	"updated_at":     "updated_at",
+}
+
+// UserFieldNames lists the fields a user can be projected to, in the order
+// they are written
+var UserFieldNames = []string{
+	"id", "name", "email", "phone", "phone_original", "address", "postal_address", "created_at", "updated_at",
+}
+
+// UserFields is a projection of users to some of their fields. An empty
+// projection keeps every field.
+type UserFields []string
+
+// ParseUserFields parses a comma-separated list of field names, such as
+// "id,name,email". Duplicates are dropped; unknown names are an error.
+func ParseUserFields(s string) (UserFields, error) {
+	var fields UserFields
+	var unknown []string
+	seen := map[string]bool{}
+	for _, name := range strings.Split(s, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := userFieldColumns[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		fields = append(fields, name)
+	}
+	if len(unknown) > 0 {
+		return nil, fmt.Errorf("unknown user fields: %s (must be among %s)", strings.Join(unknown, ", "), strings.Join(UserFieldNames, ", "))
+	}
+	return fields, nil
+}
+
+// Has reports whether the projection keeps the field name
+func (f UserFields) Has(name string) bool {
+	if len(f) == 0 {
+		return true
+	}
+	for _, field := range f {
+		if field == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Columns returns the columns to select for the projection, or nil to
+// select every column
+func (f UserFields) Columns() []string {
+	if len(f) == 0 {
+		return nil
+	}
+	columns := make([]string, len(f))
+	for i, field := range f {
+		columns[i] = userFieldColumns[field]
+	}
+	return columns
+}
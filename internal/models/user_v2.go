@@ -0,0 +1,127 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// TimestampLayoutV2 formats v2 timestamps: ISO 8601 in UTC with milliseconds
+const TimestampLayoutV2 = "2006-01-02T15:04:05.000Z"
+
+// PersonName is a name split into its parts. Full is set on responses and
+// ignored on requests.
+type PersonName struct {
+	Full   string `json:"full,omitempty" xml:"full,omitempty"`
+	Given  string `json:"given" xml:"given" binding:"required,max=100"`
+	Family string `json:"family,omitempty" xml:"family,omitempty" binding:"max=100"`
+}
+
+// SplitName splits a stored name into its parts. The last word is taken as
+// the family name and the rest as the given name.
+func SplitName(name string) PersonName {
+	name = strings.TrimSpace(name)
+	parts := PersonName{Full: name, Given: name}
+	if i := strings.LastIndexAny(name, " \t"); i >= 0 {
+		parts.Given = strings.TrimSpace(name[:i])
+		parts.Family = name[i+1:]
+	}
+	return parts
+}
+
+// String joins the given and family names as they are stored
+func (n PersonName) String() string {
+	return strings.TrimSpace(strings.TrimSpace(n.Given) + " " + strings.TrimSpace(n.Family))
+}
+
+// UserV2 is the v2 representation of a user
+type UserV2 struct {
+	ID            uint       `json:"id"`
+	Name          PersonName `json:"name" binding:"required"`
+	Email         string     `json:"email" binding:"required,email"`
+	Phone         *string    `json:"phone"`
+	PhoneOriginal *string    `json:"phone_original,omitempty"`
+	Address       *string    `json:"address,omitempty"`
+	PostalAddress *Address   `json:"postal_address,omitempty"`
+	CreatedAt     string     `json:"created_at" binding:"required"`
+	UpdatedAt     string     `json:"updated_at" binding:"required"`
+}
+
+// NewUserV2 maps a user to its v2 representation
+func NewUserV2(user *User) *UserV2 {
+	if user == nil {
+		return nil
+	}
+	return &UserV2{
+		ID:            user.ID,
+		Name:          SplitName(user.Name),
+		Email:         user.Email,
+		Phone:         user.Phone,
+		PhoneOriginal: user.PhoneOriginal,
+		Address:       user.Address,
+		PostalAddress: user.PostalAddress,
+		CreatedAt:     FormatTimestampV2(user.CreatedAt),
+		UpdatedAt:     FormatTimestampV2(user.UpdatedAt),
+	}
+}
+
+// FormatTimestampV2 formats t with TimestampLayoutV2
+func FormatTimestampV2(t time.Time) string {
+	return t.UTC().Format(TimestampLayoutV2)
+}
+
+// CreateUserRequestV2 is the v2 request payload for creating a user
+type CreateUserRequestV2 struct {
+	Name          PersonName `json:"name" xml:"name" binding:"required"`
+	Email         string     `json:"email" xml:"email" binding:"required,email"`
+	Phone         string     `json:"phone" xml:"phone" binding:"required,phone"`
+	Address       *string    `json:"address,omitempty" xml:"address,omitempty"`
+	PostalAddress *Address   `json:"postal_address,omitempty" xml:"postal_address,omitempty"`
+}
+
+// V1 converts the request to its v1 equivalent
+func (r CreateUserRequestV2) V1() CreateUserRequest {
+	return CreateUserRequest{
+		Name:          r.Name.String(),
+		Email:         r.Email,
+		Phone:         r.Phone,
+		Address:       r.Address,
+		PostalAddress: r.PostalAddress,
+	}
+}
+
+// UpdateUserRequestV2 is the v2 request payload for updating a user
+type UpdateUserRequestV2 struct {
+	Name          PersonName `json:"name" xml:"name" binding:"required"`
+	Email         string     `json:"email" xml:"email" binding:"required,email"`
+	Phone         string     `json:"phone" xml:"phone" binding:"required,phone"`
+	Address       *string    `json:"address,omitempty" xml:"address,omitempty"`
+	PostalAddress *Address   `json:"postal_address,omitempty" xml:"postal_address,omitempty"`
+}
+
+// V1 converts the request to its v1 equivalent
+func (r UpdateUserRequestV2) V1() UpdateUserRequest {
+	return UpdateUserRequest{
+		Name:          r.Name.String(),
+		Email:         r.Email,
+		Phone:         r.Phone,
+		Address:       r.Address,
+		PostalAddress: r.PostalAddress,
+	}
+}
+
+// UserSearchResultV2 is the v2 representation of a search result
+type UserSearchResultV2 struct {
+	User       UserV2            `json:"user"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights,omitempty"`
+}
+
+// BulkResultV2 is the v2 representation of a bulk operation result
+type BulkResultV2 struct {
+	Index  int     `json:"index"`
+	Op     string  `json:"op"`
+	ID     uint    `json:"id,omitempty"`
+	Status string  `json:"status"`
+	Error  string  `json:"error,omitempty"`
+	User   *UserV2 `json:"user,omitempty"`
+}
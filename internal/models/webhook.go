@@ -0,0 +1,116 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	// WebhookDeliveryDead marks a delivery that failed too many times and
+	// will not be retried unless redelivered by hand
+	WebhookDeliveryDead = "dead"
+)
+
+// WebhookSubscription is a partner endpoint that receives the user events
+// of its tenant
+type WebhookSubscription struct {
+	ID         uint       `json:"id" gorm:"primarykey"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	TenantID   uint       `json:"-" gorm:"not null;default:1;index"`
+	URL        string     `json:"url" gorm:"size:2048;not null"`
+	EventTypes EventTypes `json:"event_types" gorm:"type:jsonb;not null"`
+	// Secret signs every delivery. It is only returned when the
+	// subscription is created.
+	Secret string `json:"secret,omitempty" gorm:"size:255;not null"`
+	Active bool   `json:"active" gorm:"not null;default:true"`
+}
+
+// Matches reports whether the subscription wants events of type eventType
+func (s *WebhookSubscription) Matches(eventType string) bool {
+	if !s.Active {
+		return false
+	}
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+	for _, t := range s.EventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// EventTypes lists the event types a subscription receives; empty means all
+type EventTypes []string
+
+// Value implements driver.Valuer so GORM stores the list as JSON
+func (t EventTypes) Value() (driver.Value, error) {
+	if t == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal([]string(t))
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+// Scan implements sql.Scanner so GORM can read the list from JSON
+func (t *EventTypes) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case []byte:
+		return json.Unmarshal(v, t)
+	case string:
+		return json.Unmarshal([]byte(v), t)
+	case nil:
+		*t = nil
+		return nil
+	}
+	return errors.New("unsupported event types value")
+}
+
+// WebhookRequest is the body for creating or replacing a webhook subscription
+type WebhookRequest struct {
+	URL        string   `json:"url" binding:"required,url,max=2048"`
+	EventTypes []string `json:"event_types" binding:"omitempty,dive,oneof=user.created user.updated user.deleted"`
+	// Secret is generated when omitted on create and kept when omitted on update
+	Secret string `json:"secret" binding:"omitempty,min=16,max=255"`
+	Active *bool  `json:"active"`
+}
+
+// WebhookDelivery is one event queued for, or sent to, one subscription
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primarykey"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	TenantID       uint       `json:"-" gorm:"not null;default:1;index"`
+	SubscriptionID uint       `json:"subscription_id" gorm:"not null;uniqueIndex:idx_webhook_delivery_event"`
+	EventID        string     `json:"event_id" gorm:"size:36;not null;uniqueIndex:idx_webhook_delivery_event"`
+	EventType      string     `json:"event_type" gorm:"size:64;not null"`
+	Payload        string     `json:"-" gorm:"type:jsonb;not null"`
+	Status         string     `json:"status" gorm:"size:16;not null;index"`
+	Attempts       int        `json:"attempts" gorm:"not null;default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"not null;index"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty" gorm:"type:text"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveryFilter holds the query parameters for the delivery log
+type WebhookDeliveryFilter struct {
+	// TenantID and SubscriptionID are set from the request, never by the client
+	TenantID       uint   `form:"-"`
+	SubscriptionID uint   `form:"-"`
+	Status         string `form:"status" binding:"omitempty,oneof=pending delivered dead"`
+	EventID        string `form:"event_id"`
+	BeforeID       uint   `form:"before_id"`
+	Limit          int    `form:"limit" binding:"omitempty,min=1,max=1000"`
+}
@@ -0,0 +1,338 @@
+// Package openapi builds an OpenAPI 3.1 document from the routes registered
+// with gin and the Go types they accept and return, and validates requests
+// and responses against it.
+package openapi
+
+import (
+	"errors"
+	"fmt"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Version is the OpenAPI version of generated documents
+const Version = "3.1.0"
+
+// Route documents one route. Routes are declared next to the route table
+// and matched to it by method and path.
+type Route struct {
+	Summary     string
+	Description string
+	Tag         string
+	// Query is a struct whose form tags are the query parameters
+	Query interface{}
+	// Params are further parameters, such as headers or query parameters
+	// the handler reads by hand
+	Params []*Parameter
+	// Body is the type of the JSON request body
+	Body interface{}
+	// BodyContentTypes lists the media types the body can be sent as
+	// besides JSON, with the same fields
+	BodyContentTypes []string
+	// Results are the responses of the route. Errors in the standard
+	// APIResponse or problem format are documented for every route.
+	Results []Result
+	// Deprecated marks routes clients should stop using
+	Deprecated bool
+	// OperationIDSuffix is appended to the operation ID derived from the
+	// handler, to tell apart routes served by the same handler, such as the
+	// same route in each API version
+	OperationIDSuffix string
+}
+
+// Result documents a response
+type Result struct {
+	Status      int
+	Description string
+	// Data is the type of the data of the response.APIResponse envelope;
+	// nil documents an envelope without data. Envelopes can also be
+	// written as XML and MessagePack.
+	Data interface{}
+	// Count marks list responses, whose envelope carries a count. Lists
+	// can also be written as CSV.
+	Count bool
+	// Body is the type of a JSON response that is not wrapped in an
+	// envelope; it takes precedence over Data
+	Body interface{}
+	// ContentTypes lists the media types of a response that is not JSON,
+	// such as a file download or an event stream
+	ContentTypes []string
+}
+
+// Document is an OpenAPI document
+type Document struct {
+	OpenAPI    string               `json:"openapi"`
+	Info       Info                 `json:"info"`
+	Paths      map[string]*PathItem `json:"paths"`
+	Components Components           `json:"components"`
+
+	// operations indexes the operations by method and gin route path
+	operations map[string]*Operation
+}
+
+// Info is the info object of a document
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+// PathItem holds the operations of a path by lowercase method
+type PathItem map[string]*Operation
+
+// Components holds the reusable schemas of a document
+type Components struct {
+	Schemas map[string]*Schema `json:"schemas"`
+}
+
+// Operation is an operation object
+type Operation struct {
+	OperationID string               `json:"operationId"`
+	Summary     string               `json:"summary,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	Parameters  []*Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody         `json:"requestBody,omitempty"`
+	Responses   map[string]*Response `json:"responses"`
+	Deprecated  bool                 `json:"deprecated,omitempty"`
+}
+
+// Parameter is a path, query or header parameter. Arrays are sent as
+// comma-separated values.
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Description string  `json:"description,omitempty"`
+	Required    bool    `json:"required,omitempty"`
+	Schema      *Schema `json:"schema"`
+	Style       string  `json:"style,omitempty"`
+	Explode     *bool   `json:"explode,omitempty"`
+}
+
+// RequestBody is a request body object
+type RequestBody struct {
+	Required bool                  `json:"required"`
+	Content  map[string]*MediaType `json:"content"`
+}
+
+// Response is a response object
+type Response struct {
+	Description string                `json:"description"`
+	Content     map[string]*MediaType `json:"content,omitempty"`
+}
+
+// MediaType is a media type object
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+// QueryParam documents a query parameter of type schema
+func QueryParam(name, description string, schema *Schema) *Parameter {
+	param := &Parameter{Name: name, In: "query", Description: description, Schema: schema}
+	if schema.Type.Is("array") {
+		param.Style = "form"
+		param.Explode = new(bool)
+	}
+	return param
+}
+
+// HeaderParam documents a request header
+func HeaderParam(name, description string) *Parameter {
+	return &Parameter{Name: name, In: "header", Description: description, Schema: &Schema{Type: Types{"string"}}}
+}
+
+// NewDocument creates a document without paths
+func NewDocument(info Info) *Document {
+	d := &Document{
+		OpenAPI:    Version,
+		Info:       info,
+		Paths:      map[string]*PathItem{},
+		Components: Components{Schemas: map[string]*Schema{}},
+		operations: map[string]*Operation{},
+	}
+	// The error formats are referred to by every operation
+	d.schemaFor(response.APIResponse{})
+	d.schemaFor(response.Problem{})
+	return d
+}
+
+// AddRoutes adds the routes of a gin engine to the document. Every route
+// must be documented in docs, keyed by method and path, e.g.
+// "GET /api/v1/users/:id", so the document cannot drift from the routes.
+func (d *Document) AddRoutes(routes gin.RoutesInfo, docs map[string]Route) error {
+	var undocumented []string
+	for _, route := range routes {
+		key := route.Method + " " + route.Path
+		doc, ok := docs[key]
+		if !ok {
+			undocumented = append(undocumented, key)
+			continue
+		}
+		op, err := d.operation(route, doc)
+		if err != nil {
+			return fmt.Errorf("%s: %w", key, err)
+		}
+
+		path := specPath(route.Path)
+		item, ok := d.Paths[path]
+		if !ok {
+			item = &PathItem{}
+			d.Paths[path] = item
+		}
+		(*item)[strings.ToLower(route.Method)] = op
+		d.operations[key] = op
+	}
+	if len(undocumented) > 0 {
+		sort.Strings(undocumented)
+		return errors.New("undocumented routes: " + strings.Join(undocumented, ", "))
+	}
+	return nil
+}
+
+// operation builds the operation object for a route
+func (d *Document) operation(route gin.RouteInfo, doc Route) (*Operation, error) {
+	op := &Operation{
+		OperationID: operationID(route.Handler) + doc.OperationIDSuffix,
+		Summary:     doc.Summary,
+		Description: doc.Description,
+		Deprecated:  doc.Deprecated,
+		Responses:   map[string]*Response{},
+	}
+	if doc.Tag != "" {
+		op.Tags = []string{doc.Tag}
+	}
+
+	for _, segment := range strings.Split(route.Path, "/") {
+		if name, ok := pathParamName(segment); ok {
+			op.Parameters = append(op.Parameters, &Parameter{
+				Name:     name,
+				In:       "path",
+				Required: true,
+				Schema:   &Schema{Type: Types{"integer"}, Minimum: float(1)},
+			})
+		}
+	}
+	if doc.Query != nil {
+		params, err := d.queryParams(doc.Query)
+		if err != nil {
+			return nil, err
+		}
+		op.Parameters = append(op.Parameters, params...)
+	}
+	op.Parameters = append(op.Parameters, doc.Params...)
+
+	if doc.Body != nil {
+		schema := d.schemaFor(doc.Body)
+		op.RequestBody = &RequestBody{
+			Required: true,
+			Content:  map[string]*MediaType{"application/json": {Schema: schema}},
+		}
+		for _, contentType := range doc.BodyContentTypes {
+			op.RequestBody.Content[contentType] = &MediaType{Schema: schema}
+		}
+	}
+
+	for _, result := range doc.Results {
+		op.Responses[strconv.Itoa(result.Status)] = d.response(result)
+	}
+	op.Responses["default"] = &Response{
+		Description: "Error",
+		Content:     envelopeContent(Ref("APIResponse")),
+	}
+	op.Responses["default"].Content[response.ProblemContentType] = &MediaType{Schema: Ref("Problem")}
+	return op, nil
+}
+
+// response builds the response object for a result
+func (d *Document) response(result Result) *Response {
+	resp := &Response{Description: result.Description}
+	if resp.Description == "" {
+		resp.Description = http.StatusText(result.Status)
+	}
+
+	switch {
+	case len(result.ContentTypes) > 0:
+		resp.Content = map[string]*MediaType{}
+		for _, contentType := range result.ContentTypes {
+			resp.Content[contentType] = &MediaType{Schema: &Schema{Type: Types{"string"}}}
+		}
+	case result.Body != nil:
+		resp.Content = map[string]*MediaType{"application/json": {Schema: d.schemaFor(result.Body)}}
+	default:
+		envelope := &Schema{
+			AllOf:      []*Schema{Ref("APIResponse")},
+			Properties: map[string]*Schema{"success": {Const: true}},
+			Required:   []string{"success", "message"},
+		}
+		if result.Data != nil {
+			envelope.Properties["data"] = d.schemaFor(result.Data)
+			envelope.Required = append(envelope.Required, "data")
+		}
+		if result.Count {
+			envelope.Properties["count"] = &Schema{Type: Types{"integer"}, Minimum: float(0)}
+			envelope.Required = append(envelope.Required, "count")
+		}
+		resp.Content = envelopeContent(envelope)
+		if result.Count {
+			resp.Content[response.FormatCSV.ContentType()] = &MediaType{Schema: &Schema{Type: Types{"string"}}}
+		}
+	}
+	return resp
+}
+
+// envelopeContent describes an envelope in each format it can be written in
+func envelopeContent(schema *Schema) map[string]*MediaType {
+	content := map[string]*MediaType{}
+	for _, format := range []response.Format{response.FormatJSON, response.FormatXML, response.FormatMsgPack} {
+		content[format.ContentType()] = &MediaType{Schema: schema}
+	}
+	return content
+}
+
+// lookup returns the operation for a method and gin route path
+func (d *Document) lookup(method, route string) (*Operation, bool) {
+	op, ok := d.operations[method+" "+route]
+	return op, ok
+}
+
+// specPath converts a gin path such as /users/:id to /users/{id}
+func specPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if name, ok := pathParamName(segment); ok {
+			segments[i] = "{" + name + "}"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// pathParamName returns the parameter name of a gin path segment
+func pathParamName(segment string) (string, bool) {
+	if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+		return segment[1:], true
+	}
+	return "", false
+}
+
+// operationID derives an operation ID from the name of a route's handler,
+// e.g. "gin-simple-app/internal/handlers.(*UserHandler).GetUsers-fm"
+// becomes "getUsers"
+func operationID(handler string) string {
+	name := strings.TrimSuffix(handler, "-fm")
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	if name == "" {
+		return handler
+	}
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,301 @@
+package openapi
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schema is a JSON Schema, as used by OpenAPI 3.1. Only the keywords the
+// generator emits are supported.
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 Types              `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Pattern              string             `json:"pattern,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Const                interface{}        `json:"const,omitempty"`
+	Enum                 []interface{}      `json:"enum,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+	AnyOf                []*Schema          `json:"anyOf,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinItems             *int               `json:"minItems,omitempty"`
+	MaxItems             *int               `json:"maxItems,omitempty"`
+}
+
+// Types is the type keyword of a schema, written as a single type when it
+// holds one
+type Types []string
+
+// MarshalJSON writes a single type as a string
+func (t Types) MarshalJSON() ([]byte, error) {
+	if len(t) == 1 {
+		return json.Marshal(t[0])
+	}
+	return json.Marshal([]string(t))
+}
+
+// UnmarshalJSON reads a type written as a string or a list
+func (t *Types) UnmarshalJSON(b []byte) error {
+	var single string
+	if err := json.Unmarshal(b, &single); err == nil {
+		*t = Types{single}
+		return nil
+	}
+	return json.Unmarshal(b, (*[]string)(t))
+}
+
+// Is reports whether the schema allows the type name
+func (t Types) Is(name string) bool {
+	for _, typ := range t {
+		if typ == name {
+			return true
+		}
+	}
+	return false
+}
+
+// AnyOf lists Go values or schemas of which a value matches at least one,
+// for use wherever a route's types are given
+type AnyOf []interface{}
+
+// Ref refers to the component schema name
+func Ref(name string) *Schema {
+	return &Schema{Ref: "#/components/schemas/" + name}
+}
+
+// ArrayOf is an array of items
+func ArrayOf(items *Schema) *Schema {
+	return &Schema{Type: Types{"array"}, Items: items}
+}
+
+// StringEnum is a string that is one of values
+func StringEnum(values ...string) *Schema {
+	schema := &Schema{Type: Types{"string"}}
+	for _, value := range values {
+		schema.Enum = append(schema.Enum, value)
+	}
+	return schema
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	rawJSONType  = reflect.TypeOf(json.RawMessage{})
+	marshalerTyp = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+)
+
+// schemaFor returns the schema of the Go value v. Named structs become
+// component schemas and are referred to.
+func (d *Document) schemaFor(v interface{}) *Schema {
+	switch v := v.(type) {
+	case *Schema:
+		return v
+	case AnyOf:
+		schema := &Schema{}
+		for _, alternative := range v {
+			schema.AnyOf = append(schema.AnyOf, d.schemaFor(alternative))
+		}
+		return schema
+	}
+	return d.schemaOf(reflect.TypeOf(v))
+}
+
+func (d *Document) schemaOf(t reflect.Type) *Schema {
+	switch {
+	case t == nil:
+		return &Schema{}
+	case t == timeType:
+		return &Schema{Type: Types{"string"}, Format: "date-time"}
+	case t == rawJSONType:
+		return &Schema{}
+	}
+
+	switch t.Kind() {
+	case reflect.Ptr:
+		return nullable(d.schemaOf(t.Elem()))
+	case reflect.Interface:
+		return &Schema{}
+	case reflect.Bool:
+		return &Schema{Type: Types{"boolean"}}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return &Schema{Type: Types{"integer"}}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: Types{"integer"}, Minimum: float(0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: Types{"number"}}
+	case reflect.String:
+		return &Schema{Type: Types{"string"}}
+	case reflect.Slice:
+		// encoding/json writes nil slices as null
+		schema := ArrayOf(d.schemaOf(t.Elem()))
+		schema.Type = append(schema.Type, "null")
+		return schema
+	case reflect.Array:
+		return ArrayOf(d.schemaOf(t.Elem()))
+	case reflect.Map:
+		return &Schema{Type: Types{"object"}, AdditionalProperties: d.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Implements(marshalerTyp) || reflect.PointerTo(t).Implements(marshalerTyp) {
+			// Custom JSON encodings cannot be described by reflection
+			return &Schema{}
+		}
+		if t.Name() == "" {
+			return d.structSchema(t)
+		}
+		if _, ok := d.Components.Schemas[t.Name()]; !ok {
+			// Reserve the name first so recursive types terminate
+			d.Components.Schemas[t.Name()] = &Schema{}
+			*d.Components.Schemas[t.Name()] = *d.structSchema(t)
+		}
+		return Ref(t.Name())
+	}
+	return &Schema{}
+}
+
+// structSchema describes the JSON fields of a struct type
+func (d *Document) structSchema(t reflect.Type) *Schema {
+	schema := &Schema{Type: Types{"object"}, Properties: map[string]*Schema{}}
+	d.addFields(schema, t)
+	return schema
+}
+
+func (d *Document) addFields(schema *Schema, t reflect.Type) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" {
+			continue
+		}
+		if field.Anonymous && name == "" && field.Type.Kind() == reflect.Struct {
+			d.addFields(schema, field.Type)
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+
+		property := d.schemaOf(field.Type)
+		required := applyBinding(property, field.Tag.Get("binding"))
+		schema.Properties[name] = property
+		if required {
+			schema.Required = append(schema.Required, name)
+		}
+	}
+}
+
+// queryParams describes the query parameters bound from the form tags of
+// the struct v
+func (d *Document) queryParams(v interface{}) ([]*Parameter, error) {
+	t := reflect.TypeOf(v)
+	if t.Kind() != reflect.Struct {
+		return nil, errors.New("query must be a struct")
+	}
+	var params []*Parameter
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("form"), ",")
+		if !field.IsExported() || name == "" || name == "-" {
+			continue
+		}
+		fieldType := field.Type
+		if fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		schema := d.schemaOf(fieldType)
+		required := applyBinding(schema, field.Tag.Get("binding"))
+		param := QueryParam(name, "", schema)
+		param.Required = required
+		params = append(params, param)
+	}
+	return params, nil
+}
+
+// nullable allows null in addition to schema
+func nullable(schema *Schema) *Schema {
+	if len(schema.Type) > 0 {
+		schema.Type = append(schema.Type, "null")
+		return schema
+	}
+	if schema.Ref != "" {
+		return &Schema{AnyOf: []*Schema{schema, {Type: Types{"null"}}}}
+	}
+	return schema
+}
+
+// applyBinding adds the constraints of a binding tag to schema and reports
+// whether it makes the field required. Rules after dive apply to the items
+// of a list.
+func applyBinding(schema *Schema, tag string) bool {
+	rules, itemRules, dive := strings.Cut(tag, "dive")
+	if dive && schema.Items != nil {
+		applyBinding(schema.Items, strings.TrimPrefix(itemRules, ","))
+	}
+
+	target := schema
+	if len(schema.AnyOf) > 0 {
+		target = schema.AnyOf[0]
+	}
+	required := false
+	for _, rule := range strings.Split(strings.TrimSuffix(rules, ","), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		switch name {
+		case "required":
+			required = true
+		case "email":
+			target.Format = "email"
+		case "url":
+			target.Format = "uri"
+		case "iso3166_1_alpha2":
+			target.Pattern = "^[A-Z]{2}$"
+		case "oneof":
+			for _, value := range strings.Fields(param) {
+				target.Enum = append(target.Enum, value)
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			applyBound(target, name == "min", n)
+		}
+	}
+	return required
+}
+
+// applyBound sets a min or max rule as the length, value or size bound
+// that fits the schema type
+func applyBound(schema *Schema, isMin bool, n int) {
+	switch {
+	case schema.Type.Is("string"):
+		if isMin {
+			schema.MinLength = &n
+		} else {
+			schema.MaxLength = &n
+		}
+	case schema.Type.Is("array"):
+		if isMin {
+			schema.MinItems = &n
+		} else {
+			schema.MaxItems = &n
+		}
+	case schema.Type.Is("integer"), schema.Type.Is("number"):
+		if isMin {
+			schema.Minimum = float(float64(n))
+		} else {
+			schema.Maximum = float(float64(n))
+		}
+	}
+}
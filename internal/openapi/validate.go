@@ -0,0 +1,335 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Violation is a way in which a request or response does not match the
+// document. Field is a JSON pointer into the body, or the location and name
+// of a parameter, e.g. "query.limit".
+type Violation struct {
+	Field   string
+	Message string
+}
+
+// ValidateRequest checks the parameters and body of a request to a
+// documented route; only JSON bodies are checked against their schema, and
+// not while they are still compressed. route is the gin path the request
+// matched and params its path parameters. Requests to undocumented routes
+// are not checked.
+func (d *Document) ValidateRequest(r *http.Request, route string, params gin.Params, body []byte) []Violation {
+	op, ok := d.lookup(r.Method, route)
+	if !ok {
+		return nil
+	}
+
+	var violations []Violation
+	query := r.URL.Query()
+	known := map[string]bool{}
+	for _, param := range op.Parameters {
+		var values []string
+		switch param.In {
+		case "path":
+			if value, ok := params.Get(param.Name); ok {
+				values = []string{value}
+			}
+		case "query":
+			known[param.Name] = true
+			values = query[param.Name]
+		case "header":
+			values = r.Header.Values(param.Name)
+		}
+		field := param.In + "." + param.Name
+		if len(values) == 0 || values[0] == "" {
+			if param.Required {
+				violations = append(violations, Violation{Field: field, Message: "is required"})
+			}
+			continue
+		}
+		violations = append(violations, d.validateParam(field, values[0], param.Schema)...)
+	}
+	for name := range query {
+		if !known[name] {
+			violations = append(violations, Violation{Field: "query." + name, Message: "is not a documented parameter"})
+		}
+	}
+
+	if op.RequestBody != nil {
+		mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch content, ok := op.RequestBody.Content[mediaType]; {
+		case len(bytes.TrimSpace(body)) == 0:
+			if op.RequestBody.Required {
+				violations = append(violations, Violation{Field: "body", Message: "is required"})
+			}
+		case !ok:
+			violations = append(violations, Violation{Field: "body", Message: "has undocumented content type " + strconv.Quote(mediaType)})
+		case isJSON(mediaType) && r.Header.Get("Content-Encoding") == "":
+			violations = append(violations, d.validateJSON("body", body, content.Schema)...)
+		}
+	}
+	return violations
+}
+
+// ValidateResponse checks the status and body of a response from a
+// documented route. Only JSON bodies are checked against their schema.
+func (d *Document) ValidateResponse(method, route string, status int, header http.Header, body []byte) []Violation {
+	op, ok := d.lookup(method, route)
+	if !ok {
+		return nil
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok {
+		if status < 400 {
+			return []Violation{{Field: "status", Message: fmt.Sprintf("%d is not a documented response", status)}}
+		}
+		resp = op.Responses["default"]
+	}
+	if len(resp.Content) == 0 {
+		if len(body) > 0 {
+			return []Violation{{Field: "body", Message: "is not documented"}}
+		}
+		return nil
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(header.Get("Content-Type"))
+	content, ok := resp.Content[mediaType]
+	if !ok {
+		return []Violation{{Field: "body", Message: "has undocumented content type " + strconv.Quote(mediaType)}}
+	}
+	if !isJSON(mediaType) {
+		return nil
+	}
+	return d.validateJSON("body", body, content.Schema)
+}
+
+// isJSON reports whether a media type is JSON
+func isJSON(mediaType string) bool {
+	return mediaType == "application/json" || strings.HasSuffix(mediaType, "+json")
+}
+
+// validateJSON decodes body and validates it against schema
+func (d *Document) validateJSON(field string, body []byte, schema *Schema) []Violation {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return []Violation{{Field: field, Message: "is not valid JSON: " + err.Error()}}
+	}
+	var violations []Violation
+	d.validate(field, value, schema, &violations)
+	return violations
+}
+
+// validateParam parses a parameter value as the type of schema and
+// validates it. Arrays are comma-separated.
+func (d *Document) validateParam(field, raw string, schema *Schema) []Violation {
+	var violations []Violation
+	if schema.Type.Is("array") && schema.Items != nil {
+		items := strings.Split(raw, ",")
+		values := make([]interface{}, len(items))
+		for i, item := range items {
+			values[i] = paramValue(strings.TrimSpace(item), schema.Items)
+		}
+		d.validate(field, values, schema, &violations)
+		return violations
+	}
+	d.validate(field, paramValue(raw, schema), schema, &violations)
+	return violations
+}
+
+// paramValue converts a parameter value to the JSON value it stands for
+func paramValue(raw string, schema *Schema) interface{} {
+	switch {
+	case schema.Type.Is("integer"), schema.Type.Is("number"):
+		if _, err := strconv.ParseFloat(raw, 64); err == nil {
+			return json.Number(raw)
+		}
+	case schema.Type.Is("boolean"):
+		if b, err := strconv.ParseBool(raw); err == nil {
+			return b
+		}
+	}
+	return raw
+}
+
+// validate checks value against schema, appending what does not match
+func (d *Document) validate(field string, value interface{}, schema *Schema, violations *[]Violation) {
+	fail := func(format string, args ...interface{}) {
+		*violations = append(*violations, Violation{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if schema.Ref != "" {
+		resolved, ok := d.Components.Schemas[strings.TrimPrefix(schema.Ref, "#/components/schemas/")]
+		if !ok {
+			fail("refers to unknown schema %s", schema.Ref)
+			return
+		}
+		d.validate(field, value, resolved, violations)
+		return
+	}
+	for _, sub := range schema.AllOf {
+		d.validate(field, value, sub, violations)
+	}
+	if len(schema.AnyOf) > 0 {
+		matched := false
+		for _, sub := range schema.AnyOf {
+			var subViolations []Violation
+			d.validate(field, value, sub, &subViolations)
+			if len(subViolations) == 0 {
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			fail("matches none of the allowed schemas")
+			return
+		}
+	}
+
+	if len(schema.Type) > 0 && !schema.Type.Is(jsonType(value)) &&
+		!(jsonType(value) == "integer" && schema.Type.Is("number")) {
+		fail("must be of type %s, not %s", strings.Join(schema.Type, " or "), jsonType(value))
+		return
+	}
+	if schema.Const != nil && fmt.Sprint(schema.Const) != fmt.Sprint(value) {
+		fail("must be %v", schema.Const)
+	}
+	if len(schema.Enum) > 0 {
+		found := false
+		for _, allowed := range schema.Enum {
+			if fmt.Sprint(allowed) == fmt.Sprint(value) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			fail("must be one of %v", schema.Enum)
+		}
+	}
+
+	switch v := value.(type) {
+	case string:
+		length := utf8.RuneCountInString(v)
+		if schema.MinLength != nil && length < *schema.MinLength {
+			fail("must be at least %d characters long", *schema.MinLength)
+		}
+		if schema.MaxLength != nil && length > *schema.MaxLength {
+			fail("must be at most %d characters long", *schema.MaxLength)
+		}
+		if schema.Pattern != "" && !compilePattern(schema.Pattern).MatchString(v) {
+			fail("must match %s", schema.Pattern)
+		}
+		if schema.Format != "" && !validFormat(schema.Format, v) {
+			fail("must be a valid %s", schema.Format)
+		}
+	case json.Number:
+		n, _ := v.Float64()
+		if schema.Minimum != nil && n < *schema.Minimum {
+			fail("must be at least %v", *schema.Minimum)
+		}
+		if schema.Maximum != nil && n > *schema.Maximum {
+			fail("must be at most %v", *schema.Maximum)
+		}
+	case []interface{}:
+		if schema.MinItems != nil && len(v) < *schema.MinItems {
+			fail("must have at least %d items", *schema.MinItems)
+		}
+		if schema.MaxItems != nil && len(v) > *schema.MaxItems {
+			fail("must have at most %d items", *schema.MaxItems)
+		}
+		if schema.Items != nil {
+			for i, item := range v {
+				d.validate(field+"/"+strconv.Itoa(i), item, schema.Items, violations)
+			}
+		}
+	case map[string]interface{}:
+		for _, name := range schema.Required {
+			if _, ok := v[name]; !ok {
+				*violations = append(*violations, Violation{Field: field + "/" + name, Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(v))
+		for name := range v {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if property, ok := schema.Properties[name]; ok {
+				d.validate(field+"/"+name, v[name], property, violations)
+			} else if schema.AdditionalProperties != nil {
+				d.validate(field+"/"+name, v[name], schema.AdditionalProperties, violations)
+			}
+		}
+	}
+}
+
+// jsonType names the JSON type of a decoded value
+func jsonType(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case json.Number:
+		if _, err := v.Int64(); err == nil {
+			return "integer"
+		}
+		return "number"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return "unknown"
+}
+
+// validFormat checks the formats the generator emits; others always pass
+func validFormat(format, value string) bool {
+	switch format {
+	case "date-time":
+		_, err := time.Parse(time.RFC3339, value)
+		return err == nil
+	case "email":
+		address, err := mail.ParseAddress(value)
+		return err == nil && address.Address == value
+	case "uri":
+		u, err := url.Parse(value)
+		return err == nil && u.Scheme != "" && u.Host != ""
+	}
+	return true
+}
+
+var (
+	patternsMu sync.Mutex
+	patterns   = map[string]*regexp.Regexp{}
+)
+
+// compilePattern compiles and caches a schema pattern
+func compilePattern(pattern string) *regexp.Regexp {
+	patternsMu.Lock()
+	defer patternsMu.Unlock()
+	re, ok := patterns[pattern]
+	if !ok {
+		re = regexp.MustCompile(pattern)
+		patterns[pattern] = re
+	}
+	return re
+}
@@ -0,0 +1,77 @@
+package phone
+
+import (
+	"errors"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/nyaruka/phonenumbers"
+)
+
+// ValidationTag is the binding tag that validates a phone number, e.g. `binding:"required,phone"`
+const ValidationTag = "phone"
+
+// ErrInvalidPhone is returned for input that is not a valid phone number
+var ErrInvalidPhone = errors.New("invalid phone number")
+
+var (
+	regionMu      sync.RWMutex
+	defaultRegion = "US"
+
+	registerOnce sync.Once
+	registerErr  error
+)
+
+// SetDefaultRegion sets the ISO 3166-1 alpha-2 region used to parse numbers
+// written without a +country prefix
+func SetDefaultRegion(region string) error {
+	region = strings.ToUpper(strings.TrimSpace(region))
+	if phonenumbers.GetCountryCodeForRegion(region) == 0 {
+		return errors.New("unknown phone region: " + region)
+	}
+
+	regionMu.Lock()
+	defer regionMu.Unlock()
+	defaultRegion = region
+	return nil
+}
+
+// DefaultRegion returns the region used for numbers without a +country prefix
+func DefaultRegion() string {
+	regionMu.RLock()
+	defer regionMu.RUnlock()
+	return defaultRegion
+}
+
+// Normalize parses a phone number and returns it in E.164 form, e.g. "+12125550101"
+func Normalize(input string) (string, error) {
+	number, err := phonenumbers.Parse(input, DefaultRegion())
+	if err != nil || !phonenumbers.IsValidNumber(number) {
+		return "", ErrInvalidPhone
+	}
+	return phonenumbers.Format(number, phonenumbers.E164), nil
+}
+
+// Valid reports whether input is a valid phone number
+func Valid(input string) bool {
+	_, err := Normalize(input)
+	return err == nil
+}
+
+// RegisterValidator registers the phone validation tag with gin's binding
+// validator. It is safe to call more than once.
+func RegisterValidator() error {
+	registerOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			registerErr = errors.New("unsupported binding validator engine")
+			return
+		}
+		registerErr = v.RegisterValidation(ValidationTag, func(fl validator.FieldLevel) bool {
+			return Valid(fl.Field().String())
+		})
+	})
+	return registerErr
+}
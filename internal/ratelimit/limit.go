@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit allows Requests requests per Period. Requests are drawn from a token
+// bucket that holds up to Requests tokens and refills continuously over
+// Period, so short bursts are allowed as long as the average rate holds.
+// A zero Limit means unlimited.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// Unlimited reports whether l places no limit on requests
+func (l Limit) Unlimited() bool {
+	return l.Requests <= 0 || l.Period <= 0
+}
+
+// String formats l the way ParseLimit reads it
+func (l Limit) String() string {
+	if l.Unlimited() {
+		return "off"
+	}
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// ParseLimit parses a limit written as "<requests>/<period>", e.g. "100/1m"
+// or "5/s". A bare unit stands for one of it. Only "off" disables limiting;
+// a limit of zero requests is rejected rather than read as unlimited.
+func ParseLimit(s string) (Limit, error) {
+	s = strings.TrimSpace(s)
+	if s == "" || strings.EqualFold(s, "off") {
+		return Limit{}, nil
+	}
+
+	countText, periodText, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: expected <requests>/<period>", s)
+	}
+	requests, err := strconv.Atoi(strings.TrimSpace(countText))
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: request count must be a positive integer, or use off to disable limiting", s)
+	}
+
+	periodText = strings.TrimSpace(periodText)
+	if periodText != "" && (periodText[0] < '0' || periodText[0] > '9') {
+		periodText = "1" + periodText
+	}
+	period, err := time.ParseDuration(periodText)
+	if err != nil || period <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit %q: period must be a positive duration", s)
+	}
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+// Limits holds the limit for each route group, falling back to Default for
+// groups without their own
+type Limits struct {
+	Default Limit
+	Groups  map[string]Limit
+}
+
+// For returns the limit that applies to group
+func (l Limits) For(group string) Limit {
+	if limit, ok := l.Groups[group]; ok {
+		return limit
+	}
+	return l.Default
+}
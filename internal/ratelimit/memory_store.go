@@ -0,0 +1,71 @@
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// bucket is a token bucket as of updated
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	period  time.Duration
+}
+
+// MemoryStore implements Store in memory, for single-node deployments and tests
+type MemoryStore struct {
+	buckets map[string]*bucket
+	calls   int
+	mutex   sync.Mutex
+}
+
+// NewMemoryStore creates a new in-memory rate limit store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Take draws a token from the bucket for key
+func (s *MemoryStore) Take(key string, limit Limit) (Result, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	s.calls++
+	if s.calls%sweepInterval == 0 {
+		// A bucket idle for a whole period is full, same as a new one
+		for k, b := range s.buckets {
+			if now.Sub(b.updated) >= b.period {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	capacity := float64(limit.Requests)
+	rate := capacity / limit.Period.Seconds()
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &bucket{tokens: capacity, updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.updated).Seconds()*rate)
+	b.updated = now
+	b.period = limit.Period
+
+	result := Result{Allowed: b.tokens >= 1}
+	if result.Allowed {
+		b.tokens--
+	} else {
+		result.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	result.Remaining = int(b.tokens)
+	result.Reset = secondsToDuration((capacity - b.tokens) / rate)
+	return result, nil
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
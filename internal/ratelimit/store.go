@@ -0,0 +1,25 @@
+package ratelimit
+
+import "time"
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	// Allowed is false when the bucket was empty and the request must be rejected
+	Allowed bool
+	// Remaining is how many requests the bucket allows right now
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next request is allowed; zero when Allowed
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. MemoryStore serves a single node; a store backed
+// by a shared cache lets several nodes enforce one limit per client.
+type Store interface {
+	// Take draws a token from the bucket for key, refilled according to limit
+	Take(key string, limit Limit) (Result, error)
+}
+
+// sweepInterval is how many Take calls pass between purges of idle buckets
+const sweepInterval = 1000
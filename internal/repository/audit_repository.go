@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// defaultAuditLimit is the number of audit entries returned when no limit is given
+const defaultAuditLimit = 100
+
+// AuditRepository stores audit entries. It is append-only: entries can be
+// added and read but never changed or removed.
+type AuditRepository interface {
+	Append(entries ...models.AuditEntry) error
+	List(filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+// GormAuditRepository implements AuditRepository using GORM
+type GormAuditRepository struct {
+	db *gorm.DB
+}
+
+// NewGormAuditRepository creates a new GORM audit repository
+func NewGormAuditRepository(db *gorm.DB) AuditRepository {
+	return &GormAuditRepository{
+		db: db,
+	}
+}
+
+// Append stores entries
+func (r *GormAuditRepository) Append(entries ...models.AuditEntry) error {
+	if len(entries) == 0 {
+		return nil
+	}
+	return r.db.Create(&entries).Error
+}
+
+// List returns the entries matching the filter, newest first
+func (r *GormAuditRepository) List(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	query := r.db.Model(&models.AuditEntry{})
+	if filter.TenantID != 0 {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.UserID != 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.Actor != "" {
+		query = query.Where("actor = ?", filter.Actor)
+	}
+	if filter.ClaimedActor != "" {
+		query = query.Where("claimed_actor = ?", filter.ClaimedActor)
+	}
+	if filter.Action != "" {
+		query = query.Where("action = ?", filter.Action)
+	}
+	if filter.RequestID != "" {
+		query = query.Where("request_id = ?", filter.RequestID)
+	}
+	if filter.Since != nil {
+		query = query.Where("created_at >= ?", *filter.Since)
+	}
+	if filter.Until != nil {
+		query = query.Where("created_at < ?", *filter.Until)
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var entries []models.AuditEntry
+	err := query.Order("id DESC").Limit(auditLimit(filter)).Find(&entries).Error
+	return entries, err
+}
+
+// auditLimit returns the page size for filter
+func auditLimit(filter models.AuditFilter) int {
+	if filter.Limit <= 0 {
+		return defaultAuditLimit
+	}
+	return filter.Limit
+}
+
+// DiscardAuditRepository is an AuditRepository that keeps nothing
+type DiscardAuditRepository struct{}
+
+// Append drops entries
+func (DiscardAuditRepository) Append(...models.AuditEntry) error {
+	return nil
+}
+
+// List returns no entries
+func (DiscardAuditRepository) List(models.AuditFilter) ([]models.AuditEntry, error) {
+	return []models.AuditEntry{}, nil
+}
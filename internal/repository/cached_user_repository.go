@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"gin-simple-app/internal/cache"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/tenant"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// CachedUserRepository decorates a UserRepository with a read-through cache
+// of users by ID. Every write through it, in a transaction or not, evicts
+// the users it touched once it is done; writes made through a UnitOfWork
+// must go through NewCachedUnitOfWork to do the same. Other reads are
+// passed straight to the underlying repository.
+//
+// Concurrent misses for the same user share one query. A query that was
+// already running when a write finished does not fill the cache, so it
+// cannot put back the user the write evicted. If the store fails, reads
+// fall back to the underlying repository.
+//
+// Users are cached per tenant. The repositories WithContext returns share
+// the cache with the one they came from.
+type CachedUserRepository struct {
+	UserRepository
+	*userCache
+	tenantID uint
+}
+
+// userCache is the cache state shared by the repositories of every tenant
+type userCache struct {
+	store cache.Store
+	ttl   time.Duration
+
+	loads singleflight.Group
+	// generation counts invalidations; fillMutex makes checking it and
+	// filling the cache atomic with respect to invalidating
+	generation uint64
+	fillMutex  sync.Mutex
+
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+// NewCachedUserRepository caches the users of repo in store for ttl
+func NewCachedUserRepository(repo UserRepository, store cache.Store, ttl time.Duration) *CachedUserRepository {
+	return &CachedUserRepository{
+		UserRepository: repo,
+		userCache: &userCache{
+			store: store,
+			ttl:   ttl,
+		},
+		tenantID: models.DefaultTenantID,
+	}
+}
+
+// WithContext returns a cached repository for the tenant of ctx
+func (r *CachedUserRepository) WithContext(ctx context.Context) UserRepository {
+	return &CachedUserRepository{
+		UserRepository: r.UserRepository.WithContext(ctx),
+		userCache:      r.userCache,
+		tenantID:       tenant.IDFromContext(ctx),
+	}
+}
+
+// Stats returns how often GetByID was served from the cache
+func (c *userCache) Stats() cache.Stats {
+	return cache.Stats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+}
+
+// userCacheKey is the cache key of a user of a tenant
+func userCacheKey(tenantID, id uint) string {
+	return "user:" + strconv.FormatUint(uint64(tenantID), 10) + ":" + strconv.FormatUint(uint64(id), 10)
+}
+
+// userCacheKeys returns the cache keys of users of a tenant
+func userCacheKeys(tenantID uint, ids []uint) []string {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = userCacheKey(tenantID, id)
+	}
+	return keys
+}
+
+// GetByID returns a user by ID, from the cache if it holds it
+func (r *CachedUserRepository) GetByID(id uint) (*models.User, error) {
+	key := userCacheKey(r.tenantID, id)
+	if data, ok, err := r.store.Get(key); err != nil {
+		log.Printf("User cache error: %v", err)
+	} else if ok {
+		var user models.User
+		if err := json.Unmarshal(data, &user); err == nil {
+			r.hits.Add(1)
+			user.TenantID = r.tenantID
+			return &user, nil
+		}
+	}
+	r.misses.Add(1)
+
+	data, err, _ := r.loads.Do(key, func() (interface{}, error) {
+		r.fillMutex.Lock()
+		generation := r.generation
+		r.fillMutex.Unlock()
+
+		user, err := r.UserRepository.GetByID(id)
+		if err != nil {
+			return nil, err
+		}
+		data, err := json.Marshal(user)
+		if err != nil {
+			return nil, err
+		}
+
+		r.fillMutex.Lock()
+		defer r.fillMutex.Unlock()
+		if r.generation == generation {
+			if err := r.store.Set(key, data, r.ttl); err != nil {
+				log.Printf("User cache error: %v", err)
+			}
+		}
+		return data, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	// Every caller gets a copy of its own
+	var user models.User
+	if err := json.Unmarshal(data.([]byte), &user); err != nil {
+		return nil, err
+	}
+	user.TenantID = r.tenantID
+	return &user, nil
+}
+
+// Create creates a new user
+func (r *CachedUserRepository) Create(user *models.User) error {
+	err := r.UserRepository.Create(user)
+	r.invalidate(userCacheKey(r.tenantID, user.ID))
+	return err
+}
+
+// Update updates an existing user
+func (r *CachedUserRepository) Update(user *models.User) error {
+	err := r.UserRepository.Update(user)
+	r.invalidate(userCacheKey(r.tenantID, user.ID))
+	return err
+}
+
+// Delete deletes a user by ID
+func (r *CachedUserRepository) Delete(id uint) error {
+	err := r.UserRepository.Delete(id)
+	r.invalidate(userCacheKey(r.tenantID, id))
+	return err
+}
+
+// CreateBatch inserts all users
+func (r *CachedUserRepository) CreateBatch(users []models.User) error {
+	err := r.UserRepository.CreateBatch(users)
+	r.invalidate(userCacheKeys(r.tenantID, userIDs(users))...)
+	return err
+}
+
+// DeleteByIDs deletes all users with the given IDs
+func (r *CachedUserRepository) DeleteByIDs(ids []uint) (int64, error) {
+	deleted, err := r.UserRepository.DeleteByIDs(ids)
+	r.invalidate(userCacheKeys(r.tenantID, ids)...)
+	return deleted, err
+}
+
+// Transaction runs fn with a repository bound to a transaction of the
+// underlying repository. Reads in the transaction bypass the cache, and the
+// users written are evicted once it ends.
+func (r *CachedUserRepository) Transaction(fn func(repo UserRepository) error) error {
+	written := &writtenUsers{}
+	defer func() { r.invalidate(written.keys...) }()
+	return r.UserRepository.Transaction(func(repo UserRepository) error {
+		return fn(&trackingUserRepository{UserRepository: repo, tenantID: r.tenantID, written: written})
+	})
+}
+
+// invalidate evicts users from the cache by key, and stops queries already
+// running from filling it with what they read
+func (c *userCache) invalidate(keys ...string) {
+	c.fillMutex.Lock()
+	defer c.fillMutex.Unlock()
+	c.generation++
+	if len(keys) == 0 {
+		return
+	}
+	if err := c.store.Delete(keys...); err != nil {
+		log.Printf("User cache error: %v", err)
+	}
+}
+
+// userIDs returns the IDs of users
+func userIDs(users []models.User) []uint {
+	ids := make([]uint, len(users))
+	for i, user := range users {
+		ids[i] = user.ID
+	}
+	return ids
+}
+
+// writtenUsers collects the cache keys of users written in a transaction
+type writtenUsers struct {
+	mutex sync.Mutex
+	keys  []string
+}
+
+func (w *writtenUsers) add(tenantID uint, ids ...uint) {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+	w.keys = append(w.keys, userCacheKeys(tenantID, ids)...)
+}
+
+// trackingUserRepository records the users written through a transaction
+// repository, so they can be evicted from the cache once it ends
+type trackingUserRepository struct {
+	UserRepository
+	tenantID uint
+	written  *writtenUsers
+}
+
+func (r *trackingUserRepository) WithContext(ctx context.Context) UserRepository {
+	return &trackingUserRepository{
+		UserRepository: r.UserRepository.WithContext(ctx),
+		tenantID:       tenant.IDFromContext(ctx),
+		written:        r.written,
+	}
+}
+
+func (r *trackingUserRepository) Create(user *models.User) error {
+	err := r.UserRepository.Create(user)
+	r.written.add(r.tenantID, user.ID)
+	return err
+}
+
+func (r *trackingUserRepository) Update(user *models.User) error {
+	r.written.add(r.tenantID, user.ID)
+	return r.UserRepository.Update(user)
+}
+
+func (r *trackingUserRepository) Delete(id uint) error {
+	r.written.add(r.tenantID, id)
+	return r.UserRepository.Delete(id)
+}
+
+func (r *trackingUserRepository) CreateBatch(users []models.User) error {
+	err := r.UserRepository.CreateBatch(users)
+	r.written.add(r.tenantID, userIDs(users)...)
+	return err
+}
+
+func (r *trackingUserRepository) DeleteByIDs(ids []uint) (int64, error) {
+	r.written.add(r.tenantID, ids...)
+	return r.UserRepository.DeleteByIDs(ids)
+}
+
+// Transaction runs fn in a nested transaction, tracking its writes too
+func (r *trackingUserRepository) Transaction(fn func(repo UserRepository) error) error {
+	return r.UserRepository.Transaction(func(repo UserRepository) error {
+		return fn(&trackingUserRepository{UserRepository: repo, tenantID: r.tenantID, written: r.written})
+	})
+}
+
+// cachedUnitOfWork evicts the users written through a unit of work from a
+// CachedUserRepository once its transaction ends
+type cachedUnitOfWork struct {
+	uow   UnitOfWork
+	users *CachedUserRepository
+}
+
+// NewCachedUnitOfWork wraps uow so the users written through it are evicted
+// from the cache of users. uow must write to the same users as the
+// repository users decorates.
+func NewCachedUnitOfWork(uow UnitOfWork, users *CachedUserRepository) UnitOfWork {
+	return &cachedUnitOfWork{
+		uow:   uow,
+		users: users,
+	}
+}
+
+// Transaction runs fn in a transaction of the wrapped unit of work
+func (u *cachedUnitOfWork) Transaction(ctx context.Context, fn func(repos Repositories) error) error {
+	written := &writtenUsers{}
+	defer func() { u.users.invalidate(written.keys...) }()
+	return u.uow.Transaction(ctx, func(repos Repositories) error {
+		repos.Users = &trackingUserRepository{UserRepository: repos.Users, tenantID: tenant.IDFromContext(ctx), written: written}
+		return fn(repos)
+	})
+}
@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+	"sync"
+	"time"
+)
+
+// InMemoryAuditRepository implements AuditRepository using in-memory storage
+type InMemoryAuditRepository struct {
+	entries []models.AuditEntry
+	nextID  uint
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryAuditRepository creates a new in-memory audit repository
+func NewInMemoryAuditRepository() *InMemoryAuditRepository {
+	return &InMemoryAuditRepository{
+		nextID: 1,
+	}
+}
+
+// Append stores entries
+func (r *InMemoryAuditRepository) Append(entries ...models.AuditEntry) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	for _, entry := range entries {
+		entry.ID = r.nextID
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = now
+		}
+		r.nextID++
+		r.entries = append(r.entries, entry)
+	}
+	return nil
+}
+
+// List returns the entries matching the filter, newest first
+func (r *InMemoryAuditRepository) List(filter models.AuditFilter) ([]models.AuditEntry, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	limit := auditLimit(filter)
+	result := make([]models.AuditEntry, 0)
+	for i := len(r.entries) - 1; i >= 0 && len(result) < limit; i-- {
+		if matchesAuditFilter(r.entries[i], filter) {
+			result = append(result, r.entries[i])
+		}
+	}
+	return result, nil
+}
+
+// matchesAuditFilter reports whether an entry passes every set filter field
+func matchesAuditFilter(entry models.AuditEntry, filter models.AuditFilter) bool {
+	switch {
+	case filter.TenantID != 0 && entry.TenantID != filter.TenantID:
+		return false
+	case filter.UserID != 0 && entry.UserID != filter.UserID:
+		return false
+	case filter.Actor != "" && entry.Actor != filter.Actor:
+		return false
+	case filter.ClaimedActor != "" && entry.ClaimedActor != filter.ClaimedActor:
+		return false
+	case filter.Action != "" && entry.Action != filter.Action:
+		return false
+	case filter.RequestID != "" && entry.RequestID != filter.RequestID:
+		return false
+	case filter.Since != nil && entry.CreatedAt.Before(*filter.Since):
+		return false
+	case filter.Until != nil && !entry.CreatedAt.Before(*filter.Until):
+		return false
+	case filter.BeforeID != 0 && entry.ID >= filter.BeforeID:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"errors"
+	"gin-simple-app/internal/models"
+	"sync"
+	"time"
+)
+
+// errEventNotFound is returned when marking an event that is not in the outbox
+var errEventNotFound = errors.New("event not found")
+
+// InMemoryOutboxRepository implements OutboxRepository using in-memory storage
+type InMemoryOutboxRepository struct {
+	events  []models.Event
+	nextSeq uint
+	mutex   sync.Mutex
+}
+
+// NewInMemoryOutboxRepository creates a new in-memory outbox repository
+func NewInMemoryOutboxRepository() *InMemoryOutboxRepository {
+	return &InMemoryOutboxRepository{
+		nextSeq: 1,
+	}
+}
+
+// Append stores events
+func (r *InMemoryOutboxRepository) Append(events ...models.Event) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, event := range events {
+		event.Sequence = r.nextSeq
+		r.nextSeq++
+		r.events = append(r.events, event)
+	}
+	return nil
+}
+
+// Claim leases due events
+func (r *InMemoryOutboxRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var claimed []models.Event
+	for i := range r.events {
+		if len(claimed) >= limit {
+			break
+		}
+		event := &r.events[i]
+		if event.PublishedAt != nil || event.NextAttemptAt.After(now) {
+			continue
+		}
+		event.NextAttemptAt = now.Add(lease)
+		event.Attempts++
+		claimed = append(claimed, *event)
+	}
+	return claimed, nil
+}
+
+// MarkPublished records that an event reached every sink
+func (r *InMemoryOutboxRepository) MarkPublished(sequence uint, at time.Time) error {
+	return r.update(sequence, func(event *models.Event) {
+		event.PublishedAt = &at
+		event.LastError = ""
+	})
+}
+
+// MarkFailed schedules another attempt for an event
+func (r *InMemoryOutboxRepository) MarkFailed(sequence uint, nextAttemptAt time.Time, lastError string) error {
+	return r.update(sequence, func(event *models.Event) {
+		event.NextAttemptAt = nextAttemptAt
+		event.LastError = lastError
+	})
+}
+
+// DeletePublishedBefore removes events published before t
+func (r *InMemoryOutboxRepository) DeletePublishedBefore(t time.Time) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	kept := r.events[:0]
+	var deleted int64
+	for _, event := range r.events {
+		if event.PublishedAt != nil && event.PublishedAt.Before(t) {
+			deleted++
+			continue
+		}
+		kept = append(kept, event)
+	}
+	r.events = kept
+	return deleted, nil
+}
+
+// ListAfter returns the events after sequence
+func (r *InMemoryOutboxRepository) ListAfter(sequence uint, limit int) ([]models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var events []models.Event
+	for _, event := range r.events {
+		if len(events) >= limit {
+			break
+		}
+		if event.Sequence > sequence {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// Latest returns the last limit events
+func (r *InMemoryOutboxRepository) Latest(limit int) ([]models.Event, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	start := len(r.events) - limit
+	if start < 0 {
+		start = 0
+	}
+	return append([]models.Event(nil), r.events[start:]...), nil
+}
+
+// Events returns a copy of every event in the outbox, for inspection in tests
+func (r *InMemoryOutboxRepository) Events() []models.Event {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]models.Event(nil), r.events...)
+}
+
+func (r *InMemoryOutboxRepository) update(sequence uint, fn func(event *models.Event)) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for i := range r.events {
+		if r.events[i].Sequence == sequence {
+			fn(&r.events[i])
+			return nil
+		}
+	}
+	return errEventNotFound
+}
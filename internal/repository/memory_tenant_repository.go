@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"errors"
+	"gin-simple-app/internal/models"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryTenantRepository implements TenantRepository using in-memory storage
+type InMemoryTenantRepository struct {
+	tenants []models.Tenant
+	nextID  uint
+	mutex   sync.RWMutex
+}
+
+// NewInMemoryTenantRepository creates a new in-memory tenant repository
+// holding the default tenant
+func NewInMemoryTenantRepository() *InMemoryTenantRepository {
+	return &InMemoryTenantRepository{
+		tenants: []models.Tenant{
+			{ID: models.DefaultTenantID, Slug: models.DefaultTenantSlug, Name: "Default", CreatedAt: time.Now()},
+		},
+		nextID: models.DefaultTenantID + 1,
+	}
+}
+
+// GetBySlug returns a tenant by slug
+func (r *InMemoryTenantRepository) GetBySlug(slug string) (*models.Tenant, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	for _, tenant := range r.tenants {
+		if tenant.Slug == slug {
+			tenantCopy := tenant
+			return &tenantCopy, nil
+		}
+	}
+	return nil, gorm.ErrRecordNotFound
+}
+
+// Create creates a new tenant
+func (r *InMemoryTenantRepository) Create(tenant *models.Tenant) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, existing := range r.tenants {
+		if existing.Slug == tenant.Slug {
+			return errors.New("tenant slug already exists")
+		}
+	}
+	tenant.ID = r.nextID
+	tenant.CreatedAt = time.Now()
+	r.nextID++
+	r.tenants = append(r.tenants, *tenant)
+	return nil
+}
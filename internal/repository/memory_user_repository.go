@@ -1,40 +1,77 @@
 package repository
 
 import (
+	"context"
 	"errors"
 	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/tenant"
+	"sort"
+	"strings"
 	"sync"
 	"time"
 
 	"gorm.io/gorm"
 )
 
-// InMemoryUserRepository implements UserRepository using in-memory storage (for testing)
+// InMemoryUserRepository implements UserRepository using in-memory storage (for testing).
+// Like GormUserRepository, it only sees the users of its tenant; the
+// repositories WithContext returns share their storage with it.
 type InMemoryUserRepository struct {
+	*memoryUsers
+	tenantID uint
+}
+
+// memoryUsers is the storage shared by the repositories of every tenant
+type memoryUsers struct {
 	users  []models.User
 	nextID uint
 	mutex  sync.RWMutex
 }
 
-// NewInMemoryUserRepository creates a new in-memory user repository with sample data
+// NewInMemoryUserRepository creates a new in-memory user repository with
+// sample data in the default tenant
 func NewInMemoryUserRepository() *InMemoryUserRepository {
+	return &InMemoryUserRepository{
+		memoryUsers: &memoryUsers{
+			users:  sampleUsers(),
+			nextID: 4,
+		},
+		tenantID: models.DefaultTenantID,
+	}
+}
+
+// sampleUsers returns the users the repository starts with
+func sampleUsers() []models.User {
 	now := time.Now()
 	address1 := "123 Main St, New York, NY 10001"
 	address2 := "456 Oak Ave, Los Angeles, CA 90210"
-	phone1 := "+1-555-0101"
-	phone2 := "+1-555-0102"
-	phone3 := "+1-555-0103"
+	phone1, original1 := "+12125550101", "+1 212-555-0101"
+	phone2, original2 := "+13105550102", "+1 310-555-0102"
+	phone3, original3 := "+13125550103", "+1 312-555-0103"
+	
+	postal1 := models.Address{Line1: "123 Main St", City: "New York", Region: "NY", PostalCode: "10001", Country: "US"}
+	postal2 := models.Address{Line1: "456 Oak Ave", City: "Los Angeles", Region: "CA", PostalCode: "90210", Country: "US"}
 	
+	return []models.User{
+		{ID: 1, TenantID: models.DefaultTenantID, Name: "John Doe", Email: "john@example.com", Phone: &phone1, PhoneOriginal: &original1, Address: &address1, PostalAddress: &postal1, CreatedAt: now, UpdatedAt: now},
+		{ID: 2, TenantID: models.DefaultTenantID, Name: "Jane Smith", Email: "jane@example.com", Phone: &phone2, PhoneOriginal: &original2, Address: &address2, PostalAddress: &postal2, CreatedAt: now, UpdatedAt: now},
+		{ID: 3, TenantID: models.DefaultTenantID, Name: "Bob Johnson", Email: "bob@example.com", Phone: &phone3, PhoneOriginal: &original3, Address: nil, CreatedAt: now, UpdatedAt: now},
+	}
+}
+
+// WithContext returns a repository for the tenant of ctx over the same users
+func (r *InMemoryUserRepository) WithContext(ctx context.Context) UserRepository {
 	return &InMemoryUserRepository{
-		users: []models.User{
-			{ID: 1, Name: "John Doe", Email: "john@example.com", Phone: &phone1, Address: &address1, CreatedAt: now, UpdatedAt: now},
-			{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Phone: &phone2, Address: &address2, CreatedAt: now, UpdatedAt: now},
-			{ID: 3, Name: "Bob Johnson", Email: "bob@example.com", Phone: &phone3, Address: nil, CreatedAt: now, UpdatedAt: now},
-		},
-		nextID: 4,
+		memoryUsers: r.memoryUsers,
+		tenantID:    tenant.IDFromContext(ctx),
 	}
 }
 
+// visible reports whether user is a live user of the repository's tenant
+func (r *InMemoryUserRepository) visible(user models.User) bool {
+	return user.TenantID == r.tenantID && user.DeletedAt.Time.IsZero()
+}
+
 // GetAll returns all users
 func (r *InMemoryUserRepository) GetAll() ([]models.User, error) {
 	r.mutex.RLock()
@@ -43,20 +80,131 @@ func (r *InMemoryUserRepository) GetAll() ([]models.User, error) {
 	// Return a copy to prevent external modification
 	usersCopy := make([]models.User, 0, len(r.users))
 	for _, user := range r.users {
-		if user.DeletedAt.Time.IsZero() { // Only non-deleted users
+		if r.visible(user) { // Only non-deleted users of the tenant
 			usersCopy = append(usersCopy, user)
 		}
 	}
 	return usersCopy, nil
 }
 
+// List returns all users matching the filter, with only the fields of the
+// projection set
+func (r *InMemoryUserRepository) List(filter models.UserFilter, fields models.UserFields) ([]models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	usersCopy := make([]models.User, 0, len(r.users))
+	for _, user := range r.users {
+		if r.visible(user) && matchesFilter(user, filter) {
+			usersCopy = append(usersCopy, project(user, fields))
+		}
+	}
+	return usersCopy, nil
+}
+
+// ListAfter returns up to limit users matching the filter with IDs above
+// afterID, in ID order
+func (r *InMemoryUserRepository) ListAfter(filter models.UserFilter, afterID uint, limit int) ([]models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var usersCopy []models.User
+	for _, user := range r.users {
+		if user.ID > afterID && r.visible(user) && matchesFilter(user, filter) {
+			usersCopy = append(usersCopy, user)
+		}
+	}
+	sort.Slice(usersCopy, func(i, j int) bool { return usersCopy[i].ID < usersCopy[j].ID })
+	if len(usersCopy) > limit {
+		usersCopy = usersCopy[:limit]
+	}
+	return usersCopy, nil
+}
+
+// CountMatching returns the number of non-deleted users matching the filter
+func (r *InMemoryUserRepository) CountMatching(filter models.UserFilter) (int64, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	var count int64
+	for _, user := range r.users {
+		if r.visible(user) && matchesFilter(user, filter) {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// project keeps the fields of a projection, leaving the others zero as if
+// their columns were not selected
+func project(user models.User, fields models.UserFields) models.User {
+	if len(fields) == 0 {
+		return user
+	}
+	var projected models.User
+	for _, field := range fields {
+		switch field {
+		case "id":
+			projected.ID = user.ID
+		case "name":
+			projected.Name = user.Name
+		case "email":
+			projected.Email = user.Email
+		case "phone":
+			projected.Phone = user.Phone
+		case "phone_original":
+			projected.PhoneOriginal = user.PhoneOriginal
+		case "address":
+			projected.Address = user.Address
+		case "postal_address":
+			projected.PostalAddress = user.PostalAddress
+		case "created_at":
+			projected.CreatedAt = user.CreatedAt
+		case "updated_at":
+			projected.UpdatedAt = user.UpdatedAt
+		}
+	}
+	return projected
+}
+
+// Stream calls fn for every user matching the filter
+func (r *InMemoryUserRepository) Stream(filter models.UserFilter, fn func(user *models.User) error) error {
+	users, err := r.List(filter, nil)
+	if err != nil {
+		return err
+	}
+	for i := range users {
+		if err := fn(&users[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// matchesFilter reports whether a user matches a UserFilter (case-insensitive substring match)
+func matchesFilter(user models.User, filter models.UserFilter) bool {
+	if filter.Name != "" && !strings.Contains(strings.ToLower(user.Name), strings.ToLower(filter.Name)) {
+		return false
+	}
+	if filter.Email != "" && !strings.Contains(strings.ToLower(user.Email), strings.ToLower(filter.Email)) {
+		return false
+	}
+	if filter.City != "" && (user.PostalAddress == nil || !strings.Contains(strings.ToLower(user.PostalAddress.City), strings.ToLower(filter.City))) {
+		return false
+	}
+	if filter.Country != "" && (user.PostalAddress == nil || !strings.EqualFold(user.PostalAddress.Country, filter.Country)) {
+		return false
+	}
+	return true
+}
+
 // GetByID returns a user by ID
 func (r *InMemoryUserRepository) GetByID(id uint) (*models.User, error) {
 	r.mutex.RLock()
 	defer r.mutex.RUnlock()
 	
 	for _, user := range r.users {
-		if user.ID == id && user.DeletedAt.Time.IsZero() {
+		if user.ID == id && r.visible(user) {
 			userCopy := user
 			return &userCopy, nil
 		}
@@ -70,7 +218,7 @@ func (r *InMemoryUserRepository) GetByEmail(email string) (*models.User, error)
 	defer r.mutex.RUnlock()
 	
 	for _, user := range r.users {
-		if user.Email == email && user.DeletedAt.Time.IsZero() {
+		if user.Email == email && r.visible(user) {
 			userCopy := user
 			return &userCopy, nil
 		}
@@ -85,13 +233,14 @@ func (r *InMemoryUserRepository) Create(user *models.User) error {
 	
 	// Check for duplicate email
 	for _, existingUser := range r.users {
-		if existingUser.Email == user.Email && existingUser.DeletedAt.Time.IsZero() {
+		if existingUser.Email == user.Email && r.visible(existingUser) {
 			return errors.New("email already exists")
 		}
 	}
 	
 	now := time.Now()
 	user.ID = r.nextID
+	user.TenantID = r.tenantID
 	user.CreatedAt = now
 	user.UpdatedAt = now
 	r.nextID++
@@ -105,14 +254,15 @@ func (r *InMemoryUserRepository) Update(user *models.User) error {
 	defer r.mutex.Unlock()
 	
 	for i, existingUser := range r.users {
-		if existingUser.ID == user.ID && existingUser.DeletedAt.Time.IsZero() {
+		if existingUser.ID == user.ID && r.visible(existingUser) {
 			// Check for duplicate email (excluding current user)
 			for _, otherUser := range r.users {
-				if otherUser.Email == user.Email && otherUser.ID != user.ID && otherUser.DeletedAt.Time.IsZero() {
+				if otherUser.Email == user.Email && otherUser.ID != user.ID && r.visible(otherUser) {
 					return errors.New("email already exists")
 				}
 			}
 			
+			user.TenantID = r.tenantID
 			user.UpdatedAt = time.Now()
 			user.CreatedAt = existingUser.CreatedAt // Preserve creation time
 			r.users[i] = *user
@@ -128,7 +278,7 @@ func (r *InMemoryUserRepository) Delete(id uint) error {
 	defer r.mutex.Unlock()
 	
 	for i, user := range r.users {
-		if user.ID == id && user.DeletedAt.Time.IsZero() {
+		if user.ID == id && r.visible(user) {
 			r.users[i].DeletedAt = gorm.DeletedAt{Time: time.Now(), Valid: true}
 			return nil
 		}
@@ -143,29 +293,121 @@ func (r *InMemoryUserRepository) Count() (int64, error) {
 	
 	var count int64
 	for _, user := range r.users {
-		if user.DeletedAt.Time.IsZero() {
+		if r.visible(user) {
 			count++
 		}
 	}
 	return count, nil
 }
 
+// GetByIDs returns all non-deleted users with the given IDs
+func (r *InMemoryUserRepository) GetByIDs(ids []uint) ([]models.User, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	users := make([]models.User, 0, len(ids))
+	for _, user := range r.users {
+		if wanted[user.ID] && r.visible(user) {
+			users = append(users, user)
+		}
+	}
+	return users, nil
+}
+
+// CreateBatch creates all users, or none of them if any email is already taken
+func (r *InMemoryUserRepository) CreateBatch(users []models.User) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	emails := make(map[string]bool, len(r.users)+len(users))
+	for _, existingUser := range r.users {
+		if r.visible(existingUser) {
+			emails[existingUser.Email] = true
+		}
+	}
+	for _, user := range users {
+		if emails[user.Email] {
+			return errors.New("email already exists")
+		}
+		emails[user.Email] = true
+	}
+
+	now := time.Now()
+	for i := range users {
+		users[i].ID = r.nextID
+		users[i].TenantID = r.tenantID
+		users[i].CreatedAt = now
+		users[i].UpdatedAt = now
+		r.nextID++
+		r.users = append(r.users, users[i])
+	}
+	return nil
+}
+
+// DeleteByIDs soft deletes all users with the given IDs
+func (r *InMemoryUserRepository) DeleteByIDs(ids []uint) (int64, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	wanted := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		wanted[id] = true
+	}
+
+	var deleted int64
+	now := time.Now()
+	for i, user := range r.users {
+		if wanted[user.ID] && r.visible(user) {
+			r.users[i].DeletedAt = gorm.DeletedAt{Time: now, Valid: true}
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+// Transaction runs fn against a private copy of the data and commits the copy
+// back only if fn succeeds. Other callers are blocked until the transaction
+// finishes, which gives the same isolation as a serializable database transaction.
+func (r *InMemoryUserRepository) Transaction(fn func(repo UserRepository) error) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	tx := &InMemoryUserRepository{
+		memoryUsers: &memoryUsers{
+			users:  append([]models.User(nil), r.users...),
+			nextID: r.nextID,
+		},
+		tenantID: r.tenantID,
+	}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	r.users = tx.users
+	r.nextID = tx.nextID
+	return nil
+}
+
+// Search returns users ranked by relevance to the query, using tokenized
+// exact, prefix and trigram fuzzy matching equivalent to the Postgres search
+func (r *InMemoryUserRepository) Search(query string, limit int) ([]models.UserSearchResult, error) {
+	users, err := r.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	return searchUsers(users, query, limit), nil
+}
+
 // Reset resets the repository to initial state (for testing)
 func (r *InMemoryUserRepository) Reset() {
 	r.mutex.Lock()
 	defer r.mutex.Unlock()
 	
-	now := time.Now()
-	address1 := "123 Main St, New York, NY 10001"
-	address2 := "456 Oak Ave, Los Angeles, CA 90210"
-	phone1 := "+1-555-0101"
-	phone2 := "+1-555-0102"
-	phone3 := "+1-555-0103"
-	
-	r.users = []models.User{
-		{ID: 1, Name: "John Doe", Email: "john@example.com", Phone: &phone1, Address: &address1, CreatedAt: now, UpdatedAt: now},
-		{ID: 2, Name: "Jane Smith", Email: "jane@example.com", Phone: &phone2, Address: &address2, CreatedAt: now, UpdatedAt: now},
-		{ID: 3, Name: "Bob Johnson", Email: "bob@example.com", Phone: &phone3, Address: nil, CreatedAt: now, UpdatedAt: now},
-	}
+	r.users = sampleUsers()
 	r.nextID = 4
 }
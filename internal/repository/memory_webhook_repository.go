@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+	"sort"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// InMemoryWebhookRepository implements WebhookRepository using in-memory storage
+type InMemoryWebhookRepository struct {
+	subscriptions  map[uint]models.WebhookSubscription
+	deliveries     map[uint]models.WebhookDelivery
+	nextSubID      uint
+	nextDeliveryID uint
+	mutex          sync.RWMutex
+}
+
+// NewInMemoryWebhookRepository creates a new in-memory webhook repository
+func NewInMemoryWebhookRepository() *InMemoryWebhookRepository {
+	return &InMemoryWebhookRepository{
+		subscriptions:  make(map[uint]models.WebhookSubscription),
+		deliveries:     make(map[uint]models.WebhookDelivery),
+		nextSubID:      1,
+		nextDeliveryID: 1,
+	}
+}
+
+// CreateSubscription creates a new subscription
+func (r *InMemoryWebhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+	sub.ID = r.nextSubID
+	sub.CreatedAt = now
+	sub.UpdatedAt = now
+	r.nextSubID++
+	r.subscriptions[sub.ID] = *sub
+	return nil
+}
+
+// GetSubscription retrieves a subscription of a tenant by ID
+func (r *InMemoryWebhookRepository) GetSubscription(tenantID, id uint) (*models.WebhookSubscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sub, ok := r.subscriptions[id]
+	if !ok || sub.TenantID != tenantID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions retrieves all subscriptions of a tenant
+func (r *InMemoryWebhookRepository) ListSubscriptions(tenantID uint) ([]models.WebhookSubscription, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	subs := make([]models.WebhookSubscription, 0, len(r.subscriptions))
+	for _, sub := range r.subscriptions {
+		if sub.TenantID == tenantID {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool { return subs[i].ID < subs[j].ID })
+	return subs, nil
+}
+
+// UpdateSubscription saves every field of sub
+func (r *InMemoryWebhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if stored, ok := r.subscriptions[sub.ID]; !ok || stored.TenantID != sub.TenantID {
+		return gorm.ErrRecordNotFound
+	}
+	sub.UpdatedAt = time.Now()
+	r.subscriptions[sub.ID] = *sub
+	return nil
+}
+
+// DeleteSubscription removes a subscription of a tenant and its delivery log
+func (r *InMemoryWebhookRepository) DeleteSubscription(tenantID, id uint) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if sub, ok := r.subscriptions[id]; !ok || sub.TenantID != tenantID {
+		return gorm.ErrRecordNotFound
+	}
+	delete(r.subscriptions, id)
+	for deliveryID, delivery := range r.deliveries {
+		if delivery.SubscriptionID == id {
+			delete(r.deliveries, deliveryID)
+		}
+	}
+	return nil
+}
+
+// EnqueueDeliveries stores new deliveries, skipping duplicates
+func (r *InMemoryWebhookRepository) EnqueueDeliveries(deliveries ...models.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	for _, delivery := range deliveries {
+		if r.hasDelivery(delivery.SubscriptionID, delivery.EventID) {
+			continue
+		}
+		now := time.Now()
+		delivery.ID = r.nextDeliveryID
+		delivery.CreatedAt = now
+		delivery.UpdatedAt = now
+		r.nextDeliveryID++
+		r.deliveries[delivery.ID] = delivery
+	}
+	return nil
+}
+
+// ClaimDeliveries leases due deliveries
+func (r *InMemoryWebhookRepository) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	var claimed []models.WebhookDelivery
+	for _, delivery := range r.sortedDeliveries() {
+		if len(claimed) >= limit {
+			break
+		}
+		if delivery.Status != models.WebhookDeliveryPending || delivery.NextAttemptAt.After(now) {
+			continue
+		}
+		delivery.NextAttemptAt = now.Add(lease)
+		delivery.Attempts++
+		r.deliveries[delivery.ID] = delivery
+		claimed = append(claimed, delivery)
+	}
+	return claimed, nil
+}
+
+// GetDelivery retrieves a delivery of a tenant by ID
+func (r *InMemoryWebhookRepository) GetDelivery(tenantID, id uint) (*models.WebhookDelivery, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	delivery, ok := r.deliveries[id]
+	if !ok || delivery.TenantID != tenantID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns the deliveries matching the filter, newest first
+func (r *InMemoryWebhookRepository) ListDeliveries(filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+
+	sorted := r.sortedDeliveries()
+	deliveries := []models.WebhookDelivery{}
+	for i := len(sorted) - 1; i >= 0 && len(deliveries) < deliveryLimit(filter); i-- {
+		delivery := sorted[i]
+		if filter.TenantID != 0 && delivery.TenantID != filter.TenantID {
+			continue
+		}
+		if filter.SubscriptionID != 0 && delivery.SubscriptionID != filter.SubscriptionID {
+			continue
+		}
+		if filter.Status != "" && delivery.Status != filter.Status {
+			continue
+		}
+		if filter.EventID != "" && delivery.EventID != filter.EventID {
+			continue
+		}
+		if filter.BeforeID != 0 && delivery.ID >= filter.BeforeID {
+			continue
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	return deliveries, nil
+}
+
+// UpdateDelivery saves every field of delivery
+func (r *InMemoryWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if stored, ok := r.deliveries[delivery.ID]; !ok || stored.TenantID != delivery.TenantID {
+		return gorm.ErrRecordNotFound
+	}
+	delivery.UpdatedAt = time.Now()
+	r.deliveries[delivery.ID] = *delivery
+	return nil
+}
+
+func (r *InMemoryWebhookRepository) hasDelivery(subscriptionID uint, eventID string) bool {
+	for _, delivery := range r.deliveries {
+		if delivery.SubscriptionID == subscriptionID && delivery.EventID == eventID {
+			return true
+		}
+	}
+	return false
+}
+
+// sortedDeliveries returns every delivery ordered by ID
+func (r *InMemoryWebhookRepository) sortedDeliveries() []models.WebhookDelivery {
+	deliveries := make([]models.WebhookDelivery, 0, len(r.deliveries))
+	for _, delivery := range r.deliveries {
+		deliveries = append(deliveries, delivery)
+	}
+	sort.Slice(deliveries, func(i, j int) bool { return deliveries[i].ID < deliveries[j].ID })
+	return deliveries
+}
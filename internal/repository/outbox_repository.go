@@ -0,0 +1,155 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// OutboxRepository stores domain events until they have been relayed
+type OutboxRepository interface {
+	Append(events ...models.Event) error
+	// Claim returns up to limit unpublished events that are due, oldest
+	// first, and hides them from other claims for lease. An event whose
+	// relay dies before marking it comes due again when the lease runs out.
+	Claim(now time.Time, lease time.Duration, limit int) ([]models.Event, error)
+	MarkPublished(sequence uint, at time.Time) error
+	MarkFailed(sequence uint, nextAttemptAt time.Time, lastError string) error
+	// DeletePublishedBefore removes events published before t
+	DeletePublishedBefore(t time.Time) (int64, error)
+	// ListAfter returns up to limit events with a sequence after sequence,
+	// published or not, in sequence order
+	ListAfter(sequence uint, limit int) ([]models.Event, error)
+	// Latest returns the last limit events, in sequence order
+	Latest(limit int) ([]models.Event, error)
+}
+
+// GormOutboxRepository implements OutboxRepository using GORM
+type GormOutboxRepository struct {
+	db *gorm.DB
+}
+
+// NewGormOutboxRepository creates a new GORM outbox repository
+func NewGormOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &GormOutboxRepository{
+		db: db,
+	}
+}
+
+// Append stores events
+func (r *GormOutboxRepository) Append(events ...models.Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	return r.db.Create(&events).Error
+}
+
+// Claim leases due events. SKIP LOCKED lets several relays claim in parallel
+// without handing out the same event twice.
+func (r *GormOutboxRepository) Claim(now time.Time, lease time.Duration, limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND next_attempt_at <= ?", now).
+			Order("sequence").
+			Limit(limit).
+			Find(&events).Error
+		if err != nil || len(events) == 0 {
+			return err
+		}
+
+		sequences := make([]uint, len(events))
+		for i := range events {
+			sequences[i] = events[i].Sequence
+		}
+		return tx.Model(&models.Event{}).Where("sequence IN ?", sequences).Updates(map[string]interface{}{
+			"next_attempt_at": now.Add(lease),
+			"attempts":        gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	for i := range events {
+		events[i].Attempts++
+	}
+	return events, err
+}
+
+// MarkPublished records that an event reached every sink
+func (r *GormOutboxRepository) MarkPublished(sequence uint, at time.Time) error {
+	return r.db.Model(&models.Event{}).Where("sequence = ?", sequence).Updates(map[string]interface{}{
+		"published_at": at,
+		"last_error":   "",
+	}).Error
+}
+
+// MarkFailed schedules another attempt for an event
+func (r *GormOutboxRepository) MarkFailed(sequence uint, nextAttemptAt time.Time, lastError string) error {
+	return r.db.Model(&models.Event{}).Where("sequence = ?", sequence).Updates(map[string]interface{}{
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      lastError,
+	}).Error
+}
+
+// DeletePublishedBefore removes events published before t
+func (r *GormOutboxRepository) DeletePublishedBefore(t time.Time) (int64, error) {
+	result := r.db.Where("published_at < ?", t).Delete(&models.Event{})
+	return result.RowsAffected, result.Error
+}
+
+// ListAfter returns the events after sequence
+func (r *GormOutboxRepository) ListAfter(sequence uint, limit int) ([]models.Event, error) {
+	var events []models.Event
+	err := r.db.Where("sequence > ?", sequence).Order("sequence").Limit(limit).Find(&events).Error
+	return events, err
+}
+
+// Latest returns the last limit events
+func (r *GormOutboxRepository) Latest(limit int) ([]models.Event, error) {
+	var events []models.Event
+	if err := r.db.Order("sequence DESC").Limit(limit).Find(&events).Error; err != nil {
+		return nil, err
+	}
+	for i, j := 0, len(events)-1; i < j; i, j = i+1, j-1 {
+		events[i], events[j] = events[j], events[i]
+	}
+	return events, nil
+}
+
+// DiscardOutboxRepository is an OutboxRepository that keeps nothing
+type DiscardOutboxRepository struct{}
+
+// Append drops events
+func (DiscardOutboxRepository) Append(...models.Event) error {
+	return nil
+}
+
+// Claim returns no events
+func (DiscardOutboxRepository) Claim(time.Time, time.Duration, int) ([]models.Event, error) {
+	return nil, nil
+}
+
+// MarkPublished does nothing
+func (DiscardOutboxRepository) MarkPublished(uint, time.Time) error {
+	return nil
+}
+
+// MarkFailed does nothing
+func (DiscardOutboxRepository) MarkFailed(uint, time.Time, string) error {
+	return nil
+}
+
+// DeletePublishedBefore does nothing
+func (DiscardOutboxRepository) DeletePublishedBefore(time.Time) (int64, error) {
+	return 0, nil
+}
+
+// ListAfter returns no events
+func (DiscardOutboxRepository) ListAfter(uint, int) ([]models.Event, error) {
+	return nil, nil
+}
+
+// Latest returns no events
+func (DiscardOutboxRepository) Latest(int) ([]models.Event, error) {
+	return nil, nil
+}
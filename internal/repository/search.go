@@ -0,0 +1,205 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+	"html"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// Highlight markers used while building snippets. They are swapped for <mark>
+// tags only after the snippet has been HTML-escaped, so user data can never
+// inject markup other than a stray <mark>.
+const (
+	highlightStart = "\x01"
+	highlightStop  = "\x02"
+)
+
+// fuzzyThreshold is the minimum trigram similarity for a typo-tolerant match,
+// matching the pg_trgm word_similarity threshold used by GormUserRepository
+const fuzzyThreshold = 0.3
+
+// Field weights, mirroring the A/B/C tsvector weights of the search_vector column
+var searchFields = []struct {
+	name   string
+	weight float64
+	value  func(u *models.User) string
+}{
+	{"name", 1.0, func(u *models.User) string { return u.Name }},
+	{"email", 1.0, func(u *models.User) string { return u.Email }},
+	{"phone", 0.4, func(u *models.User) string { return deref(u.Phone) }},
+	{"address", 0.2, func(u *models.User) string { return deref(u.Address) }},
+}
+
+func deref(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// token is a lowercased word and its byte offsets in the original text
+type token struct {
+	text       string
+	start, end int
+}
+
+// tokenize splits text into lowercase alphanumeric words
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		} else if !isWord && start >= 0 {
+			tokens = append(tokens, token{strings.ToLower(text[start:i]), start, i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{strings.ToLower(text[start:]), start, len(text)})
+	}
+	return tokens
+}
+
+// trigrams returns the pg_trgm style trigram set of a word
+func trigrams(word string) map[string]bool {
+	padded := []rune("  " + word + " ")
+	set := make(map[string]bool, len(padded))
+	for i := 0; i+3 <= len(padded); i++ {
+		set[string(padded[i:i+3])] = true
+	}
+	return set
+}
+
+// similarity returns the pg_trgm similarity of two words: shared trigrams over total distinct trigrams
+func similarity(a, b string) float64 {
+	ta, tb := trigrams(a), trigrams(b)
+	shared := 0
+	for t := range ta {
+		if tb[t] {
+			shared++
+		}
+	}
+	union := len(ta) + len(tb) - shared
+	if union == 0 {
+		return 0
+	}
+	return float64(shared) / float64(union)
+}
+
+// isDigits reports whether s consists only of ASCII digits
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// matchScore scores how well a query term matches a word: exact matches beat
+// prefix matches, which beat fuzzy trigram matches
+func matchScore(term, word string) float64 {
+	switch {
+	case term == word:
+		return 1.0
+	case strings.HasPrefix(word, term):
+		return 0.8
+	case len(term) >= 3 && isDigits(term) && strings.Contains(word, term):
+		// Partial phone numbers, e.g. the last digits of an E.164 number
+		return 0.7
+	}
+	if sim := similarity(term, word); sim >= fuzzyThreshold {
+		return 0.6 * sim
+	}
+	return 0
+}
+
+// searchUsers ranks users against a query. Every query term must match at
+// least one word of the user; the rank sums the best score of each term,
+// weighted by the field it matched in.
+func searchUsers(users []models.User, query string, limit int) []models.UserSearchResult {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return []models.UserSearchResult{}
+	}
+
+	results := []models.UserSearchResult{}
+	for i := range users {
+		user := &users[i]
+		rank := 0.0
+		matched := make(map[string][]token)
+		allTermsMatched := true
+
+		for _, term := range terms {
+			best := 0.0
+			for _, field := range searchFields {
+				for _, word := range tokenize(field.value(user)) {
+					score := matchScore(term.text, word.text)
+					if score == 0 {
+						continue
+					}
+					matched[field.name] = append(matched[field.name], word)
+					if score*field.weight > best {
+						best = score * field.weight
+					}
+				}
+			}
+			if best == 0 {
+				allTermsMatched = false
+				break
+			}
+			rank += best
+		}
+		if !allTermsMatched {
+			continue
+		}
+
+		highlights := make(map[string]string, len(matched))
+		for _, field := range searchFields {
+			if words, ok := matched[field.name]; ok {
+				highlights[field.name] = markWords(field.value(user), words)
+			}
+		}
+		results = append(results, models.UserSearchResult{User: *user, Rank: rank, Highlights: highlights})
+	}
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if results[i].Rank != results[j].Rank {
+			return results[i].Rank > results[j].Rank
+		}
+		return results[i].User.ID < results[j].User.ID
+	})
+	if limit > 0 && len(results) > limit {
+		results = results[:limit]
+	}
+	return results
+}
+
+// markWords wraps the given words of text in highlight markers and renders the snippet
+func markWords(text string, words []token) string {
+	sort.Slice(words, func(i, j int) bool { return words[i].start < words[j].start })
+
+	var b strings.Builder
+	pos := 0
+	for _, w := range words {
+		if w.start < pos {
+			continue // same word matched by several terms
+		}
+		b.WriteString(text[pos:w.start])
+		b.WriteString(highlightStart + text[w.start:w.end] + highlightStop)
+		pos = w.end
+	}
+	b.WriteString(text[pos:])
+	return renderHighlight(b.String())
+}
+
+// renderHighlight HTML-escapes a snippet and turns highlight markers into <mark> tags
+func renderHighlight(snippet string) string {
+	escaped := html.EscapeString(snippet)
+	escaped = strings.ReplaceAll(escaped, highlightStart, "<mark>")
+	return strings.ReplaceAll(escaped, highlightStop, "</mark>")
+}
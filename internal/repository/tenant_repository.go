@@ -0,0 +1,39 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// TenantRepository stores the tenants users belong to
+type TenantRepository interface {
+	GetBySlug(slug string) (*models.Tenant, error)
+	Create(tenant *models.Tenant) error
+}
+
+// GormTenantRepository implements TenantRepository using GORM
+type GormTenantRepository struct {
+	db *gorm.DB
+}
+
+// NewGormTenantRepository creates a new GORM tenant repository
+func NewGormTenantRepository(db *gorm.DB) TenantRepository {
+	return &GormTenantRepository{
+		db: db,
+	}
+}
+
+// GetBySlug returns a tenant by slug
+func (r *GormTenantRepository) GetBySlug(slug string) (*models.Tenant, error) {
+	var tenant models.Tenant
+	if err := r.db.Where("slug = ?", slug).First(&tenant).Error; err != nil {
+		return nil, err
+	}
+	return &tenant, nil
+}
+
+// Create creates a new tenant
+func (r *GormTenantRepository) Create(tenant *models.Tenant) error {
+	return r.db.Create(tenant).Error
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"gin-simple-app/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// Repositories holds repositories that take part in the same transaction
+type Repositories struct {
+	Users  UserRepository
+	Audit  AuditRepository
+	Outbox OutboxRepository
+}
+
+// UnitOfWork runs a function in one transaction across several
+// repositories, so related writes are committed or rolled back together.
+// The user repository of the transaction is scoped to the tenant of ctx.
+type UnitOfWork interface {
+	Transaction(ctx context.Context, fn func(repos Repositories) error) error
+}
+
+// GormUnitOfWork implements UnitOfWork with a database transaction
+type GormUnitOfWork struct {
+	db          *gorm.DB
+	userOptions []GormUserOption
+}
+
+// NewGormUnitOfWork creates a unit of work over the GORM repositories. The
+// user repository of each transaction is configured with userOptions.
+func NewGormUnitOfWork(db *gorm.DB, userOptions ...GormUserOption) UnitOfWork {
+	return &GormUnitOfWork{
+		db:          db,
+		userOptions: userOptions,
+	}
+}
+
+// Transaction runs fn in a database transaction
+func (u *GormUnitOfWork) Transaction(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		users := newGormUserRepository(tx, u.userOptions...)
+		if err := users.bindTenant(); err != nil {
+			return err
+		}
+		return fn(Repositories{
+			Users:  users,
+			Audit:  &GormAuditRepository{db: tx},
+			Outbox: &GormOutboxRepository{db: tx},
+		})
+	})
+}
+
+// InMemoryUnitOfWork implements UnitOfWork over the in-memory repositories
+type InMemoryUnitOfWork struct {
+	users  *InMemoryUserRepository
+	audit  *InMemoryAuditRepository
+	outbox *InMemoryOutboxRepository
+}
+
+// NewInMemoryUnitOfWork creates a unit of work over the in-memory repositories
+func NewInMemoryUnitOfWork(users *InMemoryUserRepository, audit *InMemoryAuditRepository, outbox *InMemoryOutboxRepository) UnitOfWork {
+	return &InMemoryUnitOfWork{
+		users:  users,
+		audit:  audit,
+		outbox: outbox,
+	}
+}
+
+// Transaction runs fn inside a user repository transaction. Audit entries
+// and events are held back and appended only once fn has succeeded.
+func (u *InMemoryUnitOfWork) Transaction(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.users.WithContext(ctx).Transaction(func(users UserRepository) error {
+		audit := &pendingAuditRepository{AuditRepository: u.audit}
+		outbox := &pendingOutboxRepository{OutboxRepository: u.outbox}
+		if err := fn(Repositories{Users: users, Audit: audit, Outbox: outbox}); err != nil {
+			return err
+		}
+		if err := u.audit.Append(audit.entries...); err != nil {
+			return err
+		}
+		return u.outbox.Append(outbox.events...)
+	})
+}
+
+// pendingAuditRepository buffers appended entries until a transaction commits
+type pendingAuditRepository struct {
+	AuditRepository
+	entries []models.AuditEntry
+}
+
+// Append buffers entries
+func (r *pendingAuditRepository) Append(entries ...models.AuditEntry) error {
+	r.entries = append(r.entries, entries...)
+	return nil
+}
+
+// pendingOutboxRepository buffers appended events until a transaction commits
+type pendingOutboxRepository struct {
+	OutboxRepository
+	events []models.Event
+}
+
+// Append buffers events
+func (r *pendingOutboxRepository) Append(events ...models.Event) error {
+	r.events = append(r.events, events...)
+	return nil
+}
+
+// UserUnitOfWork implements UnitOfWork with a user repository transaction
+// alone, discarding audit entries and events
+type UserUnitOfWork struct {
+	users UserRepository
+}
+
+// NewUserUnitOfWork creates a unit of work for services without an audit log
+func NewUserUnitOfWork(users UserRepository) UnitOfWork {
+	return &UserUnitOfWork{
+		users: users,
+	}
+}
+
+// Transaction runs fn in a user repository transaction
+func (u *UserUnitOfWork) Transaction(ctx context.Context, fn func(repos Repositories) error) error {
+	return u.users.WithContext(ctx).Transaction(func(users UserRepository) error {
+		return fn(Repositories{Users: users, Audit: DiscardAuditRepository{}, Outbox: DiscardOutboxRepository{}})
+	})
+}
@@ -1,45 +1,236 @@
 package repository
 
 import (
+	"context"
 	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/tenant"
+	"strconv"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
-// UserRepository defines the interface for user data operations
+// exportBatchSize is the number of rows fetched per query when streaming users
+const exportBatchSize = 500
+
+// UserRepository defines the interface for user data operations. A
+// repository only sees the users of one tenant: the default tenant, or the
+// tenant of the context it was bound to with WithContext.
 type UserRepository interface {
+	WithContext(ctx context.Context) UserRepository
 	GetAll() ([]models.User, error)
+	List(filter models.UserFilter, fields models.UserFields) ([]models.User, error)
+	ListAfter(filter models.UserFilter, afterID uint, limit int) ([]models.User, error)
+	CountMatching(filter models.UserFilter) (int64, error)
+	Stream(filter models.UserFilter, fn func(user *models.User) error) error
 	GetByID(id uint) (*models.User, error)
 	GetByEmail(email string) (*models.User, error)
 	Create(user *models.User) error
 	Update(user *models.User) error
 	Delete(id uint) error
 	Count() (int64, error)
+	GetByIDs(ids []uint) ([]models.User, error)
+	CreateBatch(users []models.User) error
+	DeleteByIDs(ids []uint) (int64, error)
+	Transaction(fn func(repo UserRepository) error) error
+	Search(query string, limit int) ([]models.UserSearchResult, error)
 }
 
-// GormUserRepository implements UserRepository using GORM
+// GormUserRepository implements UserRepository using GORM. Every query is
+// scoped to the tenant of the context of its database session.
 type GormUserRepository struct {
 	db *gorm.DB
+	// rowLevelSecurity runs every operation in a transaction that sets
+	// app.tenant_id, which the Postgres policies on users check each row against
+	rowLevelSecurity bool
+	// tenantBound is set once db is a transaction that has set app.tenant_id
+	tenantBound bool
+}
+
+// GormUserOption configures a GormUserRepository
+type GormUserOption func(r *GormUserRepository)
+
+// WithRowLevelSecurity makes the repository tell Postgres the tenant of
+// every operation, so the row-level security policies on the users table
+// hide and protect the rows of other tenants even from a query that forgets
+// to scope itself. The policies only apply once the table has row-level
+// security enabled, and never to superusers.
+func WithRowLevelSecurity(enabled bool) GormUserOption {
+	return func(r *GormUserRepository) {
+		r.rowLevelSecurity = enabled
+	}
 }
 
 // NewGormUserRepository creates a new GORM user repository
-func NewGormUserRepository(db *gorm.DB) UserRepository {
-	return &GormUserRepository{
+func NewGormUserRepository(db *gorm.DB, opts ...GormUserOption) UserRepository {
+	return newGormUserRepository(db, opts...)
+}
+
+func newGormUserRepository(db *gorm.DB, opts ...GormUserOption) *GormUserRepository {
+	r := &GormUserRepository{
 		db: db,
 	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// WithContext returns a repository for the tenant of ctx that runs its
+// queries with ctx
+func (r *GormUserRepository) WithContext(ctx context.Context) UserRepository {
+	return &GormUserRepository{
+		db:               r.db.WithContext(ctx),
+		rowLevelSecurity: r.rowLevelSecurity,
+		tenantBound:      r.tenantBound && tenant.IDFromContext(ctx) == r.tenantID(),
+	}
+}
+
+// tenantScope restricts a query to the users of the tenant of its context
+func tenantScope(db *gorm.DB) *gorm.DB {
+	return db.Where("users.tenant_id = ?", tenant.IDFromContext(db.Statement.Context))
+}
+
+// users starts a query scoped to the tenant of the repository
+func users(db *gorm.DB) *gorm.DB {
+	return db.Scopes(tenantScope)
+}
+
+// tenantID returns the tenant the repository is bound to
+func (r *GormUserRepository) tenantID() uint {
+	return tenant.IDFromContext(r.db.Statement.Context)
+}
+
+// session runs fn with the database session of the repository. With
+// row-level security, that is a transaction bound to the tenant.
+func (r *GormUserRepository) session(fn func(db *gorm.DB) error) error {
+	if !r.rowLevelSecurity || r.tenantBound {
+		return fn(r.db)
+	}
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := setTenant(tx, r.tenantID()); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// bindTenant sets app.tenant_id for the rest of the transaction of the
+// repository, if it uses row-level security
+func (r *GormUserRepository) bindTenant() error {
+	if !r.rowLevelSecurity || r.tenantBound {
+		return nil
+	}
+	if err := setTenant(r.db, r.tenantID()); err != nil {
+		return err
+	}
+	r.tenantBound = true
+	return nil
+}
+
+// setTenant sets app.tenant_id until the end of the current transaction
+func setTenant(tx *gorm.DB, tenantID uint) error {
+	return tx.Exec("SELECT set_config('app.tenant_id', ?, true)", strconv.FormatUint(uint64(tenantID), 10)).Error
 }
 
 // GetAll returns all users
 func (r *GormUserRepository) GetAll() ([]models.User, error) {
-	var users []models.User
-	err := r.db.Find(&users).Error
-	return users, err
+	var result []models.User
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Find(&result).Error
+	})
+	return result, err
+}
+
+// List returns all users matching the filter, selecting only the columns
+// of fields
+func (r *GormUserRepository) List(filter models.UserFilter, fields models.UserFields) ([]models.User, error) {
+	var result []models.User
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Scopes(filterScope(filter), selectScope(fields)).Find(&result).Error
+	})
+	return result, err
+}
+
+// ListAfter returns up to limit users matching the filter with IDs above
+// afterID, in ID order, so callers page through users with an index seek
+// rather than loading every user
+func (r *GormUserRepository) ListAfter(filter models.UserFilter, afterID uint, limit int) ([]models.User, error) {
+	var result []models.User
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Scopes(filterScope(filter)).Where("id > ?", afterID).Order("id").Limit(limit).Find(&result).Error
+	})
+	return result, err
+}
+
+// CountMatching returns the number of users matching the filter
+func (r *GormUserRepository) CountMatching(filter models.UserFilter) (int64, error) {
+	var count int64
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Model(&models.User{}).Scopes(filterScope(filter)).Count(&count).Error
+	})
+	return count, err
+}
+
+// Stream calls fn for every user matching the filter, fetching rows in batches
+// so the full result set is never held in memory
+func (r *GormUserRepository) Stream(filter models.UserFilter, fn func(user *models.User) error) error {
+	var batch []models.User
+	return r.session(func(db *gorm.DB) error {
+		return users(db).Scopes(filterScope(filter)).Order("id").FindInBatches(&batch, exportBatchSize, func(tx *gorm.DB, _ int) error {
+			for i := range batch {
+				if err := fn(&batch[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		}).Error
+	})
+}
+
+// likeEscaper escapes the LIKE wildcards, so filters match them literally
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// containsPattern returns a LIKE pattern matching values that contain s
+func containsPattern(s string) string {
+	return "%" + likeEscaper.Replace(s) + "%"
+}
+
+// filterScope applies a UserFilter to a query
+func filterScope(filter models.UserFilter) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if filter.Name != "" {
+			db = db.Where(`name ILIKE ? ESCAPE '\'`, containsPattern(filter.Name))
+		}
+		if filter.Email != "" {
+			db = db.Where(`email ILIKE ? ESCAPE '\'`, containsPattern(filter.Email))
+		}
+		if filter.City != "" {
+			db = db.Where(`postal_address->>'city' ILIKE ? ESCAPE '\'`, containsPattern(filter.City))
+		}
+		if filter.Country != "" {
+			db = db.Where("postal_address->>'country' = ?", strings.ToUpper(filter.Country))
+		}
+		return db
+	}
+}
+
+// selectScope narrows a query to the columns of a projection
+func selectScope(fields models.UserFields) func(db *gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if columns := fields.Columns(); columns != nil {
+			db = db.Select(columns)
+		}
+		return db
+	}
 }
 
 // GetByID returns a user by ID
 func (r *GormUserRepository) GetByID(id uint) (*models.User, error) {
 	var user models.User
-	err := r.db.First(&user, id).Error
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).First(&user, id).Error
+	})
 	if err != nil {
 		return nil, err
 	}
@@ -49,31 +240,168 @@ func (r *GormUserRepository) GetByID(id uint) (*models.User, error) {
 // GetByEmail returns a user by email
 func (r *GormUserRepository) GetByEmail(email string) (*models.User, error) {
 	var user models.User
-	err := r.db.Where("email = ?", email).First(&user).Error
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Where("email = ?", email).First(&user).Error
+	})
 	if err != nil {
 		return nil, err
 	}
 	return &user, nil
 }
 
-// Create creates a new user
+// Create creates a new user in the tenant of the repository
 func (r *GormUserRepository) Create(user *models.User) error {
-	return r.db.Create(user).Error
+	user.TenantID = r.tenantID()
+	return r.session(func(db *gorm.DB) error {
+		return db.Create(user).Error
+	})
 }
 
-// Update updates an existing user
+// Update updates an existing user. Selecting every column stops Save from
+// falling back to an upsert, which could overwrite another tenant's user.
 func (r *GormUserRepository) Update(user *models.User) error {
-	return r.db.Save(user).Error
+	user.TenantID = r.tenantID()
+	return r.session(func(db *gorm.DB) error {
+		result := users(db).Select("*").Save(user)
+		if result.Error == nil && result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return result.Error
+	})
 }
 
 // Delete deletes a user by ID (soft delete)
 func (r *GormUserRepository) Delete(id uint) error {
-	return r.db.Delete(&models.User{}, id).Error
+	return r.session(func(db *gorm.DB) error {
+		return users(db).Delete(&models.User{}, id).Error
+	})
 }
 
 // Count returns the total number of users
 func (r *GormUserRepository) Count() (int64, error) {
 	var count int64
-	err := r.db.Model(&models.User{}).Count(&count).Error
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Model(&models.User{}).Count(&count).Error
+	})
 	return count, err
 }
+
+// GetByIDs returns all users with the given IDs in a single query
+func (r *GormUserRepository) GetByIDs(ids []uint) ([]models.User, error) {
+	var result []models.User
+	if len(ids) == 0 {
+		return result, nil
+	}
+	err := r.session(func(db *gorm.DB) error {
+		return users(db).Where("id IN ?", ids).Find(&result).Error
+	})
+	return result, err
+}
+
+// CreateBatch inserts all users with a single multi-row INSERT, filling in their IDs
+func (r *GormUserRepository) CreateBatch(users []models.User) error {
+	if len(users) == 0 {
+		return nil
+	}
+	tenantID := r.tenantID()
+	for i := range users {
+		users[i].TenantID = tenantID
+	}
+	return r.session(func(db *gorm.DB) error {
+		return db.Create(&users).Error
+	})
+}
+
+// DeleteByIDs soft deletes all users with the given IDs using a single UPDATE ... WHERE id IN
+func (r *GormUserRepository) DeleteByIDs(ids []uint) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	var deleted int64
+	err := r.session(func(db *gorm.DB) error {
+		result := users(db).Where("id IN ?", ids).Delete(&models.User{})
+		deleted = result.RowsAffected
+		return result.Error
+	})
+	return deleted, err
+}
+
+// Transaction runs fn with a repository bound to a database transaction.
+// The transaction is committed if fn returns nil and rolled back otherwise.
+func (r *GormUserRepository) Transaction(fn func(repo UserRepository) error) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		repo := &GormUserRepository{db: tx, rowLevelSecurity: r.rowLevelSecurity}
+		if err := repo.bindTenant(); err != nil {
+			return err
+		}
+		return fn(repo)
+	})
+}
+
+// searchRow is a user row with its search rank and per-field headlines
+type searchRow struct {
+	models.User     `gorm:"embedded"`
+	Rank            float64
+	NameHeadline    *string
+	EmailHeadline   *string
+	PhoneHeadline   *string
+	AddressHeadline *string
+}
+
+// searchSQL combines full-text matching on the search_vector column with
+// pg_trgm word similarity on search_text for typo tolerance
+const searchSQL = `
+SELECT users.id, users.created_at, users.updated_at, users.tenant_id, users.name, users.email,
+	users.phone, users.phone_original, users.address, users.postal_address,
+	ts_rank(users.search_vector, q) + word_similarity(@term, users.search_text) AS rank,
+	ts_headline('simple', users.name, q, @opts) AS name_headline,
+	ts_headline('simple', users.email, q, @opts) AS email_headline,
+	ts_headline('simple', users.phone, q, @opts) AS phone_headline,
+	ts_headline('simple', users.address, q, @opts) AS address_headline
+FROM users, websearch_to_tsquery('simple', @term) AS q
+WHERE users.deleted_at IS NULL AND users.tenant_id = @tenant AND (users.search_vector @@ q OR @term <% users.search_text)
+ORDER BY rank DESC, users.id
+LIMIT @limit`
+
+// Search returns users ranked by relevance to the query, with highlighted snippets
+func (r *GormUserRepository) Search(query string, limit int) ([]models.UserSearchResult, error) {
+	var rows []searchRow
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if r.rowLevelSecurity {
+			if err := setTenant(tx, r.tenantID()); err != nil {
+				return err
+			}
+		}
+		// Scope the fuzzy threshold to this transaction so the <% operator can use the trigram index
+		if err := tx.Exec("SELECT set_config('pg_trgm.word_similarity_threshold', ?, true)", strconv.FormatFloat(fuzzyThreshold, 'f', -1, 64)).Error; err != nil {
+			return err
+		}
+		return tx.Raw(searchSQL, map[string]interface{}{
+			"term":   strings.ToLower(query),
+			"opts":   "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true",
+			"limit":  limit,
+			"tenant": r.tenantID(),
+		}).Scan(&rows).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]models.UserSearchResult, 0, len(rows))
+	for _, row := range rows {
+		highlights := make(map[string]string)
+		for field, headline := range map[string]*string{
+			"name":    row.NameHeadline,
+			"email":   row.EmailHeadline,
+			"phone":   row.PhoneHeadline,
+			"address": row.AddressHeadline,
+		} {
+			// ts_headline returns the unmarked text for fields that did not match
+			if headline != nil && strings.Contains(*headline, highlightStart) {
+				highlights[field] = renderHighlight(*headline)
+			}
+		}
+		results = append(results, models.UserSearchResult{User: row.User, Rank: row.Rank, Highlights: highlights})
+	}
+	return results, nil
+}
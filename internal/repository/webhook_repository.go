@@ -0,0 +1,185 @@
+package repository
+
+import (
+	"gin-simple-app/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// defaultDeliveryLimit is the number of deliveries returned when no limit is given
+const defaultDeliveryLimit = 100
+
+// WebhookRepository stores webhook subscriptions and their deliveries.
+// Subscriptions and deliveries are only found in the tenant they belong to.
+type WebhookRepository interface {
+	CreateSubscription(sub *models.WebhookSubscription) error
+	GetSubscription(tenantID, id uint) (*models.WebhookSubscription, error)
+	ListSubscriptions(tenantID uint) ([]models.WebhookSubscription, error)
+	// UpdateSubscription saves sub, if it exists in its tenant
+	UpdateSubscription(sub *models.WebhookSubscription) error
+	// DeleteSubscription removes a subscription and its delivery log
+	DeleteSubscription(tenantID, id uint) error
+
+	// EnqueueDeliveries stores new deliveries, skipping any for an event
+	// the subscription already has a delivery for
+	EnqueueDeliveries(deliveries ...models.WebhookDelivery) error
+	// ClaimDeliveries returns up to limit pending deliveries that are due,
+	// oldest first, and hides them from other claims for lease
+	ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error)
+	GetDelivery(tenantID, id uint) (*models.WebhookDelivery, error)
+	// ListDeliveries returns the deliveries matching the filter, newest first
+	ListDeliveries(filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	UpdateDelivery(delivery *models.WebhookDelivery) error
+}
+
+// GormWebhookRepository implements WebhookRepository using GORM
+type GormWebhookRepository struct {
+	db *gorm.DB
+}
+
+// NewGormWebhookRepository creates a new GORM webhook repository
+func NewGormWebhookRepository(db *gorm.DB) WebhookRepository {
+	return &GormWebhookRepository{
+		db: db,
+	}
+}
+
+// CreateSubscription creates a new subscription
+func (r *GormWebhookRepository) CreateSubscription(sub *models.WebhookSubscription) error {
+	return r.db.Create(sub).Error
+}
+
+// GetSubscription retrieves a subscription of a tenant by ID
+func (r *GormWebhookRepository) GetSubscription(tenantID, id uint) (*models.WebhookSubscription, error) {
+	var sub models.WebhookSubscription
+	if err := r.db.Where("tenant_id = ?", tenantID).First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListSubscriptions retrieves all subscriptions of a tenant
+func (r *GormWebhookRepository) ListSubscriptions(tenantID uint) ([]models.WebhookSubscription, error) {
+	var subs []models.WebhookSubscription
+	err := r.db.Where("tenant_id = ?", tenantID).Order("id").Find(&subs).Error
+	return subs, err
+}
+
+// UpdateSubscription saves every field of sub. Selecting every column stops
+// Save from falling back to an upsert, which could overwrite another
+// tenant's subscription.
+func (r *GormWebhookRepository) UpdateSubscription(sub *models.WebhookSubscription) error {
+	result := r.db.Where("tenant_id = ?", sub.TenantID).Select("*").Save(sub)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// DeleteSubscription removes a subscription of a tenant and its delivery log
+func (r *GormWebhookRepository) DeleteSubscription(tenantID, id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("tenant_id = ? AND subscription_id = ?", tenantID, id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		result := tx.Where("tenant_id = ?", tenantID).Delete(&models.WebhookSubscription{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// EnqueueDeliveries stores new deliveries, skipping duplicates
+func (r *GormWebhookRepository) EnqueueDeliveries(deliveries ...models.WebhookDelivery) error {
+	if len(deliveries) == 0 {
+		return nil
+	}
+	return r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error
+}
+
+// ClaimDeliveries leases due deliveries. SKIP LOCKED lets several workers
+// claim in parallel without sending the same delivery twice.
+func (r *GormWebhookRepository) ClaimDeliveries(now time.Time, lease time.Duration, limit int) ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_attempt_at <= ?", models.WebhookDeliveryPending, now).
+			Order("id").
+			Limit(limit).
+			Find(&deliveries).Error
+		if err != nil || len(deliveries) == 0 {
+			return err
+		}
+
+		ids := make([]uint, len(deliveries))
+		for i := range deliveries {
+			ids[i] = deliveries[i].ID
+		}
+		return tx.Model(&models.WebhookDelivery{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"next_attempt_at": now.Add(lease),
+			"attempts":        gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	for i := range deliveries {
+		deliveries[i].Attempts++
+		deliveries[i].NextAttemptAt = now.Add(lease)
+	}
+	return deliveries, err
+}
+
+// GetDelivery retrieves a delivery of a tenant by ID
+func (r *GormWebhookRepository) GetDelivery(tenantID, id uint) (*models.WebhookDelivery, error) {
+	var delivery models.WebhookDelivery
+	if err := r.db.Where("tenant_id = ?", tenantID).First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// ListDeliveries returns the deliveries matching the filter, newest first
+func (r *GormWebhookRepository) ListDeliveries(filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	query := r.db.Model(&models.WebhookDelivery{})
+	if filter.TenantID != 0 {
+		query = query.Where("tenant_id = ?", filter.TenantID)
+	}
+	if filter.SubscriptionID != 0 {
+		query = query.Where("subscription_id = ?", filter.SubscriptionID)
+	}
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.EventID != "" {
+		query = query.Where("event_id = ?", filter.EventID)
+	}
+	if filter.BeforeID != 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var deliveries []models.WebhookDelivery
+	err := query.Order("id DESC").Limit(deliveryLimit(filter)).Find(&deliveries).Error
+	return deliveries, err
+}
+
+// UpdateDelivery saves every field of delivery, which must still exist in
+// its tenant
+func (r *GormWebhookRepository) UpdateDelivery(delivery *models.WebhookDelivery) error {
+	result := r.db.Where("tenant_id = ?", delivery.TenantID).Select("*").Save(delivery)
+	if result.Error == nil && result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return result.Error
+}
+
+// deliveryLimit returns the page size for filter
+func deliveryLimit(filter models.WebhookDeliveryFilter) int {
+	if filter.Limit <= 0 {
+		return defaultDeliveryLimit
+	}
+	return filter.Limit
+}
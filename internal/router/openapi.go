@@ -0,0 +1,358 @@
+package router
+
+import (
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/openapi"
+	"gin-simple-app/internal/versioning"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"strings"
+)
+
+// apiInfo describes the API in the OpenAPI document
+var apiInfo = openapi.Info{
+	Title:   "Gin Simple REST API",
+	Version: "1.0.0",
+	Description: "Manage users, follow their changes and subscribe to them with webhooks. " +
+		"Each version of the API is served under /api/v1 and /api/v2; /api serves the version asked for " +
+		"with an Accept header such as application/vnd.app.v2+json, or the latest one. " +
+		"Users belong to a tenant, named by the X-Tenant header set by a trusted proxy, the tenant claim " +
+		"of a signed bearer token or the subdomain, depending on the deployment.",
+}
+
+// Schemas of responses that are not built from Go types
+var (
+	messageSchema = &openapi.Schema{
+		Type: openapi.Types{"object"},
+		Properties: map[string]*openapi.Schema{
+			"message": {Type: openapi.Types{"string"}},
+			"version": {Type: openapi.Types{"string"}},
+			"status":  {Type: openapi.Types{"string"}},
+			"cache": {
+				Type: openapi.Types{"object"},
+				Properties: map[string]*openapi.Schema{
+					"hits":      {Type: openapi.Types{"integer"}, Minimum: float(0)},
+					"misses":    {Type: openapi.Types{"integer"}, Minimum: float(0)},
+					"hit_ratio": {Type: openapi.Types{"number"}, Minimum: float(0)},
+				},
+			},
+		},
+	}
+	graphQLRequestSchema = &openapi.Schema{
+		Type: openapi.Types{"object"},
+		Properties: map[string]*openapi.Schema{
+			"query":         {Type: openapi.Types{"string"}},
+			"operationName": {Type: openapi.Types{"string", "null"}},
+			"variables":     {Type: openapi.Types{"object", "null"}},
+		},
+		Required: []string{"query"},
+	}
+	graphQLResponseSchema = &openapi.Schema{
+		Type: openapi.Types{"object"},
+		Properties: map[string]*openapi.Schema{
+			"data": {},
+			"errors": openapi.ArrayOf(&openapi.Schema{
+				Type:       openapi.Types{"object"},
+				Properties: map[string]*openapi.Schema{"message": {Type: openapi.Types{"string"}}},
+				Required:   []string{"message"},
+			}),
+			"extensions": {Type: openapi.Types{"object"}},
+		},
+	}
+	sparseUsersSchema = openapi.ArrayOf(&openapi.Schema{
+		Type:        openapi.Types{"object"},
+		Description: "A user with only the fields asked for",
+	})
+	deletedSchema = &openapi.Schema{
+		Type:       openapi.Types{"object"},
+		Properties: map[string]*openapi.Schema{"deleted": {Type: openapi.Types{"integer"}, Minimum: new(float64)}},
+		Required:   []string{"deleted"},
+	}
+)
+
+// idempotencyKey documents the Idempotency-Key header of POST requests
+var idempotencyKey = openapi.HeaderParam(middleware.IdempotencyKeyHeader,
+	"Makes the request safe to retry: a retry with the same key and body gets the first response back")
+
+// userBodyContentTypes lists the formats users can be sent in besides JSON
+var userBodyContentTypes = response.ContentTypes([]response.Format{response.FormatXML, response.FormatMsgPack})
+
+// userFields documents the projection of user lists
+var userFields = openapi.QueryParam("fields", "Comma-separated fields to return for each user, all by default",
+	openapi.ArrayOf(openapi.StringEnum(models.UserFieldNames...)))
+
+// userIDs documents the IDs of DELETE /api/v1/users and its other versions
+var userIDs = func() *openapi.Parameter {
+	ids := openapi.ArrayOf(&openapi.Schema{Type: openapi.Types{"integer"}, Minimum: float(1)})
+	maxIDs := 1000
+	ids.MaxItems = &maxIDs
+	param := openapi.QueryParam("ids", "Comma-separated IDs of the users to delete", ids)
+	param.Required = true
+	return param
+}()
+
+// rootDocs documents the routes served outside the versioned API, keyed by
+// method and path
+var rootDocs = map[string]openapi.Route{
+	"GET /": {
+		Summary: "Welcome message",
+		Tag:     "health",
+		Results: []openapi.Result{{Status: http.StatusOK, Data: messageSchema}},
+	},
+	"GET /health": {
+		Summary: "Health check",
+		Tag:     "health",
+		Results: []openapi.Result{{Status: http.StatusOK, Data: messageSchema}},
+	},
+	"GET /openapi.json": {
+		Summary: "This OpenAPI document",
+		Tag:     "docs",
+		Results: []openapi.Result{{Status: http.StatusOK, Body: &openapi.Schema{Type: openapi.Types{"object"}}}},
+	},
+	"GET /docs": {
+		Summary: "Swagger UI for this document",
+		Tag:     "docs",
+		Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/html"}}},
+	},
+	"GET /docs/swagger-ui-bundle.js": {
+		Summary: "Swagger UI script",
+		Tag:     "docs",
+		Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/javascript"}}},
+	},
+	"GET /docs/swagger-ui.css": {
+		Summary: "Swagger UI stylesheet",
+		Tag:     "docs",
+		Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/css"}}},
+	},
+
+	"POST /graphql": {
+		Summary:     "Run GraphQL operations",
+		Description: "The body is one operation, or an array of up to 20 operations run as a batch.",
+		Tag:         "graphql",
+		Body:        &openapi.Schema{AnyOf: []*openapi.Schema{graphQLRequestSchema, openapi.ArrayOf(graphQLRequestSchema)}},
+		Params:      []*openapi.Parameter{openapi.HeaderParam("Accept-Language", "Language of validation messages")},
+		Results: []openapi.Result{
+			{Status: http.StatusOK, Body: &openapi.Schema{AnyOf: []*openapi.Schema{graphQLResponseSchema, openapi.ArrayOf(graphQLResponseSchema)}}},
+			{Status: http.StatusBadRequest, Description: "The body is not a GraphQL request", Body: graphQLResponseSchema},
+		},
+	},
+	"GET /graphql/schema": {
+		Summary: "GraphQL schema in SDL",
+		Tag:     "graphql",
+		Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/plain"}}},
+	},
+}
+
+// representation holds the types a version of the API reads and writes
+// users as
+type representation struct {
+	user          interface{}
+	users         interface{}
+	searchResults interface{}
+	bulkResults   interface{}
+	createRequest interface{}
+	updateRequest interface{}
+}
+
+// Representations of users in each version. The unversioned API serves any
+// of them, depending on the Accept header.
+var (
+	representationV1 = representation{
+		user:          models.User{},
+		users:         []models.User{},
+		searchResults: []models.UserSearchResult{},
+		bulkResults:   []models.BulkResult{},
+		createRequest: models.CreateUserRequest{},
+		updateRequest: models.UpdateUserRequest{},
+	}
+	representationV2 = representation{
+		user:          models.UserV2{},
+		users:         []models.UserV2{},
+		searchResults: []models.UserSearchResultV2{},
+		bulkResults:   []models.BulkResultV2{},
+		createRequest: models.CreateUserRequestV2{},
+		updateRequest: models.UpdateUserRequestV2{},
+	}
+	representationNegotiated = representation{
+		user:          openapi.AnyOf{models.User{}, models.UserV2{}},
+		users:         openapi.AnyOf{[]models.User{}, []models.UserV2{}},
+		searchResults: openapi.AnyOf{[]models.UserSearchResult{}, []models.UserSearchResultV2{}},
+		bulkResults:   openapi.AnyOf{[]models.BulkResult{}, []models.BulkResultV2{}},
+		createRequest: openapi.AnyOf{models.CreateUserRequest{}, models.CreateUserRequestV2{}},
+		updateRequest: openapi.AnyOf{models.UpdateUserRequest{}, models.UpdateUserRequestV2{}},
+	}
+)
+
+// routeDocs documents every route served by SetupRoutes, keyed by method
+// and path. SetupRoutes fails if a route is missing, so add new routes here
+// too. Operations of deprecated versions are marked as such.
+func routeDocs(deprecations versioning.Deprecations) map[string]openapi.Route {
+	docs := map[string]openapi.Route{}
+	for key, doc := range rootDocs {
+		docs[key] = doc
+	}
+	for _, version := range versioning.Supported {
+		rep := representationV1
+		if version == versioning.V2 {
+			rep = representationV2
+		}
+		_, deprecated := deprecations[version]
+		for key, doc := range apiDocs("/api/"+version, rep) {
+			doc.Deprecated = deprecated
+			doc.OperationIDSuffix = strings.ToUpper(version)
+			docs[key] = doc
+		}
+	}
+	for key, doc := range apiDocs("/api", representationNegotiated) {
+		docs[key] = doc
+	}
+	return docs
+}
+
+// apiDocs documents the routes of the API mounted at prefix, which reads
+// and writes users as rep
+func apiDocs(prefix string, rep representation) map[string]openapi.Route {
+	return map[string]openapi.Route{
+		"GET " + prefix + "/users": {
+			Summary: "List users",
+			Tag:     "users",
+			Query:   models.UserFilter{},
+			Params:  []*openapi.Parameter{userFields},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: openapi.AnyOf{rep.users, sparseUsersSchema}, Count: true}},
+		},
+		"GET " + prefix + "/users/export": {
+			Summary:     "Export users",
+			Description: "Streams every user matching the filters as a file download.",
+			Tag:         "users",
+			Query:       models.UserFilter{},
+			Params: []*openapi.Parameter{
+				openapi.QueryParam("format", "File format, csv by default", openapi.StringEnum("csv", "ndjson", "xlsx")),
+			},
+			Results: []openapi.Result{{
+				Status: http.StatusOK,
+				ContentTypes: []string{
+					"text/csv",
+					"application/x-ndjson",
+					"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+				},
+			}},
+		},
+		"POST " + prefix + "/users/bulk": {
+			Summary:     "Create, update and delete users in one transaction",
+			Description: "Either every operation is applied or none is; failures are reported per operation.",
+			Tag:         "users",
+			Body:        models.BulkRequest{},
+			Params:      []*openapi.Parameter{idempotencyKey, openapi.HeaderParam("Accept-Language", "Language of validation messages")},
+			Results:     []openapi.Result{{Status: http.StatusOK, Data: rep.bulkResults, Count: true}},
+		},
+		"DELETE " + prefix + "/users": {
+			Summary: "Delete several users",
+			Tag:     "users",
+			Params:  []*openapi.Parameter{userIDs},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: deletedSchema}},
+		},
+		"GET " + prefix + "/users/search": {
+			Summary: "Search users by name, email and address",
+			Tag:     "users",
+			Query:   models.UserSearchRequest{},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: rep.searchResults, Count: true}},
+		},
+		"GET " + prefix + "/users/events": {
+			Summary:     "Stream user events",
+			Description: "Server-sent events for every created, updated and deleted user. Reconnecting clients resume after Last-Event-ID.",
+			Tag:         "users",
+			Params: []*openapi.Parameter{
+				openapi.QueryParam("types", "Event types to receive, all by default",
+					openapi.ArrayOf(openapi.StringEnum(models.EventUserCreated, models.EventUserUpdated, models.EventUserDeleted))),
+				openapi.QueryParam("last_event_id", "Resume after this event when Last-Event-ID is not sent", &openapi.Schema{Type: openapi.Types{"integer"}, Minimum: new(float64)}),
+				openapi.HeaderParam("Last-Event-ID", "Resume after this event"),
+			},
+			Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/event-stream"}}},
+		},
+		"GET " + prefix + "/users/:id": {
+			Summary: "Get a user",
+			Tag:     "users",
+			Results: []openapi.Result{{Status: http.StatusOK, Data: rep.user}},
+		},
+		"GET " + prefix + "/users/:id/history": {
+			Summary: "List the changes made to a user",
+			Tag:     "audit",
+			Query:   models.AuditFilter{},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: []models.AuditEntry{}, Count: true}},
+		},
+		"POST " + prefix + "/users": {
+			Summary:          "Create a user",
+			Tag:              "users",
+			Body:             rep.createRequest,
+			BodyContentTypes: userBodyContentTypes,
+			Params:           []*openapi.Parameter{idempotencyKey},
+			Results:          []openapi.Result{{Status: http.StatusCreated, Data: rep.user}},
+		},
+		"PUT " + prefix + "/users/:id": {
+			Summary:          "Update a user",
+			Tag:              "users",
+			Body:             rep.updateRequest,
+			BodyContentTypes: userBodyContentTypes,
+			Results:          []openapi.Result{{Status: http.StatusOK, Data: rep.user}},
+		},
+		"DELETE " + prefix + "/users/:id": {
+			Summary: "Delete a user",
+			Tag:     "users",
+			Results: []openapi.Result{{Status: http.StatusOK}},
+		},
+
+		"GET " + prefix + "/audit": {
+			Summary: "List the changes made to all users",
+			Tag:     "audit",
+			Query:   models.AuditFilter{},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: []models.AuditEntry{}, Count: true}},
+		},
+
+		"GET " + prefix + "/webhooks": {
+			Summary: "List webhook subscriptions",
+			Tag:     "webhooks",
+			Results: []openapi.Result{{Status: http.StatusOK, Data: []models.WebhookSubscription{}, Count: true}},
+		},
+		"POST " + prefix + "/webhooks": {
+			Summary:     "Subscribe to user events",
+			Description: "The secret that signs deliveries is only returned here.",
+			Tag:         "webhooks",
+			Body:        models.WebhookRequest{},
+			Params:      []*openapi.Parameter{idempotencyKey},
+			Results:     []openapi.Result{{Status: http.StatusCreated, Data: models.WebhookSubscription{}}},
+		},
+		"GET " + prefix + "/webhooks/:id": {
+			Summary: "Get a webhook subscription",
+			Tag:     "webhooks",
+			Results: []openapi.Result{{Status: http.StatusOK, Data: models.WebhookSubscription{}}},
+		},
+		"PUT " + prefix + "/webhooks/:id": {
+			Summary: "Replace a webhook subscription",
+			Tag:     "webhooks",
+			Body:    models.WebhookRequest{},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: models.WebhookSubscription{}}},
+		},
+		"DELETE " + prefix + "/webhooks/:id": {
+			Summary: "Delete a webhook subscription",
+			Tag:     "webhooks",
+			Results: []openapi.Result{{Status: http.StatusOK}},
+		},
+		"GET " + prefix + "/webhooks/:id/deliveries": {
+			Summary: "List the deliveries of a webhook subscription",
+			Tag:     "webhooks",
+			Query:   models.WebhookDeliveryFilter{},
+			Results: []openapi.Result{{Status: http.StatusOK, Data: []models.WebhookDelivery{}, Count: true}},
+		},
+		"POST " + prefix + "/webhooks/:id/deliveries/:delivery_id/redeliver": {
+			Summary: "Queue a delivery to be sent again",
+			Tag:     "webhooks",
+			Params:  []*openapi.Parameter{idempotencyKey},
+			Results: []openapi.Result{{Status: http.StatusAccepted, Data: models.WebhookDelivery{}}},
+		},
+	}
+}
+
+func float(f float64) *float64 {
+	return &f
+}
@@ -0,0 +1,109 @@
+package router
+
+import (
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/idempotency"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/ratelimit"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/versioning"
+	"time"
+)
+
+// defaultIdempotencyTTL is how long idempotent responses are kept by default
+const defaultIdempotencyTTL = 24 * time.Hour
+
+// Option customizes a Router
+type Option func(r *Router)
+
+// WithIdempotency sets the store and retention used for Idempotency-Key handling
+func WithIdempotency(store idempotency.Store, ttl time.Duration) Option {
+	return func(r *Router) {
+		r.idempotencyStore = store
+		r.idempotencyTTL = ttl
+	}
+}
+
+// WithRateLimit enables per-client rate limiting, with limits looked up by
+// route group name
+func WithRateLimit(store ratelimit.Store, limits ratelimit.Limits) Option {
+	return func(r *Router) {
+		r.rateLimitStore = store
+		r.rateLimits = limits
+	}
+}
+
+// WithCORS allows browser requests from the configured origins
+func WithCORS(cfg middleware.CORSConfig) Option {
+	return func(r *Router) {
+		r.cors = cfg
+	}
+}
+
+// WithSecurityHeaders replaces the default security headers
+func WithSecurityHeaders(cfg middleware.SecurityHeadersConfig) Option {
+	return func(r *Router) {
+		r.securityHeaders = cfg
+	}
+}
+
+// WithCompression replaces the default response compression settings
+func WithCompression(cfg middleware.CompressionConfig) Option {
+	return func(r *Router) {
+		r.compression = cfg
+	}
+}
+
+// WithTenants serves every API request for the tenant it names, looked up
+// in tenants as cfg describes
+func WithTenants(tenants repository.TenantRepository, cfg middleware.TenantConfig) Option {
+	return func(r *Router) {
+		r.tenants = tenants
+		r.tenantConfig = cfg
+	}
+}
+
+// WithTrustedProxies sets the proxy addresses or CIDR ranges whose
+// X-Forwarded-For header is believed when working out the client IP
+func WithTrustedProxies(proxies []string) Option {
+	return func(r *Router) {
+		r.trustedProxies = proxies
+	}
+}
+
+// WithEventStream serves the live stream of user events
+func WithEventStream(eventStreamHandler *handlers.EventStreamHandler) Option {
+	return func(r *Router) {
+		r.eventStreamHandler = eventStreamHandler
+	}
+}
+
+// WithGraphQL serves the GraphQL endpoint
+func WithGraphQL(graphQLHandler *handlers.GraphQLHandler) Option {
+	return func(r *Router) {
+		r.graphQLHandler = graphQLHandler
+	}
+}
+
+// WithWebhooks serves the webhook subscription endpoints
+func WithWebhooks(webhookHandler *handlers.WebhookHandler) Option {
+	return func(r *Router) {
+		r.webhookHandler = webhookHandler
+	}
+}
+
+// WithOpenAPIValidation checks every request and response against the
+// OpenAPI document when enabled. It is meant for tests and development.
+func WithOpenAPIValidation(enabled bool) Option {
+	return func(r *Router) {
+		r.validateOpenAPI = enabled
+	}
+}
+
+// WithDeprecations announces the deprecation and sunset of API versions in
+// the responses they serve
+func WithDeprecations(deprecations versioning.Deprecations) Option {
+	return func(r *Router) {
+		r.deprecations = deprecations
+	}
+}
@@ -2,6 +2,16 @@ package router
 
 import (
 	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/idempotency"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/openapi"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/internal/ratelimit"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/versioning"
+	"gin-simple-app/pkg/response"
+	"slices"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -10,38 +20,201 @@ import (
 type Router struct {
 	userHandler   *handlers.UserHandler
 	healthHandler *handlers.HealthHandler
+	// eventStreamHandler, webhookHandler and graphQLHandler are nil unless
+	// their endpoints are enabled
+	eventStreamHandler *handlers.EventStreamHandler
+	webhookHandler     *handlers.WebhookHandler
+	graphQLHandler     *handlers.GraphQLHandler
+
+	idempotencyStore idempotency.Store
+	idempotencyTTL   time.Duration
+
+	rateLimitStore ratelimit.Store
+	rateLimits     ratelimit.Limits
+
+	// tenants resolves the tenant of API requests; when nil, every
+	// request is for the default tenant
+	tenants      repository.TenantRepository
+	tenantConfig middleware.TenantConfig
+
+	cors            middleware.CORSConfig
+	securityHeaders middleware.SecurityHeadersConfig
+	compression     middleware.CompressionConfig
+	trustedProxies  []string
+
+	// validateOpenAPI checks requests and responses against the OpenAPI
+	// document
+	validateOpenAPI bool
+
+	// deprecations announces the API versions going away
+	deprecations versioning.Deprecations
 }
 
-// NewRouter creates a new router with all handlers
-func NewRouter(userHandler *handlers.UserHandler, healthHandler *handlers.HealthHandler) *Router {
-	return &Router{
-		userHandler:   userHandler,
-		healthHandler: healthHandler,
+// NewRouter creates a new router with all handlers. Without options,
+// idempotency keys are kept in memory, requests are not rate limited,
+// cross-origin requests are refused, no proxy is trusted, every request is
+// for the default tenant, none of the event stream, webhook and GraphQL
+// endpoints are served, requests are not validated against the OpenAPI
+// document, no API version is deprecated and responses are compressed with
+// the default settings.
+func NewRouter(userHandler *handlers.UserHandler, healthHandler *handlers.HealthHandler, opts ...Option) *Router {
+	r := &Router{
+		userHandler:      userHandler,
+		healthHandler:    healthHandler,
+		idempotencyStore: idempotency.NewMemoryStore(),
+		idempotencyTTL:   defaultIdempotencyTTL,
+		securityHeaders:  middleware.DefaultSecurityHeadersConfig(),
+		compression:      middleware.DefaultCompressionConfig(),
+	}
+	for _, opt := range opts {
+		opt(r)
 	}
+	return r
 }
 
 // SetupRoutes configures all routes and returns a Gin engine
 func (r *Router) SetupRoutes() *gin.Engine {
 	// Create Gin router with default middleware (logger and recovery)
 	engine := gin.Default()
+	if err := engine.SetTrustedProxies(r.trustedProxies); err != nil {
+		panic("failed to set trusted proxies: " + err.Error())
+	}
+	// CORS runs before anything route specific so preflight requests are
+	// answered without being rate limited. Compression wraps everything
+	// else, so the rest of the chain only ever sees uncompressed responses.
+	engine.Use(
+		middleware.RequestID(),
+		middleware.Actor(),
+		middleware.SecurityHeaders(r.securityHeaders),
+		middleware.CORS(r.cors),
+		middleware.Compression(r.compression),
+	)
+
+	// The OpenAPI document is completed once every route is registered
+	doc := openapi.NewDocument(apiInfo)
+	if r.validateOpenAPI {
+		engine.Use(middleware.OpenAPIValidation(doc))
+	}
+
+	// Register custom binding validators used by the request models
+	if err := phone.RegisterValidator(); err != nil {
+		panic("failed to register phone validator: " + err.Error())
+	}
+	if err := response.SetupValidator(); err != nil {
+		panic("failed to set up validation messages: " + err.Error())
+	}
+
+	// Unknown routes get the same error format as everything else
+	engine.NoRoute(func(c *gin.Context) {
+		response.NotFound(c, "Route not found")
+	})
 
 	// Health and root endpoints
 	engine.GET("/", r.healthHandler.Root)
 	engine.GET("/health", r.healthHandler.HealthCheck)
 
-	// API v1 routes
-	v1 := engine.Group("/api/v1")
+	// API documentation
+	openAPIHandler := handlers.NewOpenAPIHandler(doc)
+	engine.GET("/openapi.json", openAPIHandler.Spec)
+	engine.GET("/docs", openAPIHandler.SwaggerUI)
+	engine.GET("/docs/swagger-ui-bundle.js", openAPIHandler.SwaggerUIScript)
+	engine.GET("/docs/swagger-ui.css", openAPIHandler.SwaggerUIStylesheet)
+
+	// GraphQL shares the user service with the REST API
+	if r.graphQLHandler != nil {
+		engine.POST("/graphql", r.tenant(), r.rateLimit("graphql"), r.graphQLHandler.Query)
+		engine.GET("/graphql/schema", r.graphQLHandler.Schema)
+	}
+
+	// The API is served at each version under its own path, and at the
+	// version negotiated from the Accept header under /api
+	for _, version := range versioning.Supported {
+		r.registerAPI(engine.Group("/api/"+version, middleware.APIVersion(version, r.deprecations), r.tenant()))
+	}
+	r.registerAPI(engine.Group("/api", middleware.APIVersion("", r.deprecations), r.tenant()))
+
+	if err := doc.AddRoutes(engine.Routes(), routeDocs(r.deprecations)); err != nil {
+		panic("failed to build OpenAPI document: " + err.Error())
+	}
+
+	return engine
+}
+
+// registerAPI registers the API routes on api. Rate limiting and content
+// negotiation run before idempotency handling, so a rejected request never
+// gets stored as the response for its key. Rate limits are shared by every
+// version.
+//
+// Responses are written in the format negotiated from the Accept header,
+// except for exports and the event stream, which have formats of their own.
+// User bodies can be sent in any format; other bodies are JSON only.
+func (r *Router) registerAPI(api *gin.RouterGroup) {
+	idempotent := middleware.Idempotency(r.idempotencyStore, r.idempotencyTTL)
+	negotiate := middleware.ContentNegotiation()
+	negotiateUser := middleware.ContentNegotiation(response.FormatJSON, response.FormatXML, response.FormatMsgPack)
+
+	// User routes
+	users := api.Group("/users")
 	{
-		// User routes
-		users := v1.Group("/users")
-		{
-			users.GET("", r.userHandler.GetUsers)
-			users.GET("/:id", r.userHandler.GetUserByID)
-			users.POST("", r.userHandler.CreateUser)
-			users.PUT("/:id", r.userHandler.UpdateUser)
-			users.DELETE("/:id", r.userHandler.DeleteUser)
+		// Exports and bulk operations touch many users per request, so
+		// they are limited separately from single-user requests. Their
+		// large bodies may be sent gzip compressed.
+		decompress := middleware.DecompressRequest(r.compression.MaxDecompressedSize)
+		bulk := users.Group("", r.rateLimit("users_bulk"), decompress)
+		bulk.GET("/export", r.userHandler.ExportUsers)
+		bulk.POST("/bulk", negotiate, idempotent, r.userHandler.BulkUsers)
+		bulk.DELETE("", negotiate, r.userHandler.DeleteUsers)
+
+		single := users.Group("", r.rateLimit("users"))
+		single.GET("", negotiate, r.userHandler.GetUsers)
+		single.GET("/search", negotiate, r.userHandler.SearchUsers)
+		if r.eventStreamHandler != nil {
+			single.GET("/events", r.eventStreamHandler.StreamEvents)
 		}
+		single.GET("/:id", negotiate, r.userHandler.GetUserByID)
+		single.GET("/:id/history", negotiate, r.userHandler.GetUserHistory)
+		single.POST("", negotiateUser, idempotent, r.userHandler.CreateUser)
+		single.PUT("/:id", negotiateUser, r.userHandler.UpdateUser)
+		single.DELETE("/:id", negotiate, r.userHandler.DeleteUser)
 	}
 
-	return engine
+	// Audit log of user changes
+	api.GET("/audit", r.rateLimit("audit"), negotiate, r.userHandler.GetAuditLog)
+
+	// Webhook subscriptions
+	if r.webhookHandler != nil {
+		webhooks := api.Group("/webhooks", r.rateLimit("webhooks"), negotiate, idempotent)
+		webhooks.GET("", r.webhookHandler.GetWebhooks)
+		webhooks.POST("", r.webhookHandler.CreateWebhook)
+		webhooks.GET("/:id", r.webhookHandler.GetWebhookByID)
+		webhooks.PUT("/:id", r.webhookHandler.UpdateWebhook)
+		webhooks.DELETE("/:id", r.webhookHandler.DeleteWebhook)
+		webhooks.GET("/:id/deliveries", r.webhookHandler.GetDeliveries)
+		webhooks.POST("/:id/deliveries/:delivery_id/redeliver", r.webhookHandler.Redeliver)
+	}
+}
+
+// tenant returns the middleware resolving the tenant of a request, or a
+// no-op if the router has no tenants
+func (r *Router) tenant() gin.HandlerFunc {
+	if r.tenants == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.Tenant(r.tenants, r.tenantConfig)
+}
+
+// RateLimitGroups lists the route groups that have rate limits of their own
+var RateLimitGroups = []string{"users", "users_bulk", "audit", "webhooks", "graphql"}
+
+// rateLimit returns the rate limiting middleware for the route group named
+// group, or a no-op if rate limiting is disabled. It panics if group is not
+// in RateLimitGroups, so every group can be configured.
+func (r *Router) rateLimit(group string) gin.HandlerFunc {
+	if !slices.Contains(RateLimitGroups, group) {
+		panic("router: rate limit group " + group + " is missing from RateLimitGroups")
+	}
+	if r.rateLimitStore == nil {
+		return func(c *gin.Context) { c.Next() }
+	}
+	return middleware.RateLimit(r.rateLimitStore, group, r.rateLimits.For(group))
 }
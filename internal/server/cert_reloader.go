@@ -0,0 +1,144 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// CertReloader holds the server certificate and client CA pool, and
+// reloads them from disk without a restart
+type CertReloader struct {
+	certFile     string
+	keyFile      string
+	clientCAFile string
+
+	cert      *tls.Certificate
+	clientCAs *x509.CertPool
+	mutex     sync.RWMutex
+}
+
+// NewCertReloader loads the certificate, key and optional client CA bundle
+func NewCertReloader(certFile, keyFile, clientCAFile string) (*CertReloader, error) {
+	r := &CertReloader{
+		certFile:     certFile,
+		keyFile:      keyFile,
+		clientCAFile: clientCAFile,
+	}
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Reload reads the files again. On error the previous certificates stay in use.
+func (r *CertReloader) Reload() error {
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+
+	var clientCAs *x509.CertPool
+	if r.clientCAFile != "" {
+		pem, err := os.ReadFile(r.clientCAFile)
+		if err != nil {
+			return fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		clientCAs = x509.NewCertPool()
+		if !clientCAs.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificates found in client CA file %s", r.clientCAFile)
+		}
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.cert = &cert
+	r.clientCAs = clientCAs
+	return nil
+}
+
+// GetCertificate returns the current server certificate, for tls.Config
+func (r *CertReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.cert, nil
+}
+
+// ClientCAs returns the current client CA pool, or nil without mTLS
+func (r *CertReloader) ClientCAs() *x509.CertPool {
+	r.mutex.RLock()
+	defer r.mutex.RUnlock()
+	return r.clientCAs
+}
+
+// Watch reloads the certificates whenever one of the files changes or the
+// process receives SIGHUP, until stop is closed. The directories are
+// watched rather than the files, so certificates replaced by renaming a new
+// file into place, as cert-manager and Kubernetes secrets do, are picked up.
+func (r *CertReloader) Watch(stop <-chan struct{}) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to watch certificates: %w", err)
+	}
+	dirs := make(map[string]bool)
+	for _, file := range []string{r.certFile, r.keyFile, r.clientCAFile} {
+		if file == "" {
+			continue
+		}
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		if err := watcher.Add(dir); err != nil {
+			watcher.Close()
+			return fmt.Errorf("failed to watch %s: %w", dir, err)
+		}
+		dirs[dir] = true
+	}
+
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+
+	go func() {
+		defer watcher.Close()
+		defer signal.Stop(hangup)
+		for {
+			select {
+			case <-stop:
+				return
+			case <-hangup:
+				r.reloadAndLog("SIGHUP")
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Rename) {
+					r.reloadAndLog(event.Name + " changed")
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				log.Printf("Certificate watcher error: %v", err)
+			}
+		}
+	}()
+	return nil
+}
+
+func (r *CertReloader) reloadAndLog(reason string) {
+	if err := r.Reload(); err != nil {
+		// Files are often written in several steps; the next event retries
+		log.Printf("Keeping current TLS certificate after %s: %v", reason, err)
+		return
+	}
+	log.Printf("Reloaded TLS certificate after %s", reason)
+}
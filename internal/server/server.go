@@ -0,0 +1,133 @@
+package server
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"gin-simple-app/internal/config"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// Server serves the API over plain HTTP, or over TLS when a certificate is
+// configured. HTTP/2 is negotiated over TLS, and spoken in cleartext (h2c)
+// by clients that ask for it, unless disabled.
+type Server struct {
+	httpServer *http.Server
+	reloader   *CertReloader
+	stop       chan struct{}
+}
+
+// New creates a server for handler. Certificates are loaded here, so a
+// missing or broken certificate fails fast instead of on the first request.
+func New(handler http.Handler, cfg config.ServerConfig) (*Server, error) {
+	s := &Server{
+		httpServer: &http.Server{
+			Addr:              ":" + cfg.Port,
+			Handler:           handler,
+			ReadHeaderTimeout: 10 * time.Second,
+		},
+		stop: make(chan struct{}),
+	}
+	if !cfg.HTTP2 {
+		// A non-nil, empty map keeps net/http from enabling HTTP/2
+		s.httpServer.TLSNextProto = make(map[string]func(*http.Server, *tls.Conn, http.Handler))
+	}
+
+	if cfg.TLSCertFile == "" && cfg.TLSKeyFile == "" {
+		if cfg.TLSClientCAFile != "" {
+			return nil, errors.New("TLS_CLIENT_CA_FILE requires TLS_CERT_FILE and TLS_KEY_FILE")
+		}
+		if cfg.HTTP2 {
+			s.httpServer.Handler = h2c.NewHandler(handler, &http2.Server{})
+		}
+		return s, nil
+	}
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return nil, errors.New("TLS_CERT_FILE and TLS_KEY_FILE must be set together")
+	}
+
+	reloader, err := NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSClientCAFile)
+	if err != nil {
+		return nil, err
+	}
+	s.reloader = reloader
+
+	nextProtos := []string{"http/1.1"}
+	if cfg.HTTP2 {
+		nextProtos = []string{"h2", "http/1.1"}
+	}
+	s.httpServer.TLSConfig = &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		NextProtos: nextProtos,
+		// Built per handshake so a reloaded client CA bundle applies to new
+		// connections right away
+		GetConfigForClient: func(*tls.ClientHelloInfo) (*tls.Config, error) {
+			tlsConfig := &tls.Config{
+				MinVersion:     tls.VersionTLS12,
+				NextProtos:     nextProtos,
+				GetCertificate: reloader.GetCertificate,
+			}
+			if clientCAs := reloader.ClientCAs(); clientCAs != nil {
+				tlsConfig.ClientCAs = clientCAs
+				tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+			}
+			return tlsConfig, nil
+		},
+	}
+	return s, nil
+}
+
+// TLS reports whether the server serves TLS
+func (s *Server) TLS() bool {
+	return s.reloader != nil
+}
+
+// TLSConfig returns the TLS configuration of the server, or nil without
+// TLS. Other listeners can share it to serve the same reloaded certificates.
+func (s *Server) TLSConfig() *tls.Config {
+	return s.httpServer.TLSConfig
+}
+
+// ListenAndServe listens on the configured port and serves until Shutdown
+func (s *Server) ListenAndServe() error {
+	listener, err := net.Listen("tcp", s.httpServer.Addr)
+	if err != nil {
+		return err
+	}
+	return s.Serve(listener)
+}
+
+// Serve serves connections from listener until Shutdown. With TLS, the
+// certificates are reloaded whenever their files change or on SIGHUP.
+func (s *Server) Serve(listener net.Listener) error {
+	if !s.TLS() {
+		return s.httpServer.Serve(listener)
+	}
+	if err := s.reloader.Watch(s.stop); err != nil {
+		return err
+	}
+	return s.httpServer.ServeTLS(listener, "", "")
+}
+
+// Reload reloads the certificates from disk right away
+func (s *Server) Reload() error {
+	if !s.TLS() {
+		return nil
+	}
+	return s.reloader.Reload()
+}
+
+// Shutdown stops the server gracefully, waiting for open requests until ctx ends
+func (s *Server) Shutdown(ctx context.Context) error {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	return s.httpServer.Shutdown(ctx)
+}
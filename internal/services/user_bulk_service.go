@@ -0,0 +1,279 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrBulkFailed is returned when at least one bulk operation is invalid.
+// No changes are applied in that case and the per-operation results say why.
+var ErrBulkFailed = errors.New("bulk operation failed")
+
+// MissingUsersError is returned by DeleteUsers when some of the IDs do not exist
+type MissingUsersError struct {
+	IDs []uint
+}
+
+func (e *MissingUsersError) Error() string {
+	ids := make([]string, len(e.IDs))
+	for i, id := range e.IDs {
+		ids[i] = strconv.FormatUint(uint64(id), 10)
+	}
+	return "users not found: " + strings.Join(ids, ", ")
+}
+
+// pendingOwner marks an email claimed by a user created earlier in the same batch
+const pendingOwner = ^uint(0)
+
+// bulkPlan is the validated set of writes for a bulk request
+type bulkPlan struct {
+	creates     []models.User
+	createIndex []int
+	updates     []models.User
+	updateIndex []int
+	// updateBefore holds each updated user as it was before that operation
+	updateBefore []models.User
+	deletes      []uint
+	deletedIDs   map[uint]bool
+	// deleteBefore holds each deleted user as stored before the batch
+	deleteBefore []models.User
+}
+
+// BulkUsers runs a list of create, update and delete operations in a single
+// transaction. Every operation is validated against the state left by the
+// operations before it; if any of them fails nothing is written.
+func (s *UserServiceImpl) BulkUsers(ctx context.Context, ops []models.BulkOperation) ([]models.BulkResult, error) {
+	results := make([]models.BulkResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BulkResult{Index: i, Op: op.Op, ID: op.ID}
+	}
+
+	err := s.uow.Transaction(ctx, func(repos repository.Repositories) error {
+		plan, err := planBulk(repos.Users, ops, results)
+		if err != nil {
+			return err
+		}
+		return executeBulk(ctx, repos, plan, results)
+	})
+	if err != nil {
+		return results, err
+	}
+	return results, nil
+}
+
+// planBulk validates every operation and records failures in results
+func planBulk(repo repository.UserRepository, ops []models.BulkOperation, results []models.BulkResult) (*bulkPlan, error) {
+	var ids []uint
+	for _, op := range ops {
+		if op.Op != models.BulkOpCreate {
+			ids = append(ids, op.ID)
+		}
+	}
+	existing, err := repo.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	live := make(map[uint]models.User, len(existing))
+	stored := make(map[uint]models.User, len(existing))
+	for _, user := range existing {
+		live[user.ID] = user
+		stored[user.ID] = user
+	}
+
+	// emails tracks ownership changes made by earlier operations in the batch;
+	// a zero owner means the email has been released
+	emails := make(map[string]uint)
+	ownerOf := func(email string) (uint, error) {
+		if owner, ok := emails[email]; ok {
+			return owner, nil
+		}
+		user, err := repo.GetByEmail(email)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		return user.ID, nil
+	}
+
+	plan := &bulkPlan{deletedIDs: make(map[uint]bool)}
+	failed := false
+	fail := func(i int, msg string) {
+		results[i].Status = models.BulkStatusFailed
+		results[i].Error = msg
+		failed = true
+	}
+
+	for i, op := range ops {
+		switch op.Op {
+		case models.BulkOpCreate:
+			owner, err := ownerOf(op.Create.Email)
+			if err != nil {
+				return nil, err
+			}
+			if owner != 0 {
+				fail(i, "user with this email already exists")
+				continue
+			}
+			user := models.User{
+				Name:  op.Create.Name,
+				Email: op.Create.Email,
+			}
+			setAddress(&user, op.Create.Address, op.Create.PostalAddress)
+			if err := setPhone(&user, op.Create.Phone); err != nil {
+				fail(i, err.Error())
+				continue
+			}
+			emails[op.Create.Email] = pendingOwner
+			plan.creates = append(plan.creates, user)
+			plan.createIndex = append(plan.createIndex, i)
+
+		case models.BulkOpUpdate:
+			user, ok := live[op.ID]
+			if !ok {
+				fail(i, "user not found")
+				continue
+			}
+			before := user
+			if err := setPhone(&user, op.Update.Phone); err != nil {
+				fail(i, err.Error())
+				continue
+			}
+			if op.Update.Email != user.Email {
+				owner, err := ownerOf(op.Update.Email)
+				if err != nil {
+					return nil, err
+				}
+				if owner != 0 && owner != op.ID {
+					fail(i, "user with this email already exists")
+					continue
+				}
+				emails[user.Email] = 0
+				emails[op.Update.Email] = op.ID
+			}
+			user.Name = op.Update.Name
+			user.Email = op.Update.Email
+			setAddress(&user, op.Update.Address, op.Update.PostalAddress)
+			live[op.ID] = user
+			plan.updates = append(plan.updates, user)
+			plan.updateIndex = append(plan.updateIndex, i)
+			plan.updateBefore = append(plan.updateBefore, before)
+
+		case models.BulkOpDelete:
+			user, ok := live[op.ID]
+			if !ok {
+				fail(i, "user not found")
+				continue
+			}
+			delete(live, op.ID)
+			emails[user.Email] = 0
+			plan.deletes = append(plan.deletes, op.ID)
+			plan.deletedIDs[op.ID] = true
+			plan.deleteBefore = append(plan.deleteBefore, stored[op.ID])
+			results[i].Status = models.BulkStatusDeleted
+
+		default:
+			fail(i, fmt.Sprintf("unknown operation %q", op.Op))
+		}
+	}
+
+	if failed {
+		for i := range results {
+			if results[i].Status != models.BulkStatusFailed {
+				results[i].Status = models.BulkStatusSkipped
+			}
+		}
+		return nil, ErrBulkFailed
+	}
+	return plan, nil
+}
+
+// executeBulk applies a validated plan and records it in the audit log and
+// event outbox.
+// Deletes run first so that released emails can be reused, then updates in
+// request order, then all creates in one batch insert.
+func executeBulk(ctx context.Context, repos repository.Repositories, plan *bulkPlan, results []models.BulkResult) error {
+	repo := repos.Users
+	if _, err := repo.DeleteByIDs(plan.deletes); err != nil {
+		return err
+	}
+	var changes []userChange
+	for n := range plan.deleteBefore {
+		changes = append(changes, userChange{models.AuditActionDelete, plan.deletes[n], &plan.deleteBefore[n], nil})
+	}
+
+	for n := range plan.updates {
+		user := plan.updates[n]
+		i := plan.updateIndex[n]
+		results[i].Status = models.BulkStatusUpdated
+		if plan.deletedIDs[user.ID] {
+			// Deleted later in the same batch, so the update has no lasting effect
+			continue
+		}
+		if err := repo.Update(&user); err != nil {
+			return err
+		}
+		results[i].User = &user
+		changes = append(changes, userChange{models.AuditActionUpdate, user.ID, &plan.updateBefore[n], &user})
+	}
+
+	if err := repo.CreateBatch(plan.creates); err != nil {
+		return err
+	}
+	for n := range plan.creates {
+		i := plan.createIndex[n]
+		results[i].ID = plan.creates[n].ID
+		results[i].Status = models.BulkStatusCreated
+		results[i].User = &plan.creates[n]
+		changes = append(changes, userChange{models.AuditActionCreate, plan.creates[n].ID, nil, &plan.creates[n]})
+	}
+	return record(ctx, repos, changes...)
+}
+
+// DeleteUsers soft deletes all users with the given IDs in a single
+// transaction. If any ID does not exist nothing is deleted.
+func (s *UserServiceImpl) DeleteUsers(ctx context.Context, ids []uint) (int64, error) {
+	var deleted int64
+	err := s.uow.Transaction(ctx, func(repos repository.Repositories) error {
+		users, err := repos.Users.GetByIDs(ids)
+		if err != nil {
+			return err
+		}
+
+		found := make(map[uint]bool, len(users))
+		for _, user := range users {
+			found[user.ID] = true
+		}
+		var missing []uint
+		for _, id := range ids {
+			if !found[id] {
+				missing = append(missing, id)
+			}
+		}
+		if len(missing) > 0 {
+			return &MissingUsersError{IDs: missing}
+		}
+
+		deleted, err = repos.Users.DeleteByIDs(ids)
+		if err != nil {
+			return err
+		}
+		changes := make([]userChange, len(users))
+		for i := range users {
+			changes[i] = userChange{models.AuditActionDelete, users[i].ID, &users[i], nil}
+		}
+		return record(ctx, repos, changes...)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return deleted, nil
+}
@@ -1,43 +1,124 @@
 package services
 
 import (
+	"context"
 	"errors"
+	"gin-simple-app/internal/address"
+	"gin-simple-app/internal/audit"
+	"gin-simple-app/internal/events"
 	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/phone"
 	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"strings"
 
 	"gorm.io/gorm"
 )
 
-// UserService defines the interface for user business logic
+// UserService defines the interface for user business logic. Every method
+// acts on the users of the tenant of the context passed in.
 type UserService interface {
-	GetAllUsers() ([]models.User, error)
-	GetUserByID(id uint) (*models.User, error)
-	CreateUser(req models.CreateUserRequest) (*models.User, error)
-	UpdateUser(id uint, req models.UpdateUserRequest) (*models.User, error)
-	DeleteUser(id uint) error
-	GetUserCount() (int64, error)
+	GetAllUsers(ctx context.Context) ([]models.User, error)
+	ListUsers(ctx context.Context, filter models.UserFilter, fields models.UserFields) ([]models.User, error)
+	ListUsersPage(ctx context.Context, filter models.UserFilter, afterID uint, limit int) (*models.UserPage, error)
+	ExportUsers(ctx context.Context, filter models.UserFilter, fn func(user *models.User) error) error
+	GetUserByID(ctx context.Context, id uint) (*models.User, error)
+	CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error)
+	UpdateUser(ctx context.Context, id uint, req models.UpdateUserRequest) (*models.User, error)
+	DeleteUser(ctx context.Context, id uint) error
+	GetUserCount(ctx context.Context) (int64, error)
+	BulkUsers(ctx context.Context, ops []models.BulkOperation) ([]models.BulkResult, error)
+	DeleteUsers(ctx context.Context, ids []uint) (int64, error)
+	SearchUsers(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error)
+	GetUserHistory(ctx context.Context, id uint, filter models.AuditFilter) ([]models.AuditEntry, error)
+	ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error)
 }
 
-// UserServiceImpl implements UserService
+// UserServiceImpl implements UserService. Every create, update and delete
+// is recorded in the audit log and published to the event outbox, in the
+// same transaction as the change; the actor and request ID are taken from
+// the context passed in.
 type UserServiceImpl struct {
-	userRepo repository.UserRepository
+	userRepo  repository.UserRepository
+	auditRepo repository.AuditRepository
+	uow       repository.UnitOfWork
 }
 
-// NewUserService creates a new user service
-func NewUserService(userRepo repository.UserRepository) UserService {
-	return &UserServiceImpl{
-		userRepo: userRepo,
+// Option customizes a UserServiceImpl
+type Option func(s *UserServiceImpl)
+
+// WithAuditLog records changes in auditRepo. uow must span the user
+// repository, auditRepo and the event outbox.
+func WithAuditLog(auditRepo repository.AuditRepository, uow repository.UnitOfWork) Option {
+	return func(s *UserServiceImpl) {
+		s.auditRepo = auditRepo
+		s.uow = uow
+	}
+}
+
+// NewUserService creates a new user service. Without WithAuditLog, changes
+// are neither audited nor published as events.
+func NewUserService(userRepo repository.UserRepository, opts ...Option) UserService {
+	s := &UserServiceImpl{
+		userRepo:  userRepo,
+		auditRepo: repository.DiscardAuditRepository{},
+		uow:       repository.NewUserUnitOfWork(userRepo),
+	}
+	for _, opt := range opts {
+		opt(s)
 	}
+	return s
+}
+
+// users returns the user repository scoped to the tenant of ctx
+func (s *UserServiceImpl) users(ctx context.Context) repository.UserRepository {
+	return s.userRepo.WithContext(ctx)
 }
 
 // GetAllUsers returns all users
-func (s *UserServiceImpl) GetAllUsers() ([]models.User, error) {
-	return s.userRepo.GetAll()
+func (s *UserServiceImpl) GetAllUsers(ctx context.Context) ([]models.User, error) {
+	return s.users(ctx).GetAll()
+}
+
+// ListUsers returns all users matching the filter. With a projection, only
+// the requested fields are loaded and the others are left zero.
+func (s *UserServiceImpl) ListUsers(ctx context.Context, filter models.UserFilter, fields models.UserFields) ([]models.User, error) {
+	return s.users(ctx).List(filter, fields)
+}
+
+// ListUsersPage returns up to limit users matching the filter with IDs above
+// afterID, in ID order, along with how many users match in total
+func (s *UserServiceImpl) ListUsersPage(ctx context.Context, filter models.UserFilter, afterID uint, limit int) (*models.UserPage, error) {
+	users := s.users(ctx)
+	// Fetching one user more than the page holds tells whether another follows
+	page, err := users.ListAfter(filter, afterID, limit+1)
+	if err != nil {
+		return nil, err
+	}
+	total, err := users.CountMatching(filter)
+	if err != nil {
+		return nil, err
+	}
+	hasNextPage := len(page) > limit
+	if hasNextPage {
+		page = page[:limit]
+	}
+	return &models.UserPage{Users: page, Total: total, HasNextPage: hasNextPage}, nil
+}
+
+// ExportUsers streams all users matching the filter to fn
+func (s *UserServiceImpl) ExportUsers(ctx context.Context, filter models.UserFilter, fn func(user *models.User) error) error {
+	return s.users(ctx).Stream(filter, fn)
+}
+
+// SearchUsers returns users ranked by relevance to a free-text query
+func (s *UserServiceImpl) SearchUsers(ctx context.Context, query string, limit int) ([]models.UserSearchResult, error) {
+	return s.users(ctx).Search(query, limit)
 }
 
 // GetUserByID returns a user by ID
-func (s *UserServiceImpl) GetUserByID(id uint) (*models.User, error) {
-	user, err := s.userRepo.GetByID(id)
+func (s *UserServiceImpl) GetUserByID(ctx context.Context, id uint) (*models.User, error) {
+	user, err := s.users(ctx).GetByID(id)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
@@ -48,76 +129,183 @@ func (s *UserServiceImpl) GetUserByID(id uint) (*models.User, error) {
 }
 
 // CreateUser creates a new user
-func (s *UserServiceImpl) CreateUser(req models.CreateUserRequest) (*models.User, error) {
-	// Check if user with email already exists
-	existingUser, err := s.userRepo.GetByEmail(req.Email)
-	if err == nil && existingUser != nil {
-		return nil, errors.New("user with this email already exists")
-	}
-	
+func (s *UserServiceImpl) CreateUser(ctx context.Context, req models.CreateUserRequest) (*models.User, error) {
 	user := &models.User{
-		Name:    req.Name,
-		Email:   req.Email,
-		Phone:   &req.Phone,
-		Address: req.Address,
+		Name:  req.Name,
+		Email: req.Email,
 	}
-	
-	err = s.userRepo.Create(user)
+	err := s.uow.Transaction(ctx, func(repos repository.Repositories) error {
+		// Check if user with email already exists
+		existingUser, err := repos.Users.GetByEmail(req.Email)
+		if err == nil && existingUser != nil {
+			return errors.New("user with this email already exists")
+		}
+
+		setAddress(user, req.Address, req.PostalAddress)
+		if err := setPhone(user, req.Phone); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Create(user); err != nil {
+			return err
+		}
+		return record(ctx, repos, userChange{models.AuditActionCreate, user.ID, nil, user})
+	})
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return user, nil
 }
 
 // UpdateUser updates an existing user
-func (s *UserServiceImpl) UpdateUser(id uint, req models.UpdateUserRequest) (*models.User, error) {
-	// Check if user exists
-	user, err := s.userRepo.GetByID(id)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("user not found")
+func (s *UserServiceImpl) UpdateUser(ctx context.Context, id uint, req models.UpdateUserRequest) (*models.User, error) {
+	var user *models.User
+	err := s.uow.Transaction(ctx, func(repos repository.Repositories) error {
+		// Check if user exists
+		var err error
+		user, err = repos.Users.GetByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
 		}
-		return nil, err
-	}
-	
-	// Check if email is already taken by another user
-	if req.Email != user.Email {
-		existingUser, err := s.userRepo.GetByEmail(req.Email)
-		if err == nil && existingUser != nil && existingUser.ID != id {
-			return nil, errors.New("user with this email already exists")
+		before := *user
+
+		// Check if email is already taken by another user
+		if req.Email != user.Email {
+			existingUser, err := repos.Users.GetByEmail(req.Email)
+			if err == nil && existingUser != nil && existingUser.ID != id {
+				return errors.New("user with this email already exists")
+			}
 		}
-	}
-	
-	// Update user fields
-	user.Name = req.Name
-	user.Email = req.Email
-	user.Phone = &req.Phone
-	user.Address = req.Address
-	
-	err = s.userRepo.Update(user)
+
+		// Update user fields
+		user.Name = req.Name
+		user.Email = req.Email
+		setAddress(user, req.Address, req.PostalAddress)
+		if err := setPhone(user, req.Phone); err != nil {
+			return err
+		}
+
+		if err := repos.Users.Update(user); err != nil {
+			return err
+		}
+		return record(ctx, repos, userChange{models.AuditActionUpdate, id, &before, user})
+	})
 	if err != nil {
 		return nil, err
 	}
-	
+
 	return user, nil
 }
 
 // DeleteUser deletes a user by ID
-func (s *UserServiceImpl) DeleteUser(id uint) error {
-	// Check if user exists
-	_, err := s.userRepo.GetByID(id)
-	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return errors.New("user not found")
+func (s *UserServiceImpl) DeleteUser(ctx context.Context, id uint) error {
+	return s.uow.Transaction(ctx, func(repos repository.Repositories) error {
+		// Check if user exists
+		user, err := repos.Users.GetByID(id)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errors.New("user not found")
+			}
+			return err
 		}
+
+		if err := repos.Users.Delete(id); err != nil {
+			return err
+		}
+		return record(ctx, repos, userChange{models.AuditActionDelete, id, user, nil})
+	})
+}
+
+// userChange is a single change to a user, as recorded by record
+type userChange struct {
+	action string
+	userID uint
+	before *models.User
+	after  *models.User
+}
+
+// record appends an audit entry and an outbox event for each change
+func record(ctx context.Context, repos repository.Repositories, changes ...userChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	entries := make([]models.AuditEntry, len(changes))
+	evts := make([]models.Event, len(changes))
+	for i, change := range changes {
+		entries[i] = audit.NewEntry(ctx, change.action, change.userID, change.before, change.after)
+		snapshot := change.after
+		if snapshot == nil {
+			snapshot = change.before
+		}
+		evts[i] = events.NewUserEvent(entries[i], snapshot)
+	}
+	if err := repos.Audit.Append(entries...); err != nil {
 		return err
 	}
-	
-	return s.userRepo.Delete(id)
+	return repos.Outbox.Append(evts...)
+}
+
+// GetUserHistory returns the audit entries for a user, newest first.
+// Deleted users keep their history.
+func (s *UserServiceImpl) GetUserHistory(ctx context.Context, id uint, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	filter.UserID = id
+	filter.TenantID = tenant.IDFromContext(ctx)
+	entries, err := s.auditRepo.List(filter)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		if _, err := s.GetUserByID(ctx, id); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+// ListAuditEntries returns the audit entries of the tenant matching the
+// filter, newest first
+func (s *UserServiceImpl) ListAuditEntries(ctx context.Context, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	filter.TenantID = tenant.IDFromContext(ctx)
+	return s.auditRepo.List(filter)
 }
 
 // GetUserCount returns the total number of users
-func (s *UserServiceImpl) GetUserCount() (int64, error) {
-	return s.userRepo.Count()
+func (s *UserServiceImpl) GetUserCount(ctx context.Context) (int64, error) {
+	return s.users(ctx).Count()
+}
+
+// setPhone stores the E.164 form of a phone number along with the original input
+func setPhone(user *models.User, input string) error {
+	normalized, err := phone.Normalize(input)
+	if err != nil {
+		return err
+	}
+	original := input
+	user.Phone = &normalized
+	user.PhoneOriginal = &original
+	return nil
+}
+
+// setAddress stores both address representations. A structured address wins
+// and is also formatted into the deprecated free-text field; a free-text
+// address alone is kept as given and parsed into a structured one.
+func setAddress(user *models.User, text *string, postal *models.Address) {
+	switch {
+	case postal != nil:
+		structured := *postal
+		formatted := structured.String()
+		user.PostalAddress = &structured
+		user.Address = &formatted
+	case text != nil && strings.TrimSpace(*text) != "":
+		parsed := address.Parse(*text)
+		user.Address = text
+		user.PostalAddress = &parsed
+	default:
+		user.Address = text
+		user.PostalAddress = nil
+	}
 }
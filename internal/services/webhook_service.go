@@ -0,0 +1,187 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"gin-simple-app/internal/webhooks"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WebhookService defines the interface for managing webhook subscriptions.
+// Every method acts on the subscriptions of the tenant of the context passed in.
+type WebhookService interface {
+	CreateWebhook(ctx context.Context, req models.WebhookRequest) (*models.WebhookSubscription, error)
+	ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error)
+	GetWebhook(ctx context.Context, id uint) (*models.WebhookSubscription, error)
+	UpdateWebhook(ctx context.Context, id uint, req models.WebhookRequest) (*models.WebhookSubscription, error)
+	DeleteWebhook(ctx context.Context, id uint) error
+	ListDeliveries(ctx context.Context, id uint, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error)
+	Redeliver(ctx context.Context, id, deliveryID uint) (*models.WebhookDelivery, error)
+}
+
+// WebhookServiceImpl implements WebhookService. Subscription secrets are
+// only returned by CreateWebhook.
+type WebhookServiceImpl struct {
+	webhookRepo repository.WebhookRepository
+	targets     webhooks.TargetPolicy
+}
+
+// WebhookOption customizes a WebhookServiceImpl
+type WebhookOption func(s *WebhookServiceImpl)
+
+// WithTargetPolicy sets which URLs subscriptions may deliver to. Without
+// it, only https:// URLs outside the server's networks are accepted.
+func WithTargetPolicy(targets webhooks.TargetPolicy) WebhookOption {
+	return func(s *WebhookServiceImpl) {
+		s.targets = targets
+	}
+}
+
+// NewWebhookService creates a new webhook service
+func NewWebhookService(webhookRepo repository.WebhookRepository, opts ...WebhookOption) WebhookService {
+	s := &WebhookServiceImpl{
+		webhookRepo: webhookRepo,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// CreateWebhook creates a subscription, generating a secret if none is given
+func (s *WebhookServiceImpl) CreateWebhook(ctx context.Context, req models.WebhookRequest) (*models.WebhookSubscription, error) {
+	if err := s.targets.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	secret := req.Secret
+	if secret == "" {
+		var err error
+		if secret, err = webhooks.NewSecret(); err != nil {
+			return nil, err
+		}
+	}
+
+	sub := &models.WebhookSubscription{
+		TenantID:   tenant.IDFromContext(ctx),
+		URL:        req.URL,
+		EventTypes: models.EventTypes(req.EventTypes),
+		Secret:     secret,
+		Active:     req.Active == nil || *req.Active,
+	}
+	if err := s.webhookRepo.CreateSubscription(sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// ListWebhooks returns all subscriptions of the tenant
+func (s *WebhookServiceImpl) ListWebhooks(ctx context.Context) ([]models.WebhookSubscription, error) {
+	subs, err := s.webhookRepo.ListSubscriptions(tenant.IDFromContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+	for i := range subs {
+		subs[i].Secret = ""
+	}
+	return subs, nil
+}
+
+// GetWebhook returns a subscription by ID
+func (s *WebhookServiceImpl) GetWebhook(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	sub, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// UpdateWebhook replaces a subscription. The secret is kept unless a new
+// one is given.
+func (s *WebhookServiceImpl) UpdateWebhook(ctx context.Context, id uint, req models.WebhookRequest) (*models.WebhookSubscription, error) {
+	if err := s.targets.CheckURL(req.URL); err != nil {
+		return nil, err
+	}
+	sub, err := s.getWebhook(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sub.URL = req.URL
+	sub.EventTypes = models.EventTypes(req.EventTypes)
+	if req.Secret != "" {
+		sub.Secret = req.Secret
+	}
+	if req.Active != nil {
+		sub.Active = *req.Active
+	}
+	if err := s.webhookRepo.UpdateSubscription(sub); err != nil {
+		return nil, err
+	}
+	sub.Secret = ""
+	return sub, nil
+}
+
+// DeleteWebhook deletes a subscription and its delivery log
+func (s *WebhookServiceImpl) DeleteWebhook(ctx context.Context, id uint) error {
+	if err := s.webhookRepo.DeleteSubscription(tenant.IDFromContext(ctx), id); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errors.New("webhook not found")
+		}
+		return err
+	}
+	return nil
+}
+
+// ListDeliveries returns the delivery log of a subscription, newest first
+func (s *WebhookServiceImpl) ListDeliveries(ctx context.Context, id uint, filter models.WebhookDeliveryFilter) ([]models.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	filter.TenantID = tenant.IDFromContext(ctx)
+	filter.SubscriptionID = id
+	return s.webhookRepo.ListDeliveries(filter)
+}
+
+// Redeliver queues a delivery to be sent again straight away with a fresh
+// set of attempts, whatever its status
+func (s *WebhookServiceImpl) Redeliver(ctx context.Context, id, deliveryID uint) (*models.WebhookDelivery, error) {
+	if _, err := s.getWebhook(ctx, id); err != nil {
+		return nil, err
+	}
+	delivery, err := s.webhookRepo.GetDelivery(tenant.IDFromContext(ctx), deliveryID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("delivery not found")
+		}
+		return nil, err
+	}
+	if delivery.SubscriptionID != id {
+		return nil, errors.New("delivery not found")
+	}
+
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.Attempts = 0
+	delivery.NextAttemptAt = time.Now()
+	if err := s.webhookRepo.UpdateDelivery(delivery); err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// getWebhook returns a subscription of the tenant, secret included
+func (s *WebhookServiceImpl) getWebhook(ctx context.Context, id uint) (*models.WebhookSubscription, error) {
+	sub, err := s.webhookRepo.GetSubscription(tenant.IDFromContext(ctx), id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("webhook not found")
+		}
+		return nil, err
+	}
+	return sub, nil
+}
@@ -0,0 +1,25 @@
+// Package tenant carries the tenant a request acts for through the request
+// context, so repositories can scope every query to it.
+package tenant
+
+import (
+	"context"
+	"gin-simple-app/internal/models"
+)
+
+type contextKey struct{}
+
+// WithID returns a context that scopes data access to the tenant with id
+func WithID(ctx context.Context, id uint) context.Context {
+	return context.WithValue(ctx, contextKey{}, id)
+}
+
+// IDFromContext returns the tenant recorded in ctx, or the default tenant.
+// Work done outside a request, such as seeding, belongs to the default
+// tenant.
+func IDFromContext(ctx context.Context) uint {
+	if id, ok := ctx.Value(contextKey{}).(uint); ok && id != 0 {
+		return id
+	}
+	return models.DefaultTenantID
+}
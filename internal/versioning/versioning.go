@@ -0,0 +1,138 @@
+// Package versioning identifies the API version a request asks for, either
+// by its path (/api/v2/...) or by a vendor media type in its Accept header
+// (application/vnd.app.v2+json), and describes deprecated versions.
+package versioning
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// API versions
+const (
+	V1 = "v1"
+	V2 = "v2"
+)
+
+// Latest is the version served to requests that do not ask for one
+const Latest = V2
+
+// Supported lists the API versions, oldest first
+var Supported = []string{V1, V2}
+
+// ContextKey is the gin context key under which the request's version is stored
+const ContextKey = "api_version"
+
+// mediaTypePattern matches vendor media types such as application/vnd.app.v2+json
+var mediaTypePattern = regexp.MustCompile(`^application/vnd\.app\.(v[0-9]+)(\+json)?$`)
+
+// ErrUnsupported is returned for versions the API does not serve
+var ErrUnsupported = errors.New("unsupported API version")
+
+// IsSupported reports whether version is served
+func IsSupported(version string) bool {
+	for _, v := range Supported {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// MediaType returns the vendor media type of version, e.g.
+// application/vnd.app.v2+json
+func MediaType(version string) string {
+	return "application/vnd.app." + version + "+json"
+}
+
+// FromAccept returns the version named by the vendor media types of an
+// Accept header, preferring the one with the highest quality. ok is false
+// if the header names no version; err is ErrUnsupported if the preferred
+// version is not served.
+func FromAccept(header string) (version string, ok bool, err error) {
+	best := -1.0
+	for _, part := range strings.Split(header, ",") {
+		mediaType, params, parseErr := mime.ParseMediaType(strings.TrimSpace(part))
+		if parseErr != nil {
+			continue
+		}
+		match := mediaTypePattern.FindStringSubmatch(mediaType)
+		if match == nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		if quality > best {
+			best = quality
+			version = match[1]
+		}
+	}
+	if version == "" {
+		return "", false, nil
+	}
+	if !IsSupported(version) {
+		return version, true, fmt.Errorf("%w: %s", ErrUnsupported, version)
+	}
+	return version, true, nil
+}
+
+// Deprecation announces that a version is going away
+type Deprecation struct {
+	// At is when the version was deprecated
+	At time.Time
+	// Sunset is when the version stops being served; zero if not decided
+	Sunset time.Time
+}
+
+// Deprecations holds the deprecated versions
+type Deprecations map[string]Deprecation
+
+// dateLayout is the layout of dates in deprecation settings
+const dateLayout = "2006-01-02"
+
+// ParseDeprecations parses a comma-separated list of deprecated versions,
+// each written as version:deprecated[:sunset] with dates as YYYY-MM-DD,
+// e.g. "v1:2026-09-01:2027-03-01"
+func ParseDeprecations(s string) (Deprecations, error) {
+	deprecations := Deprecations{}
+	for _, entry := range strings.Split(s, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("%q: expected version:deprecated[:sunset]", entry)
+		}
+		version := parts[0]
+		if !IsSupported(version) {
+			return nil, fmt.Errorf("%q: %w", entry, ErrUnsupported)
+		}
+		if version == Latest {
+			return nil, fmt.Errorf("%q: the latest version cannot be deprecated", entry)
+		}
+
+		var deprecation Deprecation
+		var err error
+		if deprecation.At, err = time.Parse(dateLayout, parts[1]); err != nil {
+			return nil, fmt.Errorf("%q: invalid deprecation date", entry)
+		}
+		if len(parts) == 3 {
+			if deprecation.Sunset, err = time.Parse(dateLayout, parts[2]); err != nil {
+				return nil, fmt.Errorf("%q: invalid sunset date", entry)
+			}
+			if deprecation.Sunset.Before(deprecation.At) {
+				return nil, fmt.Errorf("%q: sunset is before deprecation", entry)
+			}
+		}
+		deprecations[version] = deprecation
+	}
+	return deprecations, nil
+}
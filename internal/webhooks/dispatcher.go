@@ -0,0 +1,59 @@
+package webhooks
+
+import (
+	"context"
+	"encoding/json"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"time"
+)
+
+// Dispatcher is an events.Sink that queues a delivery of each event for
+// every subscription of its tenant that wants it. Queuing is idempotent, so an event
+// relayed twice is still delivered once per subscription.
+type Dispatcher struct {
+	repo repository.WebhookRepository
+}
+
+// NewDispatcher creates a dispatcher that queues deliveries in repo
+func NewDispatcher(repo repository.WebhookRepository) *Dispatcher {
+	return &Dispatcher{
+		repo: repo,
+	}
+}
+
+// Name identifies the sink in logs
+func (d *Dispatcher) Name() string {
+	return "webhook subscriptions"
+}
+
+// Deliver queues event for the matching subscriptions of its tenant
+func (d *Dispatcher) Deliver(_ context.Context, event models.Event) error {
+	subs, err := d.repo.ListSubscriptions(event.TenantID)
+	if err != nil {
+		return err
+	}
+
+	var deliveries []models.WebhookDelivery
+	var payload []byte
+	for _, sub := range subs {
+		if sub.TenantID != event.TenantID || !sub.Matches(event.Type) {
+			continue
+		}
+		if payload == nil {
+			if payload, err = json.Marshal(event); err != nil {
+				return err
+			}
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			TenantID:       sub.TenantID,
+			SubscriptionID: sub.ID,
+			EventID:        event.EventID,
+			EventType:      event.Type,
+			Payload:        string(payload),
+			Status:         models.WebhookDeliveryPending,
+			NextAttemptAt:  time.Now(),
+		})
+	}
+	return d.repo.EnqueueDeliveries(deliveries...)
+}
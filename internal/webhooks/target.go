@@ -0,0 +1,134 @@
+package webhooks
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// TargetError is returned for webhook URLs the TargetPolicy forbids
+type TargetError struct {
+	// Reason says why the URL is forbidden
+	Reason string
+}
+
+func (e *TargetError) Error() string {
+	return "webhook URL not allowed: " + e.Reason
+}
+
+// internalPrefixes are reserved ranges the netip.Addr predicates miss
+var internalPrefixes = []netip.Prefix{
+	// Carrier-grade NAT, which some clouds use for their metadata services
+	netip.MustParsePrefix("100.64.0.0/10"),
+	// IETF protocol assignments
+	netip.MustParsePrefix("192.0.0.0/24"),
+	// Benchmarking
+	netip.MustParsePrefix("198.18.0.0/15"),
+	// Reserved, including the limited broadcast address
+	netip.MustParsePrefix("240.0.0.0/4"),
+	// Local-use NAT64, which translates to the operator's own networks
+	netip.MustParsePrefix("64:ff9b:1::/48"),
+}
+
+// nat64 is the well-known NAT64 prefix, whose addresses reach the IPv4
+// address in their last four bytes
+var nat64 = netip.MustParsePrefix("64:ff9b::/96")
+
+// TargetPolicy restricts where webhooks are delivered, so subscriptions
+// cannot make the server send requests into its own network
+type TargetPolicy struct {
+	// AllowHTTP accepts plain http:// URLs; otherwise only https:// is
+	AllowHTTP bool
+	// AllowPrivateNetworks accepts loopback, private, link-local and other
+	// internal addresses
+	AllowPrivateNetworks bool
+}
+
+// CheckURL returns a *TargetError if the policy forbids delivering to
+// rawURL. Host names are only resolved when a delivery connects, which is
+// where the worker checks the address they resolve to.
+func (p TargetPolicy) CheckURL(rawURL string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return &TargetError{Reason: err.Error()}
+	}
+	switch {
+	case u.Scheme == "https":
+	case u.Scheme == "http" && p.AllowHTTP:
+	default:
+		return &TargetError{Reason: "scheme must be https"}
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return &TargetError{Reason: "host is missing"}
+	}
+	if p.AllowPrivateNetworks {
+		return nil
+	}
+	if host = strings.ToLower(strings.TrimSuffix(host, ".")); host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return &TargetError{Reason: host + " is a loopback host"}
+	}
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return p.checkAddr(addr)
+	}
+	return nil
+}
+
+// checkAddr returns a *TargetError if the policy forbids connecting to addr
+func (p TargetPolicy) checkAddr(addr netip.Addr) error {
+	if p.AllowPrivateNetworks {
+		return nil
+	}
+	addr = addr.Unmap()
+	if nat64.Contains(addr) {
+		// Check the IPv4 address the translator would connect to
+		ip := addr.As16()
+		if err := p.checkAddr(netip.AddrFrom4([4]byte(ip[12:]))); err != nil {
+			return &TargetError{Reason: addr.String() + " translates to an internal address"}
+		}
+		return nil
+	}
+	if addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() ||
+		addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return &TargetError{Reason: addr.String() + " is an internal address"}
+	}
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return &TargetError{Reason: addr.String() + " is an internal address"}
+		}
+	}
+	return nil
+}
+
+// client returns an HTTP client that only connects to addresses the policy
+// allows, checked after DNS resolution so a host name cannot point it
+// elsewhere, and that does not follow redirects
+func (p TargetPolicy) client(timeout time.Duration) *http.Client {
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return &TargetError{Reason: err.Error()}
+			}
+			return p.checkAddr(addrPort.Addr())
+		},
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would connect on the worker's behalf, past the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
@@ -0,0 +1,198 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// WorkerConfig tunes webhook delivery
+type WorkerConfig struct {
+	// PollInterval is how often pending deliveries are checked
+	PollInterval time.Duration
+	// BatchSize is how many deliveries are claimed at once
+	BatchSize int
+	// Lease hides claimed deliveries from other workers; it must be longer
+	// than sending a batch takes, see Validate
+	Lease time.Duration
+	// Timeout bounds a single request to a subscriber
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is tried before it is
+	// dead-lettered
+	MaxAttempts int
+	// MinBackoff and MaxBackoff bound the exponential delay between attempts
+	MinBackoff time.Duration
+	MaxBackoff time.Duration
+	// Targets restricts the addresses deliveries are sent to
+	Targets TargetPolicy
+}
+
+// DefaultWorkerConfig returns the settings used when none are configured
+func DefaultWorkerConfig() WorkerConfig {
+	return WorkerConfig{
+		PollInterval: time.Second,
+		BatchSize:    20,
+		Lease:        5 * time.Minute,
+		Timeout:      10 * time.Second,
+		MaxAttempts:  10,
+		MinBackoff:   30 * time.Second,
+		MaxBackoff:   time.Hour,
+	}
+}
+
+// leaseMargin is how much longer than its slowest batch a lease lasts, to
+// cover the database writes around the requests
+const leaseMargin = time.Minute
+
+// MinLease returns the shortest lease that outlasts a batch whose every
+// request runs until Timeout
+func (c WorkerConfig) MinLease() time.Duration {
+	return time.Duration(c.BatchSize)*c.Timeout + leaseMargin
+}
+
+// Validate returns an error if a batch could outlast its lease, which would
+// let another worker claim the deliveries still being sent and send them
+// again
+func (c WorkerConfig) Validate() error {
+	if c.Lease < c.MinLease() {
+		return fmt.Errorf("lease of %s is shorter than the %s a batch of %d deliveries can take", c.Lease, c.MinLease(), c.BatchSize)
+	}
+	return nil
+}
+
+// Worker sends queued deliveries to their subscribers
+type Worker struct {
+	repo   repository.WebhookRepository
+	client *http.Client
+	config WorkerConfig
+}
+
+// NewWorker creates a worker that sends the deliveries queued in repo
+func NewWorker(repo repository.WebhookRepository, config WorkerConfig) *Worker {
+	return &Worker{
+		repo:   repo,
+		client: config.Targets.client(config.Timeout),
+		config: config,
+	}
+}
+
+// Run sends deliveries until ctx is cancelled
+func (w *Worker) Run(ctx context.Context) {
+	ticker := time.NewTicker(w.config.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		// Drain everything that is due before waiting for the next tick
+		for {
+			n, err := w.DeliverOnce(ctx)
+			if err != nil {
+				log.Printf("Webhook worker error: %v", err)
+			}
+			if err != nil || n < w.config.BatchSize || ctx.Err() != nil {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// DeliverOnce claims one batch of due deliveries and sends it, returning
+// how many deliveries were claimed
+func (w *Worker) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := w.repo.ClaimDeliveries(time.Now(), w.config.Lease, w.config.BatchSize)
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range deliveries {
+		delivery := &deliveries[i]
+		sub, err := w.repo.GetSubscription(delivery.TenantID, delivery.SubscriptionID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			// Deleted along with its delivery log while the batch was claimed
+			continue
+		}
+		if err != nil {
+			return len(deliveries), err
+		}
+
+		w.attempt(ctx, sub, delivery)
+		if err := w.repo.UpdateDelivery(delivery); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return len(deliveries), err
+		}
+	}
+	return len(deliveries), nil
+}
+
+// attempt sends delivery once and records the outcome on it
+func (w *Worker) attempt(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) {
+	now := time.Now()
+	delivery.LastAttemptAt = &now
+
+	var statusCode int
+	var err error
+	if sub.Active {
+		statusCode, err = w.send(ctx, sub, delivery)
+	} else {
+		err = errors.New("subscription is inactive")
+	}
+	delivery.ResponseStatus = statusCode
+
+	if err == nil {
+		delivery.Status = models.WebhookDeliveryDelivered
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if !sub.Active || delivery.Attempts >= w.config.MaxAttempts {
+		delivery.Status = models.WebhookDeliveryDead
+		log.Printf("Webhook delivery %d to %s dead-lettered after %d attempts: %v", delivery.ID, sub.URL, delivery.Attempts, err)
+		return
+	}
+	delivery.Status = models.WebhookDeliveryPending
+	delivery.NextAttemptAt = now.Add(events.Backoff(delivery.Attempts, w.config.MinBackoff, w.config.MaxBackoff))
+}
+
+// send posts the signed delivery and returns the response status code
+func (w *Worker) send(ctx context.Context, sub *models.WebhookSubscription, delivery *models.WebhookDelivery) (int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(events.EventIDHeader, delivery.EventID)
+	req.Header.Set(events.EventTypeHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(now.Unix(), 10))
+	req.Header.Set(SignatureHeader, Sign(sub.Secret, now, body))
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("subscriber responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
@@ -0,0 +1,241 @@
+package response
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// XML, MessagePack and CSV responses are written from the JSON encoding of
+// a response, so field names, omitted fields and custom encodings are the
+// same in every format.
+
+// xmlRoot names the root element of XML responses
+const xmlRoot = "response"
+
+// object is a decoded JSON object that keeps the order of its keys
+type object struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+// decodeTree decodes JSON into objects, []interface{}, json.Number,
+// string, bool and nil
+func decodeTree(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return readValue(decoder)
+}
+
+func readValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		obj := &object{values: map[string]interface{}{}}
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := readValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			obj.keys = append(obj.keys, key.(string))
+			obj.values[key.(string)] = value
+		}
+		_, err = decoder.Token()
+		return obj, err
+	case '[':
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := readValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err = decoder.Token()
+		return list, err
+	}
+	return nil, fmt.Errorf("unexpected %v", delim)
+}
+
+// xmlName matches keys that can be used as XML element names
+var xmlName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9._-]*$`)
+
+// writeXML writes a decoded JSON value as an XML document. Object keys
+// become elements, list items become item elements and null becomes an
+// empty element with nil="true". Keys that are not valid element names
+// are written as entry elements with a key attribute.
+func writeXML(w io.Writer, value interface{}) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	if err := encodeXML(encoder, xmlRoot, value); err != nil {
+		return err
+	}
+	return encoder.Flush()
+}
+
+func encodeXML(encoder *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}}
+	if !xmlName.MatchString(name) || strings.HasPrefix(strings.ToLower(name), "xml") {
+		start = xml.StartElement{
+			Name: xml.Name{Local: "entry"},
+			Attr: []xml.Attr{{Name: xml.Name{Local: "key"}, Value: name}},
+		}
+	}
+
+	switch value := value.(type) {
+	case nil:
+		start.Attr = append(start.Attr, xml.Attr{Name: xml.Name{Local: "nil"}, Value: "true"})
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+	case *object:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, key := range value.keys {
+			if err := encodeXML(encoder, key, value.values[key]); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		if err := encoder.EncodeToken(start); err != nil {
+			return err
+		}
+		for _, item := range value {
+			if err := encodeXML(encoder, "item", item); err != nil {
+				return err
+			}
+		}
+	default:
+		return encoder.EncodeElement(fmt.Sprint(value), start)
+	}
+	return encoder.EncodeToken(start.End())
+}
+
+// plain converts a decoded JSON value to maps, slices and Go numbers for
+// encoders that do not know about objects and json.Number. Integers stay
+// integers.
+func plain(value interface{}) interface{} {
+	switch value := value.(type) {
+	case *object:
+		m := make(map[string]interface{}, len(value.keys))
+		for _, key := range value.keys {
+			m[key] = plain(value.values[key])
+		}
+		return m
+	case []interface{}:
+		list := make([]interface{}, len(value))
+		for i, item := range value {
+			list[i] = plain(item)
+		}
+		return list
+	case json.Number:
+		if i, err := value.Int64(); err == nil {
+			return i
+		}
+		f, _ := value.Float64()
+		return f
+	}
+	return value
+}
+
+// writeCSV writes a decoded JSON list as CSV with a header row. Nested
+// objects are flattened into dotted columns such as postal_address.city,
+// and lists are written as JSON. Columns appear in the order their keys
+// are first seen. Values that spreadsheets would evaluate as formulas are
+// escaped, see EscapeFormula.
+func writeCSV(w io.Writer, list []interface{}) error {
+	var columns []string
+	seen := map[string]bool{}
+	rows := make([]map[string]string, len(list))
+	for i, item := range list {
+		rows[i] = map[string]string{}
+		flatten("", item, rows[i], func(column string) {
+			if !seen[column] {
+				seen[column] = true
+				columns = append(columns, column)
+			}
+		})
+	}
+
+	writer := csv.NewWriter(w)
+	if len(columns) > 0 {
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, column := range columns {
+			record[i] = EscapeFormula(row[column])
+		}
+		if err := writer.Write(record); err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formulaTriggers are the characters that make spreadsheets evaluate a
+// cell as a formula
+const formulaTriggers = "=+-@\t\r"
+
+// plainNumber matches signed numbers, which spreadsheets read as numbers
+var plainNumber = regexp.MustCompile(`^[+-]?[0-9]+(\.[0-9]+)?$`)
+
+// EscapeFormula prefixes value with a quote if a spreadsheet opening it in
+// a CSV file would evaluate it as a formula, so user data such as
+// =HYPERLINK(...) is shown as text. Plain numbers such as +13125550101 or
+// -4 are left as they are.
+func EscapeFormula(value string) string {
+	if value == "" || !strings.ContainsRune(formulaTriggers, rune(value[0])) || plainNumber.MatchString(value) {
+		return value
+	}
+	return "'" + value
+}
+
+func flatten(prefix string, value interface{}, row map[string]string, addColumn func(string)) {
+	column := prefix
+	if column == "" {
+		column = "value"
+	}
+	switch value := value.(type) {
+	case *object:
+		for _, key := range value.keys {
+			nested := key
+			if prefix != "" {
+				nested = prefix + "." + key
+			}
+			flatten(nested, value.values[key], row, addColumn)
+		}
+		return
+	case []interface{}:
+		data, _ := json.Marshal(plain(value))
+		row[column] = string(data)
+	case nil:
+		row[column] = ""
+	default:
+		row[column] = fmt.Sprint(value)
+	}
+	addColumn(column)
+}
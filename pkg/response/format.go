@@ -0,0 +1,185 @@
+package response
+
+import (
+	"mime"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Format is a representation responses and request bodies can be written in
+type Format string
+
+// Supported formats
+const (
+	FormatJSON    Format = "json"
+	FormatXML     Format = "xml"
+	FormatMsgPack Format = "msgpack"
+	// FormatCSV is only used for lists
+	FormatCSV Format = "csv"
+)
+
+// Formats lists every format, in order of preference when a client accepts
+// several equally
+var Formats = []Format{FormatJSON, FormatXML, FormatMsgPack, FormatCSV}
+
+// FormatsKey is the gin context key under which the formats a client
+// accepts are stored, most preferred first
+const FormatsKey = "response_formats"
+
+// mediaTypes lists the media types of each format, canonical one first
+var mediaTypes = map[Format][]string{
+	FormatJSON:    {"application/json"},
+	FormatXML:     {"application/xml", "text/xml"},
+	FormatMsgPack: {"application/msgpack", "application/x-msgpack", "application/vnd.msgpack"},
+	FormatCSV:     {"text/csv"},
+}
+
+// ContentType returns the canonical media type of the format
+func (f Format) ContentType() string {
+	return mediaTypes[f][0]
+}
+
+// Header returns the Content-Type header of the format. Text formats are
+// labelled UTF-8; MessagePack is binary and has no charset.
+func (f Format) Header() string {
+	if f == FormatMsgPack {
+		return f.ContentType()
+	}
+	return f.ContentType() + "; charset=utf-8"
+}
+
+// ContentTypes returns the canonical media types of formats
+func ContentTypes(formats []Format) []string {
+	types := make([]string, len(formats))
+	for i, format := range formats {
+		types[i] = format.ContentType()
+	}
+	return types
+}
+
+// FormatOf returns the format of a media type. Structured syntax suffixes
+// count, so application/problem+json is JSON.
+func FormatOf(mediaType string) (Format, bool) {
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if base, _, err := mime.ParseMediaType(mediaType); err == nil {
+		mediaType = base
+	}
+	if strings.HasSuffix(mediaType, "+json") {
+		return FormatJSON, true
+	}
+	for _, format := range Formats {
+		for _, candidate := range mediaTypes[format] {
+			if candidate == mediaType {
+				return format, true
+			}
+		}
+	}
+	return "", false
+}
+
+// acceptance is how much a client wants a format
+type acceptance struct {
+	format Format
+	// quality is the q parameter of the most specific range matching the
+	// format, specificity ranks exact types over type/* over */*, and
+	// position is where that range appears in the header
+	quality     float64
+	specificity int
+	position    int
+}
+
+// AcceptedFormats returns the formats among offered that an Accept header
+// allows, most preferred first. A client with no Accept header accepts
+// JSON. Ties between equally wanted formats go to the one named first by
+// the client, then to the order of offered.
+func AcceptedFormats(accept string, offered []Format) []Format {
+	if strings.TrimSpace(accept) == "" {
+		for _, format := range offered {
+			if format == FormatJSON {
+				return []Format{FormatJSON}
+			}
+		}
+		return nil
+	}
+
+	best := make(map[Format]acceptance, len(offered))
+	for position, part := range strings.Split(accept, ",") {
+		mediaRange, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+		if err != nil {
+			continue
+		}
+		quality := 1.0
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil {
+			quality = q
+		}
+		for _, format := range offered {
+			specificity := matchRange(mediaRange, format)
+			if specificity == 0 {
+				continue
+			}
+			current, ok := best[format]
+			if !ok || specificity > current.specificity || (specificity == current.specificity && quality > current.quality) {
+				best[format] = acceptance{format: format, quality: quality, specificity: specificity, position: position}
+			}
+		}
+	}
+
+	var accepted []acceptance
+	for _, format := range offered {
+		if a, ok := best[format]; ok && a.quality > 0 {
+			accepted = append(accepted, a)
+		}
+	}
+	sort.SliceStable(accepted, func(i, j int) bool {
+		if accepted[i].quality != accepted[j].quality {
+			return accepted[i].quality > accepted[j].quality
+		}
+		return accepted[i].position < accepted[j].position
+	})
+
+	formats := make([]Format, len(accepted))
+	for i, a := range accepted {
+		formats[i] = a.format
+	}
+	return formats
+}
+
+// matchRange returns how specifically a media range of an Accept header
+// matches a format: 3 for an exact type, 2 for type/*, 1 for */* and 0
+// if it does not match
+func matchRange(mediaRange string, format Format) int {
+	if mediaRange == "*/*" {
+		return 1
+	}
+	if f, ok := FormatOf(mediaRange); ok {
+		if f == format {
+			return 3
+		}
+		return 0
+	}
+	if prefix, ok := strings.CutSuffix(mediaRange, "/*"); ok {
+		for _, mediaType := range mediaTypes[format] {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return 2
+			}
+		}
+	}
+	return 0
+}
+
+// acceptedFormats returns the formats the client accepts, as negotiated by
+// middleware or else from the Accept header. Without negotiation, lists are
+// not offered as CSV and a client accepting nothing else gets JSON.
+func acceptedFormats(c *gin.Context) []Format {
+	if formats, ok := c.Get(FormatsKey); ok {
+		return formats.([]Format)
+	}
+	formats := AcceptedFormats(c.GetHeader("Accept"), []Format{FormatJSON, FormatXML, FormatMsgPack})
+	if len(formats) == 0 {
+		return []Format{FormatJSON}
+	}
+	return formats
+}
@@ -0,0 +1,91 @@
+package response
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ProblemContentType is the media type of RFC 7807 error responses
+const ProblemContentType = "application/problem+json"
+
+// RequestIDKey is the gin context key under which the request ID is stored
+const RequestIDKey = "request_id"
+
+// Problem is an RFC 7807 problem details object. Code, Errors, RequestID and
+// Data are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Instance  string       `json:"instance,omitempty"`
+	Code      string       `json:"code,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+	RequestID string       `json:"request_id,omitempty"`
+	Data      interface{}  `json:"data,omitempty"`
+}
+
+var (
+	problemMu       sync.RWMutex
+	problemDefault  bool
+	problemTypeBase string
+)
+
+// SetProblemDetails configures error rendering. When enabled, every error is
+// sent as application/problem+json; otherwise only clients that ask for it in
+// their Accept header get it. typeBaseURL, if set, is joined with the error
+// code to build the problem type URI; without it the type is "about:blank".
+func SetProblemDetails(enabled bool, typeBaseURL string) {
+	problemMu.Lock()
+	defer problemMu.Unlock()
+	problemDefault = enabled
+	problemTypeBase = typeBaseURL
+}
+
+// renderError sends an error response as either an APIResponse, in the
+// format the client accepts, or a Problem
+func renderError(c *gin.Context, statusCode int, resp APIResponse) {
+	if !wantsProblem(c) {
+		render(c, statusCode, resp, false)
+		return
+	}
+
+	c.Header("Content-Type", ProblemContentType)
+	c.JSON(statusCode, newProblem(c, statusCode, resp))
+}
+
+// wantsProblem reports whether the error should be rendered as problem+json
+func wantsProblem(c *gin.Context) bool {
+	problemMu.RLock()
+	enabled := problemDefault
+	problemMu.RUnlock()
+
+	return enabled || strings.Contains(c.GetHeader("Accept"), ProblemContentType)
+}
+
+// newProblem converts an error APIResponse into a Problem
+func newProblem(c *gin.Context, statusCode int, resp APIResponse) Problem {
+	problemMu.RLock()
+	base := problemTypeBase
+	problemMu.RUnlock()
+
+	problemType := "about:blank"
+	if base != "" && resp.Code != "" {
+		problemType = strings.TrimSuffix(base, "/") + "/" + strings.ReplaceAll(resp.Code, "_", "-")
+	}
+
+	return Problem{
+		Type:      problemType,
+		Title:     http.StatusText(statusCode),
+		Status:    statusCode,
+		Detail:    resp.Error,
+		Instance:  c.Request.URL.Path,
+		Code:      resp.Code,
+		Errors:    resp.Errors,
+		RequestID: c.GetString(RequestIDKey),
+		Data:      resp.Data,
+	}
+}
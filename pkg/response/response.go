@@ -1,18 +1,24 @@
 package response
 
 import (
+	"bytes"
+	"encoding/json"
 	"net/http"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	ginrender "github.com/gin-gonic/gin/render"
 )
 
 // APIResponse represents a standard API response structure
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
-	Error   string      `json:"error,omitempty"`
-	Count   *int        `json:"count,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message,omitempty"`
+	Data    interface{}  `json:"data,omitempty"`
+	Error   string       `json:"error,omitempty"`
+	Code    string       `json:"code,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+	Count   *int         `json:"count,omitempty"`
 }
 
 // Success sends a successful response
@@ -22,7 +28,7 @@ func Success(c *gin.Context, statusCode int, message string, data interface{}) {
 		Message: message,
 		Data:    data,
 	}
-	c.JSON(statusCode, response)
+	render(c, statusCode, response, false)
 }
 
 // SuccessWithCount sends a successful response with count
@@ -33,7 +39,7 @@ func SuccessWithCount(c *gin.Context, statusCode int, message string, data inter
 		Data:    data,
 		Count:   &count,
 	}
-	c.JSON(statusCode, response)
+	render(c, statusCode, response, true)
 }
 
 // Error sends an error response
@@ -42,16 +48,17 @@ func Error(c *gin.Context, statusCode int, message string) {
 		Success: false,
 		Error:   message,
 	}
-	c.JSON(statusCode, response)
+	renderError(c, statusCode, response)
 }
 
-// ValidationError sends a validation error response
-func ValidationError(c *gin.Context, err error) {
+// ErrorWithData sends an error response that also carries data, e.g. per-item failure details
+func ErrorWithData(c *gin.Context, statusCode int, message string, data interface{}) {
 	response := APIResponse{
 		Success: false,
-		Error:   err.Error(),
+		Error:   message,
+		Data:    data,
 	}
-	c.JSON(http.StatusBadRequest, response)
+	renderError(c, statusCode, response)
 }
 
 // NotFound sends a not found error response
@@ -68,3 +75,68 @@ func BadRequest(c *gin.Context, message string) {
 func InternalServerError(c *gin.Context, message string) {
 	Error(c, http.StatusInternalServerError, message)
 }
+
+// TooManyRequests sends a rate limit error response
+func TooManyRequests(c *gin.Context, message string) {
+	response := APIResponse{
+		Success: false,
+		Error:   message,
+		Code:    "rate_limited",
+	}
+	renderError(c, http.StatusTooManyRequests, response)
+}
+
+// render writes resp in the format the client prefers among those that can
+// represent it; only lists can be written as CSV. A success the client
+// accepts no format for is replaced by 406 Not Acceptable, and an error is
+// sent as JSON.
+func render(c *gin.Context, statusCode int, resp APIResponse, list bool) {
+	for _, format := range acceptedFormats(c) {
+		if format == FormatCSV && !list {
+			continue
+		}
+		write(c, statusCode, resp, format)
+		return
+	}
+	if !resp.Success {
+		c.JSON(statusCode, resp)
+		return
+	}
+	Error(c, http.StatusNotAcceptable, "This response is only available as "+
+		strings.Join(ContentTypes([]Format{FormatJSON, FormatXML, FormatMsgPack}), ", "))
+}
+
+// write sends resp in format
+func write(c *gin.Context, statusCode int, resp APIResponse, format Format) {
+	if format == FormatJSON {
+		c.JSON(statusCode, resp)
+		return
+	}
+
+	data, err := json.Marshal(resp)
+	var tree interface{}
+	if err == nil {
+		tree, err = decodeTree(data)
+	}
+	var body bytes.Buffer
+	if err == nil {
+		switch format {
+		case FormatMsgPack:
+			// gin labels MessagePack with a charset unless the header is set
+			c.Header("Content-Type", format.Header())
+			c.Render(statusCode, ginrender.MsgPack{Data: plain(tree)})
+			return
+		case FormatXML:
+			err = writeXML(&body, tree)
+		case FormatCSV:
+			list, _ := tree.(*object).values["data"].([]interface{})
+			err = writeCSV(&body, list)
+		}
+	}
+	if err != nil {
+		_ = c.Error(err)
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(statusCode, format.Header(), body.Bytes())
+}
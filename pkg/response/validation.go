@@ -0,0 +1,258 @@
+package response
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/locales/en"
+	"github.com/go-playground/locales/es"
+	"github.com/go-playground/locales/fr"
+	"github.com/go-playground/locales/zh"
+	ut "github.com/go-playground/universal-translator"
+	"github.com/go-playground/validator/v10"
+	en_translations "github.com/go-playground/validator/v10/translations/en"
+	es_translations "github.com/go-playground/validator/v10/translations/es"
+	fr_translations "github.com/go-playground/validator/v10/translations/fr"
+	zh_translations "github.com/go-playground/validator/v10/translations/zh"
+)
+
+// Error codes distinguishing the two kinds of bad request bodies
+const (
+	CodeValidationFailed = "validation_failed"
+	CodeMalformedRequest = "malformed_request"
+)
+
+// FieldError describes a single invalid field of a request
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// defaultLocale is used when the Accept-Language header names no supported language
+const defaultLocale = "en"
+
+// customMessages translates validation tags that the go-playground
+// translations do not cover. The "" entry is the fallback for any other tag.
+var customMessages = map[string]map[string]string{
+	"phone": {
+		"en": "{0} must be a valid phone number",
+		"es": "{0} debe ser un número de teléfono válido",
+		"fr": "{0} doit être un numéro de téléphone valide",
+		"zh": "{0}必须是一个有效的电话号码",
+	},
+	"iso3166_1_alpha2": {
+		"en": "{0} must be a valid ISO 3166-1 alpha-2 country code",
+		"es": "{0} debe ser un código de país ISO 3166-1 alfa-2 válido",
+		"fr": "{0} doit être un code pays ISO 3166-1 alpha-2 valide",
+		"zh": "{0}必须是一个有效的ISO 3166-1 alpha-2国家代码",
+	},
+	"": {
+		"en": "{0} is invalid",
+		"es": "{0} no es válido",
+		"fr": "{0} n'est pas valide",
+		"zh": "{0}无效",
+	},
+}
+
+var (
+	uni          *ut.UniversalTranslator
+	setupOnce    sync.Once
+	setupErr     error
+	translations = map[string]func(v *validator.Validate, trans ut.Translator) error{
+		"en": en_translations.RegisterDefaultTranslations,
+		"es": es_translations.RegisterDefaultTranslations,
+		"fr": fr_translations.RegisterDefaultTranslations,
+		"zh": zh_translations.RegisterDefaultTranslations,
+	}
+)
+
+// SetupValidator configures gin's binding validator to report JSON field
+// names and registers message translations for every supported language.
+// It is safe to call more than once.
+func SetupValidator() error {
+	setupOnce.Do(func() {
+		v, ok := binding.Validator.Engine().(*validator.Validate)
+		if !ok {
+			setupErr = errors.New("unsupported binding validator engine")
+			return
+		}
+		v.RegisterTagNameFunc(fieldName)
+
+		english := en.New()
+		uni = ut.New(english, english, es.New(), fr.New(), zh.New())
+		for locale, register := range translations {
+			trans, _ := uni.GetTranslator(locale)
+			if err := register(v, trans); err != nil {
+				setupErr = err
+				return
+			}
+			for tag, messages := range customMessages {
+				if tag == "" {
+					continue
+				}
+				if err := registerMessage(v, trans, tag, messages[locale]); err != nil {
+					setupErr = err
+					return
+				}
+			}
+		}
+	})
+	return setupErr
+}
+
+// registerMessage registers a translation for a tag with a single {0} field placeholder
+func registerMessage(v *validator.Validate, trans ut.Translator, tag, message string) error {
+	return v.RegisterTranslation(tag, trans,
+		func(ut ut.Translator) error {
+			return ut.Add(tag, message, true)
+		},
+		func(ut ut.Translator, fe validator.FieldError) string {
+			t, _ := ut.T(tag, fe.Field())
+			return t
+		},
+	)
+}
+
+// fieldName reports struct fields by their JSON name, falling back to the
+// form name for query parameters
+func fieldName(field reflect.StructField) string {
+	for _, key := range []string{"json", "form"} {
+		name := strings.SplitN(field.Tag.Get(key), ",", 2)[0]
+		if name == "-" {
+			return ""
+		}
+		if name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// ValidationError sends a 400 response for a request that failed to bind.
+// Validation failures list every invalid field with a message in the
+// client's language; malformed input such as broken JSON is reported
+// separately with the malformed_request code.
+func ValidationError(c *gin.Context, err error) {
+	var verrs validator.ValidationErrors
+	if !errors.As(err, &verrs) {
+		renderError(c, http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   "Malformed request: " + err.Error(),
+			Code:    CodeMalformedRequest,
+		})
+		return
+	}
+	if setupErr := SetupValidator(); setupErr != nil {
+		renderError(c, http.StatusBadRequest, APIResponse{
+			Success: false,
+			Error:   err.Error(),
+			Code:    CodeValidationFailed,
+		})
+		return
+	}
+
+	InvalidFields(c, TranslateValidationErrors(verrs, c.GetHeader("Accept-Language")))
+}
+
+// InvalidFields sends a 400 validation_failed response listing fields
+func InvalidFields(c *gin.Context, fields []FieldError) {
+	renderError(c, http.StatusBadRequest, APIResponse{
+		Success: false,
+		Error:   JoinMessages(fields),
+		Code:    CodeValidationFailed,
+		Errors:  fields,
+	})
+}
+
+// TranslateValidationErrors converts validator errors into field errors with
+// messages in the best language for an Accept-Language header. If the
+// translations cannot be set up, every message is the generic English one.
+func TranslateValidationErrors(verrs validator.ValidationErrors, acceptLanguage string) []FieldError {
+	locale := negotiateLocale(acceptLanguage)
+	var trans ut.Translator
+	if err := SetupValidator(); err != nil {
+		locale = "en"
+	} else {
+		trans, _ = uni.GetTranslator(locale)
+	}
+
+	fields := make([]FieldError, len(verrs))
+	for i, fe := range verrs {
+		message := fe.Error()
+		if trans != nil {
+			message = fe.Translate(trans)
+		}
+		if message == fe.Error() {
+			// No translation for this tag
+			message = strings.Replace(customMessages[""][locale], "{0}", fe.Field(), 1)
+		}
+		fields[i] = FieldError{
+			Field:   fieldPath(fe.Namespace()),
+			Code:    fe.Tag(),
+			Message: message,
+		}
+	}
+	return fields
+}
+
+// JoinMessages combines the messages of several field errors into one line
+func JoinMessages(fields []FieldError) string {
+	messages := make([]string, len(fields))
+	for i, f := range fields {
+		messages[i] = f.Message
+	}
+	return strings.Join(messages, "; ")
+}
+
+// fieldPath strips the top-level struct name from a namespace, e.g.
+// "CreateUserRequest.postal_address.city" becomes "postal_address.city"
+func fieldPath(namespace string) string {
+	if i := strings.IndexByte(namespace, '.'); i >= 0 {
+		return namespace[i+1:]
+	}
+	return namespace
+}
+
+// negotiateLocale picks the supported language with the highest quality from
+// an Accept-Language header such as "fr-CH, fr;q=0.9, en;q=0.8"
+func negotiateLocale(header string) string {
+	type candidate struct {
+		locale  string
+		quality float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(header, ",") {
+		fields := strings.Split(strings.TrimSpace(part), ";")
+		tag := strings.ToLower(strings.TrimSpace(fields[0]))
+		if tag == "" {
+			continue
+		}
+		quality := 1.0
+		for _, param := range fields[1:] {
+			if q, ok := strings.CutPrefix(strings.TrimSpace(param), "q="); ok {
+				if v, err := strconv.ParseFloat(q, 64); err == nil {
+					quality = v
+				}
+			}
+		}
+		base := strings.SplitN(strings.ReplaceAll(tag, "_", "-"), "-", 2)[0]
+		if _, ok := translations[base]; ok && quality > 0 {
+			candidates = append(candidates, candidate{base, quality})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return defaultLocale
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	return candidates[0].locale
+}
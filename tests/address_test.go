@@ -0,0 +1,121 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/address"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateUserWithPostalAddress(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	user := map[string]interface{}{
+		"name":  "Alice Cooper",
+		"email": "alice@example.com",
+		"phone": "+1 212-555-0104",
+		"postal_address": map[string]string{
+			"line1":       "789 Pine St",
+			"line2":       "Suite 5",
+			"city":        "Chicago",
+			"region":      "IL",
+			"postal_code": "60601",
+			"country":     "US",
+		},
+	}
+	w := send(app.router, http.MethodPost, "/api/v1/users", user, nil)
+
+	assert.Equal(t, http.StatusCreated, w.Code)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	userData := resp.Data.(map[string]interface{})
+	postal := userData["postal_address"].(map[string]interface{})
+	assert.Equal(t, "Chicago", postal["city"])
+	assert.Equal(t, "US", postal["country"])
+	// The deprecated free-text field stays readable
+	assert.Equal(t, "789 Pine St, Suite 5, Chicago, IL 60601, US", userData["address"])
+}
+
+func TestCreateUserWithLegacyAddressIsParsed(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	code, resp := app.createUser(t, map[string]string{
+		"name":    "Alice Cooper",
+		"email":   "alice@example.com",
+		"phone":   "+1 212-555-0104",
+		"address": "789 Pine St, Chicago, IL 60601",
+	})
+
+	assert.Equal(t, http.StatusCreated, code)
+	userData := resp.Data.(map[string]interface{})
+	assert.Equal(t, "789 Pine St, Chicago, IL 60601", userData["address"])
+	postal := userData["postal_address"].(map[string]interface{})
+	assert.Equal(t, "789 Pine St", postal["line1"])
+	assert.Equal(t, "Chicago", postal["city"])
+	assert.Equal(t, "IL", postal["region"])
+	assert.Equal(t, "60601", postal["postal_code"])
+	assert.Equal(t, "US", postal["country"])
+}
+
+func TestCreateUserInvalidPostalAddress(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	user := map[string]interface{}{
+		"name":           "Alice Cooper",
+		"email":          "alice@example.com",
+		"phone":          "+1 212-555-0104",
+		"postal_address": map[string]string{"line1": "789 Pine St", "country": "USA"},
+	}
+	w := send(app.router, http.MethodPost, "/api/v1/users", user, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []response.FieldError{
+		{Field: "postal_address.city", Code: "required", Message: "city is a required field"},
+		{Field: "postal_address.country", Code: "iso3166_1_alpha2", Message: "country must be a valid ISO 3166-1 alpha-2 country code"},
+	}, resp.Errors)
+}
+
+func TestGetUsersFilterByCityAndCountry(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users?city=los%20angeles&country=US", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Equal(t, 1, *resp.Count)
+	assert.Equal(t, "Jane Smith", resp.Data.([]interface{})[0].(map[string]interface{})["name"])
+
+	w = send(app.router, http.MethodGet, "/api/v1/users?country=GB", nil, nil)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, 0, *resp.Count)
+}
+
+func TestParseAddress(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected models.Address
+	}{
+		{"123 Main St, New York, NY 10001", models.Address{Line1: "123 Main St", City: "New York", Region: "NY", PostalCode: "10001", Country: "US"}},
+		{"1 Loop Rd, Apt 4, Austin, TX 73301-1234", models.Address{Line1: "1 Loop Rd", Line2: "Apt 4", City: "Austin", Region: "TX", PostalCode: "73301-1234", Country: "US"}},
+		{"10 Downing St, London, England, GB", models.Address{Line1: "10 Downing St", City: "London", Region: "England", Country: "GB"}},
+		{"Somewhere only we know", models.Address{Line1: "Somewhere only we know", Country: "US"}},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, address.Parse(tt.input), tt.input)
+	}
+}
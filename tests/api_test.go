@@ -19,27 +19,78 @@ import (
 
 // TestApp holds the application components for testing
 type TestApp struct {
-	router   *gin.Engine
-	userRepo *repository.InMemoryUserRepository
+	router      *gin.Engine
+	userRepo    *repository.InMemoryUserRepository
+	auditRepo   *repository.InMemoryAuditRepository
+	outbox      *repository.InMemoryOutboxRepository
+	userService services.UserService
 }
 
-// setupTestApp initializes the application for testing
-func setupTestApp() *TestApp {
+// setupTestApp initializes the application for testing, served with opts
+func setupTestApp(opts ...router.Option) *TestApp {
+	return newTestApp().serve(opts...)
+}
+
+// newTestApp initializes the components of the application, with changes
+// recorded in an in-memory audit log and outbox as the server does, for
+// tests whose router options need them; serve builds its router
+func newTestApp() *TestApp {
 	gin.SetMode(gin.TestMode)
 
 	// Initialize components with in-memory repository for testing
 	userRepo := repository.NewInMemoryUserRepository()
-	userService := services.NewUserService(userRepo)
-	userHandler := handlers.NewUserHandler(userService)
-	healthHandler := handlers.NewHealthHandler()
-	appRouter := router.NewRouter(userHandler, healthHandler)
+	auditRepo := repository.NewInMemoryAuditRepository()
+	outbox := repository.NewInMemoryOutboxRepository()
+	userService := services.NewUserService(userRepo,
+		services.WithAuditLog(auditRepo, repository.NewInMemoryUnitOfWork(userRepo, auditRepo, outbox)),
+	)
 
 	return &TestApp{
-		router:   appRouter.SetupRoutes(),
-		userRepo: userRepo,
+		userRepo:    userRepo,
+		auditRepo:   auditRepo,
+		outbox:      outbox,
+		userService: userService,
 	}
 }
 
+// serve sets up the routes of the app with opts
+func (app *TestApp) serve(opts ...router.Option) *TestApp {
+	userHandler := handlers.NewUserHandler(app.userService)
+	healthHandler := handlers.NewHealthHandler()
+	app.router = router.NewRouter(userHandler, healthHandler, opts...).SetupRoutes()
+	return app
+}
+
+// send sends a request with the given headers; a Host header sets the
+// host the request is sent to and a RemoteAddr header the address it comes
+// from. A []byte body is sent as it is; any other
+// body is sent as JSON, with a JSON Content-Type unless headers set one.
+func send(engine http.Handler, method, path string, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	switch body := body.(type) {
+	case nil:
+	case []byte:
+		req = httptest.NewRequest(method, path, bytes.NewReader(body))
+	default:
+		data, _ := json.Marshal(body)
+		req = httptest.NewRequest(method, path, bytes.NewReader(data))
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for name, value := range headers {
+		switch name {
+		case "Host":
+			req.Host = value
+		case "RemoteAddr":
+			req.RemoteAddr = value
+		default:
+			req.Header.Set(name, value)
+		}
+	}
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	return w
+}
+
 // resetTestData resets the test data before each test
 func (app *TestApp) resetTestData() {
 	app.userRepo.Reset()
@@ -112,7 +163,7 @@ func TestGetAllUsers(t *testing.T) {
 	assert.Equal(t, float64(1), firstUser["id"])
 	assert.Equal(t, "John Doe", firstUser["name"])
 	assert.Equal(t, "john@example.com", firstUser["email"])
-	assert.Equal(t, "+1-555-0101", firstUser["phone"])
+	assert.Equal(t, "+12125550101", firstUser["phone"])
 	assert.Equal(t, "123 Main St, New York, NY 10001", firstUser["address"])
 }
 
@@ -136,7 +187,7 @@ func TestGetUserByID(t *testing.T) {
 	assert.Equal(t, float64(1), userData["id"])
 	assert.Equal(t, "John Doe", userData["name"])
 	assert.Equal(t, "john@example.com", userData["email"])
-	assert.Equal(t, "+1-555-0101", userData["phone"])
+	assert.Equal(t, "+12125550101", userData["phone"])
 	assert.Equal(t, "123 Main St, New York, NY 10001", userData["address"])
 }
 
@@ -181,7 +232,7 @@ func TestCreateUser(t *testing.T) {
 	newUser := map[string]string{
 		"name":    "Alice Cooper",
 		"email":   "alice@example.com",
-		"phone":   "+1-555-0104",
+		"phone":   "+1 212-555-0104",
 		"address": "789 Pine St, Chicago, IL 60601",
 	}
 
@@ -204,7 +255,8 @@ func TestCreateUser(t *testing.T) {
 	assert.Equal(t, float64(4), userData["id"]) // Should be assigned ID 4
 	assert.Equal(t, "Alice Cooper", userData["name"])
 	assert.Equal(t, "alice@example.com", userData["email"])
-	assert.Equal(t, "+1-555-0104", userData["phone"])
+	assert.Equal(t, "+12125550104", userData["phone"])
+	assert.Equal(t, "+1 212-555-0104", userData["phone_original"])
 	assert.Equal(t, "789 Pine St, Chicago, IL 60601", userData["address"])
 }
 
@@ -215,7 +267,7 @@ func TestCreateUserDuplicateEmail(t *testing.T) {
 	newUser := map[string]string{
 		"name":  "John Smith",
 		"email": "john@example.com", // This email already exists
-		"phone": "+1-555-0999",
+		"phone": "+1 212-555-0999",
 	}
 
 	jsonData, _ := json.Marshal(newUser)
@@ -284,7 +336,7 @@ func TestUpdateUser(t *testing.T) {
 	updatedUser := map[string]string{
 		"name":    "John Updated",
 		"email":   "john.updated@example.com",
-		"phone":   "+1-555-0111",
+		"phone":   "+1 212-555-0111",
 		"address": "Updated Address St, Updated City, UC 12345",
 	}
 
@@ -307,7 +359,7 @@ func TestUpdateUser(t *testing.T) {
 	assert.Equal(t, float64(1), userData["id"])
 	assert.Equal(t, "John Updated", userData["name"])
 	assert.Equal(t, "john.updated@example.com", userData["email"])
-	assert.Equal(t, "+1-555-0111", userData["phone"])
+	assert.Equal(t, "+12125550111", userData["phone"])
 	assert.Equal(t, "Updated Address St, Updated City, UC 12345", userData["address"])
 }
 
@@ -318,7 +370,7 @@ func TestUpdateUserNotFound(t *testing.T) {
 	updatedUser := map[string]string{
 		"name":  "Non Existent",
 		"email": "nonexistent@example.com",
-		"phone": "+1-555-9999",
+		"phone": "+1 212-555-9999",
 	}
 
 	jsonData, _ := json.Marshal(updatedUser)
@@ -403,7 +455,7 @@ func TestCompleteUserLifecycle(t *testing.T) {
 	newUser := map[string]string{
 		"name":  "Lifecycle Test",
 		"email": "lifecycle@example.com",
-		"phone": "+1-555-LIFE",
+		"phone": "+1 212-555-5433",
 	}
 	jsonData, _ := json.Marshal(newUser)
 
@@ -436,7 +488,7 @@ func TestCompleteUserLifecycle(t *testing.T) {
 	updatedUser := map[string]string{
 		"name":  "Lifecycle Updated",
 		"email": "lifecycle.updated@example.com",
-		"phone": "+1-555-UPDT",
+		"phone": "+1 212-555-8738",
 	}
 	updateData, _ := json.Marshal(updatedUser)
 
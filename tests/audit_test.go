@@ -0,0 +1,225 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// auditEntries fetches path and decodes the audit entries in the response
+func auditEntries(t *testing.T, engine *gin.Engine, path string) []models.AuditEntry {
+	t.Helper()
+	w := send(engine, http.MethodGet, path, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data  []models.AuditEntry `json:"data"`
+		Count int                 `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, len(resp.Data), resp.Count)
+	return resp.Data
+}
+
+func createdUserID(t *testing.T, w *httptest.ResponseRecorder) uint {
+	t.Helper()
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp struct {
+		Data models.User `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data.ID
+}
+
+var auditedUser = map[string]interface{}{
+	"name":  "Grace Hopper",
+	"email": "grace@example.com",
+	"phone": "+1 212-555-0199",
+}
+
+func TestAuditRecordsUserLifecycle(t *testing.T) {
+	engine := setupTestApp().router
+
+	created := send(engine, http.MethodPost, "/api/v1/users", auditedUser, map[string]string{"X-API-Key": "admin-key"})
+	id := createdUserID(t, created)
+	historyPath := fmt.Sprintf("/api/v1/users/%d/history", id)
+
+	entries := auditEntries(t, engine, historyPath)
+	require.Len(t, entries, 1)
+	entry := entries[0]
+	assert.Equal(t, models.AuditActionCreate, entry.Action)
+	assert.Equal(t, id, entry.UserID)
+	assert.Equal(t, "ip:192.0.2.1", entry.Actor)
+	assert.Regexp(t, `^key:[0-9a-f]{32}$`, entry.ClaimedActor)
+	assert.NotContains(t, entry.ClaimedActor, "admin-key")
+	assert.Equal(t, created.Header().Get("X-Request-ID"), entry.RequestID)
+	assert.False(t, entry.CreatedAt.IsZero())
+	assert.Equal(t, models.FieldChange{Before: nil, After: "Grace Hopper"}, entry.Changes["name"])
+	assert.Equal(t, models.FieldChange{Before: nil, After: "+12125550199"}, entry.Changes["phone"])
+	assert.NotContains(t, entry.Changes, "id")
+	assert.NotContains(t, entry.Changes, "created_at")
+
+	// Only the fields that actually changed are recorded
+	update := map[string]interface{}{
+		"name":  "Grace B. Hopper",
+		"email": "grace@example.com",
+		"phone": "+1 212-555-0199",
+	}
+	w := send(engine, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", id), update, map[string]string{"X-API-Key": "other-key"})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries = auditEntries(t, engine, historyPath)
+	require.Len(t, entries, 2)
+	entry = entries[0]
+	assert.Equal(t, models.AuditActionUpdate, entry.Action)
+	assert.NotEqual(t, entries[1].ClaimedActor, entry.ClaimedActor)
+	assert.Equal(t, models.AuditChanges{
+		"name": {Before: "Grace Hopper", After: "Grace B. Hopper"},
+	}, entry.Changes)
+
+	// History outlives the user
+	w = send(engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, map[string]string{"X-API-Key": "admin-key"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, http.StatusNotFound, send(engine, http.MethodGet, fmt.Sprintf("/api/v1/users/%d", id), nil, nil).Code)
+
+	entries = auditEntries(t, engine, historyPath)
+	require.Len(t, entries, 3)
+	entry = entries[0]
+	assert.Equal(t, models.AuditActionDelete, entry.Action)
+	assert.Equal(t, entries[2].ClaimedActor, entry.ClaimedActor)
+	assert.Equal(t, models.FieldChange{Before: "Grace B. Hopper", After: nil}, entry.Changes["name"])
+	assert.Equal(t, models.FieldChange{Before: "grace@example.com", After: nil}, entry.Changes["email"])
+}
+
+func TestAuditActorIsClientAddressWithClaimedIdentity(t *testing.T) {
+	engine := setupTestApp().router
+
+	w := send(engine, http.MethodPost, "/api/v1/users", auditedUser, map[string]string{"Authorization": "Bearer " + unsignedJWT(map[string]interface{}{"sub": "alice"})})
+	id := createdUserID(t, w)
+
+	w = send(engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	entries := auditEntries(t, engine, fmt.Sprintf("/api/v1/users/%d/history", id))
+	require.Len(t, entries, 2)
+	assert.Equal(t, "ip:192.0.2.1", entries[0].Actor)
+	assert.Empty(t, entries[0].ClaimedActor)
+
+	// An unsigned token is only a claim: it is recorded next to the
+	// address, never as the actor
+	assert.Equal(t, "ip:192.0.2.1", entries[1].Actor)
+	assert.Equal(t, "sub:alice", entries[1].ClaimedActor)
+}
+
+func TestAuditSkipsFailedChanges(t *testing.T) {
+	engine := setupTestApp().router
+
+	duplicate := map[string]interface{}{
+		"name":  "Someone Else",
+		"email": "john@example.com",
+		"phone": "+1 212-555-0198",
+	}
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodPost, "/api/v1/users", duplicate, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodPut, "/api/v1/users/2", duplicate, nil).Code)
+	assert.Equal(t, http.StatusNotFound, send(engine, http.MethodDelete, "/api/v1/users/999", nil, nil).Code)
+
+	assert.Empty(t, auditEntries(t, engine, "/api/v1/audit"))
+}
+
+func TestAuditRecordsBulkOperations(t *testing.T) {
+	engine := setupTestApp().router
+
+	w := send(engine, http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": auditedUser},
+			{"op": "update", "id": 1, "data": map[string]interface{}{
+				"name": "Johnny Doe", "email": "john@example.com", "phone": "+1 212-555-0101",
+			}},
+			{"op": "delete", "id": 2},
+		},
+	}, map[string]string{"X-API-Key": "bulk-key"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	requestID := w.Header().Get("X-Request-ID")
+
+	entries := auditEntries(t, engine, "/api/v1/audit?request_id="+requestID)
+	require.Len(t, entries, 3)
+	actions := map[string]uint{}
+	for _, entry := range entries {
+		actions[entry.Action] = entry.UserID
+		assert.Equal(t, entries[0].ClaimedActor, entry.ClaimedActor)
+	}
+	assert.Equal(t, uint(1), actions[models.AuditActionUpdate])
+	assert.Equal(t, uint(2), actions[models.AuditActionDelete])
+	assert.NotZero(t, actions[models.AuditActionCreate])
+
+	// DELETE /users records one entry per user
+	w = send(engine, http.MethodDelete, "/api/v1/users?ids=1,3", nil, map[string]string{"X-API-Key": "bulk-key"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Len(t, auditEntries(t, engine, "/api/v1/audit?action=delete"), 3)
+
+	// A failed batch writes nothing, audit included
+	w = send(engine, http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]interface{}{
+				"name": "New Person", "email": "new@example.com", "phone": "+1 212-555-0197",
+			}},
+			{"op": "delete", "id": 999},
+		},
+	}, map[string]string{"X-API-Key": "bulk-key"})
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	assert.Empty(t, auditEntries(t, engine, "/api/v1/audit?request_id="+w.Header().Get("X-Request-ID")))
+}
+
+func TestAuditLogFilters(t *testing.T) {
+	engine := setupTestApp().router
+
+	id := createdUserID(t, send(engine, http.MethodPost, "/api/v1/users", auditedUser, map[string]string{"X-API-Key": "key-a"}))
+	require.Equal(t, http.StatusOK, send(engine, http.MethodDelete, "/api/v1/users/1", nil, map[string]string{"X-API-Key": "key-b"}).Code)
+	require.Equal(t, http.StatusOK, send(engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, map[string]string{"X-API-Key": "key-b"}).Code)
+
+	all := auditEntries(t, engine, "/api/v1/audit")
+	require.Len(t, all, 3)
+	assert.Greater(t, all[0].ID, all[1].ID, "newest first")
+
+	deletes := auditEntries(t, engine, "/api/v1/audit?action=delete")
+	assert.Len(t, deletes, 2)
+
+	assert.Len(t, auditEntries(t, engine, "/api/v1/audit?actor="+all[2].Actor), 3)
+	byClaimedActor := auditEntries(t, engine, "/api/v1/audit?claimed_actor="+all[2].ClaimedActor)
+	require.Len(t, byClaimedActor, 1)
+	assert.Equal(t, models.AuditActionCreate, byClaimedActor[0].Action)
+
+	byUser := auditEntries(t, engine, fmt.Sprintf("/api/v1/audit?user_id=%d", id))
+	assert.Len(t, byUser, 2)
+
+	page := auditEntries(t, engine, "/api/v1/audit?limit=2")
+	require.Len(t, page, 2)
+	next := auditEntries(t, engine, fmt.Sprintf("/api/v1/audit?limit=2&before_id=%d", page[1].ID))
+	require.Len(t, next, 1)
+	assert.Equal(t, all[2].ID, next[0].ID)
+
+	since := time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)
+	assert.Len(t, auditEntries(t, engine, "/api/v1/audit?since="+since), 3)
+	assert.Empty(t, auditEntries(t, engine, "/api/v1/audit?until=2000-01-01T00:00:00Z"))
+
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodGet, "/api/v1/audit?action=rename", nil, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodGet, "/api/v1/audit?since=yesterday", nil, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodGet, "/api/v1/audit?limit=5000", nil, nil).Code)
+}
+
+func TestUserHistoryNotFound(t *testing.T) {
+	engine := setupTestApp().router
+
+	assert.Equal(t, http.StatusNotFound, send(engine, http.MethodGet, "/api/v1/users/999/history", nil, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(engine, http.MethodGet, "/api/v1/users/abc/history", nil, nil).Code)
+
+	// Seeded users exist but have no recorded changes yet
+	assert.Empty(t, auditEntries(t, engine, "/api/v1/users/1/history"))
+}
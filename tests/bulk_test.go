@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (app *TestApp) postBulk(t *testing.T, payload interface{}) (*httptest.ResponseRecorder, response.APIResponse) {
+	w := send(app.router, http.MethodPost, "/api/v1/users/bulk", payload, nil)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w, resp
+}
+
+func TestBulkUsers(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w, resp := app.postBulk(t, map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]string{"name": "Alice", "email": "alice@example.com", "phone": "+1 212-555-0104"}},
+			{"op": "update", "id": 1, "data": map[string]string{"name": "John Bulk", "email": "john@example.com", "phone": "+1 212-555-0101"}},
+			{"op": "delete", "id": 3},
+			// Bob's email is released by the delete above, so it can be reused
+			{"op": "create", "data": map[string]string{"name": "New Bob", "email": "bob@example.com", "phone": "+1 212-555-0105"}},
+		},
+	})
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.True(t, resp.Success)
+
+	results := resp.Data.([]interface{})
+	require.Len(t, results, 4)
+	statuses := make([]string, len(results))
+	for i, r := range results {
+		statuses[i] = r.(map[string]interface{})["status"].(string)
+	}
+	assert.Equal(t, []string{"created", "updated", "deleted", "created"}, statuses)
+	assert.Equal(t, float64(4), results[0].(map[string]interface{})["id"])
+
+	users, _ := app.userRepo.GetAll()
+	assert.Len(t, users, 4)
+	john, _ := app.userRepo.GetByID(1)
+	assert.Equal(t, "John Bulk", john.Name)
+	_, err := app.userRepo.GetByID(3)
+	assert.Error(t, err)
+}
+
+func TestBulkUsersRollsBackOnFailure(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w, resp := app.postBulk(t, map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "delete", "id": 1},
+			{"op": "create", "data": map[string]string{"name": "Dup", "email": "jane@example.com", "phone": "+1 212-555-0104"}},
+			{"op": "update", "id": 999, "data": map[string]string{"name": "Ghost", "email": "ghost@example.com", "phone": "+1 212-555-0106"}},
+		},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, resp.Success)
+
+	results := resp.Data.([]interface{})
+	require.Len(t, results, 3)
+	assert.Equal(t, "skipped", results[0].(map[string]interface{})["status"])
+	assert.Equal(t, "failed", results[1].(map[string]interface{})["status"])
+	assert.Equal(t, "user with this email already exists", results[1].(map[string]interface{})["error"])
+	assert.Equal(t, "failed", results[2].(map[string]interface{})["status"])
+	assert.Equal(t, "user not found", results[2].(map[string]interface{})["error"])
+
+	// Nothing was applied
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(3), count)
+}
+
+func TestBulkUsersInvalidOperationData(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w, resp := app.postBulk(t, map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]string{"name": "No Email", "phone": "+1 212-555-0104"}},
+			{"op": "delete"},
+		},
+	})
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	results := resp.Data.([]interface{})
+	assert.Equal(t, "email is a required field", results[0].(map[string]interface{})["error"])
+	assert.Equal(t, "id is required", results[1].(map[string]interface{})["error"])
+}
+
+func TestDeleteUsersByIDs(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodDelete, "/api/v1/users?ids=1,3", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.True(t, resp.Success)
+	assert.Equal(t, float64(2), resp.Data.(map[string]interface{})["deleted"])
+
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(1), count)
+}
+
+func TestDeleteUsersByIDsMissing(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodDelete, "/api/v1/users?ids=1,42", nil, nil)
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, []interface{}{float64(42)}, resp.Data.(map[string]interface{})["missing_ids"])
+
+	// User 1 must not have been deleted
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(3), count)
+}
+
+func TestDeleteUsersByIDsInvalid(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodDelete, "/api/v1/users?ids=1,abc", nil, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
@@ -0,0 +1,199 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/compression"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/pkg/response"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decompress decodes a response body by its Content-Encoding
+func decompress(t *testing.T, w *httptest.ResponseRecorder) []byte {
+	t.Helper()
+	var reader io.Reader
+	switch encoding := w.Header().Get("Content-Encoding"); encoding {
+	case "gzip":
+		gz, err := gzip.NewReader(w.Body)
+		require.NoError(t, err)
+		reader = gz
+	case "br":
+		reader = brotli.NewReader(w.Body)
+	case "zstd":
+		decoder, err := zstd.NewReader(w.Body)
+		require.NoError(t, err)
+		defer decoder.Close()
+		reader = decoder
+	default:
+		t.Fatalf("unexpected Content-Encoding %q", encoding)
+	}
+	body, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	return body
+}
+
+// gzipBytes compresses data with gzip
+func gzipBytes(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestCompressedResponses(t *testing.T) {
+	cfg := middleware.DefaultCompressionConfig()
+	cfg.MinSize = 0
+	engine := setupTestApp(router.WithCompression(cfg)).router
+
+	for _, encoding := range []string{"gzip", "br", "zstd"} {
+		t.Run(encoding, func(t *testing.T) {
+			w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Accept-Encoding": encoding})
+
+			require.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, encoding, w.Header().Get("Content-Encoding"))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+			assert.Empty(t, w.Header().Get("Content-Length"))
+
+			var resp response.APIResponse
+			require.NoError(t, json.Unmarshal(decompress(t, w), &resp))
+			assert.True(t, resp.Success)
+			assert.Len(t, resp.Data, 3)
+		})
+	}
+}
+
+func TestCompressionNegotiation(t *testing.T) {
+	cfg := middleware.DefaultCompressionConfig()
+	cfg.MinSize = 0
+	engine := setupTestApp(router.WithCompression(cfg)).router
+
+	for accept, expected := range map[string]string{
+		"":                           "",
+		"gzip, deflate, br":          "br",
+		"gzip, br;q=0.5":             "gzip",
+		"*":                          "zstd",
+		"*;q=0.5, zstd;q=0":          "br",
+		"deflate":                    "",
+		"gzip;q=0.5, identity":       "",
+		"gzip, identity;q=0":         "gzip",
+		"x-gzip":                     "gzip",
+		"br;q=0, gzip;q=0, zstd;q=0": "",
+	} {
+		w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Accept-Encoding": accept})
+		assert.Equal(t, http.StatusOK, w.Code, accept)
+		assert.Equal(t, expected, w.Header().Get("Content-Encoding"), accept)
+	}
+}
+
+func TestCompressionSkipsSmallAndUnlistedResponses(t *testing.T) {
+	engine := setupTestApp(router.WithCompression(middleware.DefaultCompressionConfig())).router
+
+	// A single user is below the default 1 KiB threshold
+	w := send(engine, http.MethodGet, "/api/v1/users/1", nil, map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Contains(t, w.Header().Values("Vary"), "Accept-Encoding")
+	assert.Contains(t, w.Body.String(), `"John Doe"`)
+
+	// XLSX files are zip archives already
+	cfg := middleware.DefaultCompressionConfig()
+	cfg.MinSize = 0
+	engine = setupTestApp(router.WithCompression(cfg)).router
+	w = send(engine, http.MethodGet, "/api/v1/users/export?format=xlsx", nil, map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.True(t, bytes.HasPrefix(w.Body.Bytes(), []byte("PK")))
+
+	// Compression can be turned off altogether
+	engine = setupTestApp(router.WithCompression(middleware.CompressionConfig{})).router
+	w = send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Accept-Encoding": "gzip"})
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.NotContains(t, w.Header().Values("Vary"), "Accept-Encoding")
+}
+
+func TestCompressedExport(t *testing.T) {
+	engine := setupTestApp(router.WithCompression(middleware.DefaultCompressionConfig())).router
+
+	// Enough users for the export to pass the threshold part way through
+	for i := 0; i < 50; i++ {
+		payload := fmt.Sprintf(`{"name":"User %d","email":"user%d@example.com","phone":"+1 202-555-%04d"}`, i, i, i)
+		w := send(engine, http.MethodPost, "/api/v1/users", []byte(payload), map[string]string{"Content-Type": "application/json"})
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	}
+
+	w := send(engine, http.MethodGet, "/api/v1/users/export?format=csv", nil, map[string]string{"Accept-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+	lines := strings.Split(strings.TrimSpace(string(decompress(t, w))), "\n")
+	assert.Len(t, lines, 54)
+	assert.Contains(t, lines[len(lines)-1], "user49@example.com")
+}
+
+func TestGzipRequestBodies(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	payload := []byte(`{"operations":[{"op":"create","data":{"name":"Alice","email":"alice@example.com","phone":"+1 212-555-0104"}}]}`)
+	w := send(app.router, http.MethodPost, "/api/v1/users/bulk", gzipBytes(t, payload),
+		map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	users, _ := app.userRepo.GetAll()
+	assert.Len(t, users, 4)
+
+	// Bodies that are not gzip, or use another coding, are refused
+	w = send(app.router, http.MethodPost, "/api/v1/users/bulk", payload,
+		map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(app.router, http.MethodPost, "/api/v1/users/bulk", payload,
+		map[string]string{"Content-Type": "application/json", "Content-Encoding": "deflate"})
+	assert.Equal(t, http.StatusUnsupportedMediaType, w.Code)
+	assert.Equal(t, "gzip", w.Header().Get("Accept-Encoding"))
+}
+
+func TestGzipRequestBodyTooLarge(t *testing.T) {
+	cfg := middleware.DefaultCompressionConfig()
+	cfg.MaxDecompressedSize = 1024
+	engine := setupTestApp(router.WithCompression(cfg)).router
+
+	// Compresses to a few dozen bytes
+	payload := []byte(`{"operations":[` + strings.Repeat(" ", 2000) + `]}`)
+	w := send(engine, http.MethodPost, "/api/v1/users/bulk", gzipBytes(t, payload),
+		map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	offered := compression.DefaultEncodings
+
+	assert.Equal(t, compression.Identity, compression.Negotiate("", offered))
+	assert.Equal(t, compression.Zstd, compression.Negotiate("gzip, br, zstd", offered))
+	assert.Equal(t, compression.Gzip, compression.Negotiate("gzip, br", []string{compression.Gzip, compression.Brotli}))
+	assert.Equal(t, compression.Brotli, compression.Negotiate("gzip;q=0.8, br;q=0.9", offered))
+	assert.Equal(t, compression.Identity, compression.Negotiate("identity;q=1, gzip;q=0.5", offered))
+	assert.Equal(t, compression.Identity, compression.Negotiate("compress", offered))
+}
+
+func TestGzipRequestBodyPassesOpenAPIValidation(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	payload := []byte(`{"operations":[{"op":"create","data":{"name":"Alice","email":"alice@example.com","phone":"+1 212-555-0104"}}]}`)
+	w := send(engine, http.MethodPost, "/api/v1/users/bulk", gzipBytes(t, payload),
+		map[string]string{"Content-Type": "application/json", "Content-Encoding": "gzip"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
@@ -0,0 +1,265 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/router"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// streamApp serves the event stream from a broadcaster polled by hand
+type streamApp struct {
+	engine      *gin.Engine
+	server      *httptest.Server
+	broadcaster *events.Broadcaster
+}
+
+func setupStreamApp(t *testing.T, bufferSize int, heartbeat time.Duration) *streamApp {
+	app := newTestApp()
+	broadcaster := events.NewBroadcaster(app.outbox, bufferSize, time.Hour)
+	engine := app.serve(router.WithEventStream(handlers.NewEventStreamHandler(broadcaster, heartbeat))).router
+
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return &streamApp{engine: engine, server: server, broadcaster: broadcaster}
+}
+
+// createUser creates a user and returns its ID
+func (a *streamApp) createUser(t *testing.T, name, email string) uint {
+	t.Helper()
+	return createdUserID(t, send(a.engine, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  name,
+		"email": email,
+		"phone": "+1 212-555-0150",
+	}, nil))
+}
+
+// poll publishes the events written since the last poll
+func (a *streamApp) poll(t *testing.T) {
+	t.Helper()
+	require.NoError(t, a.broadcaster.Poll())
+}
+
+// sseMessage is one event or comment read from a stream
+type sseMessage struct {
+	id      string
+	event   string
+	data    string
+	comment string
+}
+
+// sseStream reads messages from an open event stream
+type sseStream struct {
+	resp     *http.Response
+	messages chan sseMessage
+	cancel   context.CancelFunc
+}
+
+// openStream connects to path and waits until the stream is subscribed
+func (a *streamApp) openStream(t *testing.T, path string, header http.Header) *sseStream {
+	t.Helper()
+	ctx, cancel := context.WithCancel(context.Background())
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.server.URL+path, nil)
+	require.NoError(t, err)
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+
+	stream := &sseStream{resp: resp, messages: make(chan sseMessage, 100), cancel: cancel}
+	t.Cleanup(func() {
+		cancel()
+		resp.Body.Close()
+	})
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	ready := make(chan struct{})
+	go func() {
+		defer close(stream.messages)
+		scanner := bufio.NewScanner(resp.Body)
+		var msg sseMessage
+		for scanner.Scan() {
+			line := scanner.Text()
+			switch {
+			case strings.HasPrefix(line, "retry:"):
+				close(ready)
+			case strings.HasPrefix(line, ":"):
+				msg.comment = strings.TrimSpace(line[1:])
+			case strings.HasPrefix(line, "id:"):
+				msg.id = line[3:]
+			case strings.HasPrefix(line, "event:"):
+				msg.event = line[6:]
+			case strings.HasPrefix(line, "data:"):
+				msg.data += line[5:]
+			case line == "" && msg != (sseMessage{}):
+				stream.messages <- msg
+				msg = sseMessage{}
+			}
+		}
+	}()
+
+	select {
+	case <-ready:
+	case <-time.After(2 * time.Second):
+		t.Fatal("stream did not start")
+	}
+	return stream
+}
+
+// next returns the next message, failing the test if none arrives in time
+func (s *sseStream) next(t *testing.T) sseMessage {
+	t.Helper()
+	select {
+	case msg, ok := <-s.messages:
+		require.True(t, ok, "stream closed")
+		return msg
+	case <-time.After(2 * time.Second):
+		t.Fatal("no message received")
+	}
+	return sseMessage{}
+}
+
+// expectNone checks that no message arrives for a short while
+func (s *sseStream) expectNone(t *testing.T) {
+	t.Helper()
+	select {
+	case msg := <-s.messages:
+		t.Fatalf("unexpected message %+v", msg)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestEventStreamSendsUserChanges(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+	stream := app.openStream(t, "/api/v1/users/events", nil)
+	assert.Equal(t, "text/event-stream", stream.resp.Header.Get("Content-Type"))
+	assert.Equal(t, "no-cache", stream.resp.Header.Get("Cache-Control"))
+
+	id := app.createUser(t, "Ada Lovelace", "ada@example.com")
+	w := send(app.engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	app.poll(t)
+
+	msg := stream.next(t)
+	assert.Equal(t, "1", msg.id)
+	assert.Equal(t, models.EventUserCreated, msg.event)
+	var event models.Event
+	require.NoError(t, json.Unmarshal([]byte(msg.data), &event))
+	assert.Equal(t, id, event.UserID)
+	assert.Equal(t, "ada@example.com", event.Data.User.Email)
+
+	msg = stream.next(t)
+	assert.Equal(t, "2", msg.id)
+	assert.Equal(t, models.EventUserDeleted, msg.event)
+
+	// Polling again publishes nothing new
+	app.poll(t)
+	stream.expectNone(t)
+}
+
+func TestEventStreamFiltersByType(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+	stream := app.openStream(t, "/api/v1/users/events?types=user.deleted", nil)
+
+	id := app.createUser(t, "Ada Lovelace", "ada@example.com")
+	w := send(app.engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	app.poll(t)
+
+	msg := stream.next(t)
+	assert.Equal(t, models.EventUserDeleted, msg.event)
+	assert.Equal(t, "2", msg.id)
+	stream.expectNone(t)
+}
+
+func TestEventStreamRejectsInvalidParameters(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+
+	w := send(app.engine, http.MethodGet, "/api/v1/users/events?types=user.renamed", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(app.engine, http.MethodGet, "/api/v1/users/events", nil, map[string]string{"Last-Event-ID": "abc"})
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestEventStreamResumesFromBuffer(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+	for i := 0; i < 3; i++ {
+		app.createUser(t, "User", fmt.Sprintf("user%d@example.com", i))
+	}
+	app.poll(t)
+
+	stream := app.openStream(t, "/api/v1/users/events", http.Header{"Last-Event-Id": {"1"}})
+	assert.Equal(t, "2", stream.next(t).id)
+	assert.Equal(t, "3", stream.next(t).id)
+
+	// Live events follow the replayed ones
+	app.createUser(t, "User", "user3@example.com")
+	app.poll(t)
+	assert.Equal(t, "4", stream.next(t).id)
+	stream.expectNone(t)
+}
+
+func TestEventStreamResumesFromOutbox(t *testing.T) {
+	app := setupStreamApp(t, 2, time.Minute)
+	for i := 0; i < 5; i++ {
+		app.createUser(t, "User", fmt.Sprintf("user%d@example.com", i))
+	}
+	app.poll(t)
+
+	// The buffer only holds events 4 and 5, so 2 and 3 come from the outbox
+	stream := app.openStream(t, "/api/v1/users/events?last_event_id=1", nil)
+	for _, want := range []string{"2", "3", "4", "5"} {
+		assert.Equal(t, want, stream.next(t).id)
+	}
+
+	app.createUser(t, "User", "user5@example.com")
+	app.poll(t)
+	assert.Equal(t, "6", stream.next(t).id)
+	stream.expectNone(t)
+}
+
+func TestEventStreamResumeWithoutMissedEvents(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+	app.createUser(t, "User", "user0@example.com")
+	app.poll(t)
+
+	stream := app.openStream(t, "/api/v1/users/events", http.Header{"Last-Event-Id": {"1"}})
+	stream.expectNone(t)
+}
+
+func TestEventStreamSendsHeartbeats(t *testing.T) {
+	app := setupStreamApp(t, 100, 20*time.Millisecond)
+	stream := app.openStream(t, "/api/v1/users/events", nil)
+
+	msg := stream.next(t)
+	assert.Equal(t, "heartbeat", msg.comment)
+	assert.Empty(t, msg.event)
+}
+
+func TestEventStreamLoadSkipsHistory(t *testing.T) {
+	app := setupStreamApp(t, 100, time.Minute)
+	app.createUser(t, "User", "user0@example.com")
+	require.NoError(t, app.broadcaster.Load())
+
+	stream := app.openStream(t, "/api/v1/users/events", nil)
+	app.poll(t)
+	stream.expectNone(t)
+
+	app.createUser(t, "User", "user1@example.com")
+	app.poll(t)
+	assert.Equal(t, "2", stream.next(t).id)
+}
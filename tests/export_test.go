@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"gin-simple-app/pkg/response"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportUsersCSV(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/export?format=csv", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/csv; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Regexp(t, `^attachment; filename="users-\d{8}-\d{6}\.csv"$`, w.Header().Get("Content-Disposition"))
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 4) // header + 3 users
+	assert.Equal(t, []string{"id", "name", "email", "phone", "address", "created_at", "updated_at"}, records[0])
+	assert.Equal(t, "1", records[1][0])
+	assert.Equal(t, "John Doe", records[1][1])
+	assert.Equal(t, "123 Main St, New York, NY 10001", records[1][4])
+	assert.Equal(t, "", records[3][4]) // Bob has no address
+}
+
+func TestExportUsersCSVEscapesFormulas(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	body := []byte(`{"name":"=cmd|' /C calc'!A0","email":"eve@example.com","phone":"+1 202-555-0147","address":"-2+3"}`)
+	w := send(app.router, http.MethodPost, "/api/v1/users", body, map[string]string{"Content-Type": "application/json"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	w = send(app.router, http.MethodGet, "/api/v1/users/export?format=csv&email=eve", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	records, err := csv.NewReader(w.Body).ReadAll()
+	require.NoError(t, err)
+	require.Len(t, records, 2)
+	assert.Equal(t, "'=cmd|' /C calc'!A0", records[1][1])
+	assert.Equal(t, "+12025550147", records[1][3], "plain numbers are not escaped")
+	assert.Equal(t, "'-2+3", records[1][4])
+}
+
+func TestExportUsersNDJSONWithFilter(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/export?format=ndjson&name=jane", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/x-ndjson", w.Header().Get("Content-Type"))
+
+	var lines []map[string]interface{}
+	scanner := bufio.NewScanner(w.Body)
+	for scanner.Scan() {
+		var line map[string]interface{}
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &line))
+		lines = append(lines, line)
+	}
+	require.Len(t, lines, 1)
+	assert.Equal(t, "jane@example.com", lines[0]["email"])
+}
+
+func TestExportUsersXLSX(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/export?format=xlsx", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Disposition"), ".xlsx")
+
+	body := w.Body.Bytes()
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	require.NoError(t, err)
+
+	var sheet string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, err := f.Open()
+			require.NoError(t, err)
+			data, _ := io.ReadAll(rc)
+			rc.Close()
+			sheet = string(data)
+		}
+	}
+	assert.Equal(t, 4, strings.Count(sheet, "<row>"))
+	assert.Contains(t, sheet, "john@example.com")
+}
+
+func TestExportUsersInvalidFormat(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/export?format=pdf", nil, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	var response response.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.False(t, response.Success)
+}
+
+func TestGetUsersWithFilter(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users?email=BOB@", nil, nil)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response response.APIResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *response.Count)
+
+	// Wildcards in a filter match themselves
+	w = send(app.router, http.MethodGet, "/api/v1/users?name=%25", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, 0, *response.Count)
+}
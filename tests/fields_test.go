@@ -0,0 +1,136 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// listUserObjects fetches a user list and decodes each user as an object
+func listUserObjects(t *testing.T, app *TestApp, path string) []map[string]interface{} {
+	t.Helper()
+	w := send(app.router, http.MethodGet, path, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data  []map[string]interface{} `json:"data"`
+		Count int                      `json:"count"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, len(resp.Data), resp.Count)
+	return resp.Data
+}
+
+func TestListUsersSparseFields(t *testing.T) {
+	app := setupTestApp()
+
+	users := listUserObjects(t, app, "/api/v1/users?fields=id,name")
+	require.Len(t, users, 3)
+	for _, user := range users {
+		assert.Len(t, user, 2)
+		assert.Contains(t, user, "id")
+		assert.Contains(t, user, "name")
+	}
+	assert.Equal(t, "John Doe", users[0]["name"])
+
+	// Filters still apply, and fields are named the same in v2
+	users = listUserObjects(t, app, "/api/v2/users?name=jane&fields=name,%20email,name")
+	require.Len(t, users, 1)
+	assert.Equal(t, map[string]interface{}{
+		"name":  map[string]interface{}{"full": "Jane Smith", "given": "Jane", "family": "Smith"},
+		"email": "jane@example.com",
+	}, users[0])
+
+	// Without fields every field is returned
+	users = listUserObjects(t, app, "/api/v1/users?fields=")
+	assert.Contains(t, users[0], "created_at")
+	assert.Contains(t, users[0], "postal_address")
+}
+
+func TestListUsersUnknownFields(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users?fields=id,password,ssn", nil, nil)
+
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, response.CodeValidationFailed, resp.Code)
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "fields", resp.Errors[0].Field)
+	assert.Contains(t, resp.Errors[0].Message, "password, ssn")
+}
+
+func TestListUsersSparseFieldsMatchOpenAPIDocument(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	w := send(engine, http.MethodGet, "/api/v1/users?fields=id,email", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = send(engine, http.MethodGet, "/api/users?fields=id,password", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestUserServiceProjection(t *testing.T) {
+	service := services.NewUserService(repository.NewInMemoryUserRepository())
+
+	fields, err := models.ParseUserFields("email,phone")
+	require.NoError(t, err)
+	users, err := service.ListUsers(context.Background(), models.UserFilter{Name: "Bob"}, fields)
+	require.NoError(t, err)
+
+	require.Len(t, users, 1)
+	assert.Equal(t, "bob@example.com", users[0].Email)
+	require.NotNil(t, users[0].Phone)
+	assert.Zero(t, users[0].ID)
+	assert.Empty(t, users[0].Name)
+	assert.True(t, users[0].CreatedAt.IsZero())
+}
+
+func TestGormListSelectsProjectedColumns(t *testing.T) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost dbname=dry_run"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+	})
+	require.NoError(t, err)
+	var queries []string
+	require.NoError(t, db.Callback().Query().After("gorm:query").Register("test:capture", func(tx *gorm.DB) {
+		queries = append(queries, tx.Statement.SQL.String())
+	}))
+	repo := repository.NewGormUserRepository(db)
+
+	_, err = repo.List(models.UserFilter{}, models.UserFields{"id", "name"})
+	require.NoError(t, err)
+	_, err = repo.List(models.UserFilter{}, nil)
+	require.NoError(t, err)
+
+	require.Len(t, queries, 2)
+	assert.Contains(t, queries[0], `SELECT "id","name" FROM "users"`)
+	assert.Contains(t, queries[1], `SELECT * FROM "users"`)
+}
+
+func TestParseUserFields(t *testing.T) {
+	fields, err := models.ParseUserFields(" id , name,,id ")
+	require.NoError(t, err)
+	assert.Equal(t, models.UserFields{"id", "name"}, fields)
+	assert.Equal(t, []string{"id", "name"}, fields.Columns())
+	assert.True(t, fields.Has("name"))
+	assert.False(t, fields.Has("email"))
+
+	fields, err = models.ParseUserFields("")
+	require.NoError(t, err)
+	assert.Nil(t, fields.Columns())
+	assert.True(t, fields.Has("email"))
+
+	_, err = models.ParseUserFields("id,deleted_at")
+	assert.Error(t, err)
+}
@@ -0,0 +1,42 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserFiltersMatchWildcardsLiterally(t *testing.T) {
+	db, acme, _ := setupPostgres(t)
+	ctx := tenant.WithID(context.Background(), acme)
+	repo := repository.NewGormUserRepository(db, repository.WithRowLevelSecurity(true)).WithContext(ctx)
+	for _, user := range []*models.User{
+		{Name: "100% Cotton", Email: "cotton_sales@example.com"},
+		{Name: "1000 Cottons", Email: "cottonXsales@example.com"},
+		{Name: `Back\slash`, Email: "backslash@example.com"},
+	} {
+		require.NoError(t, repo.Create(user))
+	}
+
+	names := func(filter models.UserFilter) []string {
+		t.Helper()
+		users, err := repo.List(filter, models.UserFields{})
+		require.NoError(t, err)
+		var names []string
+		for _, user := range users {
+			names = append(names, user.Name)
+		}
+		return names
+	}
+	assert.Equal(t, []string{"100% Cotton"}, names(models.UserFilter{Name: "0% c"}))
+	assert.Equal(t, []string{"100% Cotton"}, names(models.UserFilter{Email: "cotton_"}))
+	assert.Equal(t, []string{`Back\slash`}, names(models.UserFilter{Name: `k\s`}))
+	assert.Empty(t, names(models.UserFilter{Name: "%%"}))
+}
@@ -0,0 +1,344 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/graph"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/router"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupGraphQLApp builds an app serving /graphql over an audited user service
+func setupGraphQLApp(t *testing.T) (*gin.Engine, *repository.InMemoryAuditRepository, *repository.InMemoryOutboxRepository) {
+	t.Helper()
+	app := newTestApp()
+	schema, err := graph.NewSchema(app.userService)
+	require.NoError(t, err)
+	return app.serve(router.WithGraphQL(handlers.NewGraphQLHandler(schema))).router, app.auditRepo, app.outbox
+}
+
+// graphQLError is an error in a GraphQL response
+type graphQLError struct {
+	Message    string   `json:"message"`
+	Path       []string `json:"path"`
+	Extensions struct {
+		Code   string `json:"code"`
+		Fields []struct {
+			Field   string `json:"field"`
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"fields"`
+	} `json:"extensions"`
+}
+
+// graphQLResult is a GraphQL response with its data left raw
+type graphQLResult struct {
+	Data   json.RawMessage `json:"data"`
+	Errors []graphQLError  `json:"errors"`
+}
+
+// postGraphQL posts body to /graphql and returns the raw response
+func postGraphQL(engine *gin.Engine, body interface{}, headers map[string]string) *httptest.ResponseRecorder {
+	return send(engine, http.MethodPost, "/graphql", body, headers)
+}
+
+// execGraphQL runs query with variables and decodes the data into data
+func execGraphQL(t *testing.T, engine *gin.Engine, query string, variables map[string]interface{}, data interface{}) []graphQLError {
+	t.Helper()
+	w := postGraphQL(engine, map[string]interface{}{"query": query, "variables": variables}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var result graphQLResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+	if data != nil && len(result.Data) > 0 && string(result.Data) != "null" {
+		require.NoError(t, json.Unmarshal(result.Data, data))
+	}
+	return result.Errors
+}
+
+type graphQLUser struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	Email         string `json:"email"`
+	Phone         string `json:"phone"`
+	PostalAddress *struct {
+		City      string `json:"city"`
+		Country   string `json:"country"`
+		Formatted string `json:"formatted"`
+	} `json:"postalAddress"`
+}
+
+type graphQLConnection struct {
+	Users struct {
+		Edges []struct {
+			Cursor string      `json:"cursor"`
+			Node   graphQLUser `json:"node"`
+		} `json:"edges"`
+		PageInfo struct {
+			HasNextPage     bool   `json:"hasNextPage"`
+			HasPreviousPage bool   `json:"hasPreviousPage"`
+			EndCursor       string `json:"endCursor"`
+		} `json:"pageInfo"`
+		TotalCount int `json:"totalCount"`
+	} `json:"users"`
+}
+
+const usersQuery = `query($first: Int, $after: String, $filter: UserFilter) {
+	users(first: $first, after: $after, filter: $filter) {
+		edges { cursor node { id name email } }
+		pageInfo { hasNextPage hasPreviousPage endCursor }
+		totalCount
+	}
+}`
+
+func TestGraphQLUserQuery(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	var data struct {
+		User *graphQLUser `json:"user"`
+	}
+	errs := execGraphQL(t, engine, `{ user(id: "1") { id name email phone postalAddress { city country formatted } } }`, nil, &data)
+	require.Empty(t, errs)
+	require.NotNil(t, data.User)
+	assert.Equal(t, "1", data.User.ID)
+	assert.Equal(t, "John Doe", data.User.Name)
+	assert.True(t, strings.HasPrefix(data.User.Phone, "+"))
+	require.NotNil(t, data.User.PostalAddress)
+	assert.NotEmpty(t, data.User.PostalAddress.Formatted)
+
+	// Unknown users resolve to null rather than an error
+	data.User = nil
+	errs = execGraphQL(t, engine, `{ user(id: "999") { id } }`, nil, &data)
+	assert.Empty(t, errs)
+	assert.Nil(t, data.User)
+}
+
+func TestGraphQLUsersPagination(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	var page graphQLConnection
+	require.Empty(t, execGraphQL(t, engine, usersQuery, map[string]interface{}{"first": 2}, &page))
+	require.Len(t, page.Users.Edges, 2)
+	assert.Equal(t, "1", page.Users.Edges[0].Node.ID)
+	assert.Equal(t, "2", page.Users.Edges[1].Node.ID)
+	assert.True(t, page.Users.PageInfo.HasNextPage)
+	assert.False(t, page.Users.PageInfo.HasPreviousPage)
+	assert.Equal(t, page.Users.Edges[1].Cursor, page.Users.PageInfo.EndCursor)
+	assert.Equal(t, 3, page.Users.TotalCount)
+
+	var next graphQLConnection
+	require.Empty(t, execGraphQL(t, engine, usersQuery, map[string]interface{}{
+		"first": 2, "after": page.Users.PageInfo.EndCursor,
+	}, &next))
+	require.Len(t, next.Users.Edges, 1)
+	assert.Equal(t, "3", next.Users.Edges[0].Node.ID)
+	assert.False(t, next.Users.PageInfo.HasNextPage)
+	assert.True(t, next.Users.PageInfo.HasPreviousPage)
+	assert.Equal(t, 3, next.Users.TotalCount)
+
+	var filtered graphQLConnection
+	require.Empty(t, execGraphQL(t, engine, usersQuery, map[string]interface{}{
+		"filter": map[string]interface{}{"name": "jane"},
+	}, &filtered))
+	require.Len(t, filtered.Users.Edges, 1)
+	assert.Equal(t, "Jane Smith", filtered.Users.Edges[0].Node.Name)
+	assert.Equal(t, 1, filtered.Users.TotalCount)
+
+	errs := execGraphQL(t, engine, usersQuery, map[string]interface{}{"after": "not-a-cursor"}, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeValidationFailed, errs[0].Extensions.Code)
+
+	errs = execGraphQL(t, engine, usersQuery, map[string]interface{}{"first": 500}, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeValidationFailed, errs[0].Extensions.Code)
+}
+
+const createUserMutation = `mutation($input: CreateUserInput!) {
+	createUser(input: $input) { id name email phone postalAddress { city country } }
+}`
+
+func TestGraphQLMutations(t *testing.T) {
+	engine, auditRepo, outbox := setupGraphQLApp(t)
+
+	var created struct {
+		CreateUser graphQLUser `json:"createUser"`
+	}
+	require.Empty(t, execGraphQL(t, engine, createUserMutation, map[string]interface{}{
+		"input": map[string]interface{}{
+			"name":  "Ada Lovelace",
+			"email": "ada@example.com",
+			"phone": "+44 20 7946 0958",
+			"postalAddress": map[string]interface{}{
+				"line1": "12 St James's Square", "city": "London", "country": "gb",
+			},
+		},
+	}, &created))
+	assert.Equal(t, "Ada Lovelace", created.CreateUser.Name)
+	assert.Equal(t, "+442079460958", created.CreateUser.Phone)
+	require.NotNil(t, created.CreateUser.PostalAddress)
+	assert.Equal(t, "GB", created.CreateUser.PostalAddress.Country)
+
+	var updated struct {
+		UpdateUser graphQLUser `json:"updateUser"`
+	}
+	require.Empty(t, execGraphQL(t, engine, `mutation($id: ID!, $input: UpdateUserInput!) {
+		updateUser(id: $id, input: $input) { id name }
+	}`, map[string]interface{}{
+		"id": created.CreateUser.ID,
+		"input": map[string]interface{}{
+			"name": "Augusta Ada King", "email": "ada@example.com", "phone": "+44 20 7946 0958",
+		},
+	}, &updated))
+	assert.Equal(t, created.CreateUser.ID, updated.UpdateUser.ID)
+	assert.Equal(t, "Augusta Ada King", updated.UpdateUser.Name)
+
+	var deleted struct {
+		DeleteUser string `json:"deleteUser"`
+	}
+	require.Empty(t, execGraphQL(t, engine, `mutation($id: ID!) { deleteUser(id: $id) }`,
+		map[string]interface{}{"id": created.CreateUser.ID}, &deleted))
+	assert.Equal(t, created.CreateUser.ID, deleted.DeleteUser)
+
+	// Mutations go through the same service as REST, audit and events included
+	entries, err := auditRepo.List(models.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, models.AuditActionDelete, entries[0].Action)
+	assert.Equal(t, models.AuditActionUpdate, entries[1].Action)
+	assert.Equal(t, models.AuditActionCreate, entries[2].Action)
+	assert.Len(t, outbox.Events(), 3)
+}
+
+func TestGraphQLValidationErrors(t *testing.T) {
+	engine, auditRepo, _ := setupGraphQLApp(t)
+
+	errs := execGraphQL(t, engine, createUserMutation, map[string]interface{}{
+		"input": map[string]interface{}{
+			"name":  "",
+			"email": "not-an-email",
+			"phone": "+1 212-555-0142",
+			"postalAddress": map[string]interface{}{
+				"line1": "1 Main St", "city": "Springfield", "country": "XX",
+			},
+		},
+	}, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, []string{"createUser"}, errs[0].Path)
+	assert.Equal(t, graph.CodeValidationFailed, errs[0].Extensions.Code)
+	fields := map[string]string{}
+	for _, field := range errs[0].Extensions.Fields {
+		fields[field.Field] = field.Code
+	}
+	assert.Equal(t, "required", fields["input.name"])
+	assert.Equal(t, "email", fields["input.email"])
+	assert.Contains(t, fields, "input.postalAddress.country")
+
+	errs = execGraphQL(t, engine, createUserMutation, map[string]interface{}{
+		"input": map[string]interface{}{"name": "Valid Name", "email": "valid@example.com", "phone": "12"},
+	}, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeValidationFailed, errs[0].Extensions.Code)
+	require.NotEmpty(t, errs[0].Extensions.Fields)
+	assert.Equal(t, "input.phone", errs[0].Extensions.Fields[0].Field)
+
+	entries, err := auditRepo.List(models.AuditFilter{})
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestGraphQLValidationMessagesAreLocalized(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	body := map[string]interface{}{
+		"query": createUserMutation,
+		"variables": map[string]interface{}{
+			"input": map[string]interface{}{"name": "Valid Name", "email": "invalid", "phone": "+1 212-555-0142"},
+		},
+	}
+	english := postGraphQL(engine, body, nil)
+	spanish := postGraphQL(engine, body, map[string]string{"Accept-Language": "es"})
+
+	var en, es graphQLResult
+	require.NoError(t, json.Unmarshal(english.Body.Bytes(), &en))
+	require.NoError(t, json.Unmarshal(spanish.Body.Bytes(), &es))
+	require.Len(t, en.Errors, 1)
+	require.Len(t, es.Errors, 1)
+	assert.Equal(t, en.Errors[0].Extensions.Fields[0].Code, es.Errors[0].Extensions.Fields[0].Code)
+	assert.NotEqual(t, en.Errors[0].Message, es.Errors[0].Message)
+}
+
+func TestGraphQLServiceErrorCodes(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	errs := execGraphQL(t, engine, createUserMutation, map[string]interface{}{
+		"input": map[string]interface{}{"name": "John Again", "email": "john@example.com", "phone": "+1 212-555-0142"},
+	}, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeConflict, errs[0].Extensions.Code)
+
+	errs = execGraphQL(t, engine, `mutation { deleteUser(id: "999") }`, nil, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeNotFound, errs[0].Extensions.Code)
+
+	errs = execGraphQL(t, engine, `mutation { updateUser(id: "abc", input: {name: "Valid Name", email: "x@example.com", phone: "+1 212-555-0142"}) { id } }`, nil, nil)
+	require.Len(t, errs, 1)
+	assert.Equal(t, graph.CodeNotFound, errs[0].Extensions.Code)
+
+	// Schema errors are reported without running anything
+	errs = execGraphQL(t, engine, `{ user(id: "1") { password } }`, nil, nil)
+	require.NotEmpty(t, errs)
+}
+
+func TestGraphQLBatch(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	w := postGraphQL(engine, []map[string]interface{}{
+		{"query": `{ user(id: "1") { name } }`},
+		{"query": `query Second { user(id: "2") { name } }`, "operationName": "Second"},
+		{"query": `mutation { deleteUser(id: "999") }`},
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	var results []graphQLResult
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &results))
+	require.Len(t, results, 3)
+	assert.JSONEq(t, `{"user": {"name": "John Doe"}}`, string(results[0].Data))
+	assert.JSONEq(t, `{"user": {"name": "Jane Smith"}}`, string(results[1].Data))
+	require.Len(t, results[2].Errors, 1)
+	assert.Equal(t, graph.CodeNotFound, results[2].Errors[0].Extensions.Code)
+}
+
+func TestGraphQLMalformedRequests(t *testing.T) {
+	engine, _, _ := setupGraphQLApp(t)
+
+	for name, body := range map[string]string{
+		"invalid json":  `{"query":`,
+		"missing query": `{"variables": {}}`,
+		"empty batch":   `[]`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := send(engine, http.MethodPost, "/graphql", []byte(body), map[string]string{"Content-Type": "application/json"})
+			assert.Equal(t, http.StatusBadRequest, w.Code)
+			var result graphQLResult
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &result))
+			assert.Len(t, result.Errors, 1)
+		})
+	}
+
+	w := send(engine, http.MethodGet, "/graphql/schema", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "type UserConnection")
+}
+
+func TestGraphQLDisabledByDefault(t *testing.T) {
+	w := postGraphQL(setupTestApp().router, map[string]interface{}{"query": "{ user(id: \"1\") { id } }"}, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
@@ -0,0 +1,302 @@
+package tests
+
+import (
+	"context"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/grpcapi"
+	"gin-simple-app/internal/grpcapi/userspb"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/services"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	reflectionpb "google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// grpcApp serves the gRPC API over an in-memory connection
+type grpcApp struct {
+	conn        *grpc.ClientConn
+	client      userspb.UserServiceClient
+	auditRepo   *repository.InMemoryAuditRepository
+	broadcaster *events.Broadcaster
+}
+
+func setupGRPCApp(t *testing.T) *grpcApp {
+	t.Helper()
+	userRepo := repository.NewInMemoryUserRepository()
+	auditRepo := repository.NewInMemoryAuditRepository()
+	outbox := repository.NewInMemoryOutboxRepository()
+	userService := services.NewUserService(userRepo,
+		services.WithAuditLog(auditRepo, repository.NewInMemoryUnitOfWork(userRepo, auditRepo, outbox)),
+	)
+	broadcaster := events.NewBroadcaster(outbox, 100, time.Hour)
+
+	srv, err := grpcapi.NewServer(grpcapi.NewUserServer(userService, broadcaster))
+	require.NoError(t, err)
+	listener := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+
+	return &grpcApp{
+		conn:        conn,
+		client:      userspb.NewUserServiceClient(conn),
+		auditRepo:   auditRepo,
+		broadcaster: broadcaster,
+	}
+}
+
+// fieldViolations returns the BadRequest field violations of err by field
+func fieldViolations(t *testing.T, err error) map[string]string {
+	t.Helper()
+	st, ok := status.FromError(err)
+	require.True(t, ok, err)
+	require.Equal(t, codes.InvalidArgument, st.Code(), st.Message())
+	violations := map[string]string{}
+	for _, detail := range st.Details() {
+		if badRequest, ok := detail.(*errdetails.BadRequest); ok {
+			for _, v := range badRequest.GetFieldViolations() {
+				violations[v.GetField()] = v.GetDescription()
+			}
+		}
+	}
+	return violations
+}
+
+var grpcUser = &userspb.CreateUserRequest{
+	Name:  "Ada Lovelace",
+	Email: "ada@example.com",
+	Phone: "+44 20 7946 0958",
+	PostalAddress: &userspb.Address{
+		Line1: "12 St James's Square", City: "London", Country: "gb",
+	},
+}
+
+func TestGRPCGetUser(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx := context.Background()
+
+	user, err := app.client.GetUser(ctx, &userspb.GetUserRequest{Id: 1})
+	require.NoError(t, err)
+	assert.Equal(t, uint64(1), user.GetId())
+	assert.Equal(t, "John Doe", user.GetName())
+	assert.Equal(t, "john@example.com", user.GetEmail())
+	assert.NotEmpty(t, user.GetPhone())
+	assert.NotNil(t, user.GetPostalAddress())
+	assert.False(t, user.GetCreatedAt().AsTime().IsZero())
+
+	_, err = app.client.GetUser(ctx, &userspb.GetUserRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+	_, err = app.client.GetUser(ctx, &userspb.GetUserRequest{})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+}
+
+func TestGRPCListUsersPaging(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx := context.Background()
+
+	page, err := app.client.ListUsers(ctx, &userspb.ListUsersRequest{PageSize: 2})
+	require.NoError(t, err)
+	require.Len(t, page.GetUsers(), 2)
+	assert.Equal(t, uint64(1), page.GetUsers()[0].GetId())
+	assert.Equal(t, uint64(2), page.GetUsers()[1].GetId())
+	assert.Equal(t, int32(3), page.GetTotalSize())
+	require.NotEmpty(t, page.GetNextPageToken())
+
+	next, err := app.client.ListUsers(ctx, &userspb.ListUsersRequest{PageSize: 2, PageToken: page.GetNextPageToken()})
+	require.NoError(t, err)
+	require.Len(t, next.GetUsers(), 1)
+	assert.Equal(t, uint64(3), next.GetUsers()[0].GetId())
+	assert.Empty(t, next.GetNextPageToken())
+
+	filtered, err := app.client.ListUsers(ctx, &userspb.ListUsersRequest{Filter: &userspb.UserFilter{Name: "jane"}})
+	require.NoError(t, err)
+	require.Len(t, filtered.GetUsers(), 1)
+	assert.Equal(t, "Jane Smith", filtered.GetUsers()[0].GetName())
+	assert.Equal(t, int32(1), filtered.GetTotalSize())
+
+	_, err = app.client.ListUsers(ctx, &userspb.ListUsersRequest{PageToken: "not-a-token"})
+	assert.Contains(t, fieldViolations(t, err), "page_token")
+	_, err = app.client.ListUsers(ctx, &userspb.ListUsersRequest{PageSize: 500})
+	assert.Contains(t, fieldViolations(t, err), "page_size")
+	_, err = app.client.ListUsers(ctx, &userspb.ListUsersRequest{Filter: &userspb.UserFilter{Country: "XX"}})
+	assert.Contains(t, fieldViolations(t, err), "filter.country")
+}
+
+func TestGRPCUserLifecycle(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(),
+		"x-api-key", "grpc-key",
+		"x-request-id", "grpc-request-1",
+	)
+
+	var header metadata.MD
+	created, err := app.client.CreateUser(ctx, grpcUser, grpc.Header(&header))
+	require.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", created.GetName())
+	assert.Equal(t, "+442079460958", created.GetPhone())
+	assert.Equal(t, "GB", created.GetPostalAddress().GetCountry())
+	assert.Equal(t, []string{"grpc-request-1"}, header.Get(grpcapi.RequestIDMetadata))
+
+	updated, err := app.client.UpdateUser(ctx, &userspb.UpdateUserRequest{
+		Id:    created.GetId(),
+		Name:  "Augusta Ada King",
+		Email: "ada@example.com",
+		Phone: "+44 20 7946 0958",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Augusta Ada King", updated.GetName())
+
+	_, err = app.client.DeleteUser(ctx, &userspb.DeleteUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	_, err = app.client.GetUser(ctx, &userspb.GetUserRequest{Id: created.GetId()})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// Calls are audited like REST requests, with the key as the claimed actor
+	entries, err := app.auditRepo.List(models.AuditFilter{})
+	require.NoError(t, err)
+	require.Len(t, entries, 3)
+	assert.Equal(t, models.AuditActionDelete, entries[0].Action)
+	assert.Equal(t, models.AuditActionCreate, entries[2].Action)
+	for _, entry := range entries {
+		assert.Regexp(t, `^key:[0-9a-f]{32}$`, entry.ClaimedActor)
+		assert.NotRegexp(t, `^(key|sub):`, entry.Actor)
+		assert.Equal(t, "grpc-request-1", entry.RequestID)
+	}
+}
+
+func TestGRPCErrorCodes(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "accept-language", "es")
+
+	_, err := app.client.CreateUser(ctx, &userspb.CreateUserRequest{
+		Name: "John Again", Email: "john@example.com", Phone: "+1 212-555-0142",
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+
+	_, err = app.client.UpdateUser(ctx, &userspb.UpdateUserRequest{
+		Id: 999, Name: "Nobody", Email: "nobody@example.com", Phone: "+1 212-555-0142",
+	})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = app.client.DeleteUser(ctx, &userspb.DeleteUserRequest{Id: 999})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = app.client.CreateUser(ctx, &userspb.CreateUserRequest{
+		Email:         "not-an-email",
+		Phone:         "12",
+		PostalAddress: &userspb.Address{Line1: "1 Main St", City: "Springfield", Country: "XX"},
+	})
+	violations := fieldViolations(t, err)
+	assert.Contains(t, violations, "name")
+	assert.Contains(t, violations, "email")
+	assert.Contains(t, violations, "phone")
+	assert.Contains(t, violations, "postal_address.country")
+
+	// Messages follow accept-language like the REST API
+	_, englishErr := app.client.CreateUser(context.Background(), &userspb.CreateUserRequest{
+		Name: "Valid Name", Email: "not-an-email", Phone: "+1 212-555-0142",
+	})
+	_, spanishErr := app.client.CreateUser(ctx, &userspb.CreateUserRequest{
+		Name: "Valid Name", Email: "not-an-email", Phone: "+1 212-555-0142",
+	})
+	assert.NotEqual(t, fieldViolations(t, englishErr)["email"], fieldViolations(t, spanishErr)["email"])
+}
+
+func TestGRPCWatchUsers(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	stream, err := app.client.WatchUsers(ctx, &userspb.WatchUsersRequest{
+		Types: []string{models.EventUserCreated, models.EventUserDeleted},
+	})
+	require.NoError(t, err)
+	// The stream is open once the server has sent its headers
+	_, err = stream.Header()
+	require.NoError(t, err)
+
+	created, err := app.client.CreateUser(ctx, grpcUser)
+	require.NoError(t, err)
+	_, err = app.client.UpdateUser(ctx, &userspb.UpdateUserRequest{
+		Id: created.GetId(), Name: "Augusta Ada King", Email: "ada@example.com", Phone: "+44 20 7946 0958",
+	})
+	require.NoError(t, err)
+	_, err = app.client.DeleteUser(ctx, &userspb.DeleteUserRequest{Id: created.GetId()})
+	require.NoError(t, err)
+	require.NoError(t, app.broadcaster.Poll())
+
+	first, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, models.EventUserCreated, first.GetType())
+	assert.Equal(t, created.GetId(), first.GetUserId())
+	assert.Equal(t, "Ada Lovelace", first.GetUser().GetName())
+	assert.Contains(t, first.GetChangedFields(), "email")
+	assert.NotEmpty(t, first.GetId())
+
+	// The update is filtered out
+	second, err := stream.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, models.EventUserDeleted, second.GetType())
+	assert.Greater(t, second.GetSequence(), first.GetSequence())
+
+	// A new stream resumes after the last sequence it saw
+	after := first.GetSequence()
+	resumed, err := app.client.WatchUsers(ctx, &userspb.WatchUsersRequest{AfterSequence: &after})
+	require.NoError(t, err)
+	next, err := resumed.Recv()
+	require.NoError(t, err)
+	assert.Equal(t, models.EventUserUpdated, next.GetType())
+	assert.Equal(t, after+1, next.GetSequence())
+
+	invalid, err := app.client.WatchUsers(ctx, &userspb.WatchUsersRequest{Types: []string{"user.renamed"}})
+	require.NoError(t, err)
+	_, err = invalid.Recv()
+	assert.Contains(t, fieldViolations(t, err), "types")
+}
+
+func TestGRPCHealthAndReflection(t *testing.T) {
+	app := setupGRPCApp(t)
+	ctx := context.Background()
+
+	health := healthpb.NewHealthClient(app.conn)
+	for _, service := range []string{"", "users.v1.UserService"} {
+		resp, err := health.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+		require.NoError(t, err)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, resp.GetStatus())
+	}
+
+	stream, err := reflectionpb.NewServerReflectionClient(app.conn).ServerReflectionInfo(ctx)
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&reflectionpb.ServerReflectionRequest{
+		MessageRequest: &reflectionpb.ServerReflectionRequest_ListServices{},
+	}))
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	assert.Contains(t, services, "users.v1.UserService")
+	assert.Contains(t, services, "grpc.health.v1.Health")
+}
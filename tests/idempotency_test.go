@@ -0,0 +1,142 @@
+package tests
+
+import (
+	"gin-simple-app/internal/idempotency"
+	"gin-simple-app/internal/router"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var idempotentUser = map[string]string{
+	"name":  "Alice Cooper",
+	"email": "alice@example.com",
+	"phone": "+1 212-555-0104",
+}
+
+func TestIdempotencyReplaysResponse(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(idempotency.NewMemoryStore(), time.Hour))
+
+	first := send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, map[string]string{"Idempotency-Key": "key-1"})
+	assert.Equal(t, http.StatusCreated, first.Code)
+	assert.Empty(t, first.Header().Get("Idempotent-Replayed"))
+
+	// The retry gets the original 201 instead of a duplicate-email 400
+	retry := send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, map[string]string{"Idempotency-Key": "key-1"})
+	assert.Equal(t, http.StatusCreated, retry.Code)
+	assert.Equal(t, "true", retry.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, first.Body.String(), retry.Body.String())
+	assert.Equal(t, first.Header().Get("Content-Type"), retry.Header().Get("Content-Type"))
+
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(4), count)
+}
+
+func TestIdempotencyKeyReusedWithDifferentBody(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(idempotency.NewMemoryStore(), time.Hour))
+
+	assert.Equal(t, http.StatusCreated, send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, map[string]string{"Idempotency-Key": "key-1"}).Code)
+
+	other := map[string]string{"name": "Bob", "email": "bob2@example.com", "phone": "+1 212-555-0105"}
+	w := send(app.router, http.MethodPost, "/api/v1/users", other, map[string]string{"Idempotency-Key": "key-1"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Contains(t, w.Body.String(), "different request")
+}
+
+func TestIdempotencyKeyReusedWithDifferentFormat(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(idempotency.NewMemoryStore(), time.Hour))
+	headers := map[string]string{"Idempotency-Key": "key-1"}
+
+	// A request rejected for its Accept header is not stored under its key
+	headers["Accept"] = "text/csv"
+	w := send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, headers)
+	assert.Equal(t, http.StatusNotAcceptable, w.Code)
+	headers["Accept"] = "application/xml"
+	w = send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, headers)
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "application/xml")
+
+	// A retry asking for another format is not answered in the stored one
+	headers["Accept"] = "application/json"
+	w = send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, headers)
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Empty(t, w.Header().Get("Idempotent-Replayed"))
+}
+
+// inFlightStore reports every key as claimed by a request that has not finished
+type inFlightStore struct {
+	idempotency.Store
+}
+
+func (inFlightStore) Begin(key, fingerprint string, ttl time.Duration) (*idempotency.Record, bool, error) {
+	return &idempotency.Record{Key: key, Fingerprint: fingerprint}, false, nil
+}
+
+func TestIdempotencyInFlightDuplicate(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(inFlightStore{}, time.Hour))
+
+	w := send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, map[string]string{"Idempotency-Key": "key-1"})
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "still being processed")
+
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(3), count)
+}
+
+func TestIdempotencyConcurrentDuplicates(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(idempotency.NewMemoryStore(), time.Hour))
+
+	const n = 10
+	codes := make([]int, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			codes[i] = send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, map[string]string{"Idempotency-Key": "key-1"}).Code
+		}(i)
+	}
+	wg.Wait()
+
+	created := 0
+	for _, code := range codes {
+		// Every request either created the user, replayed that result, or was told to retry
+		assert.Contains(t, []int{http.StatusCreated, http.StatusConflict}, code)
+		if code == http.StatusCreated {
+			created++
+		}
+	}
+	assert.GreaterOrEqual(t, created, 1)
+
+	count, _ := app.userRepo.Count()
+	assert.Equal(t, int64(4), count, "the user must be created exactly once")
+}
+
+func TestRequestsWithoutKeyAreNotDeduplicated(t *testing.T) {
+	app := setupTestApp(router.WithIdempotency(idempotency.NewMemoryStore(), time.Hour))
+
+	assert.Equal(t, http.StatusCreated, send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, nil).Code)
+	assert.Equal(t, http.StatusBadRequest, send(app.router, http.MethodPost, "/api/v1/users", idempotentUser, nil).Code)
+}
+
+func TestMemoryStoreExpiry(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+
+	_, created, err := store.Begin("key", "fp", time.Millisecond)
+	require.NoError(t, err)
+	assert.True(t, created)
+	require.NoError(t, store.Complete("key", http.StatusCreated, "application/json", []byte("{}")))
+
+	record, created, _ := store.Begin("key", "fp", time.Hour)
+	assert.False(t, created)
+	assert.True(t, record.Completed)
+
+	time.Sleep(5 * time.Millisecond)
+	_, created, _ = store.Begin("key", "fp", time.Hour)
+	assert.True(t, created, "an expired key can be claimed again")
+}
@@ -0,0 +1,306 @@
+package tests
+
+import (
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/graph"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/openapi"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// setupOpenAPIApp serves every endpoint, validating requests and responses
+// against the OpenAPI document
+func setupOpenAPIApp(t *testing.T) *gin.Engine {
+	t.Helper()
+	app := newTestApp()
+	schema, err := graph.NewSchema(app.userService)
+	require.NoError(t, err)
+	return app.serve(
+		router.WithGraphQL(handlers.NewGraphQLHandler(schema)),
+		router.WithEventStream(handlers.NewEventStreamHandler(events.NewBroadcaster(app.outbox, 10, time.Hour), time.Minute)),
+		router.WithWebhooks(handlers.NewWebhookHandler(services.NewWebhookService(repository.NewInMemoryWebhookRepository()))),
+		router.WithOpenAPIValidation(true),
+	).router
+}
+
+// fetchOpenAPI returns the document served by engine
+func fetchOpenAPI(t *testing.T, engine *gin.Engine) openapi.Document {
+	t.Helper()
+	w := send(engine, http.MethodGet, "/openapi.json", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var doc openapi.Document
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &doc))
+	return doc
+}
+
+func TestOpenAPIDocumentCoversEveryRoute(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+	doc := fetchOpenAPI(t, engine)
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "Gin Simple REST API", doc.Info.Title)
+
+	operationIDs := map[string]bool{}
+	for _, route := range engine.Routes() {
+		path := route.Path
+		for _, param := range []string{"id", "delivery_id"} {
+			path = strings.ReplaceAll(path, ":"+param, "{"+param+"}")
+		}
+		item, ok := doc.Paths[path]
+		require.True(t, ok, "%s is not documented", path)
+		op, ok := (*item)[strings.ToLower(route.Method)]
+		require.True(t, ok, "%s %s is not documented", route.Method, path)
+		assert.NotEmpty(t, op.Summary, "%s %s", route.Method, path)
+		assert.Contains(t, op.Responses, "default", "%s %s", route.Method, path)
+		assert.False(t, operationIDs[op.OperationID], "duplicate operation ID %s", op.OperationID)
+		operationIDs[op.OperationID] = true
+	}
+	assert.True(t, operationIDs["getUserByID"])
+}
+
+func TestOpenAPIDocumentDescribesModels(t *testing.T) {
+	doc := fetchOpenAPI(t, setupOpenAPIApp(t))
+
+	create := doc.Components.Schemas["CreateUserRequest"]
+	require.NotNil(t, create)
+	assert.ElementsMatch(t, []string{"name", "email", "phone"}, create.Required)
+	assert.Equal(t, "email", create.Properties["email"].Format)
+
+	address := doc.Components.Schemas["Address"]
+	require.NotNil(t, address)
+	assert.Equal(t, "^[A-Z]{2}$", address.Properties["country"].Pattern)
+	require.NotNil(t, address.Properties["line1"].MaxLength)
+	assert.Equal(t, 200, *address.Properties["line1"].MaxLength)
+
+	user := doc.Components.Schemas["User"]
+	require.NotNil(t, user)
+	assert.Equal(t, "date-time", user.Properties["created_at"].Format)
+	assert.True(t, user.Properties["phone"].Type.Is("null"), "phone is nullable")
+	assert.NotContains(t, user.Properties, "DeletedAt")
+
+	webhook := doc.Components.Schemas["WebhookRequest"]
+	require.NotNil(t, webhook)
+	assert.Equal(t, "uri", webhook.Properties["url"].Format)
+	assert.Len(t, webhook.Properties["event_types"].Items.Enum, 3)
+
+	search := (*doc.Paths["/api/v1/users/search"])["get"]
+	params := map[string]*openapi.Parameter{}
+	for _, param := range search.Parameters {
+		params[param.Name] = param
+	}
+	require.Contains(t, params, "q")
+	assert.True(t, params["q"].Required)
+	require.Contains(t, params, "limit")
+	assert.Equal(t, 100.0, *params["limit"].Schema.Maximum)
+
+	getUser := (*doc.Paths["/api/v1/users/{id}"])["get"]
+	require.Len(t, getUser.Parameters, 1)
+	assert.Equal(t, "path", getUser.Parameters[0].In)
+	assert.True(t, getUser.Parameters[0].Required)
+
+	export := (*doc.Paths["/api/v1/users/export"])["get"]
+	assert.Contains(t, export.Responses["200"].Content, "text/csv")
+	assert.Contains(t, export.Responses["default"].Content, response.ProblemContentType)
+}
+
+func TestSwaggerUI(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	w := send(engine, http.MethodGet, "/docs", nil, map[string]string{"Host": ""})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Content-Type"), "text/html")
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+
+	page := w.Body.String()
+	assert.NotContains(t, page, "unpkg.com", "Swagger UI is served by the API itself")
+
+	csp := w.Header().Get("Content-Security-Policy")
+	assert.NotEqual(t, middleware.DefaultContentSecurityPolicy, csp)
+	assert.Contains(t, csp, "script-src 'self' 'sha256-")
+
+	// Scripts and styles are limited to the exact files on the host served
+	w = send(engine, http.MethodGet, "/docs", nil, map[string]string{"Host": "api.example.com:8080"})
+	csp = w.Header().Get("Content-Security-Policy")
+	assert.Contains(t, csp, "script-src api.example.com:8080/docs/swagger-ui-bundle.js 'sha256-")
+	assert.Contains(t, csp, "style-src api.example.com:8080/docs/swagger-ui.css;")
+	assert.Contains(t, csp, "frame-ancestors 'none'")
+
+	// Each file matches the integrity hash the page loads it with
+	for _, file := range []string{"swagger-ui-bundle.js", "swagger-ui.css"} {
+		match := regexp.MustCompile(`"/docs/` + regexp.QuoteMeta(file) + `" integrity="(sha384-[^"]+)"`).FindStringSubmatch(page)
+		require.Len(t, match, 2, "%s has no integrity hash", file)
+
+		w = send(engine, http.MethodGet, "/docs/"+file, nil, map[string]string{"Accept-Encoding": "identity"})
+		require.Equal(t, http.StatusOK, w.Code)
+		sum := sha512.Sum384(w.Body.Bytes())
+		assert.Equal(t, match[1], "sha384-"+base64.StdEncoding.EncodeToString(sum[:]), file)
+	}
+	w = send(engine, http.MethodGet, "/docs/swagger-ui-bundle.js", nil, map[string]string{"Accept-Encoding": "gzip"})
+	assert.Equal(t, "gzip", w.Header().Get("Content-Encoding"))
+
+	// Every other response keeps the API's policy
+	w = send(engine, http.MethodGet, "/openapi.json", nil, nil)
+	assert.Equal(t, middleware.DefaultContentSecurityPolicy, w.Header().Get("Content-Security-Policy"))
+}
+
+func TestOpenAPIValidationPassesConformingTraffic(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	ok := func(w *httptest.ResponseRecorder, status int) {
+		t.Helper()
+		require.Equal(t, status, w.Code, w.Body.String())
+	}
+
+	ok(send(engine, http.MethodGet, "/", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/health", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/users?country=US", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/users/1", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/users/search?q=john&limit=5", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/users/export?format=ndjson", nil, nil), http.StatusOK)
+
+	id := createdUserID(t, send(engine, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "Ada Lovelace",
+		"email": "ada@example.com",
+		"phone": "+44 20 7946 0958",
+		"postal_address": map[string]interface{}{
+			"line1": "12 St James's Square", "city": "London", "country": "GB",
+		},
+	}, nil))
+	path := "/api/v1/users/" + strconv.FormatUint(uint64(id), 10)
+	ok(send(engine, http.MethodPut, path, map[string]interface{}{
+		"name": "Ada King", "email": "ada@example.com", "phone": "+44 20 7946 0958",
+	}, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, path+"/history", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/audit?action=update&limit=10", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodDelete, path, nil, nil), http.StatusOK)
+
+	ok(send(engine, http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "update", "id": 2, "data": map[string]interface{}{"name": "Jane Doe", "email": "jane@example.com", "phone": "+1 212-555-0100"}},
+		},
+	}, nil), http.StatusOK)
+	ok(send(engine, http.MethodDelete, "/api/v1/users?ids=2,3", nil, nil), http.StatusOK)
+
+	w := send(engine, http.MethodPost, "/api/v1/webhooks", map[string]interface{}{
+		"url": "https://partner.example.com/hooks", "event_types": []string{models.EventUserCreated},
+	}, nil)
+	ok(w, http.StatusCreated)
+	ok(send(engine, http.MethodGet, "/api/v1/webhooks", nil, nil), http.StatusOK)
+	ok(send(engine, http.MethodGet, "/api/v1/webhooks/1/deliveries?status=pending", nil, nil), http.StatusOK)
+
+	ok(send(engine, http.MethodPost, "/graphql", map[string]interface{}{
+		"query": "{ user(id: 1) { name } }",
+	}, nil), http.StatusOK)
+
+	// Errors are documented for every route, in both formats
+	ok(send(engine, http.MethodGet, "/api/v1/users/999", nil, nil), http.StatusNotFound)
+	ok(send(engine, http.MethodGet, "/api/v1/users/999", nil, map[string]string{"Accept": response.ProblemContentType}), http.StatusNotFound)
+}
+
+func TestOpenAPIValidationRejectsRequests(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   interface{}
+		field  string
+	}{
+		{"undocumented query parameter", http.MethodGet, "/api/v1/users?sort=name", nil, "query.sort"},
+		{"query parameter out of range", http.MethodGet, "/api/v1/users/search?q=john&limit=500", nil, "query.limit"},
+		{"query parameter not in enum", http.MethodGet, "/api/v1/audit?action=rename", nil, "query.action"},
+		{"path parameter not an integer", http.MethodGet, "/api/v1/users/abc", nil, "path.id"},
+		{"array item not an integer", http.MethodDelete, "/api/v1/users?ids=1,x", nil, "query.ids/1"},
+		{"wrong body type", http.MethodPost, "/api/v1/users", map[string]interface{}{
+			"name": 42, "email": "x@example.com", "phone": "+1 212-555-0100",
+		}, "body/name"},
+		{"missing body field", http.MethodPost, "/api/v1/users", map[string]interface{}{
+			"name": "No Email", "phone": "+1 212-555-0100",
+		}, "body/email"},
+		{"nested body field", http.MethodPost, "/api/v1/users", map[string]interface{}{
+			"name": "Bad Country", "email": "c@example.com", "phone": "+1 212-555-0100",
+			"postal_address": map[string]interface{}{"line1": "1 Main St", "city": "Springfield", "country": "usa"},
+		}, "body/postal_address"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := send(engine, tt.method, tt.path, tt.body, nil)
+			require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+			var resp response.APIResponse
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+			assert.Equal(t, response.CodeValidationFailed, resp.Code)
+			require.NotEmpty(t, resp.Errors)
+			assert.Equal(t, tt.field, resp.Errors[0].Field)
+			assert.Equal(t, "openapi", resp.Errors[0].Code)
+		})
+	}
+}
+
+func TestOpenAPIValidationRejectsUndocumentedResponses(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	doc := openapi.NewDocument(openapi.Info{Title: "Test", Version: "1"})
+	engine := gin.New()
+	engine.Use(middleware.OpenAPIValidation(doc))
+	engine.GET("/users/:id", func(c *gin.Context) {
+		// The ID should be an integer
+		response.Success(c, http.StatusOK, "User retrieved successfully", gin.H{"id": "one", "name": "John Doe"})
+	})
+	engine.GET("/created", func(c *gin.Context) {
+		response.Success(c, http.StatusCreated, "Created", nil)
+	})
+	engine.GET("/export", func(c *gin.Context) {
+		c.Data(http.StatusOK, "text/csv", []byte("id\n1\n"))
+	})
+	require.NoError(t, doc.AddRoutes(engine.Routes(), map[string]openapi.Route{
+		"GET /users/:id": {Summary: "Get a user", Results: []openapi.Result{{Status: http.StatusOK, Data: models.User{}}}},
+		"GET /created":   {Summary: "Documented as 200", Results: []openapi.Result{{Status: http.StatusOK}}},
+		"GET /export":    {Summary: "Export", Results: []openapi.Result{{Status: http.StatusOK, ContentTypes: []string{"text/csv"}}}},
+	}))
+
+	w := send(engine, http.MethodGet, "/users/1", nil, nil)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "body/data/id must be of type integer")
+	assert.Contains(t, w.Body.String(), "body/data/email is required")
+
+	w = send(engine, http.MethodGet, "/created", nil, nil)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.Contains(t, w.Body.String(), "201 is not a documented response")
+
+	// Responses that are not JSON are streamed as they are
+	w = send(engine, http.MethodGet, "/export", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id\n1\n", w.Body.String())
+}
+
+func TestOpenAPIRequiresDocumentedRoutes(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	engine := gin.New()
+	engine.GET("/documented", func(c *gin.Context) {})
+	engine.POST("/undocumented", func(c *gin.Context) {})
+
+	doc := openapi.NewDocument(openapi.Info{Title: "Test", Version: "1"})
+	err := doc.AddRoutes(engine.Routes(), map[string]openapi.Route{"GET /documented": {Summary: "Documented"}})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "POST /undocumented")
+}
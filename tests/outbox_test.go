@@ -0,0 +1,325 @@
+package tests
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testRelayConfig retries immediately so tests can drive the relay with RelayOnce
+func testRelayConfig() events.RelayConfig {
+	config := events.DefaultRelayConfig()
+	config.MinBackoff = 0
+	config.MaxBackoff = 0
+	return config
+}
+
+// flakySink fails the first failures deliveries and records the rest
+type flakySink struct {
+	failures  int
+	mutex     sync.Mutex
+	delivered []models.Event
+}
+
+func (s *flakySink) Name() string {
+	return "flaky"
+}
+
+func (s *flakySink) Deliver(_ context.Context, event models.Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink unavailable")
+	}
+	s.delivered = append(s.delivered, event)
+	return nil
+}
+
+func TestOutboxRecordsUserEvents(t *testing.T) {
+	app := setupTestApp()
+
+	id := createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, map[string]string{"X-API-Key": "key-1"}))
+	w := send(app.router, http.MethodPut, fmt.Sprintf("/api/v1/users/%d", id), map[string]interface{}{
+		"name":  "Grace Brewster Hopper",
+		"email": "grace@example.com",
+		"phone": "+1 212-555-0199",
+	}, map[string]string{"X-API-Key": "key-1"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	w = send(app.router, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, map[string]string{"X-API-Key": "key-1"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 3)
+	assert.Equal(t, models.EventUserCreated, recorded[0].Type)
+	assert.Equal(t, models.EventUserUpdated, recorded[1].Type)
+	assert.Equal(t, models.EventUserDeleted, recorded[2].Type)
+
+	for i, event := range recorded {
+		assert.Equal(t, uint(i+1), event.Sequence)
+		assert.Len(t, event.EventID, 36)
+		assert.Equal(t, id, event.UserID)
+		assert.NotEqual(t, "system", event.Actor)
+		require.NotNil(t, event.Data.User)
+		assert.Equal(t, id, event.Data.User.ID)
+		assert.Nil(t, event.PublishedAt)
+	}
+	assert.Equal(t, "Grace Brewster Hopper", recorded[1].Data.User.Name)
+	assert.Equal(t, "Grace Brewster Hopper", recorded[1].Data.Changes["name"].After)
+	// Deletes carry the user as it was before
+	assert.Equal(t, "Grace Brewster Hopper", recorded[2].Data.User.Name)
+	assert.NotEqual(t, recorded[0].EventID, recorded[1].EventID)
+}
+
+func TestOutboxSkipsFailedChanges(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":  "Duplicate",
+		"email": "john@example.com",
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = send(app.router, http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]interface{}{"name": "Ada", "email": "ada@example.com", "phone": "+1 212-555-0106"}},
+			{"op": "delete", "id": 9999},
+		},
+	}, nil)
+	require.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+
+	assert.Empty(t, app.outbox.Events())
+}
+
+func TestOutboxRecordsBulkEvents(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]interface{}{"name": "Ada", "email": "ada@example.com", "phone": "+1 212-555-0106"}},
+			{"op": "delete", "id": 2},
+		},
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 2)
+	types := []string{recorded[0].Type, recorded[1].Type}
+	assert.ElementsMatch(t, []string{models.EventUserCreated, models.EventUserDeleted}, types)
+}
+
+func TestRelayDeliversToWebhook(t *testing.T) {
+	app := setupTestApp()
+
+	var mutex sync.Mutex
+	var received []models.Event
+	var headers []http.Header
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event models.Event
+		if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		mutex.Lock()
+		received = append(received, event)
+		headers = append(headers, r.Header.Clone())
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	id := createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	relay := events.NewRelay(app.outbox, []events.Sink{events.NewWebhookSink(receiver.URL)}, testRelayConfig())
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+
+	require.Len(t, received, 1)
+	assert.Equal(t, models.EventUserCreated, received[0].Type)
+	assert.Equal(t, id, received[0].UserID)
+	assert.Equal(t, "grace@example.com", received[0].Data.User.Email)
+	assert.Equal(t, received[0].EventID, headers[0].Get(events.EventIDHeader))
+	assert.Equal(t, models.EventUserCreated, headers[0].Get(events.EventTypeHeader))
+
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 1)
+	assert.NotNil(t, recorded[0].PublishedAt)
+
+	// Published events are not delivered again
+	n, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n)
+	assert.Len(t, received, 1)
+}
+
+func TestRelayRetriesFailedDeliveries(t *testing.T) {
+	app := setupTestApp()
+
+	status := http.StatusServiceUnavailable
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	relay := events.NewRelay(app.outbox, []events.Sink{events.NewWebhookSink(receiver.URL)}, testRelayConfig())
+	_, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 1)
+	assert.Nil(t, recorded[0].PublishedAt)
+	assert.Equal(t, 1, recorded[0].Attempts)
+	assert.Contains(t, recorded[0].LastError, "503")
+
+	status = http.StatusOK
+	_, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	recorded = app.outbox.Events()
+	assert.NotNil(t, recorded[0].PublishedAt)
+	assert.Equal(t, 2, recorded[0].Attempts)
+	assert.Empty(t, recorded[0].LastError)
+}
+
+func TestRelayBacksOffAfterFailure(t *testing.T) {
+	app := setupTestApp()
+	createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	config := events.DefaultRelayConfig()
+	config.MinBackoff = time.Hour
+	config.MaxBackoff = time.Hour
+	sink := &flakySink{failures: 1}
+	relay := events.NewRelay(app.outbox, []events.Sink{sink}, config)
+
+	_, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, n, "event should wait for its backoff")
+
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 1)
+	assert.True(t, recorded[0].NextAttemptAt.After(time.Now().Add(59*time.Minute)))
+	assert.Empty(t, sink.delivered)
+}
+
+// hangingSink blocks every delivery until its context is done
+type hangingSink struct{}
+
+func (hangingSink) Name() string {
+	return "hanging"
+}
+
+func (hangingSink) Deliver(ctx context.Context, _ models.Event) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRelayBoundsEachEvent(t *testing.T) {
+	app := setupTestApp()
+	createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	config := testRelayConfig()
+	config.EventTimeout = 50 * time.Millisecond
+	relay := events.NewRelay(app.outbox, []events.Sink{hangingSink{}}, config)
+
+	n, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	recorded := app.outbox.Events()
+	require.Len(t, recorded, 1)
+	assert.Contains(t, recorded[0].LastError, "deadline exceeded")
+}
+
+func TestRelayLeaseOutlastsBatch(t *testing.T) {
+	config := events.DefaultRelayConfig()
+	require.NoError(t, config.Validate())
+
+	// A batch whose every event times out must finish before its lease ends
+	config.BatchSize = 100
+	assert.Error(t, config.Validate())
+	config.BatchSize = 20
+	config.EventTimeout = 0
+	assert.Error(t, config.Validate())
+}
+
+func TestRelayDeliversAtLeastOnceToEverySink(t *testing.T) {
+	app := setupTestApp()
+	createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	healthy := &flakySink{}
+	flaky := &flakySink{failures: 2}
+	relay := events.NewRelay(app.outbox, []events.Sink{healthy, flaky}, testRelayConfig())
+
+	for i := 0; i < 3; i++ {
+		_, err := relay.RelayOnce(context.Background())
+		require.NoError(t, err)
+	}
+
+	// The healthy sink sees the event again each time the flaky one fails
+	require.Len(t, flaky.delivered, 1)
+	require.Len(t, healthy.delivered, 3)
+	for _, event := range healthy.delivered {
+		assert.Equal(t, flaky.delivered[0].EventID, event.EventID)
+	}
+	assert.NotNil(t, app.outbox.Events()[0].PublishedAt)
+}
+
+func TestRelayWritesToFileSink(t *testing.T) {
+	app := setupTestApp()
+	id := createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	w := send(app.router, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+
+	path := filepath.Join(t.TempDir(), "events.ndjson")
+	sink, err := events.NewFileSink(path)
+	require.NoError(t, err)
+	relay := events.NewRelay(app.outbox, []events.Sink{sink}, testRelayConfig())
+	_, err = relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	file, err := os.Open(path)
+	require.NoError(t, err)
+	defer file.Close()
+
+	var types []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event models.Event
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &event))
+		assert.Equal(t, id, event.UserID)
+		types = append(types, event.Type)
+	}
+	assert.Equal(t, []string{models.EventUserCreated, models.EventUserDeleted}, types)
+}
+
+func TestRelayPurgesPublishedEvents(t *testing.T) {
+	app := setupTestApp()
+	createdUserID(t, send(app.router, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	relay := events.NewRelay(app.outbox, nil, testRelayConfig())
+	_, err := relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+
+	deleted, err := app.outbox.DeletePublishedBefore(time.Now().Add(time.Second))
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+	assert.Empty(t, app.outbox.Events())
+}
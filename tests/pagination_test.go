@@ -0,0 +1,61 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/services"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// keysetUserRepository fails any attempt to load every user, so paging has
+// to seek past its cursor
+type keysetUserRepository struct {
+	repository.UserRepository
+}
+
+func (r *keysetUserRepository) WithContext(ctx context.Context) repository.UserRepository {
+	return &keysetUserRepository{UserRepository: r.UserRepository.WithContext(ctx)}
+}
+
+func (r *keysetUserRepository) GetAll() ([]models.User, error) {
+	return nil, errors.New("paging loaded every user")
+}
+
+func (r *keysetUserRepository) List(models.UserFilter, models.UserFields) ([]models.User, error) {
+	return nil, errors.New("paging loaded every user")
+}
+
+func TestListUsersPageSeeksPastCursor(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	service := services.NewUserService(&keysetUserRepository{UserRepository: userRepo})
+	ctx := context.Background()
+
+	page, err := service.ListUsersPage(ctx, models.UserFilter{}, 0, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Users, 2)
+	assert.Equal(t, uint(1), page.Users[0].ID)
+	assert.Equal(t, uint(2), page.Users[1].ID)
+	assert.True(t, page.HasNextPage)
+	assert.EqualValues(t, 3, page.Total)
+
+	// The cursor stays valid once the user it points at is gone
+	require.NoError(t, service.DeleteUser(ctx, 2))
+	page, err = service.ListUsersPage(ctx, models.UserFilter{}, 2, 2)
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, uint(3), page.Users[0].ID)
+	assert.False(t, page.HasNextPage)
+	assert.EqualValues(t, 2, page.Total)
+
+	page, err = service.ListUsersPage(ctx, models.UserFilter{Name: "john"}, 0, 1)
+	require.NoError(t, err)
+	require.Len(t, page.Users, 1)
+	assert.Equal(t, "John Doe", page.Users[0].Name)
+	assert.True(t, page.HasNextPage)
+	assert.EqualValues(t, 2, page.Total)
+}
@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/phone"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (app *TestApp) createUser(t *testing.T, user map[string]string) (int, response.APIResponse) {
+	w := send(app.router, http.MethodPost, "/api/v1/users", user, nil)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestCreateUserNormalizesPhone(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	// National format is parsed with the default region (US)
+	code, resp := app.createUser(t, map[string]string{
+		"name":  "Alice Cooper",
+		"email": "alice@example.com",
+		"phone": "(212) 555-0104",
+	})
+
+	assert.Equal(t, http.StatusCreated, code)
+	userData := resp.Data.(map[string]interface{})
+	assert.Equal(t, "+12125550104", userData["phone"])
+	assert.Equal(t, "(212) 555-0104", userData["phone_original"])
+}
+
+func TestCreateUserInvalidPhone(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	for _, input := range []string{"555 0101", "not a phone", "+1-555-0101"} {
+		code, resp := app.createUser(t, map[string]string{
+			"name":  "Alice Cooper",
+			"email": "alice@example.com",
+			"phone": input,
+		})
+
+		assert.Equal(t, http.StatusBadRequest, code, input)
+		assert.False(t, resp.Success)
+		assert.Equal(t, []response.FieldError{
+			{Field: "phone", Code: "phone", Message: "phone must be a valid phone number"},
+		}, resp.Errors, input)
+	}
+}
+
+func TestUpdateUserInvalidPhone(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	user := map[string]string{
+		"name":  "John Doe",
+		"email": "john@example.com",
+		"phone": "12345",
+	}
+	w := send(app.router, http.MethodPut, "/api/v1/users/1", user, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestPhoneDefaultRegion(t *testing.T) {
+	require.NoError(t, phone.SetDefaultRegion("GB"))
+	defer phone.SetDefaultRegion("US")
+
+	normalized, err := phone.Normalize("020 7946 0958")
+	require.NoError(t, err)
+	assert.Equal(t, "+442079460958", normalized)
+
+	// Numbers with an explicit country code ignore the default region
+	normalized, err = phone.Normalize("+1 212-555-0101")
+	require.NoError(t, err)
+	assert.Equal(t, "+12125550101", normalized)
+
+	assert.Error(t, phone.SetDefaultRegion("XX"))
+}
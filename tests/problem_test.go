@@ -0,0 +1,83 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProblemDetailsNegotiatedByAccept(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/999", nil, map[string]string{"Accept": "application/problem+json", "X-Request-ID": "req-123"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+	assert.Equal(t, "req-123", w.Header().Get("X-Request-ID"))
+
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, response.Problem{
+		Type:      "about:blank",
+		Title:     "Not Found",
+		Status:    http.StatusNotFound,
+		Detail:    "User not found",
+		Instance:  "/api/v1/users/999",
+		RequestID: "req-123",
+	}, problem)
+}
+
+func TestProblemDetailsValidationExtensions(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	response.SetProblemDetails(true, "https://api.example.com/problems/")
+	defer response.SetProblemDetails(false, "")
+
+	w := send(app.router, http.MethodPost, "/api/v1/users", map[string]string{"name": "Alice", "email": "alice@example.com"}, nil)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "https://api.example.com/problems/validation-failed", problem.Type)
+	assert.Equal(t, "Bad Request", problem.Title)
+	assert.Equal(t, http.StatusBadRequest, problem.Status)
+	assert.Equal(t, "validation_failed", problem.Code)
+	assert.Equal(t, []response.FieldError{{Field: "phone", Code: "required", Message: "phone is a required field"}}, problem.Errors)
+	// A request ID is generated when the client does not send one
+	assert.Len(t, problem.RequestID, 32)
+	assert.Equal(t, problem.RequestID, w.Header().Get("X-Request-ID"))
+}
+
+func TestErrorsDefaultToAPIResponse(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/999", nil, map[string]string{"Accept": "application/json"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.False(t, resp.Success)
+	assert.Equal(t, "User not found", resp.Error)
+}
+
+func TestUnknownRouteUsesErrorFormat(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodGet, "/api/v1/nope", nil, map[string]string{"Accept": "application/problem+json"})
+
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	var problem response.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &problem))
+	assert.Equal(t, "Route not found", problem.Detail)
+}
@@ -0,0 +1,190 @@
+package tests
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"gin-simple-app/internal/config"
+	"gin-simple-app/internal/ratelimit"
+	"gin-simple-app/internal/router"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// unsignedJWT builds a token carrying claims without a valid signature,
+// enough for the middleware that only reads claims
+func unsignedJWT(claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+	return encode(map[string]string{"alg": "none"}) + "." + encode(claims) + ".sig"
+}
+
+func TestRateLimitRejectsOverLimit(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 3, Period: time.Minute},
+	})).router
+
+	for i, remaining := range []string{"2", "1", "0"} {
+		w := send(engine, http.MethodGet, "/api/v1/users", nil, nil)
+		require.Equal(t, http.StatusOK, w.Code, "request %d", i+1)
+		assert.Equal(t, "3", w.Header().Get("RateLimit-Limit"))
+		assert.Equal(t, remaining, w.Header().Get("RateLimit-Remaining"))
+		assert.NotEmpty(t, w.Header().Get("RateLimit-Reset"))
+		assert.Empty(t, w.Header().Get("Retry-After"))
+	}
+
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	// One request's worth of tokens takes 20s to come back at 3 per minute
+	assert.Equal(t, "20", w.Header().Get("Retry-After"))
+	assert.Equal(t, "60", w.Header().Get("RateLimit-Reset"))
+
+	var resp map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, false, resp["success"])
+	assert.Equal(t, "rate_limited", resp["code"])
+}
+
+func TestRateLimitRefills(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 1, Period: 100 * time.Millisecond},
+	})).router
+
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(engine, http.MethodGet, "/api/v1/users", nil, nil).Code)
+
+	time.Sleep(150 * time.Millisecond)
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, nil).Code)
+}
+
+func TestRateLimitKeysByClientAddress(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	})).router
+
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, nil).Code)
+
+	// Unverified API keys and tokens do not buy a fresh budget
+	for _, headers := range []map[string]string{
+		{"X-API-Key": "key-a"},
+		{"X-API-Key": "key-b"},
+		{"Authorization": "Bearer " + unsignedJWT(map[string]interface{}{"sub": "user-1"})},
+		{"Authorization": "Bearer " + unsignedJWT(map[string]interface{}{"sub": "user-2"})},
+	} {
+		assert.Equal(t, http.StatusTooManyRequests, send(engine, http.MethodGet, "/api/v1/users", nil, headers).Code, "request with %v", headers)
+	}
+
+	// Requests from another address are counted separately
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "203.0.113.9:4000"})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitPerRouteGroup(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 5, Period: time.Minute},
+		Groups: map[string]ratelimit.Limit{
+			"users_bulk": {Requests: 1, Period: time.Minute},
+		},
+	})).router
+
+	first := send(engine, http.MethodGet, "/api/v1/users/export", nil, nil)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Equal(t, "1", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, http.StatusTooManyRequests, send(engine, http.MethodGet, "/api/v1/users/export", nil, nil).Code)
+
+	// Single-user requests have their own budget
+	w := send(engine, http.MethodGet, "/api/v1/users/1", nil, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "5", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "4", w.Header().Get("RateLimit-Remaining"))
+
+	// Health checks are never limited
+	health := send(engine, http.MethodGet, "/health", nil, nil)
+	assert.Equal(t, http.StatusOK, health.Code)
+	assert.Empty(t, health.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitDisabledGroup(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		Groups: map[string]ratelimit.Limit{
+			"users": {},
+		},
+	})).router
+
+	for i := 0; i < 3; i++ {
+		w := send(engine, http.MethodGet, "/api/v1/users", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+// failingRateLimitStore simulates an unreachable shared store
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) Take(string, ratelimit.Limit) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimitStoreErrorLetsRequestsThrough(t *testing.T) {
+	engine := setupTestApp(router.WithRateLimit(failingRateLimitStore{}, ratelimit.Limits{
+		Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+	})).router
+
+	for i := 0; i < 2; i++ {
+		w := send(engine, http.MethodGet, "/api/v1/users", nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code)
+		assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+	}
+}
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		input string
+		want  ratelimit.Limit
+		err   bool
+	}{
+		{input: "100/1m", want: ratelimit.Limit{Requests: 100, Period: time.Minute}},
+		{input: "5/s", want: ratelimit.Limit{Requests: 5, Period: time.Second}},
+		{input: " 10 / 30s ", want: ratelimit.Limit{Requests: 10, Period: 30 * time.Second}},
+		{input: "off"},
+		{input: "0", err: true},
+		{input: "0/1m", err: true},
+		{input: "100", err: true},
+		{input: "x/1m", err: true},
+		{input: "-1/1m", err: true},
+		{input: "10/0s", err: true},
+		{input: "10/fortnight", err: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ratelimit.ParseLimit(tt.input)
+			if tt.err {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRateLimitSettings(t *testing.T) {
+	t.Setenv("RATE_LIMIT_USERS_BULK", "5/1m")
+	cfg, err := config.Load()
+	require.NoError(t, err)
+	assert.Equal(t, ratelimit.Limit{Requests: 5, Period: time.Minute}, cfg.RateLimit.For("users_bulk"))
+
+	// A misspelt group would otherwise be ignored
+	t.Setenv("RATE_LIMIT_USER_BULK", "5/1m")
+	_, err = config.Load()
+	assert.ErrorContains(t, err, "RATE_LIMIT_USER_BULK")
+}
@@ -0,0 +1,177 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"gin-simple-app/internal/config"
+	"gin-simple-app/internal/database"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/tenant"
+	"io"
+	"net"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// These tests run against a real Postgres, downloaded and started by the
+// test suite:
+//
+//	go test -tags integration ./tests -run RowLevelSecurity
+
+// setupPostgres starts a Postgres of its own for the test and returns a
+// connection to a migrated and seeded database with row-level security
+// enabled, along with the IDs of the acme and globex tenants. The
+// connection uses a role that owns the schema but is not a superuser,
+// since superusers are never subject to row-level security.
+func setupPostgres(t *testing.T) (db *gorm.DB, acme, globex uint) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	port := listener.Addr().(*net.TCPAddr).Port
+	require.NoError(t, listener.Close())
+
+	dir := t.TempDir()
+	pg := embeddedpostgres.NewDatabase(embeddedpostgres.DefaultConfig().
+		Port(uint32(port)).
+		RuntimePath(filepath.Join(dir, "runtime")).
+		DataPath(filepath.Join(dir, "data")).
+		Logger(io.Discard))
+	require.NoError(t, pg.Start(), "failed to start Postgres")
+	t.Cleanup(func() { pg.Stop() })
+
+	admin := openPostgres(t, port, "postgres", "postgres")
+	require.NoError(t, admin.Exec(`CREATE ROLE app LOGIN PASSWORD 'app'`).Error)
+	require.NoError(t, admin.Exec(`CREATE DATABASE app OWNER app`).Error)
+
+	db = openPostgres(t, port, "app", "app")
+	previous := database.DB
+	database.DB = db
+	t.Cleanup(func() { database.DB = previous })
+	require.NoError(t, database.AutoMigrate())
+	require.NoError(t, database.SeedData())
+	require.NoError(t, database.SetRowLevelSecurity(true))
+
+	tenants := repository.NewGormTenantRepository(db)
+	for _, record := range []*models.Tenant{{Slug: "acme", Name: "Acme"}, {Slug: "globex", Name: "Globex"}} {
+		require.NoError(t, tenants.Create(record))
+	}
+	acmeTenant, err := tenants.GetBySlug("acme")
+	require.NoError(t, err)
+	globexTenant, err := tenants.GetBySlug("globex")
+	require.NoError(t, err)
+	return db, acmeTenant.ID, globexTenant.ID
+}
+
+// openPostgres connects to the database named after user
+func openPostgres(t *testing.T, port int, user, password string) *gorm.DB {
+	cfg := config.DatabaseConfig{
+		Host:     "127.0.0.1",
+		Port:     strconv.Itoa(port),
+		User:     user,
+		Password: password,
+		Name:     user,
+		SSLMode:  "disable",
+	}
+	db, err := gorm.Open(postgres.Open(cfg.GetDSN()), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	require.NoError(t, err)
+	sqlDB, err := db.DB()
+	require.NoError(t, err)
+	t.Cleanup(func() { sqlDB.Close() })
+	return db
+}
+
+func TestRowLevelSecurityScopesUserService(t *testing.T) {
+	db, acme, globex := setupPostgres(t)
+	rowLevelSecurity := repository.WithRowLevelSecurity(true)
+	service := services.NewUserService(
+		repository.NewGormUserRepository(db, rowLevelSecurity),
+		services.WithAuditLog(repository.NewGormAuditRepository(db), repository.NewGormUnitOfWork(db, rowLevelSecurity)),
+	)
+	acmeCtx := tenant.WithID(context.Background(), acme)
+	globexCtx := tenant.WithID(context.Background(), globex)
+
+	acmeUser, err := service.CreateUser(acmeCtx, models.CreateUserRequest{Name: "Ann Acme", Email: "ann@example.com", Phone: "+1 212-555-0198"})
+	require.NoError(t, err)
+	globexUser, err := service.CreateUser(globexCtx, models.CreateUserRequest{Name: "Ann Globex", Email: "ann@example.com", Phone: "+1 212-555-0199"})
+	require.NoError(t, err, "emails are unique per tenant")
+
+	users, err := service.GetAllUsers(acmeCtx)
+	require.NoError(t, err)
+	require.Len(t, users, 1)
+	assert.Equal(t, acmeUser.ID, users[0].ID)
+
+	users, err = service.GetAllUsers(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, users, 3, "the default tenant only sees the seeded users")
+
+	results, err := service.SearchUsers(globexCtx, "ann", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, globexUser.ID, results[0].User.ID)
+
+	_, err = service.GetUserByID(acmeCtx, globexUser.ID)
+	assert.Error(t, err)
+	_, err = service.UpdateUser(acmeCtx, globexUser.ID, models.UpdateUserRequest{Name: "Taken Over", Email: "ann@example.com", Phone: "+1 212-555-0199"})
+	assert.Error(t, err)
+	assert.Error(t, service.DeleteUser(acmeCtx, globexUser.ID))
+
+	user, err := service.GetUserByID(globexCtx, globexUser.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ann Globex", user.Name)
+}
+
+func TestRowLevelSecurityHidesOtherTenantsFromUnscopedQueries(t *testing.T) {
+	db, acme, globex := setupPostgres(t)
+	for _, tenantID := range []uint{acme, globex} {
+		ctx := tenant.WithID(context.Background(), tenantID)
+		repo := repository.NewGormUserRepository(db, repository.WithRowLevelSecurity(true)).WithContext(ctx)
+		require.NoError(t, repo.Create(&models.User{Name: "User", Email: "user@example.com"}))
+	}
+
+	// A query that forgets to filter by tenant still only sees the tenant
+	// in app.tenant_id
+	err := db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, tx.Exec("SELECT set_config('app.tenant_id', ?, true)", strconv.FormatUint(uint64(acme), 10)).Error)
+
+		var users []models.User
+		require.NoError(t, tx.Unscoped().Find(&users).Error)
+		require.Len(t, users, 1)
+		assert.Equal(t, acme, users[0].TenantID)
+
+		result := tx.Model(&models.User{}).Where("1 = 1").Update("name", "Renamed")
+		require.NoError(t, result.Error)
+		assert.EqualValues(t, 1, result.RowsAffected)
+		return nil
+	})
+	require.NoError(t, err)
+
+	// Nor can it write rows into another tenant
+	err = db.Transaction(func(tx *gorm.DB) error {
+		require.NoError(t, tx.Exec("SELECT set_config('app.tenant_id', ?, true)", strconv.FormatUint(uint64(acme), 10)).Error)
+		return tx.Create(&models.User{TenantID: globex, Name: "Intruder", Email: "intruder@example.com"}).Error
+	})
+	assert.ErrorContains(t, err, "row-level security")
+
+	// Without app.tenant_id no user is visible at all
+	var count int64
+	require.NoError(t, db.Model(&models.User{}).Count(&count).Error)
+	assert.Zero(t, count)
+	users, err := repository.NewGormUserRepository(db).GetAll()
+	require.NoError(t, err)
+	assert.Empty(t, users, "a repository without row-level security sees nothing once it is enforced")
+
+	ctx := tenant.WithID(context.Background(), globex)
+	user, err := repository.NewGormUserRepository(db, repository.WithRowLevelSecurity(true)).WithContext(ctx).GetByEmail("user@example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "User", user.Name, "renaming every row only renamed acme's user")
+}
@@ -0,0 +1,39 @@
+//go:build integration
+
+package tests
+
+import (
+	"context"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/tenant"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchReturnsEveryUserColumn(t *testing.T) {
+	db, acme, _ := setupPostgres(t)
+	ctx := tenant.WithID(context.Background(), acme)
+	repo := repository.NewGormUserRepository(db, repository.WithRowLevelSecurity(true)).WithContext(ctx)
+
+	phone, original := "+13125550177", "(312) 555-0177"
+	require.NoError(t, repo.Create(&models.User{
+		Name:          "Grace Hopper",
+		Email:         "grace@example.com",
+		Phone:         &phone,
+		PhoneOriginal: &original,
+		PostalAddress: &models.Address{Line1: "1 Navy Way", City: "Arlington", Country: "US"},
+	}))
+
+	results, err := repo.Search("grace", 10)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	user := results[0].User
+	assert.Equal(t, acme, user.TenantID)
+	require.NotNil(t, user.PhoneOriginal)
+	assert.Equal(t, original, *user.PhoneOriginal)
+	require.NotNil(t, user.PostalAddress)
+	assert.Equal(t, "Arlington", user.PostalAddress.City)
+}
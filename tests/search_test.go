@@ -0,0 +1,114 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (app *TestApp) search(t *testing.T, query string) (int, []map[string]interface{}) {
+	w := send(app.router, http.MethodGet, "/api/v1/users/search?q="+url.QueryEscape(query), nil, nil)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	if !resp.Success {
+		return w.Code, nil
+	}
+
+	var results []map[string]interface{}
+	for _, r := range resp.Data.([]interface{}) {
+		results = append(results, r.(map[string]interface{}))
+	}
+	return w.Code, results
+}
+
+func TestSearchUsersByName(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	code, results := app.search(t, "jane")
+	assert.Equal(t, http.StatusOK, code)
+	require.Len(t, results, 1)
+
+	user := results[0]["user"].(map[string]interface{})
+	assert.Equal(t, "Jane Smith", user["name"])
+	highlights := results[0]["highlights"].(map[string]interface{})
+	assert.Equal(t, "<mark>Jane</mark> Smith", highlights["name"])
+	assert.Equal(t, "<mark>jane</mark>@example.com", highlights["email"])
+}
+
+func TestSearchUsersAcrossFields(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	// Matches the address of John and nobody else
+	_, results := app.search(t, "new york")
+	require.Len(t, results, 1)
+	assert.Equal(t, float64(1), results[0]["user"].(map[string]interface{})["id"])
+	assert.Contains(t, results[0]["highlights"].(map[string]interface{})["address"], "<mark>New</mark> <mark>York</mark>")
+
+	// Phone digits; the other numbers are close enough to match fuzzily but rank lower
+	_, results = app.search(t, "0103")
+	require.NotEmpty(t, results)
+	assert.Equal(t, "Bob Johnson", results[0]["user"].(map[string]interface{})["name"])
+	assert.Equal(t, "+<mark>13125550103</mark>", results[0]["highlights"].(map[string]interface{})["phone"])
+}
+
+func TestSearchUsersFuzzy(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	// Typo in the last name still finds Bob Johnson first
+	_, results := app.search(t, "johnsen")
+	require.NotEmpty(t, results)
+	assert.Equal(t, "Bob Johnson", results[0]["user"].(map[string]interface{})["name"])
+	assert.Equal(t, "Bob <mark>Johnson</mark>", results[0]["highlights"].(map[string]interface{})["name"])
+}
+
+func TestSearchUsersRanking(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	// "john" is an exact name match for John Doe and only a prefix match for Bob Johnson
+	_, results := app.search(t, "john")
+	require.Len(t, results, 2)
+	assert.Equal(t, "John Doe", results[0]["user"].(map[string]interface{})["name"])
+	assert.Equal(t, "Bob Johnson", results[1]["user"].(map[string]interface{})["name"])
+	assert.Greater(t, results[0]["rank"], results[1]["rank"])
+}
+
+func TestSearchUsersNoMatchAndMissingQuery(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	code, results := app.search(t, "zzzz")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Empty(t, results)
+
+	code, _ = app.search(t, "")
+	assert.Equal(t, http.StatusBadRequest, code)
+}
+
+func TestSearchUsersReturnsPostalAddress(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	w := send(app.router, http.MethodPost, "/api/v1/users", map[string]interface{}{
+		"name":           "Grace Hopper",
+		"email":          "grace@example.com",
+		"phone":          "(312) 555-0177",
+		"postal_address": map[string]string{"line1": "1 Navy Way", "city": "Arlington", "country": "US"},
+	}, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	_, results := app.search(t, "grace")
+	require.Len(t, results, 1)
+	user := results[0]["user"].(map[string]interface{})
+	assert.Equal(t, "Arlington", user["postal_address"].(map[string]interface{})["city"])
+	assert.NotEmpty(t, user["phone_original"])
+}
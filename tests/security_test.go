@@ -0,0 +1,147 @@
+package tests
+
+import (
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/ratelimit"
+	"gin-simple-app/internal/router"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+const spaOrigin = "https://app.example.com"
+
+func corsConfig(origins ...string) middleware.CORSConfig {
+	cfg := middleware.DefaultCORSConfig()
+	cfg.AllowedOrigins = origins
+	return cfg
+}
+
+func preflight(engine *gin.Engine, origin, method string) *httptest.ResponseRecorder {
+	return send(engine, http.MethodOptions, "/api/v1/users", nil, map[string]string{
+		"Origin":                         origin,
+		"Access-Control-Request-Method":  method,
+		"Access-Control-Request-Headers": "Content-Type, Idempotency-Key",
+	})
+}
+
+func TestCORSPreflight(t *testing.T) {
+	engine := setupTestApp(
+		router.WithCORS(corsConfig(spaOrigin)),
+		// Preflight requests must not use up the client's budget
+		router.WithRateLimit(ratelimit.NewMemoryStore(), ratelimit.Limits{
+			Default: ratelimit.Limit{Requests: 1, Period: time.Minute},
+		}),
+	).router
+
+	for i := 0; i < 3; i++ {
+		w := preflight(engine, spaOrigin, "POST")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, spaOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Methods"), "POST")
+		assert.Contains(t, w.Header().Get("Access-Control-Allow-Headers"), "Idempotency-Key")
+		assert.Equal(t, "43200", w.Header().Get("Access-Control-Max-Age"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+	}
+
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Origin": spaOrigin})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestCORSActualRequest(t *testing.T) {
+	cfg := corsConfig(spaOrigin)
+	cfg.AllowCredentials = true
+	engine := setupTestApp(router.WithCORS(cfg)).router
+
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Origin": spaOrigin})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, spaOrigin, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "X-Request-Id")
+	assert.Contains(t, w.Header().Get("Access-Control-Expose-Headers"), "Ratelimit-Remaining")
+}
+
+func TestCORSRejectsUnknownOrigin(t *testing.T) {
+	engine := setupTestApp(router.WithCORS(corsConfig(spaOrigin))).router
+
+	w := preflight(engine, "https://evil.example.com", "DELETE")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Contains(t, w.Body.String(), "Origin not allowed")
+
+	w = send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Origin": "https://evil.example.com"})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	engine := setupTestApp(router.WithCORS(corsConfig("*"))).router
+
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Origin": "https://anywhere.example.org"})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSDisabledByDefault(t *testing.T) {
+	engine := setupTestApp().router
+
+	w := send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Origin": spaOrigin})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = preflight(engine, spaOrigin, "POST")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestSecurityHeaders(t *testing.T) {
+	engine := setupTestApp().router
+
+	for _, path := range []string{"/api/v1/users", "/health", "/no-such-route"} {
+		w := send(engine, http.MethodGet, path, nil, nil)
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"), path)
+		assert.Equal(t, "DENY", w.Header().Get("X-Frame-Options"), path)
+		assert.Equal(t, "no-referrer", w.Header().Get("Referrer-Policy"), path)
+		assert.Equal(t, "max-age=31536000", w.Header().Get("Strict-Transport-Security"), path)
+		assert.Equal(t, middleware.DefaultContentSecurityPolicy, w.Header().Get("Content-Security-Policy"), path)
+	}
+}
+
+func TestSecurityHeadersConfigured(t *testing.T) {
+	engine := setupTestApp(router.WithSecurityHeaders(middleware.SecurityHeadersConfig{
+		HSTSMaxAge:            time.Hour,
+		HSTSIncludeSubdomains: true,
+		ContentSecurityPolicy: "default-src 'self'",
+	})).router
+
+	w := send(engine, http.MethodGet, "/health", nil, nil)
+	assert.Equal(t, "max-age=3600; includeSubDomains", w.Header().Get("Strict-Transport-Security"))
+	assert.Equal(t, "default-src 'self'", w.Header().Get("Content-Security-Policy"))
+
+	engine = setupTestApp(router.WithSecurityHeaders(middleware.SecurityHeadersConfig{})).router
+	w = send(engine, http.MethodGet, "/health", nil, nil)
+	assert.Empty(t, w.Header().Get("Strict-Transport-Security"))
+	assert.Empty(t, w.Header().Get("Content-Security-Policy"))
+	assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"))
+}
+
+func TestTrustedProxies(t *testing.T) {
+	limits := ratelimit.Limits{Default: ratelimit.Limit{Requests: 1, Period: time.Minute}}
+
+	// Behind a trusted load balancer, each forwarded client gets its own bucket
+	engine := setupTestApp(
+		router.WithTrustedProxies([]string{"10.0.0.0/8"}),
+		router.WithRateLimit(ratelimit.NewMemoryStore(), limits),
+	).router
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "10.1.2.3:5000", "X-Forwarded-For": "198.51.100.1"}).Code)
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "10.1.2.3:5000", "X-Forwarded-For": "198.51.100.2"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "10.1.2.3:5000", "X-Forwarded-For": "198.51.100.1"}).Code)
+
+	// Without trust, X-Forwarded-For is ignored and the proxy is the client
+	engine = setupTestApp(router.WithRateLimit(ratelimit.NewMemoryStore(), limits)).router
+	assert.Equal(t, http.StatusOK, send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "10.1.2.3:5000", "X-Forwarded-For": "198.51.100.1"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, send(engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "10.1.2.3:5000", "X-Forwarded-For": "198.51.100.2"}).Code)
+}
@@ -0,0 +1,412 @@
+package tests
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"gin-simple-app/internal/cache"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/tenant"
+	"gin-simple-app/internal/webhooks"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// tenantApp serves two tenants besides the default one, acme and globex,
+// with the user cache, audit log, event stream and webhooks enabled
+type tenantApp struct {
+	engine      *gin.Engine
+	server      *httptest.Server
+	broadcaster *events.Broadcaster
+	webhooks    *webhookApp
+}
+
+func setupTenantApp(t *testing.T, cfg middleware.TenantConfig) *tenantApp {
+	gin.SetMode(gin.TestMode)
+	tenants := repository.NewInMemoryTenantRepository()
+	for _, slug := range []string{"acme", "globex"} {
+		require.NoError(t, tenants.Create(&models.Tenant{Slug: slug, Name: strings.ToUpper(slug)}))
+	}
+
+	userRepo := repository.NewInMemoryUserRepository()
+	auditRepo := repository.NewInMemoryAuditRepository()
+	outbox := repository.NewInMemoryOutboxRepository()
+	cached := repository.NewCachedUserRepository(userRepo, cache.NewMemoryStore(100), time.Minute)
+	uow := repository.NewCachedUnitOfWork(repository.NewInMemoryUnitOfWork(userRepo, auditRepo, outbox), cached)
+	userService := services.NewUserService(cached, services.WithAuditLog(auditRepo, uow))
+	broadcaster := events.NewBroadcaster(outbox, 100, time.Hour)
+	webhookRepo := repository.NewInMemoryWebhookRepository()
+	webhookConfig := testWorkerConfig()
+
+	engine := router.NewRouter(handlers.NewUserHandler(userService), handlers.NewHealthHandler(),
+		router.WithTenants(tenants, cfg),
+		router.WithEventStream(handlers.NewEventStreamHandler(broadcaster, time.Minute)),
+		router.WithWebhooks(handlers.NewWebhookHandler(services.NewWebhookService(webhookRepo, services.WithTargetPolicy(webhookConfig.Targets)))),
+	).SetupRoutes()
+	server := httptest.NewServer(engine)
+	t.Cleanup(server.Close)
+	return &tenantApp{engine: engine, server: server, broadcaster: broadcaster, webhooks: &webhookApp{
+		engine: engine,
+		relay:  events.NewRelay(outbox, []events.Sink{webhooks.NewDispatcher(webhookRepo)}, testRelayConfig()),
+		worker: webhooks.NewWorker(webhookRepo, webhookConfig),
+		repo:   webhookRepo,
+	}}
+}
+
+// as sends a JSON request for the tenant with slug
+func (a *tenantApp) as(slug, method, path string, payload interface{}) *httptest.ResponseRecorder {
+	headers := map[string]string{}
+	if slug != "" {
+		headers[middleware.TenantHeader] = slug
+	}
+	return send(a.engine, method, path, payload, headers)
+}
+
+// userNames lists the names of the users a tenant sees
+func (a *tenantApp) userNames(t *testing.T, slug string) []string {
+	t.Helper()
+	w := a.as(slug, http.MethodGet, "/api/v1/users", nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data []models.User `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	names := []string{}
+	for _, user := range resp.Data {
+		names = append(names, user.Name)
+	}
+	return names
+}
+
+// testTenantSecret signs the bearer tokens of tests
+var testTenantSecret = []byte("tenant-token-secret-for-tests-only")
+
+// testTenantConfig verifies tokens signed with testTenantSecret and trusts
+// the X-Tenant header of test requests, which come from 192.0.2.1 when
+// served directly and from loopback through a test server
+func testTenantConfig() middleware.TenantConfig {
+	cfg := middleware.DefaultTenantConfig()
+	cfg.TokenSecret = testTenantSecret
+	cfg.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24"), netip.MustParsePrefix("127.0.0.0/8")}
+	return cfg
+}
+
+// signedJWT builds an HS256 token carrying claims, signed with secret
+func signedJWT(secret []byte, claims map[string]interface{}) string {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload, _ := json.Marshal(claims)
+	signingInput := header + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// tenantJWT builds a token carrying a tenant claim, signed with testTenantSecret
+func tenantJWT(slug string) string {
+	return signedJWT(testTenantSecret, map[string]interface{}{"sub": "user-1", "tenant": slug})
+}
+
+func TestTenantSlug(t *testing.T) {
+	cfg := testTenantConfig()
+	cfg.BaseDomain = "api.example.com"
+	slug := func(header, authorization, host string, cfg middleware.TenantConfig) string {
+		t.Helper()
+		slug, err := middleware.TenantSlug(header, authorization, host, cfg)
+		require.NoError(t, err)
+		return slug
+	}
+
+	assert.Equal(t, "acme", slug(" ACME ", "Bearer "+tenantJWT("acme"), "acme.api.example.com", cfg))
+	assert.Equal(t, "acme", slug(" ACME ", "", "", cfg))
+	assert.Equal(t, "globex", slug("", "Bearer "+tenantJWT("globex"), "", cfg))
+	assert.Equal(t, "acme", slug("", "Bearer "+tenantJWT("acme"), "acme.api.example.com:8443", cfg))
+	assert.Equal(t, "", slug("", "", "api.example.com", cfg))
+	assert.Equal(t, "", slug("", "", "acme.other.com", cfg))
+	assert.Equal(t, "", slug("", "Bearer opaque-token", "", cfg))
+
+	// Neither the header nor the host can override the tenant of a token
+	_, err := middleware.TenantSlug("ACME", "Bearer "+tenantJWT("globex"), "", cfg)
+	assert.ErrorIs(t, err, middleware.ErrTenantMismatch)
+	_, err = middleware.TenantSlug("", "Bearer "+tenantJWT("globex"), "acme.api.example.com", cfg)
+	assert.ErrorIs(t, err, middleware.ErrTenantMismatch)
+
+	// The client picks the host, so it cannot name a tenant by itself
+	_, err = middleware.TenantSlug("", "", "acme.api.example.com", cfg)
+	assert.ErrorIs(t, err, middleware.ErrUnverifiedTenant)
+	_, err = middleware.TenantSlug("", "Bearer opaque-token", "acme.api.example.com:8443", cfg)
+	assert.ErrorIs(t, err, middleware.ErrUnverifiedTenant)
+
+	// Only tokens signed with the secret are read, and only until they expire
+	_, err = middleware.TenantSlug("", "Bearer "+unsignedJWT(map[string]interface{}{"sub": "user-1", "tenant": "globex"}), "", cfg)
+	assert.ErrorIs(t, err, middleware.ErrInvalidToken)
+	_, err = middleware.TenantSlug("", "Bearer "+signedJWT([]byte("another-secret-of-thirty-two-chars"), map[string]interface{}{"tenant": "globex"}), "", cfg)
+	assert.ErrorIs(t, err, middleware.ErrInvalidToken)
+	expired := signedJWT(testTenantSecret, map[string]interface{}{"tenant": "globex", "exp": time.Now().Add(-time.Minute).Unix()})
+	_, err = middleware.TenantSlug("", "Bearer "+expired, "", cfg)
+	assert.ErrorIs(t, err, middleware.ErrInvalidToken)
+
+	// Without a secret, tokens are ignored
+	assert.Equal(t, "", slug("", "Bearer "+unsignedJWT(map[string]interface{}{"sub": "user-1", "tenant": "globex"}), "localhost", middleware.DefaultTenantConfig()))
+
+	assert.True(t, cfg.TrustsProxy("192.0.2.7"))
+	assert.True(t, cfg.TrustsProxy("::ffff:127.0.0.1"))
+	assert.False(t, cfg.TrustsProxy("203.0.113.9"))
+	assert.False(t, cfg.TrustsProxy(""))
+}
+
+func TestTenantResolution(t *testing.T) {
+	cfg := testTenantConfig()
+	cfg.BaseDomain = "api.example.com"
+	app := setupTenantApp(t, cfg)
+	w := app.as("acme", http.MethodPost, "/api/v1/users", map[string]string{"name": "Ada", "email": "ada@acme.com", "phone": "+1 202-555-0147"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The same tenant is found from the header, a bearer token or the host
+	assert.Equal(t, []string{"Ada"}, app.userNames(t, "acme"))
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Authorization": "Bearer " + tenantJWT("acme")})
+	assert.Contains(t, w.Body.String(), "Ada")
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Host": "acme.api.example.com", "Authorization": "Bearer " + tenantJWT("acme")})
+	assert.Contains(t, w.Body.String(), "Ada")
+	assert.Contains(t, w.Header().Values("Vary"), middleware.TenantHeader)
+
+	// The host alone does not name the tenant
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Host": "acme.api.example.com"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "Ada")
+
+	// Requests naming no tenant are for the default tenant
+	assert.Equal(t, []string{"John Doe", "Jane Smith", "Bob Johnson"}, app.userNames(t, ""))
+
+	w = app.as("initech", http.MethodGet, "/api/v1/users", nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "Unknown tenant initech")
+
+	// Health checks never need a tenant
+	requiredConfig := testTenantConfig()
+	requiredConfig.Required = true
+	required := setupTenantApp(t, requiredConfig)
+	assert.Equal(t, http.StatusBadRequest, required.as("", http.MethodGet, "/api/v1/users", nil).Code)
+	assert.Equal(t, http.StatusOK, required.as("", http.MethodGet, "/health", nil).Code)
+	assert.Equal(t, http.StatusOK, required.as("default", http.MethodGet, "/api/v1/users", nil).Code)
+}
+
+func TestTenantsCannotReadEachOthersUsers(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	w := app.as("acme", http.MethodPost, "/api/v1/users", map[string]string{"name": "John Acme", "email": "john@acme.com", "phone": "+1 202-555-0147"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	acmeID := createdUserID(t, w)
+
+	// Reading John in the default tenant fills the cache first
+	require.Equal(t, http.StatusOK, app.as("", http.MethodGet, "/api/v1/users/1", nil).Code)
+
+	assert.Equal(t, []string{"John Acme"}, app.userNames(t, "acme"))
+	assert.Empty(t, app.userNames(t, "globex"))
+	assert.NotContains(t, app.userNames(t, ""), "John Acme")
+
+	for _, path := range []string{"/api/v1/users/1", "/api/v1/users/1/history"} {
+		assert.Equal(t, http.StatusNotFound, app.as("acme", http.MethodGet, path, nil).Code, path)
+	}
+	assert.Equal(t, http.StatusNotFound, app.as("", http.MethodGet, "/api/v1/users/"+strconv.FormatUint(uint64(acmeID), 10), nil).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("globex", http.MethodGet, "/api/v1/users/"+strconv.FormatUint(uint64(acmeID), 10)+"/history", nil).Code)
+
+	w = app.as("acme", http.MethodGet, "/api/v1/users/search?q=john", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "john@acme.com")
+	assert.NotContains(t, w.Body.String(), "john@example.com")
+
+	w = app.as("globex", http.MethodGet, "/api/v1/users/export?format=csv", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, 1, strings.Count(strings.TrimSpace(w.Body.String()), "\n")+1, "only the header row")
+
+	// Each tenant's audit log holds its own changes only
+	w = app.as("acme", http.MethodGet, "/api/v1/audit", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "john@acme.com")
+	w = app.as("globex", http.MethodGet, "/api/v1/audit", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "john@acme.com")
+}
+
+func TestTenantCannotBeSpoofed(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	w := app.as("acme", http.MethodPost, "/api/v1/users", map[string]string{"name": "John Acme", "email": "john@acme.com", "phone": "+1 202-555-0147"})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	// The header of a client that is not a trusted proxy is ignored
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"RemoteAddr": "203.0.113.9:4000", middleware.TenantHeader: "acme"})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), "john@acme.com")
+
+	// A forged token is rejected rather than trusted
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Authorization": "Bearer " + unsignedJWT(map[string]interface{}{"sub": "user-1", "tenant": "acme"})})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.NotContains(t, w.Body.String(), "john@acme.com")
+
+	// A header naming another tenant than the token is rejected, even from a trusted proxy
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{
+		middleware.TenantHeader: "acme",
+		"Authorization":         "Bearer " + tenantJWT("globex"),
+	})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.NotContains(t, w.Body.String(), "john@acme.com")
+
+	w = send(app.engine, http.MethodGet, "/api/v1/users", nil, map[string]string{"Authorization": "Bearer " + tenantJWT("acme")})
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), "john@acme.com")
+}
+
+func TestTenantsCannotChangeEachOthersUsers(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	update := map[string]string{"name": "Hijacked", "email": "john@example.com", "phone": "+1 212-555-0101"}
+
+	assert.Equal(t, http.StatusNotFound, app.as("acme", http.MethodPut, "/api/v1/users/1", update).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("acme", http.MethodDelete, "/api/v1/users/1", nil).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("acme", http.MethodDelete, "/api/v1/users?ids=1,2", nil).Code)
+	w := app.as("acme", http.MethodPost, "/api/v1/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{{"op": "delete", "id": 3}},
+	})
+	assert.NotEqual(t, http.StatusOK, w.Code, w.Body.String())
+
+	assert.Equal(t, []string{"John Doe", "Jane Smith", "Bob Johnson"}, app.userNames(t, ""))
+}
+
+func TestUserEmailsAreUniquePerTenant(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	john := map[string]string{"name": "John Acme", "email": "john@example.com", "phone": "+1 202-555-0147"}
+
+	assert.Equal(t, http.StatusCreated, app.as("acme", http.MethodPost, "/api/v1/users", john).Code)
+	assert.Equal(t, http.StatusCreated, app.as("globex", http.MethodPost, "/api/v1/users", john).Code)
+	for _, slug := range []string{"acme", ""} {
+		w := app.as(slug, http.MethodPost, "/api/v1/users", john)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "already exists")
+	}
+}
+
+func TestIdempotencyKeysAreScopedToTenant(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	body := []byte(`{"name":"Ada","email":"ada@example.com","phone":"+1 202-555-0147"}`)
+	send := func(slug string) *httptest.ResponseRecorder {
+		return send(app.engine, http.MethodPost, "/api/v1/users", body, map[string]string{
+			"Content-Type":                  "application/json",
+			middleware.IdempotencyKeyHeader: "key-1",
+			middleware.TenantHeader:         slug,
+		})
+	}
+
+	require.Equal(t, http.StatusCreated, send("acme").Code)
+	w := send("globex")
+	assert.Equal(t, http.StatusCreated, w.Code, "a key another tenant used is still free")
+	assert.Empty(t, w.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, []string{"Ada"}, app.userNames(t, "globex"))
+
+	w = send("acme")
+	assert.Equal(t, http.StatusCreated, w.Code)
+	assert.Equal(t, "true", w.Header().Get(middleware.IdempotentReplayedHeader))
+	assert.Equal(t, []string{"Ada"}, app.userNames(t, "acme"))
+}
+
+func TestEventStreamIsScopedToTenant(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	stream := (&streamApp{engine: app.engine, server: app.server, broadcaster: app.broadcaster}).
+		openStream(t, "/api/v1/users/events", http.Header{middleware.TenantHeader: {"acme"}})
+
+	require.Equal(t, http.StatusCreated, app.as("globex", http.MethodPost, "/api/v1/users", map[string]string{"name": "Gina", "email": "gina@globex.com", "phone": "+1 202-555-0147"}).Code)
+	require.Equal(t, http.StatusCreated, app.as("acme", http.MethodPost, "/api/v1/users", map[string]string{"name": "Ada", "email": "ada@acme.com", "phone": "+1 202-555-0148"}).Code)
+	require.NoError(t, app.broadcaster.Poll())
+
+	msg := stream.next(t)
+	assert.Contains(t, msg.data, "ada@acme.com")
+	stream.expectNone(t)
+}
+
+func TestWebhooksAreScopedToTenant(t *testing.T) {
+	app := setupTenantApp(t, testTenantConfig())
+	acmeReceiver := newWebhookReceiver(http.StatusOK)
+	defer acmeReceiver.Close()
+	globexReceiver := newWebhookReceiver(http.StatusOK)
+	defer globexReceiver.Close()
+
+	w := app.as("acme", http.MethodPost, "/api/v1/webhooks", map[string]string{"url": acmeReceiver.URL})
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created struct {
+		Data models.WebhookSubscription `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	acmeID := created.Data.ID
+	require.Equal(t, http.StatusCreated, app.as("globex", http.MethodPost, "/api/v1/webhooks", map[string]string{"url": globexReceiver.URL}).Code)
+
+	// Other tenants can neither see nor change acme's subscription
+	for _, slug := range []string{"globex", ""} {
+		w = app.as(slug, http.MethodGet, "/api/v1/webhooks", nil)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.NotContains(t, w.Body.String(), acmeReceiver.URL, slug)
+	}
+	path := "/api/v1/webhooks/" + strconv.FormatUint(uint64(acmeID), 10)
+	assert.Equal(t, http.StatusNotFound, app.as("globex", http.MethodGet, path, nil).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("globex", http.MethodPut, path, map[string]string{"url": globexReceiver.URL}).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("globex", http.MethodGet, path+"/deliveries", nil).Code)
+	assert.Equal(t, http.StatusNotFound, app.as("globex", http.MethodDelete, path, nil).Code)
+
+	// Each subscription only receives the events of its own tenant
+	require.Equal(t, http.StatusCreated, app.as("acme", http.MethodPost, "/api/v1/users", map[string]string{"name": "Ada", "email": "ada@acme.com", "phone": "+1 202-555-0147"}).Code)
+	require.Equal(t, http.StatusCreated, app.as("", http.MethodPost, "/api/v1/users", map[string]string{"name": "Dee", "email": "dee@example.com", "phone": "+1 202-555-0148"}).Code)
+	app.webhooks.deliverAll(t)
+
+	received := acmeReceiver.requests()
+	require.Len(t, received, 1)
+	assert.Contains(t, string(received[0].body), "ada@acme.com")
+	assert.Empty(t, globexReceiver.requests())
+
+	assert.Equal(t, http.StatusNotFound, app.as("", http.MethodGet, path+"/deliveries", nil).Code)
+	w = app.as("acme", http.MethodGet, path+"/deliveries", nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+}
+
+func TestInMemoryUserRepositoryIsolatesTenants(t *testing.T) {
+	repo := repository.NewInMemoryUserRepository()
+	acme := repo.WithContext(tenant.WithID(context.Background(), 2))
+
+	user := &models.User{Name: "Ada", Email: "john@example.com"}
+	require.NoError(t, acme.Create(user))
+	assert.Equal(t, uint(2), user.TenantID)
+
+	count, err := acme.Count()
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+	_, err = acme.GetByID(1)
+	assert.Error(t, err)
+	_, err = acme.GetByEmail("jane@example.com")
+	assert.Error(t, err)
+	users, err := acme.GetByIDs([]uint{1, 2, user.ID})
+	require.NoError(t, err)
+	assert.Len(t, users, 1)
+
+	// Writes in a transaction stay in the tenant too
+	require.NoError(t, acme.Transaction(func(tx repository.UserRepository) error {
+		deleted, err := tx.DeleteByIDs([]uint{1, 2, 3})
+		assert.Equal(t, int64(0), deleted)
+		return err
+	}))
+	all, err := repo.GetAll()
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+}
@@ -0,0 +1,284 @@
+package tests
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"gin-simple-app/internal/config"
+	"gin-simple-app/internal/server"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+)
+
+// testCA is a locally generated certificate authority
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+}
+
+func newTestCA(t *testing.T, name string) *testCA {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return &testCA{cert: cert, key: key, pem: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})}
+}
+
+// issue signs a certificate for commonName, valid for 127.0.0.1 when used by a server
+func (ca *testCA) issue(t *testing.T, commonName string, usage x509.ExtKeyUsage) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func (ca *testCA) pool() *x509.CertPool {
+	pool := x509.NewCertPool()
+	pool.AddCert(ca.cert)
+	return pool
+}
+
+// writeFileAtomically replaces path the way certificate managers do
+func writeFileAtomically(t *testing.T, path string, data []byte) {
+	t.Helper()
+	tmp := path + ".tmp"
+	require.NoError(t, os.WriteFile(tmp, data, 0o600))
+	require.NoError(t, os.Rename(tmp, path))
+}
+
+// writeServerCert issues a server certificate into dir and returns the TLS settings for it
+func writeServerCert(t *testing.T, ca *testCA, dir, commonName string) config.ServerConfig {
+	t.Helper()
+	certPEM, keyPEM := ca.issue(t, commonName, x509.ExtKeyUsageServerAuth)
+	cfg := config.ServerConfig{
+		TLSCertFile: filepath.Join(dir, "tls.crt"),
+		TLSKeyFile:  filepath.Join(dir, "tls.key"),
+		HTTP2:       true,
+	}
+	writeFileAtomically(t, cfg.TLSKeyFile, keyPEM)
+	writeFileAtomically(t, cfg.TLSCertFile, certPEM)
+	return cfg
+}
+
+// startServer serves the app on a random local port until the test ends
+func startServer(t *testing.T, cfg config.ServerConfig) (*server.Server, string) {
+	t.Helper()
+	srv, err := server.New(setupTestApp().router, cfg)
+	require.NoError(t, err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	go func() { _ = srv.Serve(listener) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_ = srv.Shutdown(ctx)
+	})
+	return srv, listener.Addr().String()
+}
+
+func httpsClient(tlsConfig *tls.Config) *http.Client {
+	return &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{TLSClientConfig: tlsConfig, ForceAttemptHTTP2: true},
+	}
+}
+
+// servedCommonName returns the common name of the certificate the server presents
+func servedCommonName(t *testing.T, addr string, roots *x509.CertPool) string {
+	t.Helper()
+	conn, err := tls.Dial("tcp", addr, &tls.Config{RootCAs: roots})
+	require.NoError(t, err)
+	defer conn.Close()
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName
+}
+
+func TestTLSServesHTTP2(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	_, addr := startServer(t, writeServerCert(t, ca, t.TempDir(), "server"))
+
+	resp, err := httpsClient(&tls.Config{RootCAs: ca.pool()}).Get("https://" + addr + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+}
+
+func TestTLSWithoutHTTP2(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	cfg := writeServerCert(t, ca, t.TempDir(), "server")
+	cfg.HTTP2 = false
+	_, addr := startServer(t, cfg)
+
+	resp, err := httpsClient(&tls.Config{RootCAs: ca.pool()}).Get("https://" + addr + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/1.1", resp.Proto)
+}
+
+func TestPlainHTTPServesH2C(t *testing.T) {
+	_, addr := startServer(t, config.ServerConfig{HTTP2: true})
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http2.Transport{
+			AllowHTTP: true,
+			DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, network, addr)
+			},
+		},
+	}
+	resp, err := client.Get("http://" + addr + "/health")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "HTTP/2.0", resp.Proto)
+}
+
+func TestTLSReloadsChangedCertificate(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	dir := t.TempDir()
+	_, addr := startServer(t, writeServerCert(t, ca, dir, "first"))
+	require.Equal(t, "first", servedCommonName(t, addr, ca.pool()))
+
+	writeServerCert(t, ca, dir, "second")
+	assert.Eventually(t, func() bool {
+		return servedCommonName(t, addr, ca.pool()) == "second"
+	}, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestTLSReloadsOnSIGHUP(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	dir := t.TempDir()
+	cfg := writeServerCert(t, ca, dir, "first")
+	reloader, err := server.NewCertReloader(cfg.TLSCertFile, cfg.TLSKeyFile, "")
+	require.NoError(t, err)
+
+	// Changed before anything watches the files, so only SIGHUP can pick it up
+	writeServerCert(t, ca, dir, "second")
+	commonName := func() string {
+		cert, err := reloader.GetCertificate(nil)
+		require.NoError(t, err)
+		leaf, err := x509.ParseCertificate(cert.Certificate[0])
+		require.NoError(t, err)
+		return leaf.Subject.CommonName
+	}
+	require.Equal(t, "first", commonName())
+
+	stop := make(chan struct{})
+	defer close(stop)
+	require.NoError(t, reloader.Watch(stop))
+	process, err := os.FindProcess(os.Getpid())
+	require.NoError(t, err)
+	require.NoError(t, process.Signal(syscall.SIGHUP))
+
+	assert.Eventually(t, func() bool { return commonName() == "second" }, 5*time.Second, 20*time.Millisecond)
+}
+
+func TestTLSKeepsCertificateWhenReloadFails(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	cfg := writeServerCert(t, ca, t.TempDir(), "server")
+	srv, addr := startServer(t, cfg)
+
+	require.NoError(t, os.WriteFile(cfg.TLSCertFile, []byte("not a certificate"), 0o600))
+	assert.Error(t, srv.Reload())
+	assert.Equal(t, "server", servedCommonName(t, addr, ca.pool()))
+}
+
+func TestMutualTLS(t *testing.T) {
+	serverCA := newTestCA(t, "Server CA")
+	clientCA := newTestCA(t, "Client CA")
+	dir := t.TempDir()
+	cfg := writeServerCert(t, serverCA, dir, "server")
+	cfg.TLSClientCAFile = filepath.Join(dir, "client-ca.crt")
+	require.NoError(t, os.WriteFile(cfg.TLSClientCAFile, clientCA.pem, 0o600))
+	_, addr := startServer(t, cfg)
+
+	clientWith := func(ca *testCA) *http.Client {
+		tlsConfig := &tls.Config{RootCAs: serverCA.pool()}
+		if ca != nil {
+			certPEM, keyPEM := ca.issue(t, "client", x509.ExtKeyUsageClientAuth)
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			require.NoError(t, err)
+			tlsConfig.Certificates = []tls.Certificate{cert}
+		}
+		return httpsClient(tlsConfig)
+	}
+
+	resp, err := clientWith(clientCA).Get("https://" + addr + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	_, err = clientWith(nil).Get("https://" + addr + "/health")
+	assert.Error(t, err, "client without a certificate")
+
+	_, err = clientWith(serverCA).Get("https://" + addr + "/health")
+	assert.Error(t, err, "client certificate from an untrusted CA")
+}
+
+func TestServerRejectsIncompleteTLSConfig(t *testing.T) {
+	ca := newTestCA(t, "Test CA")
+	cfg := writeServerCert(t, ca, t.TempDir(), "server")
+	handler := setupTestApp().router
+
+	_, err := server.New(handler, config.ServerConfig{TLSCertFile: cfg.TLSCertFile})
+	assert.Error(t, err, "certificate without key")
+
+	_, err = server.New(handler, config.ServerConfig{TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"})
+	assert.Error(t, err, "missing files")
+
+	_, err = server.New(handler, config.ServerConfig{TLSClientCAFile: cfg.TLSCertFile})
+	assert.Error(t, err, "client CA without server certificate")
+
+	_, err = server.New(handler, config.ServerConfig{TLSCertFile: cfg.TLSCertFile, TLSKeyFile: cfg.TLSCertFile})
+	assert.Error(t, err, "certificate as key")
+
+	srv, err := server.New(handler, cfg)
+	require.NoError(t, err)
+	assert.True(t, srv.TLS())
+}
@@ -0,0 +1,243 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"gin-simple-app/internal/cache"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/services"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countingUserRepository counts GetByID calls, optionally holding each one
+// until release is closed
+type countingUserRepository struct {
+	repository.UserRepository
+	*getCounter
+}
+
+// getCounter is shared by a countingUserRepository and its tenant views
+type getCounter struct {
+	calls   atomic.Int32
+	started chan struct{}
+	release chan struct{}
+}
+
+func (r *countingUserRepository) WithContext(ctx context.Context) repository.UserRepository {
+	return &countingUserRepository{UserRepository: r.UserRepository.WithContext(ctx), getCounter: r.getCounter}
+}
+
+func (r *countingUserRepository) GetByID(id uint) (*models.User, error) {
+	r.calls.Add(1)
+	if r.started != nil {
+		r.started <- struct{}{}
+	}
+	if r.release != nil {
+		<-r.release
+	}
+	return r.UserRepository.GetByID(id)
+}
+
+// failingStore is a cache store that is always down
+type failingStore struct{}
+
+func (failingStore) Get(string) ([]byte, bool, error)        { return nil, false, errors.New("down") }
+func (failingStore) Set(string, []byte, time.Duration) error { return errors.New("down") }
+func (failingStore) Delete(...string) error                  { return errors.New("down") }
+
+// setupUserCache returns a service over a cached repository, wired the way
+// the server wires it
+func setupUserCache(t *testing.T) (services.UserService, *repository.CachedUserRepository, *countingUserRepository) {
+	t.Helper()
+	userRepo := repository.NewInMemoryUserRepository()
+	counting := &countingUserRepository{UserRepository: userRepo, getCounter: &getCounter{}}
+	cached := repository.NewCachedUserRepository(counting, cache.NewMemoryStore(100), time.Minute)
+	uow := repository.NewCachedUnitOfWork(repository.NewInMemoryUnitOfWork(userRepo, repository.NewInMemoryAuditRepository(), repository.NewInMemoryOutboxRepository()), cached)
+	return services.NewUserService(cached, services.WithAuditLog(repository.NewInMemoryAuditRepository(), uow)), cached, counting
+}
+
+func TestUserCacheReadThrough(t *testing.T) {
+	service, cached, counting := setupUserCache(t)
+	ctx := context.Background()
+
+	first, err := service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	second, err := service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.NotSame(t, first, second)
+	assert.Equal(t, int32(1), counting.calls.Load())
+	assert.Equal(t, cache.Stats{Hits: 1, Misses: 1}, cached.Stats())
+	assert.Equal(t, 0.5, cached.Stats().HitRatio())
+
+	// Missing users are not cached
+	_, err = service.GetUserByID(ctx, 999)
+	assert.EqualError(t, err, "user not found")
+	_, err = service.GetUserByID(ctx, 999)
+	assert.EqualError(t, err, "user not found")
+	assert.Equal(t, int32(3), counting.calls.Load())
+}
+
+func TestUserCacheInvalidatedByWrites(t *testing.T) {
+	service, _, _ := setupUserCache(t)
+	ctx := context.Background()
+
+	_, err := service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	_, err = service.UpdateUser(ctx, 1, models.UpdateUserRequest{Name: "John Updated", Email: "john@example.com", Phone: "+1 212-555-0101"})
+	require.NoError(t, err)
+	user, err := service.GetUserByID(ctx, 1)
+	require.NoError(t, err)
+	assert.Equal(t, "John Updated", user.Name)
+
+	require.NoError(t, service.DeleteUser(ctx, 1))
+	_, err = service.GetUserByID(ctx, 1)
+	assert.EqualError(t, err, "user not found")
+
+	created, err := service.CreateUser(ctx, models.CreateUserRequest{Name: "Ada", Email: "ada@example.com", Phone: "+1 202-555-0147"})
+	require.NoError(t, err)
+	user, err = service.GetUserByID(ctx, created.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "Ada", user.Name)
+}
+
+func TestUserCacheInvalidatedByBulkWrites(t *testing.T) {
+	userRepo := repository.NewInMemoryUserRepository()
+	cached := repository.NewCachedUserRepository(userRepo, cache.NewMemoryStore(100), time.Minute)
+
+	_, err := cached.GetByID(2)
+	require.NoError(t, err)
+	_, err = cached.DeleteByIDs([]uint{2})
+	require.NoError(t, err)
+	_, err = cached.GetByID(2)
+	assert.Error(t, err)
+
+	// Writes in a transaction are evicted once it ends
+	user, err := cached.GetByID(3)
+	require.NoError(t, err)
+	require.NoError(t, cached.Transaction(func(repo repository.UserRepository) error {
+		user.Name = "Bob Updated"
+		return repo.Update(user)
+	}))
+	user, err = cached.GetByID(3)
+	require.NoError(t, err)
+	assert.Equal(t, "Bob Updated", user.Name)
+}
+
+func TestUserCacheStampedeProtection(t *testing.T) {
+	_, cached, counting := setupUserCache(t)
+	counting.release = make(chan struct{})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			user, err := cached.GetByID(1)
+			assert.NoError(t, err)
+			assert.Equal(t, "John Doe", user.Name)
+		}()
+	}
+	// Give every caller time to join the query before it returns
+	time.Sleep(50 * time.Millisecond)
+	close(counting.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), counting.calls.Load())
+	assert.Equal(t, uint64(20), cached.Stats().Misses)
+}
+
+func TestUserCacheSkipsFillRacingAWrite(t *testing.T) {
+	service, cached, counting := setupUserCache(t)
+	counting.started = make(chan struct{}, 1)
+	counting.release = make(chan struct{})
+
+	// A read starts, then a write finishes before the read returns
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := cached.GetByID(1)
+		assert.NoError(t, err)
+	}()
+	<-counting.started
+	counting.started = nil
+	_, err := service.UpdateUser(context.Background(), 1, models.UpdateUserRequest{Name: "John Updated", Email: "john@example.com", Phone: "+1 212-555-0101"})
+	require.NoError(t, err)
+	close(counting.release)
+	<-done
+
+	// The stale read was not cached
+	user, err := cached.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "John Updated", user.Name)
+	assert.Equal(t, int32(2), counting.calls.Load())
+}
+
+func TestUserCacheFallsBackWhenStoreFails(t *testing.T) {
+	cached := repository.NewCachedUserRepository(repository.NewInMemoryUserRepository(), failingStore{}, time.Minute)
+
+	user, err := cached.GetByID(1)
+	require.NoError(t, err)
+	assert.Equal(t, "John Doe", user.Name)
+	require.NoError(t, cached.Delete(1))
+	_, err = cached.GetByID(1)
+	assert.Error(t, err)
+}
+
+func TestMemoryCacheStoreEvictsLeastRecentlyUsed(t *testing.T) {
+	store := cache.NewMemoryStore(2)
+	require.NoError(t, store.Set("a", []byte("1"), time.Minute))
+	require.NoError(t, store.Set("b", []byte("2"), time.Minute))
+	_, ok, _ := store.Get("a")
+	require.True(t, ok)
+	require.NoError(t, store.Set("c", []byte("3"), time.Minute))
+
+	assert.Equal(t, 2, store.Len())
+	_, ok, _ = store.Get("b")
+	assert.False(t, ok, "b was least recently used")
+	value, ok, _ := store.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+
+	// Expired values are not returned
+	require.NoError(t, store.Set("d", []byte("4"), -time.Second))
+	_, ok, _ = store.Get("d")
+	assert.False(t, ok)
+
+	require.NoError(t, store.Delete("a", "missing"))
+	_, ok, _ = store.Get("a")
+	assert.False(t, ok)
+}
+
+func TestHealthReportsUserCacheStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	_, cached, _ := setupUserCache(t)
+	_, _ = cached.GetByID(1)
+	_, _ = cached.GetByID(1)
+
+	engine := router.NewRouter(handlers.NewUserHandler(services.NewUserService(cached)),
+		handlers.NewHealthHandler(handlers.WithCacheStats(cached.Stats))).SetupRoutes()
+	w := send(engine, http.MethodGet, "/health", nil, nil)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	var resp struct {
+		Data struct {
+			Cache map[string]float64 `json:"cache"`
+		} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, map[string]float64{"hits": 1, "misses": 1, "hit_ratio": 0.5}, resp.Data.Cache)
+}
@@ -0,0 +1,102 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/pkg/response"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func (app *TestApp) postUserWithLanguage(t *testing.T, body []byte, acceptLanguage string) (int, response.APIResponse) {
+	headers := map[string]string{"Content-Type": "application/json"}
+	if acceptLanguage != "" {
+		headers["Accept-Language"] = acceptLanguage
+	}
+	w := send(app.router, http.MethodPost, "/api/v1/users", body, headers)
+
+	var resp response.APIResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return w.Code, resp
+}
+
+func TestValidationErrorFields(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	body, _ := json.Marshal(map[string]string{"name": "Alice", "email": "not-an-email"})
+	code, resp := app.postUserWithLanguage(t, body, "")
+
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, response.CodeValidationFailed, resp.Code)
+	assert.Equal(t, []response.FieldError{
+		{Field: "email", Code: "email", Message: "email must be a valid email address"},
+		{Field: "phone", Code: "required", Message: "phone is a required field"},
+	}, resp.Errors)
+	assert.Equal(t, "email must be a valid email address; phone is a required field", resp.Error)
+}
+
+func TestValidationErrorLocalized(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	body, _ := json.Marshal(map[string]string{"name": "Alice", "email": "alice@example.com"})
+
+	tests := []struct {
+		acceptLanguage string
+		message        string
+	}{
+		{"es-ES,es;q=0.9", "phone es un campo requerido"},
+		{"de-DE, fr;q=0.8, en;q=0.5", "phone est un champ obligatoire"},
+		{"de-DE", "phone is a required field"}, // unsupported language falls back to English
+	}
+
+	for _, tt := range tests {
+		code, resp := app.postUserWithLanguage(t, body, tt.acceptLanguage)
+		assert.Equal(t, http.StatusBadRequest, code)
+		require.Len(t, resp.Errors, 1, tt.acceptLanguage)
+		assert.Equal(t, "phone", resp.Errors[0].Field)
+		assert.Equal(t, "required", resp.Errors[0].Code)
+		assert.Equal(t, tt.message, resp.Errors[0].Message, tt.acceptLanguage)
+	}
+
+	// Custom tags are translated too
+	body, _ = json.Marshal(map[string]string{"name": "Alice", "email": "alice@example.com", "phone": "12"})
+	_, resp := app.postUserWithLanguage(t, body, "es")
+	require.Len(t, resp.Errors, 1)
+	assert.Equal(t, "phone debe ser un número de teléfono válido", resp.Errors[0].Message)
+}
+
+func TestMalformedJSONIsDistinctFromValidation(t *testing.T) {
+	app := setupTestApp()
+	app.resetTestData()
+
+	code, resp := app.postUserWithLanguage(t, []byte(`{"name": "Alice",`), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, response.CodeMalformedRequest, resp.Code)
+	assert.Empty(t, resp.Errors)
+
+	code, resp = app.postUserWithLanguage(t, []byte(`{"name": 42}`), "")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Equal(t, response.CodeMalformedRequest, resp.Code)
+	assert.Contains(t, resp.Error, "cannot unmarshal number")
+}
+
+func TestTranslateValidationErrorsWithoutRouter(t *testing.T) {
+	// Called directly, e.g. by a handler outside the router, the
+	// translations are set up on first use
+	err := binding.Validator.ValidateStruct(struct {
+		Email string `json:"email" binding:"required,email"`
+	}{Email: "not-an-email"})
+	var verrs validator.ValidationErrors
+	require.ErrorAs(t, err, &verrs)
+
+	fields := response.TranslateValidationErrors(verrs, "fr")
+	require.Len(t, fields, 1)
+	assert.Equal(t, "email", fields[0].Code)
+	assert.NotEmpty(t, fields[0].Message)
+}
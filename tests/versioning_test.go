@@ -0,0 +1,258 @@
+package tests
+
+import (
+	"encoding/json"
+	"gin-simple-app/internal/middleware"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/versioning"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeUserV2 decodes the v2 user in the data of a response
+func decodeUserV2(t *testing.T, w *httptest.ResponseRecorder) models.UserV2 {
+	t.Helper()
+	var resp struct {
+		Data models.UserV2 `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+func TestV1RepresentationIsUnchanged(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodGet, "/api/v1/users/1", nil, nil)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, versioning.V1, w.Header().Get(middleware.APIVersionHeader))
+	assert.Empty(t, w.Header().Get(middleware.DeprecationHeader))
+	var resp struct {
+		Data map[string]interface{} `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	assert.Equal(t, "John Doe", resp.Data["name"])
+}
+
+func TestV2RepresentsStructuredNamesAndISOTimestamps(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodGet, "/api/v2/users/1", nil, nil)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, versioning.V2, w.Header().Get(middleware.APIVersionHeader))
+	assert.Equal(t, "application/json; charset=utf-8", w.Header().Get("Content-Type"))
+	user := decodeUserV2(t, w)
+	assert.Equal(t, models.PersonName{Full: "John Doe", Given: "John", Family: "Doe"}, user.Name)
+	assert.Regexp(t, `^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{3}Z$`, user.CreatedAt)
+	_, err := time.Parse(models.TimestampLayoutV2, user.UpdatedAt)
+	assert.NoError(t, err)
+
+	w = send(app.router, http.MethodGet, "/api/v2/users?name=Jane", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var list struct {
+		Data []models.UserV2 `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Data, 1)
+	assert.Equal(t, "Smith", list.Data[0].Name.Family)
+}
+
+func TestV2CreateAndUpdateWithStructuredName(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodPost, "/api/v2/users", map[string]interface{}{
+		"name":  map[string]string{"given": "Ada", "family": "Lovelace"},
+		"email": "ada@example.com",
+		"phone": "+1 202-555-0147",
+	}, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	created := decodeUserV2(t, w)
+	assert.Equal(t, "Ada Lovelace", created.Name.Full)
+
+	// The same user is plain text in v1
+	w = send(app.router, http.MethodGet, "/api/v1/users/"+strconv.FormatUint(uint64(created.ID), 10), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"name":"Ada Lovelace"`)
+
+	w = send(app.router, http.MethodPut, "/api/v2/users/"+strconv.FormatUint(uint64(created.ID), 10), map[string]interface{}{
+		"name":  map[string]string{"given": "Augusta Ada", "family": "King"},
+		"email": "ada@example.com",
+		"phone": "+1 202-555-0147",
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	updated := decodeUserV2(t, w)
+	assert.Equal(t, models.PersonName{Full: "Augusta Ada King", Given: "Augusta Ada", Family: "King"}, updated.Name)
+
+	// A v1 payload is not a valid v2 request
+	w = send(app.router, http.MethodPost, "/api/v2/users", map[string]interface{}{
+		"name":  "Ada Lovelace",
+		"email": "lovelace@example.com",
+		"phone": "+1 202-555-0147",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestV2BulkOperations(t *testing.T) {
+	app := setupTestApp()
+
+	w := send(app.router, http.MethodPost, "/api/v2/users/bulk", map[string]interface{}{
+		"operations": []map[string]interface{}{
+			{"op": "create", "data": map[string]interface{}{
+				"name":  map[string]string{"given": "Grace", "family": "Hopper"},
+				"email": "grace@example.com",
+				"phone": "+1 212-555-0199",
+			}},
+		},
+	}, nil)
+
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data []models.BulkResultV2 `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	require.Len(t, resp.Data, 1)
+	require.NotNil(t, resp.Data[0].User)
+	assert.Equal(t, "Hopper", resp.Data[0].User.Name.Family)
+}
+
+func TestUnversionedAPINegotiatesFromAccept(t *testing.T) {
+	app := setupTestApp()
+
+	tests := []struct {
+		accept  string
+		version string
+	}{
+		{"", versioning.V2},
+		{"application/json", versioning.V2},
+		{"application/vnd.app.v1+json", versioning.V1},
+		{"application/vnd.app.v2+json", versioning.V2},
+		{"application/vnd.app.v2+json;q=0.5, application/vnd.app.v1+json", versioning.V1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			w := send(app.router, http.MethodGet, "/api/users/1", nil, map[string]string{"Accept": tt.accept})
+
+			require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+			assert.Equal(t, tt.version, w.Header().Get(middleware.APIVersionHeader))
+			assert.Contains(t, w.Header().Values("Vary"), "Accept")
+			if tt.version == versioning.V1 {
+				assert.Contains(t, w.Body.String(), `"name":"John Doe"`)
+			} else {
+				assert.Equal(t, "Doe", decodeUserV2(t, w).Name.Family)
+			}
+		})
+	}
+}
+
+func TestUnacceptableVersions(t *testing.T) {
+	app := setupTestApp()
+
+	tests := []struct {
+		path   string
+		accept string
+	}{
+		{"/api/users/1", "application/vnd.app.v9+json"},
+		{"/api/v2/users/1", "application/vnd.app.v9+json"},
+		{"/api/v1/users/1", "application/vnd.app.v2+json"},
+	}
+	for _, tt := range tests {
+		w := send(app.router, http.MethodGet, tt.path, nil, map[string]string{"Accept": tt.accept})
+		assert.Equal(t, http.StatusNotAcceptable, w.Code, "%s with %s", tt.path, tt.accept)
+	}
+
+	// Naming the version of the path is fine
+	w := send(app.router, http.MethodGet, "/api/v1/users/1", nil, map[string]string{"Accept": "application/vnd.app.v1+json"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+}
+
+func TestDeprecatedVersionHeaders(t *testing.T) {
+	deprecations, err := versioning.ParseDeprecations("v1:2026-09-01:2027-03-01")
+	require.NoError(t, err)
+	engine := setupTestApp(router.WithDeprecations(deprecations)).router
+
+	w := send(engine, http.MethodGet, "/api/v1/users/1", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "@1788220800", w.Header().Get(middleware.DeprecationHeader))
+	assert.Equal(t, "Mon, 01 Mar 2027 00:00:00 GMT", w.Header().Get(middleware.SunsetHeader))
+	assert.Equal(t, `</api/v2/users/1>; rel="successor-version"`, w.Header().Get("Link"))
+
+	// Negotiating the deprecated version is announced too, without a link
+	w = send(engine, http.MethodGet, "/api/users/1", nil, map[string]string{"Accept": "application/vnd.app.v1+json"})
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.NotEmpty(t, w.Header().Get(middleware.DeprecationHeader))
+	assert.Empty(t, w.Header().Get("Link"))
+
+	// The latest version is not deprecated
+	w = send(engine, http.MethodGet, "/api/v2/users/1", nil, nil)
+	assert.Empty(t, w.Header().Get(middleware.DeprecationHeader))
+	assert.Empty(t, w.Header().Get(middleware.SunsetHeader))
+
+	// Operations of the deprecated version are marked in the OpenAPI document
+	doc := fetchOpenAPI(t, engine)
+	assert.True(t, (*doc.Paths["/api/v1/users/{id}"])["get"].Deprecated)
+	assert.False(t, (*doc.Paths["/api/v2/users/{id}"])["get"].Deprecated)
+}
+
+func TestVersionedTrafficMatchesOpenAPIDocument(t *testing.T) {
+	engine := setupOpenAPIApp(t)
+
+	w := send(engine, http.MethodPost, "/api/v2/users", map[string]interface{}{
+		"name":  map[string]string{"given": "Ada", "family": "Lovelace"},
+		"email": "ada@example.com",
+		"phone": "+1 202-555-0147",
+	}, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	id := strconv.FormatUint(uint64(decodeUserV2(t, w).ID), 10)
+
+	for _, path := range []string{"/api/v2/users", "/api/v2/users/" + id, "/api/v2/users/search?q=ada", "/api/users/" + id} {
+		w = send(engine, http.MethodGet, path, nil, nil)
+		assert.Equal(t, http.StatusOK, w.Code, "%s: %s", path, w.Body.String())
+	}
+	w = send(engine, http.MethodGet, "/api/users/"+id, nil, map[string]string{"Accept": "application/vnd.app.v1+json"})
+	assert.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	// A structured name is validated against the v2 schema
+	w = send(engine, http.MethodPost, "/api/v2/users", map[string]interface{}{
+		"name":  map[string]string{"family": "Lovelace"},
+		"email": "lovelace@example.com",
+		"phone": "+1 202-555-0147",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+}
+
+func TestParseDeprecations(t *testing.T) {
+	deprecations, err := versioning.ParseDeprecations(" v1:2026-09-01 ")
+	require.NoError(t, err)
+	require.Contains(t, deprecations, versioning.V1)
+	assert.True(t, deprecations[versioning.V1].Sunset.IsZero())
+
+	deprecations, err = versioning.ParseDeprecations("")
+	require.NoError(t, err)
+	assert.Empty(t, deprecations)
+
+	for _, invalid := range []string{
+		"v1",
+		"v9:2026-09-01",
+		"v2:2026-09-01",
+		"v1:September",
+		"v1:2026-09-01:soon",
+		"v1:2026-09-01:2026-01-01",
+	} {
+		_, err := versioning.ParseDeprecations(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
+
+func TestSplitName(t *testing.T) {
+	assert.Equal(t, models.PersonName{Full: "Cher", Given: "Cher"}, models.SplitName("Cher"))
+	assert.Equal(t, models.PersonName{Full: "Mary Ann Evans", Given: "Mary Ann", Family: "Evans"}, models.SplitName(" Mary Ann Evans "))
+	assert.Equal(t, "Mary Ann Evans", models.PersonName{Given: "Mary Ann", Family: "Evans"}.String())
+}
@@ -0,0 +1,460 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"gin-simple-app/internal/events"
+	"gin-simple-app/internal/handlers"
+	"gin-simple-app/internal/models"
+	"gin-simple-app/internal/repository"
+	"gin-simple-app/internal/router"
+	"gin-simple-app/internal/services"
+	"gin-simple-app/internal/webhooks"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// webhookApp is an app with webhook endpoints whose relay and worker are
+// driven by hand
+type webhookApp struct {
+	engine *gin.Engine
+	relay  *events.Relay
+	worker *webhooks.Worker
+	repo   *repository.InMemoryWebhookRepository
+}
+
+func setupWebhookApp(config webhooks.WorkerConfig) *webhookApp {
+	app := newTestApp()
+	webhookRepo := repository.NewInMemoryWebhookRepository()
+	engine := app.serve(
+		router.WithWebhooks(handlers.NewWebhookHandler(services.NewWebhookService(webhookRepo, services.WithTargetPolicy(config.Targets)))),
+	).router
+
+	return &webhookApp{
+		engine: engine,
+		relay:  events.NewRelay(app.outbox, []events.Sink{webhooks.NewDispatcher(webhookRepo)}, testRelayConfig()),
+		worker: webhooks.NewWorker(webhookRepo, config),
+		repo:   webhookRepo,
+	}
+}
+
+// testWorkerConfig retries immediately so tests can drive the worker with
+// DeliverOnce, and delivers to the plain http:// loopback receivers of tests
+func testWorkerConfig() webhooks.WorkerConfig {
+	config := webhooks.DefaultWorkerConfig()
+	config.MinBackoff = 0
+	config.MaxBackoff = 0
+	config.MaxAttempts = 3
+	config.Targets = webhooks.TargetPolicy{AllowHTTP: true, AllowPrivateNetworks: true}
+	return config
+}
+
+// deliverAll relays pending events and sends one round of webhook deliveries
+func (a *webhookApp) deliverAll(t *testing.T) {
+	t.Helper()
+	_, err := a.relay.RelayOnce(context.Background())
+	require.NoError(t, err)
+	_, err = a.worker.DeliverOnce(context.Background())
+	require.NoError(t, err)
+}
+
+// createWebhook subscribes url and returns the created subscription
+func (a *webhookApp) createWebhook(t *testing.T, payload map[string]interface{}) models.WebhookSubscription {
+	t.Helper()
+	w := send(a.engine, http.MethodPost, "/api/v1/webhooks", payload, nil)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var resp struct {
+		Data models.WebhookSubscription `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// deliveries fetches the delivery log of a subscription
+func (a *webhookApp) deliveries(t *testing.T, id uint) []models.WebhookDelivery {
+	t.Helper()
+	w := send(a.engine, http.MethodGet, fmt.Sprintf("/api/v1/webhooks/%d/deliveries", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var resp struct {
+		Data []models.WebhookDelivery `json:"data"`
+	}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+	return resp.Data
+}
+
+// receivedWebhook is a request captured by a webhookReceiver
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+// webhookReceiver is a subscriber endpoint that answers with status
+type webhookReceiver struct {
+	*httptest.Server
+	mutex    sync.Mutex
+	status   int
+	received []receivedWebhook
+}
+
+func newWebhookReceiver(status int) *webhookReceiver {
+	r := &webhookReceiver{status: status}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mutex.Lock()
+		defer r.mutex.Unlock()
+		r.received = append(r.received, receivedWebhook{header: req.Header.Clone(), body: body})
+		w.WriteHeader(r.status)
+	}))
+	return r
+}
+
+func (r *webhookReceiver) setStatus(status int) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	r.status = status
+}
+
+func (r *webhookReceiver) requests() []receivedWebhook {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return append([]receivedWebhook(nil), r.received...)
+}
+
+func TestWebhookSubscriptionCRUD(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+
+	sub := app.createWebhook(t, map[string]interface{}{
+		"url":         "https://partner.example.com/hooks",
+		"event_types": []string{"user.created", "user.deleted"},
+	})
+	assert.NotZero(t, sub.ID)
+	assert.True(t, sub.Active)
+	assert.Regexp(t, `^whsec_[0-9a-f]{48}$`, sub.Secret)
+	assert.Equal(t, models.EventTypes{"user.created", "user.deleted"}, sub.EventTypes)
+
+	path := fmt.Sprintf("/api/v1/webhooks/%d", sub.ID)
+	w := send(app.engine, http.MethodGet, path, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.NotContains(t, w.Body.String(), sub.Secret, "secret is only returned on create")
+
+	w = send(app.engine, http.MethodGet, "/api/v1/webhooks", nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	assert.NotContains(t, w.Body.String(), sub.Secret)
+
+	w = send(app.engine, http.MethodPut, path, map[string]interface{}{
+		"url":    "https://partner.example.com/v2/hooks",
+		"active": false,
+	}, nil)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	stored, err := app.repo.GetSubscription(models.DefaultTenantID, sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://partner.example.com/v2/hooks", stored.URL)
+	assert.False(t, stored.Active)
+	assert.Empty(t, stored.EventTypes)
+	assert.Equal(t, sub.Secret, stored.Secret, "secret is kept when not given")
+
+	w = send(app.engine, http.MethodDelete, path, nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	w = send(app.engine, http.MethodGet, path, nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(app.engine, http.MethodDelete, path, nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWebhookSubscriptionValidation(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+
+	tests := []map[string]interface{}{
+		{},
+		{"url": "not a url"},
+		{"url": "https://partner.example.com/hooks", "event_types": []string{"user.renamed"}},
+		{"url": "https://partner.example.com/hooks", "secret": "short"},
+	}
+	for _, payload := range tests {
+		w := send(app.engine, http.MethodPost, "/api/v1/webhooks", payload, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "payload %v", payload)
+	}
+
+	w := send(app.engine, http.MethodGet, "/api/v1/webhooks/abc", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	w = send(app.engine, http.MethodGet, "/api/v1/webhooks/1/deliveries?status=lost", nil, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestWebhookRejectsInternalTargets(t *testing.T) {
+	config := testWorkerConfig()
+	config.Targets = webhooks.TargetPolicy{}
+	app := setupWebhookApp(config)
+
+	for _, url := range []string{
+		"http://partner.example.com/hooks",
+		"https://localhost/hooks",
+		"https://api.localhost./hooks",
+		"https://127.0.0.1/hooks",
+		"https://10.0.0.8/hooks",
+		"https://192.168.1.1/hooks",
+		"https://169.254.169.254/latest/meta-data",
+		"https://100.100.100.200/hooks",
+		"https://0.0.0.0/hooks",
+		"https://[::1]/hooks",
+		"https://[::ffff:127.0.0.1]/hooks",
+		"https://[fe80::1]/hooks",
+		"https://[fd00::1]/hooks",
+		"https://192.0.0.170/hooks",
+		"https://198.18.0.1/hooks",
+		"https://240.0.0.1/hooks",
+		"https://255.255.255.255/hooks",
+		"https://[64:ff9b::a9fe:a9fe]/latest/meta-data",
+		"https://[64:ff9b::127.0.0.1]/hooks",
+		"https://[64:ff9b:1::8.8.8.8]/hooks",
+	} {
+		w := send(app.engine, http.MethodPost, "/api/v1/webhooks", map[string]interface{}{"url": url}, nil)
+		assert.Equal(t, http.StatusBadRequest, w.Code, url)
+		assert.Contains(t, w.Body.String(), "Webhook URL not allowed", url)
+	}
+
+	// NAT64 addresses of public hosts are public too
+	app.createWebhook(t, map[string]interface{}{"url": "https://[64:ff9b::8.8.8.8]/hooks"})
+
+	sub := app.createWebhook(t, map[string]interface{}{"url": "https://partner.example.com/hooks"})
+	w := send(app.engine, http.MethodPut, fmt.Sprintf("/api/v1/webhooks/%d", sub.ID), map[string]interface{}{
+		"url": "https://169.254.169.254/latest/meta-data",
+	}, nil)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	stored, err := app.repo.GetSubscription(models.DefaultTenantID, sub.ID)
+	require.NoError(t, err)
+	assert.Equal(t, "https://partner.example.com/hooks", stored.URL)
+}
+
+func TestWebhookWorkerChecksResolvedAddress(t *testing.T) {
+	config := testWorkerConfig()
+	config.Targets = webhooks.TargetPolicy{AllowHTTP: true}
+	app := setupWebhookApp(config)
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	// A host name passes the check on create, as it may resolve anywhere
+	// later; here it resolves to the loopback receiver
+	url := strings.Replace(receiver.URL, "127.0.0.1", "localhost", 1)
+	sub := &models.WebhookSubscription{TenantID: models.DefaultTenantID, URL: url, Secret: "partner-shared-secret", Active: true}
+	require.NoError(t, app.repo.CreateSubscription(sub))
+	createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+
+	assert.Empty(t, receiver.requests())
+	deliveries := app.deliveries(t, sub.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	assert.Contains(t, deliveries[0].LastError, "internal address")
+}
+
+func TestWebhookWorkerDoesNotFollowRedirects(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	target := newWebhookReceiver(http.StatusOK)
+	defer target.Close()
+	redirector := httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+	defer redirector.Close()
+
+	sub := app.createWebhook(t, map[string]interface{}{"url": redirector.URL})
+	createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+
+	assert.Empty(t, target.requests())
+	deliveries := app.deliveries(t, sub.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	assert.Equal(t, http.StatusTemporaryRedirect, deliveries[0].ResponseStatus)
+}
+
+func TestWebhookDeliveriesAreSigned(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	sub := app.createWebhook(t, map[string]interface{}{
+		"url":    receiver.URL,
+		"secret": "partner-shared-secret",
+	})
+	id := createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+
+	received := receiver.requests()
+	require.Len(t, received, 1)
+	req := received[0]
+	assert.NoError(t, webhooks.Verify("partner-shared-secret", req.header, req.body, 5*time.Minute))
+	assert.ErrorIs(t, webhooks.Verify("wrong-secret-value", req.header, req.body, 5*time.Minute), webhooks.ErrInvalidSignature)
+	assert.ErrorIs(t, webhooks.Verify("partner-shared-secret", req.header, append(req.body, ' '), 5*time.Minute), webhooks.ErrInvalidSignature)
+
+	var event models.Event
+	require.NoError(t, json.Unmarshal(req.body, &event))
+	assert.Equal(t, models.EventUserCreated, event.Type)
+	assert.Equal(t, id, event.UserID)
+	assert.Equal(t, event.EventID, req.header.Get(events.EventIDHeader))
+	assert.Equal(t, models.EventUserCreated, req.header.Get(events.EventTypeHeader))
+
+	deliveries := app.deliveries(t, sub.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, strconv.FormatUint(uint64(deliveries[0].ID), 10), req.header.Get(webhooks.DeliveryHeader))
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusOK, deliveries[0].ResponseStatus)
+	assert.NotNil(t, deliveries[0].DeliveredAt)
+}
+
+func TestWebhookVerifyRejectsStaleTimestamps(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	sentAt := time.Now().Add(-10 * time.Minute)
+	header := http.Header{}
+	header.Set(webhooks.TimestampHeader, strconv.FormatInt(sentAt.Unix(), 10))
+	header.Set(webhooks.SignatureHeader, webhooks.Sign("secret", sentAt, body))
+
+	assert.ErrorIs(t, webhooks.Verify("secret", header, body, 5*time.Minute), webhooks.ErrStaleTimestamp)
+	assert.NoError(t, webhooks.Verify("secret", header, body, 15*time.Minute))
+}
+
+func TestWebhookEventTypeFilter(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	app.createWebhook(t, map[string]interface{}{
+		"url":         receiver.URL,
+		"event_types": []string{"user.deleted"},
+	})
+	inactive := app.createWebhook(t, map[string]interface{}{
+		"url":    receiver.URL,
+		"active": false,
+	})
+
+	id := createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+	assert.Empty(t, receiver.requests())
+
+	w := send(app.engine, http.MethodDelete, fmt.Sprintf("/api/v1/users/%d", id), nil, nil)
+	require.Equal(t, http.StatusOK, w.Code)
+	app.deliverAll(t)
+
+	received := receiver.requests()
+	require.Len(t, received, 1)
+	assert.Equal(t, models.EventUserDeleted, received[0].header.Get(events.EventTypeHeader))
+	assert.Empty(t, app.deliveries(t, inactive.ID))
+}
+
+func TestWebhookRetriesThenDeadLetters(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	receiver := newWebhookReceiver(http.StatusInternalServerError)
+	defer receiver.Close()
+
+	sub := app.createWebhook(t, map[string]interface{}{"url": receiver.URL})
+	createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+
+	app.deliverAll(t)
+	deliveries := app.deliveries(t, sub.ID)
+	require.Len(t, deliveries, 1)
+	assert.Equal(t, models.WebhookDeliveryPending, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Equal(t, http.StatusInternalServerError, deliveries[0].ResponseStatus)
+	assert.Contains(t, deliveries[0].LastError, "500")
+
+	for i := 0; i < 5; i++ {
+		app.deliverAll(t)
+	}
+	assert.Len(t, receiver.requests(), 3, "delivery stops after WEBHOOK_MAX_ATTEMPTS")
+	deliveries = app.deliveries(t, sub.ID)
+	assert.Equal(t, models.WebhookDeliveryDead, deliveries[0].Status)
+	assert.Equal(t, 3, deliveries[0].Attempts)
+
+	w := send(app.engine, http.MethodGet, fmt.Sprintf("/api/v1/webhooks/%d/deliveries?status=dead", sub.ID), nil, nil)
+	assert.Contains(t, w.Body.String(), `"count":1`)
+	w = send(app.engine, http.MethodGet, fmt.Sprintf("/api/v1/webhooks/%d/deliveries?status=delivered", sub.ID), nil, nil)
+	assert.Contains(t, w.Body.String(), `"count":0`)
+
+	// Redelivering a dead delivery gives it a fresh set of attempts
+	receiver.setStatus(http.StatusNoContent)
+	redeliverPath := fmt.Sprintf("/api/v1/webhooks/%d/deliveries/%d/redeliver", sub.ID, deliveries[0].ID)
+	w = send(app.engine, http.MethodPost, redeliverPath, nil, nil)
+	require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"status":"pending"`)
+
+	app.deliverAll(t)
+	deliveries = app.deliveries(t, sub.ID)
+	assert.Equal(t, models.WebhookDeliveryDelivered, deliveries[0].Status)
+	assert.Equal(t, 1, deliveries[0].Attempts)
+	assert.Empty(t, deliveries[0].LastError)
+	assert.Len(t, receiver.requests(), 4)
+}
+
+func TestWebhookRedeliverNotFound(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	receiver := newWebhookReceiver(http.StatusOK)
+	defer receiver.Close()
+
+	first := app.createWebhook(t, map[string]interface{}{"url": receiver.URL})
+	second := app.createWebhook(t, map[string]interface{}{"url": receiver.URL})
+	createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+	delivery := app.deliveries(t, first.ID)[0]
+
+	// A delivery can only be redelivered through its own subscription
+	w := send(app.engine, http.MethodPost, fmt.Sprintf("/api/v1/webhooks/%d/deliveries/%d/redeliver", second.ID, delivery.ID), nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(app.engine, http.MethodPost, fmt.Sprintf("/api/v1/webhooks/%d/deliveries/9999/redeliver", first.ID), nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	w = send(app.engine, http.MethodGet, "/api/v1/webhooks/9999/deliveries", nil, nil)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestWebhookBacksOffBetweenAttempts(t *testing.T) {
+	config := testWorkerConfig()
+	config.MinBackoff = time.Minute
+	config.MaxBackoff = time.Hour
+	app := setupWebhookApp(config)
+	receiver := newWebhookReceiver(http.StatusBadGateway)
+	defer receiver.Close()
+
+	sub := app.createWebhook(t, map[string]interface{}{"url": receiver.URL})
+	createdUserID(t, send(app.engine, http.MethodPost, "/api/v1/users", auditedUser, nil))
+	app.deliverAll(t)
+	app.deliverAll(t)
+
+	assert.Len(t, receiver.requests(), 1, "the retry waits for its backoff")
+	deliveries := app.deliveries(t, sub.ID)
+	require.Len(t, deliveries, 1)
+	assert.True(t, deliveries[0].NextAttemptAt.After(time.Now().Add(59*time.Second)))
+}
+
+func TestWebhookDispatchIsIdempotent(t *testing.T) {
+	app := setupWebhookApp(testWorkerConfig())
+	sub := app.createWebhook(t, map[string]interface{}{"url": "https://partner.example.com/hooks"})
+
+	dispatcher := webhooks.NewDispatcher(app.repo)
+	event := models.Event{EventID: "5b0d8f64-4c7e-4f0a-9d1e-2a3b4c5d6e7f", Type: models.EventUserCreated, UserID: 1, TenantID: models.DefaultTenantID}
+	require.NoError(t, dispatcher.Deliver(context.Background(), event))
+	require.NoError(t, dispatcher.Deliver(context.Background(), event))
+
+	assert.Len(t, app.deliveries(t, sub.ID), 1, "an event relayed twice is queued once")
+}
+
+func TestWebhookWorkerLeaseOutlastsBatch(t *testing.T) {
+	config := webhooks.DefaultWorkerConfig()
+	require.NoError(t, config.Validate())
+
+	// A batch whose every request times out must finish before its lease ends
+	config.Timeout = time.Minute
+	assert.Error(t, config.Validate())
+	config.Lease = config.MinLease()
+	assert.NoError(t, config.Validate())
+	assert.Greater(t, config.Lease, time.Duration(config.BatchSize)*config.Timeout)
+}